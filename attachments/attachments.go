@@ -0,0 +1,256 @@
+package attachments
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/Timothylock/inventory-management/storage"
+)
+
+// Limits used when the config does not set any
+const (
+	DefaultMaxSize = 25 << 20
+	maxFilename    = 255
+)
+
+var (
+	AttachmentNotFoundErr = errors.New("attachment not found")
+	AttachmentTooLargeErr = errors.New("attachment is larger than the most that can be uploaded")
+	QuotaExceededErr      = errors.New("attachment does not fit in what is left of the org's quota")
+	InvalidFilenameErr    = errors.New("filename must not be blank")
+	NoStorageErr          = errors.New("there is no storage for attachments")
+)
+
+type Persister interface {
+	// AddAttachment records the attachment and returns it with its ID
+	AddAttachment(orgID int, a Attachment, userID int) (Attachment, error)
+	GetAttachment(orgID, attachmentID int) (Attachment, error)
+	// GetAttachments returns the attachments of the item, oldest first
+	GetAttachments(orgID int, itemID string) (Attachments, error)
+	DeleteAttachment(orgID, attachmentID, userID int) error
+	// LogAttachmentDownload records that the user downloaded the attachment
+	LogAttachmentDownload(orgID int, a Attachment, userID int) error
+	// GetAttachmentsSize returns how many bytes the org's attachments take up
+	GetAttachmentsSize(orgID int) (int64, error)
+}
+
+type Attachments []Attachment
+
+// Attachment is a file such as a manual or receipt that was uploaded for an
+// item. The file is kept in storage under Key.
+type Attachment struct {
+	ID          int       `json:"id"`
+	ItemID      string    `json:"itemId"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Key         string    `json:"-"`
+	UploadedBy  string    `json:"uploadedBy"`
+	UploadedAt  time.Time `json:"uploadedAt"`
+}
+
+type Service struct {
+	persister Persister
+	storage   storage.Storage
+	maxSize   int64
+	// quota is 0 when orgs can keep any amount
+	quota int64
+}
+
+// NewService returns a service that keeps attachments in st
+func NewService(p Persister, st storage.Storage, c config.Config) Service {
+	maxSize := int64(c.AttachmentMaxMb) << 20
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	quota := int64(c.AttachmentQuotaMb) << 20
+	if quota < 0 {
+		quota = 0
+	}
+
+	return Service{
+		persister: p,
+		storage:   st,
+		maxSize:   maxSize,
+		quota:     quota,
+	}
+}
+
+// GetAttachments returns the attachments of the item, oldest first
+func (s *Service) GetAttachments(orgID int, itemID string) (Attachments, error) {
+	return s.persister.GetAttachments(orgID, itemID)
+}
+
+// Upload stores the file read from r as an attachment of the item. The
+// content type is worked out from the file when it is not given. Uploads
+// that happen at the same time can go over the quota by up to the size of
+// one of them.
+func (s *Service) Upload(orgID int, itemID, filename, contentType string, r io.Reader, userID int) (Attachment, error) {
+	if s.storage == nil {
+		return Attachment{}, NoStorageErr
+	}
+
+	filename = cleanFilename(filename)
+	if filename == "" {
+		return Attachment{}, InvalidFilenameErr
+	}
+
+	limit, limitErr := s.maxSize, AttachmentTooLargeErr
+	if s.quota > 0 {
+		used, err := s.persister.GetAttachmentsSize(orgID)
+		if err != nil {
+			return Attachment{}, err
+		}
+		if s.quota-used < limit {
+			limit, limitErr = s.quota-used, QuotaExceededErr
+		}
+		if limit <= 0 {
+			return Attachment{}, QuotaExceededErr
+		}
+	}
+
+	// Reading one byte past the limit tells files that are exactly at it from
+	// ones that are over it
+	lr := &countingReader{r: io.LimitReader(r, limit+1)}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(lr, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Attachment{}, err
+	}
+	head = head[:n]
+
+	if _, _, err = mime.ParseMediaType(contentType); err != nil || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(head)
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return Attachment{}, err
+	}
+	key := "attachments/" + strconv.Itoa(orgID) + "/" + name
+
+	if err = s.storage.Put(key, io.MultiReader(bytes.NewReader(head), lr)); err != nil {
+		s.storage.Delete(key)
+		return Attachment{}, err
+	}
+	if lr.n > limit {
+		s.storage.Delete(key)
+		return Attachment{}, limitErr
+	}
+
+	a, err := s.persister.AddAttachment(orgID, Attachment{
+		ItemID:      itemID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        lr.n,
+		Key:         key,
+	}, userID)
+	if err != nil {
+		s.storage.Delete(key)
+		return Attachment{}, err
+	}
+
+	return a, nil
+}
+
+// Open returns the attachment and its file, which the caller must close
+func (s *Service) Open(orgID, attachmentID, userID int) (Attachment, io.ReadCloser, error) {
+	if s.storage == nil {
+		return Attachment{}, nil, NoStorageErr
+	}
+
+	a, err := s.persister.GetAttachment(orgID, attachmentID)
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+
+	f, err := s.storage.Get(a.Key)
+	if err == storage.NotFoundErr {
+		return Attachment{}, nil, AttachmentNotFoundErr
+	} else if err != nil {
+		return Attachment{}, nil, err
+	}
+
+	if err = s.persister.LogAttachmentDownload(orgID, a, userID); err != nil {
+		f.Close()
+		return Attachment{}, nil, err
+	}
+
+	return a, f, nil
+}
+
+// Delete removes the attachment and then its file
+func (s *Service) Delete(orgID, attachmentID, userID int) error {
+	if s.storage == nil {
+		return NoStorageErr
+	}
+
+	a, err := s.persister.GetAttachment(orgID, attachmentID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.persister.DeleteAttachment(orgID, attachmentID, userID); err != nil {
+		return err
+	}
+
+	return s.storage.Delete(a.Key)
+}
+
+// cleanFilename keeps only the last part of paths that some browsers send
+// and drops control characters so that the name is safe to send back in a
+// Content-Disposition header
+func cleanFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name))
+
+	if name == "." || name == ".." {
+		return ""
+	}
+	if r := []rune(name); len(r) > maxFilename {
+		name = string(r[:maxFilename])
+	}
+
+	return name
+}
+
+// randomName returns a name for a stored file that cannot be guessed or
+// clash with another one
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,198 @@
+package attachments
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/Timothylock/inventory-management/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStorage(t *testing.T) (storage.Local, string, func()) {
+	dir, err := ioutil.TempDir("", "attachments")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := storage.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return st, dir, func() { os.RemoveAll(dir) }
+}
+
+// storedFiles counts the files kept for the org
+func storedFiles(t *testing.T, dir string, orgID int) int {
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "attachments", strconv.Itoa(orgID)))
+	if os.IsNotExist(err) {
+		return 0
+	}
+	assert.NoError(t, err)
+	return len(entries)
+}
+
+func TestUpload(t *testing.T) {
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+
+	st, _, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	var saved Attachment
+	p := NewMockPersister(mc)
+	p.EXPECT().GetAttachmentsSize(1).Return(int64(0), nil)
+	p.EXPECT().AddAttachment(1, gomock.Any(), 123).DoAndReturn(func(orgID int, a Attachment, userID int) (Attachment, error) {
+		saved = a
+		a.ID = 5
+		return a, nil
+	})
+
+	s := NewService(p, st, config.Config{AttachmentQuotaMb: 1})
+	a, err := s.Upload(1, "1234", `C:\Users\me\Manual.pdf`, "", strings.NewReader("%PDF-1.4 the manual"), 123)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, a.ID)
+	assert.Equal(t, "1234", saved.ItemID)
+	assert.Equal(t, "Manual.pdf", saved.Filename)
+	assert.Equal(t, "application/pdf", saved.ContentType)
+	assert.Equal(t, int64(19), saved.Size)
+	assert.Regexp(t, `^attachments/1/[0-9a-f]{32}$`, saved.Key)
+
+	f, err := st.Get(saved.Key)
+	assert.NoError(t, err)
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4 the manual", string(b))
+}
+
+func TestUploadLimits(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		cfg         config.Config
+		used        int64
+		filename    string
+		size        int
+		expectedErr error
+	}{
+		{
+			desc:        "blank filename",
+			filename:    " / ",
+			size:        10,
+			expectedErr: InvalidFilenameErr,
+		},
+		{
+			desc:        "larger than the most that can be uploaded",
+			cfg:         config.Config{AttachmentMaxMb: 1},
+			filename:    "a.bin",
+			size:        1<<20 + 1,
+			expectedErr: AttachmentTooLargeErr,
+		},
+		{
+			desc:        "quota already used up",
+			cfg:         config.Config{AttachmentQuotaMb: 1},
+			used:        1 << 20,
+			filename:    "a.bin",
+			size:        1,
+			expectedErr: QuotaExceededErr,
+		},
+		{
+			desc:        "does not fit in what is left of the quota",
+			cfg:         config.Config{AttachmentQuotaMb: 2},
+			used:        1<<20 + 10,
+			filename:    "a.bin",
+			size:        1 << 20,
+			expectedErr: QuotaExceededErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			st, dir, cleanup := newTestStorage(t)
+			defer cleanup()
+
+			p := NewMockPersister(mc)
+			p.EXPECT().GetAttachmentsSize(1).Return(tc.used, nil).AnyTimes()
+
+			s := NewService(p, st, tc.cfg)
+			_, err := s.Upload(1, "1234", tc.filename, "application/octet-stream", strings.NewReader(strings.Repeat("a", tc.size)), 123)
+			assert.Equal(t, tc.expectedErr, err)
+
+			// Nothing is kept of refused uploads
+			assert.Equal(t, 0, storedFiles(t, dir, 1))
+		})
+	}
+}
+
+func TestOpenAndDelete(t *testing.T) {
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+
+	st, _, cleanup := newTestStorage(t)
+	defer cleanup()
+	assert.NoError(t, st.Put("attachments/1/abc", strings.NewReader("receipt")))
+
+	stored := Attachment{ID: 5, ItemID: "1234", Filename: "receipt.txt", Key: "attachments/1/abc"}
+	p := NewMockPersister(mc)
+	p.EXPECT().GetAttachment(1, 5).Return(stored, nil).Times(2)
+	p.EXPECT().GetAttachment(1, 6).Return(Attachment{}, AttachmentNotFoundErr).Times(2)
+	p.EXPECT().LogAttachmentDownload(1, stored, 123).Return(nil)
+	p.EXPECT().DeleteAttachment(1, 5, 123).Return(nil)
+
+	s := NewService(p, st, config.Config{})
+	a, f, err := s.Open(1, 5, 123)
+	assert.NoError(t, err)
+	assert.Equal(t, stored, a)
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "receipt", string(b))
+
+	_, _, err = s.Open(1, 6, 123)
+	assert.Equal(t, AttachmentNotFoundErr, err)
+
+	assert.NoError(t, s.Delete(1, 5, 123))
+	_, err = st.Get("attachments/1/abc")
+	assert.Equal(t, storage.NotFoundErr, err)
+
+	assert.Equal(t, AttachmentNotFoundErr, s.Delete(1, 6, 123))
+}
+
+func TestNoStorage(t *testing.T) {
+	s := NewService(nil, nil, config.Config{})
+
+	_, err := s.Upload(1, "1234", "a.txt", "", strings.NewReader("a"), 123)
+	assert.Equal(t, NoStorageErr, err)
+	_, _, err = s.Open(1, 5, 123)
+	assert.Equal(t, NoStorageErr, err)
+	assert.Equal(t, NoStorageErr, s.Delete(1, 5, 123))
+}
+
+func TestCleanFilename(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"manual.pdf", "manual.pdf"},
+		{"  warranty card.pdf ", "warranty card.pdf"},
+		{"/etc/passwd", "passwd"},
+		{`C:\fakepath\receipt.jpg`, "receipt.jpg"},
+		{"bad\r\nname\".txt", "badname.txt"},
+		{"..", ""},
+		{"folder/", ""},
+		{strings.Repeat("é", 300), strings.Repeat("é", 255)},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, cleanFilename(tc.name), tc.name)
+	}
+}
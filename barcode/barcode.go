@@ -0,0 +1,167 @@
+package barcode
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	NotBarcodeErr        = errors.New("not a UPC, EAN, ISBN or GTIN barcode")
+	InvalidCheckDigitErr = errors.New("the check digit of the barcode is wrong")
+)
+
+// Symbologies that can be recognized
+const (
+	UPCA   = "UPC-A"
+	UPCE   = "UPC-E"
+	EAN8   = "EAN-8"
+	EAN13  = "EAN-13"
+	ISBN10 = "ISBN-10"
+	ISBN13 = "ISBN-13"
+	GTIN14 = "GTIN-14"
+)
+
+// Barcode is a retail barcode along with the GTIN-14 it stands for. Barcodes of
+// the same product in different symbologies share a GTIN.
+type Barcode struct {
+	Symbology string `json:"symbology"`
+	// Code is the barcode as given without spaces or hyphens
+	Code string `json:"code"`
+	// GTIN is the 14 digit form of the barcode
+	GTIN string `json:"gtin"`
+}
+
+// Parse works out the symbology of the barcode from its length and checks its
+// check digit. Spaces and hyphens, as often printed in ISBNs, are ignored.
+// 8 digits starting with 0 or 1 are read as UPC-E when their check digit
+// allows it and as EAN-8 otherwise.
+func Parse(s string) (Barcode, error) {
+	code := strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(s))
+	if len(code) == 10 && code[9] == 'x' {
+		code = code[:9] + "X"
+	}
+	for i := 0; i < len(code); i++ {
+		if (code[i] < '0' || code[i] > '9') && !(len(code) == 10 && i == 9 && code[i] == 'X') {
+			return Barcode{}, NotBarcodeErr
+		}
+	}
+
+	b := Barcode{Code: code}
+	switch len(code) {
+	case 8:
+		if code[0] <= '1' && validUPCE(code) {
+			b.Symbology = UPCE
+			b.GTIN = "00" + expandUPCE(code)
+			return b, nil
+		}
+		b.Symbology = EAN8
+	case 10:
+		b.Symbology = ISBN10
+		if !validISBN10(code) {
+			return Barcode{}, InvalidCheckDigitErr
+		}
+		ean := "978" + code[:9]
+		b.GTIN = "0" + ean + string(checkDigit(ean))
+		return b, nil
+	case 12:
+		b.Symbology = UPCA
+	case 13:
+		b.Symbology = EAN13
+		if strings.HasPrefix(code, "978") || strings.HasPrefix(code, "979") {
+			b.Symbology = ISBN13
+		}
+	case 14:
+		b.Symbology = GTIN14
+	default:
+		return Barcode{}, NotBarcodeErr
+	}
+
+	if checkDigit(code[:len(code)-1]) != code[len(code)-1] {
+		return Barcode{}, InvalidCheckDigitErr
+	}
+	b.GTIN = strings.Repeat("0", 14-len(code)) + code
+
+	return b, nil
+}
+
+// Forms returns every way the barcode can be written, starting with its
+// GTIN-14, followed by the shorter forms that only drop leading zeros and
+// then the code as given
+func (b Barcode) Forms() []string {
+	forms := []string{b.GTIN}
+	for _, n := range []int{13, 12, 8} {
+		if strings.Trim(b.GTIN[:14-n], "0") == "" {
+			forms = append(forms, b.GTIN[14-n:])
+		}
+	}
+
+	for _, f := range forms {
+		if f == b.Code {
+			return forms
+		}
+	}
+	return append(forms, b.Code)
+}
+
+// Normalize returns the GTIN-14 of IDs that are barcodes and any other ID as
+// is, so that the same product is always stored under the same ID. Barcodes
+// with the wrong check digit are an error.
+func Normalize(id string) (string, error) {
+	b, err := Parse(id)
+	if err == NotBarcodeErr {
+		return id, nil
+	} else if err != nil {
+		return id, err
+	}
+	return b.GTIN, nil
+}
+
+// checkDigit returns the GS1 check digit of the digits, which weights them 3
+// and 1 in turn starting from the right
+func checkDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// expandUPCE returns the UPC-A that the UPC-E is a zero suppressed form of
+func expandUPCE(code string) string {
+	ns, d, check := code[:1], code[1:7], code[7:]
+	switch d[5] {
+	case '0', '1', '2':
+		return ns + d[0:2] + d[5:6] + "0000" + d[2:5] + check
+	case '3':
+		return ns + d[0:3] + "00000" + d[3:5] + check
+	case '4':
+		return ns + d[0:4] + "00000" + d[4:5] + check
+	default:
+		return ns + d[0:5] + "0000" + d[5:6] + check
+	}
+}
+
+// validUPCE returns whether the check digit of the UPC-E is that of the UPC-A
+// it expands to
+func validUPCE(code string) bool {
+	upca := expandUPCE(code)
+	return checkDigit(upca[:11]) == upca[11]
+}
+
+// validISBN10 returns whether the digits weighted 10 down to 1 add up to a
+// multiple of 11, where a final X is 10
+func validISBN10(code string) bool {
+	sum := 0
+	for i := 0; i < 10; i++ {
+		d := int(code[i] - '0')
+		if code[i] == 'X' {
+			d = 10
+		}
+		sum += (10 - i) * d
+	}
+	return sum%11 == 0
+}
@@ -0,0 +1,129 @@
+package barcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	type testCase struct {
+		testName    string
+		code        string
+		expected    Barcode
+		expectedErr error
+	}
+
+	testCases := []testCase{
+		{
+			testName: "UPC-A",
+			code:     "036000291452",
+			expected: Barcode{Symbology: UPCA, Code: "036000291452", GTIN: "00036000291452"},
+		},
+		{
+			testName: "UPC-A as EAN-13 has the same GTIN",
+			code:     "0036000291452",
+			expected: Barcode{Symbology: EAN13, Code: "0036000291452", GTIN: "00036000291452"},
+		},
+		{
+			testName: "EAN-13",
+			code:     "4006381333931",
+			expected: Barcode{Symbology: EAN13, Code: "4006381333931", GTIN: "04006381333931"},
+		},
+		{
+			testName: "EAN-8",
+			code:     "96385074",
+			expected: Barcode{Symbology: EAN8, Code: "96385074", GTIN: "00000096385074"},
+		},
+		{
+			testName: "UPC-E expands to UPC-A",
+			code:     "04252614",
+			expected: Barcode{Symbology: UPCE, Code: "04252614", GTIN: "00042100005264"},
+		},
+		{
+			testName: "ISBN-10 becomes the ISBN-13",
+			code:     "0-306-40615-2",
+			expected: Barcode{Symbology: ISBN10, Code: "0306406152", GTIN: "09780306406157"},
+		},
+		{
+			testName: "ISBN-10 ending in x",
+			code:     "080442957x",
+			expected: Barcode{Symbology: ISBN10, Code: "080442957X", GTIN: "09780804429573"},
+		},
+		{
+			testName: "ISBN-13",
+			code:     "978 0 306 40615 7",
+			expected: Barcode{Symbology: ISBN13, Code: "9780306406157", GTIN: "09780306406157"},
+		},
+		{
+			testName: "GTIN-14",
+			code:     "10036000291459",
+			expected: Barcode{Symbology: GTIN14, Code: "10036000291459", GTIN: "10036000291459"},
+		},
+		{
+			testName:    "wrong check digit",
+			code:        "036000291453",
+			expectedErr: InvalidCheckDigitErr,
+		},
+		{
+			testName:    "wrong ISBN-10 check digit",
+			code:        "0306406153",
+			expectedErr: InvalidCheckDigitErr,
+		},
+		{
+			testName:    "wrong EAN-8 check digit",
+			code:        "96385075",
+			expectedErr: InvalidCheckDigitErr,
+		},
+		{
+			testName:    "too short",
+			code:        "1234",
+			expectedErr: NotBarcodeErr,
+		},
+		{
+			testName:    "not digits",
+			code:        "INV000042",
+			expectedErr: NotBarcodeErr,
+		},
+		{
+			testName:    "x that is not the end of an ISBN-10",
+			code:        "03060X6152",
+			expectedErr: NotBarcodeErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			b, err := Parse(tc.code)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expected, b)
+		})
+	}
+}
+
+func TestForms(t *testing.T) {
+	b, err := Parse("036000291452")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"00036000291452", "0036000291452", "036000291452"}, b.Forms())
+
+	b, err = Parse("04252614")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"00042100005264", "0042100005264", "042100005264", "04252614"}, b.Forms())
+
+	b, err = Parse("96385074")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"00000096385074", "0000096385074", "000096385074", "96385074"}, b.Forms())
+}
+
+func TestNormalize(t *testing.T) {
+	id, err := Normalize("036000291452")
+	assert.Nil(t, err)
+	assert.Equal(t, "00036000291452", id)
+
+	id, err = Normalize("INV000042")
+	assert.Nil(t, err)
+	assert.Equal(t, "INV000042", id)
+
+	_, err = Normalize("036000291453")
+	assert.Equal(t, InvalidCheckDigitErr, err)
+}
@@ -1,17 +1,99 @@
 package config
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/kelseyhightower/envconfig"
 )
 
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+)
+
+// Backends that uploaded files can be kept in
+const (
+	StorageLocal = "local"
+)
+
+// Barcode lookup providers that can make up UpcProviders
+const (
+	ProviderLocal         = "local"
+	ProviderUpc           = "upc"
+	ProviderUpcItemDB     = "upcitemdb"
+	ProviderOpenFoodFacts = "openfoodfacts"
+	ProviderOpenLibrary   = "openlibrary"
+	ProviderBarcodeLookup = "barcodelookup"
+)
+
 type Config struct {
-	DbUrl  string `split_words:"true" required:"true"`
-	DbUser string `split_words:"true" required:"true"`
-	DbPass string `split_words:"true" required:"true"`
-	DbName string `split_words:"true" required:"true"`
+	DbDriver string `split_words:"true" default:"mysql"`
+
+	// Used when DbDriver is mysql
+	DbUrl  string `split_words:"true" required:"false"`
+	DbUser string `split_words:"true" required:"false"`
+	DbPass string `split_words:"true" required:"false"`
+	DbName string `split_words:"true" required:"false"`
+
+	// Used when DbDriver is sqlite
+	DbPath string `split_words:"true" default:"inventory.db"`
+
+	// Work factor for new password hashes. Existing hashes with a lower cost are
+	// upgraded the next time the user logs in.
+	BcryptCost int `split_words:"true" default:"10"`
+
+	// How long a login stays valid for
+	SessionLength time.Duration `split_words:"true" default:"744h"`
+
+	// How long deleted items stay in the trash before they can be purged
+	TrashRetention time.Duration `split_words:"true" default:"720h"`
+
+	// IDs given to items without a barcode of their own are the prefix followed
+	// by a number padded with zeros to the number of digits
+	ItemIdPrefix string `split_words:"true" default:"INV"`
+	ItemIdDigits int    `split_words:"true" default:"6"`
+
+	// Search items with the embedded index, which matches prefixes and typos,
+	// instead of the full text search of the database
+	SearchIndex bool `split_words:"true" default:"true"`
+
+	// Where uploaded files are kept. Only local, which keeps them in
+	// StoragePath, is supported so far.
+	StorageDriver string `split_words:"true" default:"local"`
 
-	UpcUrl   string `split_words:"true" required:"true"`
-	UpcToken string `split_words:"true" required:"true"`
+	// Directory that uploaded files are kept in when StorageDriver is local
+	StoragePath string `split_words:"true" default:"files"`
+
+	// Limits of item attachments in MB. Each org can keep up to the quota in
+	// total, or any amount when it is 0.
+	AttachmentMaxMb   int `split_words:"true" default:"25"`
+	AttachmentQuotaMb int `split_words:"true" default:"1024"`
+
+	// Store a copy of pictures that added items link to elsewhere so that they
+	// keep working when the site moves them
+	CachePictures bool `split_words:"true" default:"false"`
+
+	// Barcodes are looked up with each provider in turn until one of them
+	// knows every detail of the product
+	UpcProviders []string `split_words:"true" default:"local,upc"`
+
+	// Used by the upc provider
+	UpcUrl   string `split_words:"true" required:"false"`
+	UpcToken string `split_words:"true" required:"false"`
+
+	// Used by the upcitemdb provider, which falls back to its free trial API
+	// when there is no key
+	UpcItemdbKey string `split_words:"true" required:"false"`
+
+	// Used by the barcodelookup provider
+	UpcBarcodelookupKey string `split_words:"true" required:"false"`
+
+	// How long lookup results are kept before the lookup API is asked again.
+	// Barcodes the API did not know are kept for less time in case they are added.
+	UpcCacheTtl     time.Duration `split_words:"true" default:"720h"`
+	UpcCacheMissTtl time.Duration `split_words:"true" default:"24h"`
 
 	EmailSmtpServ string `split_words:"true" required:"false"`
 	EmailSmtpPort int    `split_words:"true" required:"false"`
@@ -30,5 +112,41 @@ func FromEnvironment() (*Config, error) {
 		return nil, err
 	}
 
+	switch cfg.DbDriver {
+	case DriverMySQL:
+		if cfg.DbUrl == "" || cfg.DbUser == "" || cfg.DbPass == "" || cfg.DbName == "" {
+			return nil, fmt.Errorf("DB_URL, DB_USER, DB_PASS and DB_NAME are required when DB_DRIVER is %s", DriverMySQL)
+		}
+	case DriverSQLite:
+	default:
+		return nil, fmt.Errorf("unknown DB_DRIVER %q, expected %s or %s", cfg.DbDriver, DriverMySQL, DriverSQLite)
+	}
+
+	if cfg.StorageDriver != StorageLocal {
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q, expected %s", cfg.StorageDriver, StorageLocal)
+	}
+
+	// IDs made only of digits would be taken for barcodes
+	if strings.Trim(cfg.ItemIdPrefix, "0123456789") == "" {
+		return nil, fmt.Errorf("ITEM_ID_PREFIX %q must have a character that is not a digit", cfg.ItemIdPrefix)
+	}
+
+	for _, p := range cfg.UpcProviders {
+		switch p {
+		case ProviderUpc:
+			if cfg.UpcUrl == "" || cfg.UpcToken == "" {
+				return nil, fmt.Errorf("UPC_URL and UPC_TOKEN are required when UPC_PROVIDERS has %s", ProviderUpc)
+			}
+		case ProviderBarcodeLookup:
+			if cfg.UpcBarcodelookupKey == "" {
+				return nil, fmt.Errorf("UPC_BARCODELOOKUP_KEY is required when UPC_PROVIDERS has %s", ProviderBarcodeLookup)
+			}
+		case ProviderLocal, ProviderUpcItemDB, ProviderOpenFoodFacts, ProviderOpenLibrary:
+		default:
+			return nil, fmt.Errorf("unknown UPC_PROVIDERS entry %q, expected %s, %s, %s, %s, %s or %s", p,
+				ProviderLocal, ProviderUpc, ProviderUpcItemDB, ProviderOpenFoodFacts, ProviderOpenLibrary, ProviderBarcodeLookup)
+		}
+	}
+
 	return cfg, nil
 }
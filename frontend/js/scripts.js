@@ -2,9 +2,102 @@ function friendlyError(code, message) {
     switch(code) {
         case 1101:
             return "Ooops! The item already exists in the system. If you want to add another, please use another ID for it or edit the item to have a higher quantity.";
+        case 1102:
+            return "Ooops! There are not enough of that item to move that many. " + message;
+        case 1103:
+            return "Ooops! That loan could not be found or has already been returned.";
+        case 1112:
+            return "Ooops! That file is too large to attach. " + message;
+        case 1113:
+            return "Ooops! Your organization has used up its space for attachments. Delete some to make room.";
+        case 1204:
+            return "Ooops! The borrower is not a member of this organization.";
         case 1001:
             return "I'm afraid I can't let you do that Dave. Looks like the current user you are logged in with (if any) does not have permissions to perform this action.";
         default:
             return "Ooops! Theres an unexpected error \nCode: " + code + "\nMessage: " + message;
     }
-}
\ No newline at end of file
+}
+
+// uploadPicture sends the file chosen in the input and puts the URL of the
+// uploaded picture in the picture URL field
+function uploadPicture(input, urlField, onError) {
+    var file = input.files[0];
+    if (!file) {
+        return;
+    }
+
+    $.ajax({ cache: false,
+        url: "api/pictures",
+        method: "POST",
+        data: file,
+        processData: false,
+        contentType: file.type || "application/octet-stream",
+        success: function (data) {
+            $(urlField).val(data.url);
+        },
+        error: function (ajaxContext) {
+            var error = JSON.parse(ajaxContext.responseText);
+            onError(friendlyError(error.code, error.details));
+        }
+    });
+}
+
+// loadAttachments lists the attachments of the item in the list element, with
+// a link to download each one and a button to delete it
+function loadAttachments(itemID, list, onError) {
+    $.ajax({ cache: false,
+        url: "api/item/attachments?id=" + encodeURIComponent(itemID),
+        method: "GET",
+        success: function (attachments) {
+            $(list).empty();
+            attachments.forEach(function (a) {
+                var link = $("<a>").attr("href", "api/attachment?id=" + a.id).text(a.filename);
+                var info = $("<small class=\"text-muted\">").text(" " + Math.ceil(a.size / 1024) + " KB, " + a.uploadedBy + ", " + new Date(a.uploadedAt).toLocaleDateString() + " ");
+                var remove = $("<button type=\"button\" class=\"btn btn-sm btn-outline-danger\">").text("Delete").click(function () {
+                    $.ajax({ cache: false,
+                        url: "api/attachment?id=" + a.id,
+                        method: "DELETE",
+                        success: function () {
+                            loadAttachments(itemID, list, onError);
+                        },
+                        error: function (ajaxContext) {
+                            var error = JSON.parse(ajaxContext.responseText);
+                            onError(friendlyError(error.code, error.details));
+                        }
+                    });
+                });
+                $(list).append($("<li class=\"list-group-item\">").append(link, info, remove));
+            });
+        },
+        error: function (ajaxContext) {
+            var error = JSON.parse(ajaxContext.responseText);
+            onError(friendlyError(error.code, error.details));
+        }
+    });
+}
+
+// uploadAttachment sends the file chosen in the input as an attachment of the
+// item and lists the attachments again
+function uploadAttachment(input, itemID, list, onError) {
+    var file = input.files[0];
+    if (!file) {
+        return;
+    }
+
+    $.ajax({ cache: false,
+        url: "api/item/attachments?id=" + encodeURIComponent(itemID) + "&filename=" + encodeURIComponent(file.name),
+        method: "POST",
+        data: file,
+        processData: false,
+        contentType: file.type || "application/octet-stream",
+        success: function () {
+            $(input).val("");
+            loadAttachments(itemID, list, onError);
+        },
+        error: function (ajaxContext) {
+            var error = JSON.parse(ajaxContext.responseText);
+            onError(friendlyError(error.code, error.details));
+        }
+    });
+}
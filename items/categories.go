@@ -0,0 +1,312 @@
+package items
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of custom fields
+const (
+	FieldText   = "text"
+	FieldNumber = "number"
+	FieldDate   = "date"
+	FieldEnum   = "enum"
+)
+
+// FieldDateLayout is the format of the values of date fields
+const FieldDateLayout = "2006-01-02"
+
+var CategoryNotFoundErr = errors.New("category not found")
+var CategoryAlreadyExistsErr = errors.New("a category with that name already exists")
+var CategoryInUseErr = errors.New("category is still used by items")
+var InvalidCategoryErr = errors.New("category names must not be blank")
+
+// FieldError is returned when a custom field, or the value an item has for it,
+// is not valid
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("field %q %s", e.Field, e.Reason)
+}
+
+type Categories []Category
+
+// Category groups items that share the same custom fields
+type Category struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Fields []Field `json:"fields"`
+}
+
+// Field is a custom field that items in a category can have a value for
+type Field struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+	// Options are the values allowed for enum fields
+	Options []string `json:"options,omitempty"`
+}
+
+// Sorted returns the categories sorted by name
+func (cs Categories) Sorted() Categories {
+	ret := append(Categories{}, cs...)
+	sort.SliceStable(ret, func(i, j int) bool {
+		return strings.ToLower(ret[i].Name) < strings.ToLower(ret[j].Name)
+	})
+	return ret
+}
+
+// Find returns the category with the ID
+func (cs Categories) Find(id int) (Category, bool) {
+	for _, c := range cs {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Category{}, false
+}
+
+// named returns the category called name
+func (cs Categories) named(name string) (Category, bool) {
+	for _, c := range cs {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+	return Category{}, false
+}
+
+// validate checks that c can be saved among the existing categories
+func (cs Categories) validate(c Category) error {
+	if c.Name == "" {
+		return InvalidCategoryErr
+	}
+
+	if c.ID != 0 {
+		if _, ok := cs.Find(c.ID); !ok {
+			return CategoryNotFoundErr
+		}
+	}
+
+	if other, ok := cs.named(c.Name); ok && other.ID != c.ID {
+		return CategoryAlreadyExistsErr
+	}
+
+	seen := map[string]bool{}
+	for _, f := range c.Fields {
+		if f.Name == "" {
+			return FieldError{Field: f.Name, Reason: "must have a name"}
+		}
+		if seen[strings.ToLower(f.Name)] {
+			return FieldError{Field: f.Name, Reason: "is defined more than once"}
+		}
+		seen[strings.ToLower(f.Name)] = true
+
+		switch f.Type {
+		case FieldText, FieldNumber, FieldDate:
+		case FieldEnum:
+			if len(f.Options) == 0 {
+				return FieldError{Field: f.Name, Reason: "must have options"}
+			}
+		default:
+			return FieldError{Field: f.Name, Reason: "must have a type of text, number, date or enum"}
+		}
+	}
+
+	return nil
+}
+
+// normalize trims the names of the category and its fields
+func (c Category) normalize() Category {
+	c.Name = strings.TrimSpace(c.Name)
+
+	fields := []Field{}
+	for _, f := range c.Fields {
+		f.Name = strings.TrimSpace(f.Name)
+		f.Type = strings.ToLower(strings.TrimSpace(f.Type))
+		if f.Type != FieldEnum {
+			f.Options = nil
+		}
+		fields = append(fields, f)
+	}
+	c.Fields = fields
+
+	return c
+}
+
+// Values checks the custom field values of an item in the category and returns
+// them keyed by the name of their field. Blank values are left out.
+func (c Category) Values(values map[string]string) (map[string]string, error) {
+	ret := map[string]string{}
+	for name, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		f, ok := c.field(name)
+		if !ok {
+			return nil, FieldError{Field: name, Reason: "is not a field of the category " + c.Name}
+		}
+
+		checked, err := f.check(v)
+		if err != nil {
+			return nil, err
+		}
+		ret[f.Name] = checked
+	}
+
+	for _, f := range c.Fields {
+		if _, ok := ret[f.Name]; f.Required && !ok {
+			return nil, FieldError{Field: f.Name, Reason: "is required"}
+		}
+	}
+
+	return ret, nil
+}
+
+func (c Category) field(name string) (Field, bool) {
+	for _, f := range c.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// check returns the value the way it is stored if it is valid for the field
+func (f Field) check(v string) (string, error) {
+	switch f.Type {
+	case FieldNumber:
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return "", FieldError{Field: f.Name, Reason: "must be a number"}
+		}
+	case FieldDate:
+		if _, err := time.Parse(FieldDateLayout, v); err != nil {
+			return "", FieldError{Field: f.Name, Reason: "must be a date formatted as " + FieldDateLayout}
+		}
+	case FieldEnum:
+		for _, o := range f.Options {
+			if strings.EqualFold(o, v) {
+				return o, nil
+			}
+		}
+		return "", FieldError{Field: f.Name, Reason: "must be one of " + strings.Join(f.Options, ", ")}
+	}
+
+	return v, nil
+}
+
+func (s *Service) GetCategories(orgID int) (Categories, error) {
+	cs, err := s.persister.GetCategories(orgID)
+	if err != nil {
+		return nil, err
+	}
+	return cs.Sorted(), nil
+}
+
+func (s *Service) AddCategory(orgID int, c Category, userID int) (Category, error) {
+	c.ID = 0
+	c = c.normalize()
+
+	cs, err := s.persister.GetCategories(orgID)
+	if err != nil {
+		return c, err
+	}
+	if err = cs.validate(c); err != nil {
+		return c, err
+	}
+
+	return s.persister.AddCategory(orgID, c, userID)
+}
+
+// UpdateCategory renames the category or changes its fields. Items keep the
+// values of fields that are removed but they are no longer returned.
+func (s *Service) UpdateCategory(orgID int, c Category, userID int) error {
+	c = c.normalize()
+
+	cs, err := s.persister.GetCategories(orgID)
+	if err != nil {
+		return err
+	}
+	if c.ID == 0 {
+		return CategoryNotFoundErr
+	}
+	if err = cs.validate(c); err != nil {
+		return err
+	}
+
+	defer s.forget(orgID)
+	return s.persister.UpdateCategory(orgID, c, userID)
+}
+
+func (s *Service) DeleteCategory(orgID, categoryID, userID int) error {
+	return s.persister.DeleteCategory(orgID, categoryID, userID)
+}
+
+// FindCategory returns the category with the name
+func (s *Service) FindCategory(orgID int, name string) (Category, error) {
+	cs, err := s.persister.GetCategories(orgID)
+	if err != nil {
+		return Category{}, err
+	}
+
+	c, ok := cs.named(strings.TrimSpace(name))
+	if !ok {
+		return Category{}, CategoryNotFoundErr
+	}
+	return c, nil
+}
+
+// ResolveCategory returns the ID of the category with the name, creating it
+// without any custom fields if it does not exist yet
+func (s *Service) ResolveCategory(orgID int, name string, userID int) (int, error) {
+	cs, err := s.persister.GetCategories(orgID)
+	if err != nil {
+		return 0, err
+	}
+
+	name = strings.TrimSpace(name)
+	if c, ok := cs.named(name); ok {
+		return c.ID, nil
+	}
+
+	c, err := s.persister.AddCategory(orgID, Category{Name: name, Fields: []Field{}}, userID)
+	return c.ID, err
+}
+
+// checkFields returns the custom field values of the item if they are valid
+// for its category and its quantity is not negative
+func (s *Service) checkFields(orgID int, item ItemDetail) (map[string]string, error) {
+	if item.Quantity < 0 {
+		return nil, NegativeQuantityErr
+	}
+
+	if item.CategoryID == 0 {
+		for name, v := range item.Fields {
+			if strings.TrimSpace(v) != "" {
+				return nil, FieldError{Field: name, Reason: "cannot be set on items without a category"}
+			}
+		}
+		return map[string]string{}, nil
+	}
+
+	cs, err := s.persister.GetCategories(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := cs.Find(item.CategoryID)
+	if !ok {
+		return nil, CategoryNotFoundErr
+	}
+
+	return c.Values(item.Fields)
+}
@@ -0,0 +1,67 @@
+package items
+
+import (
+	"time"
+)
+
+// ExportedItem is an item along with what is only needed when exporting it
+type ExportedItem struct {
+	ItemDetail
+	// DeletedAt is when the item was put in the trash, or nil if it was not
+	DeletedAt *time.Time `json:",omitempty"`
+	// Loans are the open loans of the item, oldest first. They are only
+	// filled in when asked for.
+	Loans Loans `json:",omitempty"`
+}
+
+// ExportOptions says what to export along with the items matching the filter
+type ExportOptions struct {
+	// Deleted also exports the matching items that are in the trash
+	Deleted bool
+	// Loans fills in the open loans of every item
+	Loans bool
+}
+
+// ExportItems calls fn with every item of the org that matches the filter, in
+// the order given by the filter, as they are read. Paging of the filter is
+// ignored and searches are never sorted by relevance.
+func (s *Service) ExportItems(orgID int, filter ItemFilter, opts ExportOptions, fn func(ExportedItem) error) error {
+	if filter.SortBy == "" {
+		filter.SortBy = SortName
+	}
+	if _, _, err := ParseSort(filter.sort()); err != nil || filter.SortBy == SortRelevance {
+		return InvalidSortErr
+	}
+
+	if !validStatus(filter.Status) {
+		return InvalidStatusErr
+	}
+
+	if _, err := s.search(orgID, &filter); err != nil {
+		return err
+	}
+	filter.After, filter.Limit = nil, 0
+	filter.Deleted = opts.Deleted
+
+	// Loans are read up front as the items are streamed from the database
+	loans := map[string]Loans{}
+	if opts.Loans {
+		ls, err := s.getLoans(orgID, LoanFilter{OpenOnly: true}, false)
+		if err != nil {
+			return err
+		}
+		for _, l := range ls {
+			loans[l.ItemID] = append(loans[l.ItemID], l)
+		}
+	}
+
+	return s.persister.ExportItems(orgID, filter, func(item ExportedItem) error {
+		if opts.Loans {
+			item.Loans = loans[item.ID]
+			if item.Loans == nil {
+				item.Loans = Loans{}
+			}
+		}
+		return fn(item)
+	})
+}
@@ -0,0 +1,128 @@
+package items
+
+import (
+	"fmt"
+
+	"github.com/Timothylock/inventory-management/barcode"
+)
+
+// DefaultItemIDDigits is used when ITEM_ID_DIGITS is not configured
+const DefaultItemIDDigits = 6
+
+// ReserveItemID returns a new ID for an item that does not have a barcode of
+// its own, such as INV000042. Numbers whose ID is already taken, including by
+// items in the trash, are skipped.
+func (s *Service) ReserveItemID(orgID int) (string, error) {
+	for {
+		n, err := s.persister.NextItemNumber(orgID)
+		if err != nil {
+			return "", err
+		}
+
+		id := fmt.Sprintf("%s%0*d", s.idPrefix, s.idDigits, n)
+		list, _, err := s.persister.SearchItems(orgID, ItemFilter{IDs: []string{id}, Deleted: true, SortBy: SortID})
+		if err != nil {
+			return "", err
+		}
+		if len(list) == 0 {
+			return id, nil
+		}
+	}
+}
+
+// FindItems returns the items with the IDs in the same order, or
+// ItemNotFoundErr if any of them does not exist. Barcodes find the item
+// whichever form it was stored under and the items have the stored ID.
+func (s *Service) FindItems(orgID int, ids []string) (ItemDetailList, error) {
+	ids, err := s.resolveIDs(orgID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	list, _, err := s.persister.SearchItems(orgID, ItemFilter{IDs: ids, SortBy: SortID})
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[string]ItemDetail{}
+	for _, item := range list {
+		byID[item.ID] = item
+	}
+
+	ret := make(ItemDetailList, len(ids))
+	for i, id := range ids {
+		item, ok := byID[id]
+		if !ok {
+			return nil, ItemNotFoundErr
+		}
+		ret[i] = item
+	}
+
+	return ret, nil
+}
+
+// ResolveID returns the ID that an item scanned as id is stored under,
+// including items in the trash. Barcodes match items stored under any form of
+// them, such as ones added before IDs were normalized, and are otherwise their
+// GTIN-14. Anything else is returned as is.
+func (s *Service) ResolveID(orgID int, id string) (string, error) {
+	ids, err := s.resolveIDs(orgID, []string{id})
+	if err != nil {
+		return "", err
+	}
+
+	return ids[0], nil
+}
+
+// resolveIDs does what ResolveID does for each of the IDs with one search.
+// The first form of a barcode that an item is stored under is used.
+func (s *Service) resolveIDs(orgID int, ids []string) ([]string, error) {
+	codes := make([]barcode.Barcode, len(ids))
+	forms := []string{}
+	seen := map[string]bool{}
+	for i, id := range ids {
+		b, err := barcode.Parse(id)
+		if err != nil {
+			continue
+		}
+
+		codes[i] = b
+		for _, f := range b.Forms() {
+			if !seen[f] {
+				seen[f] = true
+				forms = append(forms, f)
+			}
+		}
+	}
+
+	ret := append([]string{}, ids...)
+	if len(forms) == 0 {
+		return ret, nil
+	}
+
+	list, _, err := s.persister.SearchItems(orgID, ItemFilter{IDs: forms, Deleted: true, SortBy: SortID})
+	if err != nil {
+		return nil, err
+	}
+
+	stored := map[string]bool{}
+	for _, item := range list {
+		stored[item.ID] = true
+	}
+
+	for i, b := range codes {
+		if b.GTIN == "" {
+			continue
+		}
+
+		ret[i] = b.GTIN
+		for _, f := range b.Forms() {
+			if stored[f] {
+				ret[i] = f
+				break
+			}
+		}
+	}
+
+	return ret, nil
+}
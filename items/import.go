@@ -0,0 +1,270 @@
+package items
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Timothylock/inventory-management/barcode"
+)
+
+// What an import does with a row whose ID is already taken
+const (
+	// ImportCreate reports the row as an error
+	ImportCreate = "create"
+	// ImportOverwrite replaces the existing item
+	ImportOverwrite = "overwrite"
+	// ImportSkip leaves the existing item alone
+	ImportSkip = "skip"
+)
+
+// ImportRow is one item of an import
+type ImportRow struct {
+	// Line is where the row is in the imported file
+	Line int
+	// Mode is ImportCreate, ImportOverwrite or ImportSkip. Blank means create.
+	Mode string
+	Item ItemDetail
+	// Category is the name of the category, which is created if it does not
+	// exist yet. It is only used when the CategoryID of the item is 0.
+	Category string
+	// Location is the path of the location, which is created if it does not
+	// exist yet. It is only used when the LocationID of the item is 0.
+	Location string
+}
+
+// ImportReport says what an import did, or would do if it is a dry run.
+// Nothing is imported when there are errors.
+type ImportReport struct {
+	DryRun      bool         `json:"dryRun"`
+	Created     int          `json:"created"`
+	Overwritten int          `json:"overwritten"`
+	Skipped     int          `json:"skipped"`
+	Errors      ImportErrors `json:"errors"`
+}
+
+type ImportErrors []ImportError
+
+// ImportError is a problem with one row of an import
+type ImportError struct {
+	Line int    `json:"line"`
+	ID   string `json:"id"`
+	// Field is the column that has the problem, if there is one
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// Sort orders the errors by line
+func (es ImportErrors) Sort() {
+	sort.SliceStable(es, func(i, j int) bool {
+		return es[i].Line < es[j].Line
+	})
+}
+
+// ImportItems checks every row and, unless it is a dry run or any row has a
+// problem, adds them all in one transaction along with any categories and
+// locations they need
+func (s *Service) ImportItems(orgID int, rows []ImportRow, dryRun bool, userID int) (ImportReport, error) {
+	report := ImportReport{DryRun: dryRun, Errors: ImportErrors{}}
+
+	cs, err := s.persister.GetCategories(orgID)
+	if err != nil {
+		return report, err
+	}
+	ls, err := s.persister.GetLocations(orgID)
+	if err != nil {
+		return report, err
+	}
+
+	// IDs that are barcodes match items stored under any form of them and are
+	// otherwise stored as their GTIN-14. Ones with the wrong check digit are
+	// left for checkImportRow to report.
+	rows = append([]ImportRow{}, rows...)
+	resolved := make([]string, len(rows))
+	for i, row := range rows {
+		resolved[i] = row.Item.ID
+	}
+	if resolved, err = s.resolveIDs(orgID, resolved); err != nil {
+		return report, err
+	}
+	for i := range rows {
+		rows[i].Item.ID = resolved[i]
+	}
+
+	ids := []string{}
+	for _, row := range rows {
+		if row.Item.ID != "" {
+			ids = append(ids, row.Item.ID)
+		}
+	}
+	list, _, err := s.persister.SearchItems(orgID, ItemFilter{IDs: ids, SortBy: SortID})
+	if err != nil {
+		return report, err
+	}
+	existing := map[string]ItemDetail{}
+	for _, item := range list {
+		existing[item.ID] = item
+	}
+
+	seen := map[string]bool{}
+	write := []ImportRow{}
+	// overwrite says which rows replace an existing item, as rows in
+	// overwrite mode with a new ID are created like any other
+	overwrite := map[string]bool{}
+	for _, row := range rows {
+		fail := func(field, reason string) {
+			report.Errors = append(report.Errors, ImportError{Line: row.Line, ID: row.Item.ID, Field: field, Reason: reason})
+		}
+
+		if row.Mode == "" {
+			row.Mode = ImportCreate
+		}
+		errs := len(report.Errors)
+		if checkImportRow(row, cs, ls, fail); len(report.Errors) > errs {
+			continue
+		}
+
+		if seen[row.Item.ID] {
+			fail("id", "appears more than once in the import")
+			continue
+		}
+		seen[row.Item.ID] = true
+
+		current, exists := existing[row.Item.ID]
+		switch {
+		case !exists:
+			report.Created++
+		case row.Mode == ImportSkip:
+			report.Skipped++
+			continue
+		case row.Mode == ImportCreate:
+			fail("id", ItemAlreadyExistsErr.Error())
+			continue
+		case row.Item.Quantity < current.CheckedOut:
+			fail("quantity", QuantityBelowCheckedOutErr.Error())
+			continue
+		default:
+			report.Overwritten++
+			overwrite[row.Item.ID] = true
+		}
+
+		write = append(write, row)
+	}
+
+	if dryRun || len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	var purged []string
+	defer s.forget(orgID)
+	err = s.persister.Transaction(func(p Persister) error {
+		tx := Service{persister: p, trashRetention: s.trashRetention}
+
+		for _, row := range write {
+			var err error
+			if row.Item.CategoryID == 0 {
+				if row.Item.CategoryID, err = tx.ResolveCategory(orgID, row.Category, userID); err != nil {
+					return err
+				}
+			}
+			if row.Item.LocationID == 0 && strings.TrimSpace(row.Location) != "" {
+				if row.Item.LocationID, err = tx.ResolveLocation(orgID, row.Location, userID); err != nil {
+					return err
+				}
+			}
+
+			keys, err := tx.addItem(orgID, row.Item, overwrite[row.Item.ID], userID)
+			if err != nil {
+				return err
+			}
+			purged = append(purged, keys...)
+		}
+
+		return nil
+	})
+	if err == nil {
+		s.deleteFiles(purged)
+	}
+
+	return report, err
+}
+
+// ImportCreates returns whether importing the rows would create any
+// categories or locations
+func (s *Service) ImportCreates(orgID int, rows []ImportRow) (bool, error) {
+	cs, err := s.persister.GetCategories(orgID)
+	if err != nil {
+		return false, err
+	}
+	ls, err := s.persister.GetLocations(orgID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, row := range rows {
+		if name := strings.TrimSpace(row.Category); row.Item.CategoryID == 0 && name != "" {
+			if _, ok := cs.named(name); !ok {
+				return true, nil
+			}
+		}
+		if row.Item.LocationID == 0 && strings.TrimSpace(row.Location) != "" {
+			if _, ok := ls.path(row.Location); !ok {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// checkImportRow reports the problems with the row that can be found without
+// looking at the other rows
+func checkImportRow(row ImportRow, cs Categories, ls Locations, fail func(field, reason string)) {
+	switch row.Mode {
+	case ImportCreate, ImportOverwrite, ImportSkip:
+	default:
+		fail("mode", "must be one of create, overwrite or skip")
+	}
+
+	if strings.TrimSpace(row.Item.ID) == "" {
+		fail("id", "is required")
+		return
+	}
+	if _, err := barcode.Normalize(row.Item.ID); err != nil {
+		fail("id", err.Error())
+		return
+	}
+	if strings.TrimSpace(row.Item.Name) == "" {
+		fail("name", "is required")
+	}
+	if row.Item.Quantity < 1 {
+		fail("quantity", "must be at least 1")
+	}
+
+	if row.Item.LocationID != 0 {
+		if _, ok := ls.Find(row.Item.LocationID); !ok {
+			fail("locationId", LocationNotFoundErr.Error())
+		}
+	}
+
+	var c Category
+	if row.Item.CategoryID != 0 {
+		var ok bool
+		if c, ok = cs.Find(row.Item.CategoryID); !ok {
+			fail("categoryId", CategoryNotFoundErr.Error())
+			return
+		}
+	} else if name := strings.TrimSpace(row.Category); name == "" {
+		fail("category", "is required")
+		return
+	} else {
+		var ok bool
+		if c, ok = cs.named(name); !ok {
+			// New categories are created without any custom fields
+			c = Category{Name: name}
+		}
+	}
+
+	if _, err := c.Values(row.Item.Fields); err != nil {
+		fail("fields", err.Error())
+	}
+}
@@ -2,53 +2,219 @@ package items
 
 import (
 	"errors"
+	"strings"
+	"time"
+
+	"github.com/Timothylock/inventory-management/barcode"
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/Timothylock/inventory-management/storage"
 )
 
+// Persister stores the items of every org. Item IDs only need to be unique
+// within an org.
 type Persister interface {
-	MoveItem(ID, direction string, userID int) error
-	DeleteItem(ID string, userID int) error
-	SearchItems(search string) (ItemDetailList, error)
-	AddItem(obj ItemDetail, overwrite bool) error
+	// MoveItem checks quantity of the item in or out and returns how many were
+	// moved. A quantity of 0 moves everything that can be moved.
+	MoveItem(orgID int, ID, direction string, quantity, userID int) (int, error)
+	DeleteItem(orgID int, ID string, userID int) error
+	// SearchItems returns up to filter.Limit of the items matching the filter
+	// along with how many match it in total
+	SearchItems(orgID int, filter ItemFilter) (ItemDetailList, int, error)
+	// ExportItems calls fn with every item matching the filter as it is read,
+	// stopping at the first error
+	ExportItems(orgID int, filter ItemFilter, fn func(ExportedItem) error) error
+	// GroupItems counts the items matching the filter grouped by category,
+	// location or status
+	GroupItems(orgID int, by string, filter ItemFilter) (ItemGroups, error)
+	AddItem(orgID int, obj ItemDetail, overwrite bool) error
+	// NextItemNumber returns the next number of the org's sequence of item
+	// IDs and moves the sequence past it
+	NextItemNumber(orgID int) (int, error)
+	AddLoan(orgID int, loan Loan, userID int) (Loan, error)
+	GetLoan(orgID, loanID int) (Loan, error)
+	GetLoans(orgID int, filter LoanFilter) (Loans, error)
+	// ReturnLoans marks quantity as returned on the open loans of the item,
+	// oldest first, or only on loanID if it is not 0
+	ReturnLoans(orgID int, itemID string, loanID, quantity int) error
+	// GetLogs returns the page of log entries given by the filter along with
+	// how many entries match it in total
+	GetLogs(orgID int, filter LogFilter) (LogEntries, int, error)
+	GetDeletedItems(orgID int) (DeletedItems, error)
+	// RestoreItem takes the item back out of the trash
+	RestoreItem(orgID int, ID string, userID int) error
+	// PurgeItem permanently removes an item that is in the trash along with
+	// its attachments and returns the storage keys of their files
+	PurgeItem(orgID int, ID string, userID int) ([]string, error)
+	// PurgeItems permanently removes the items that were put in the trash
+	// before deletedBefore and returns how many there were and the storage
+	// keys of their attachments
+	PurgeItems(orgID int, deletedBefore time.Time, userID int) (int, []string, error)
+	// GetLocations returns every location of the org with its path
+	GetLocations(orgID int) (Locations, error)
+	AddLocation(orgID int, loc Location, userID int) (Location, error)
+	UpdateLocation(orgID int, loc Location, userID int) error
+	// DeleteLocation removes a location that has no items or locations in it
+	DeleteLocation(orgID, locationID, userID int) error
+	GetCategories(orgID int) (Categories, error)
+	AddCategory(orgID int, c Category, userID int) (Category, error)
+	UpdateCategory(orgID int, c Category, userID int) error
+	// DeleteCategory removes a category that no items are in
+	DeleteCategory(orgID, categoryID, userID int) error
+	// Transaction runs fn with a persister that makes all of its changes at
+	// once, or none of them if fn returns an error
+	Transaction(fn func(Persister) error) error
 }
 
 var ItemNotFoundErr = errors.New("item not found")
 var ItemAlreadyExistsErr = errors.New("item already exists")
+var NotEnoughAvailableErr = errors.New("not enough of the item is available to check out")
+var NotEnoughCheckedOutErr = errors.New("not enough of the item is checked out to check in")
+var QuantityBelowCheckedOutErr = errors.New("quantity cannot be lower than the number checked out")
+var NegativeQuantityErr = errors.New("quantity must not be negative")
+
+// Statuses of an item
+const (
+	StatusCheckedIn           = "checked in"
+	StatusCheckedOut          = "checked out"
+	StatusPartiallyCheckedOut = "partially checked out"
+)
 
 type ItemDetailList []ItemDetail
 type ItemDetail struct {
-	ID              string `db:"ID"`
-	Name            string `db:"NAME"`
-	Category        string `db:"CATEGORY"`
-	PictureURL      string `db:"PICTURE_URL"`
-	Details         string `db:"DETAILS"`
-	Location        string `db:"LOCATION"`
-	LastPerformedBy string `db:"USERNAME"`
-	Quantity        int    `db:"QUANTITY"`
-	Status          string `db:"STATUS"`
+	ID         string `db:"ID"`
+	Name       string `db:"NAME"`
+	CategoryID int    `db:"CATEGORYID"`
+	Category   string `db:"-"` // Name of the category
+	PictureURL string `db:"PICTURE_URL"`
+	Details    string `db:"DETAILS"`
+	LocationID int    `db:"LOCATIONID"`
+	Location   string `db:"-"` // Path of the location
+	// Fields are the values of the custom fields of the category by their name
+	Fields          map[string]string `db:"-"`
+	LastPerformedBy string            `db:"USERNAME"`
+	Quantity        int               `db:"QUANTITY"`
+	Available       int               `db:"AVAILABLE"`
+	CheckedOut      int               `db:"CHECKED_OUT"`
+	Status          string            `db:"STATUS"`
+}
+
+// StatusFor returns the status of an item with checkedOut of quantity checked out
+func StatusFor(quantity, checkedOut int) string {
+	if checkedOut <= 0 {
+		return StatusCheckedIn
+	} else if checkedOut >= quantity {
+		return StatusCheckedOut
+	}
+	return StatusPartiallyCheckedOut
 }
 
 type Service struct {
 	persister Persister
+	// searcher is nil when the database does the searching
+	searcher Searcher
+	// storage holds the files of attachments, which are deleted along with
+	// purged items. It is nil when no storage is configured.
+	storage        storage.Storage
+	trashRetention time.Duration
+	idPrefix       string
+	idDigits       int
 }
 
-func NewService(p Persister) Service {
+// NewService returns a service that searches items with sr, or with the
+// database when sr is nil
+func NewService(p Persister, sr Searcher, st storage.Storage, c config.Config) Service {
+	tr := c.TrashRetention
+	if tr <= 0 {
+		tr = DefaultTrashRetention
+	}
+
+	digits := c.ItemIdDigits
+	if digits <= 0 {
+		digits = DefaultItemIDDigits
+	}
+
 	return Service{
-		persister: p,
+		persister:      p,
+		searcher:       sr,
+		storage:        st,
+		trashRetention: tr,
+		idPrefix:       c.ItemIdPrefix,
+		idDigits:       digits,
 	}
 }
 
-func (s *Service) FetchItems(search string) (ItemDetailList, error) {
-	return s.persister.SearchItems(search)
-}
+func (s *Service) DeleteItem(orgID int, id string, userID int) error {
+	id, err := s.ResolveID(orgID, id)
+	if err != nil {
+		return err
+	}
 
-func (s *Service) DeleteItem(id string, userID int) error {
-	return s.persister.DeleteItem(id, userID)
+	defer s.forget(orgID)
+	return s.persister.DeleteItem(orgID, id, userID)
 }
 
-func (s *Service) AddItem(item ItemDetail, overwrite bool) error {
-	return s.persister.AddItem(item, overwrite)
+// AddItem adds or overwrites an item after checking its custom field values
+// against its category. IDs that are barcodes are stored as their GTIN-14,
+// unless an item is already stored under another form of them.
+// When the category or location ID is 0 they are found by the Category name
+// and Location path of the item, and created if they do not exist yet, in the
+// same transaction as the item so that nothing is left behind if it fails.
+// An item in the trash with the same ID is purged first.
+func (s *Service) AddItem(orgID int, item ItemDetail, overwrite bool, userID int) error {
+	if _, err := barcode.Normalize(item.ID); err != nil {
+		return err
+	}
+
+	var purged []string
+	defer s.forget(orgID)
+	err := s.persister.Transaction(func(p Persister) error {
+		tx := Service{persister: p, trashRetention: s.trashRetention}
+
+		var err error
+		if item.ID, err = tx.ResolveID(orgID, item.ID); err != nil {
+			return err
+		}
+		purged, err = tx.addItem(orgID, item, overwrite, userID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.deleteFiles(purged)
+	return nil
 }
 
-func (s *Service) MoveItem(id, direction string, userID int) error {
-	return s.persister.MoveItem(id, direction, userID)
+// addItem resolves the category and location of the item and adds it,
+// purging any item in the trash that it replaces. It is run inside a
+// transaction and returns the storage keys of the purged attachments, whose
+// files are only deleted once it commits.
+func (s *Service) addItem(orgID int, item ItemDetail, overwrite bool, userID int) ([]string, error) {
+	var err error
+	if item.CategoryID == 0 && strings.TrimSpace(item.Category) != "" {
+		if item.CategoryID, err = s.ResolveCategory(orgID, item.Category, userID); err != nil {
+			return nil, err
+		}
+	}
+	if item.LocationID == 0 && strings.TrimSpace(item.Location) != "" {
+		if item.LocationID, err = s.ResolveLocation(orgID, item.Location, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	fields, err := s.checkFields(orgID, item)
+	if err != nil {
+		return nil, err
+	}
+	item.Fields = fields
+
+	var purged []string
+	if !overwrite {
+		purged, err = s.persister.PurgeItem(orgID, item.ID, userID)
+		if err != nil && err != ItemNotFoundErr {
+			return nil, err
+		}
+	}
+
+	return purged, s.persister.AddItem(orgID, item, overwrite)
 }
@@ -0,0 +1,126 @@
+package items
+
+import (
+	"errors"
+	"time"
+)
+
+var LoanNotFoundErr = errors.New("loan not found")
+
+type Loans []Loan
+
+// Loan records who has some quantity of an item and when it should come back.
+// A loan is closed once everything has been returned.
+type Loan struct {
+	ID       int    `json:"id"`
+	ItemID   string `json:"itemId"`
+	ItemName string `json:"itemName"`
+	Quantity int    `json:"quantity"`
+	Returned int    `json:"returned"`
+	// BorrowerUserID is 0 when the borrower is not a user
+	BorrowerUserID int        `json:"borrowerUserId"`
+	Borrower       string     `json:"borrower"`
+	DueAt          *time.Time `json:"dueAt"`
+	Notes          string     `json:"notes"`
+	CheckedOutAt   time.Time  `json:"checkedOutAt"`
+	CheckedOutBy   string     `json:"checkedOutBy"`
+	ReturnedAt     *time.Time `json:"returnedAt"`
+	Overdue        bool       `json:"overdue"`
+}
+
+// LoanFilter narrows down the loans returned by GetLoans
+type LoanFilter struct {
+	OpenOnly bool
+	Borrower string
+}
+
+// Outstanding is how much of the loan has not been returned yet
+func (l Loan) Outstanding() int {
+	return l.Quantity - l.Returned
+}
+
+// IsOverdue returns whether the loan is still open after its due date
+func (l Loan) IsOverdue(now time.Time) bool {
+	return l.ReturnedAt == nil && l.DueAt != nil && now.After(*l.DueAt)
+}
+
+// CheckOut moves the quantity of the item out and records who borrowed it in
+// one transaction. A quantity of 0 checks out everything that is available.
+func (s *Service) CheckOut(orgID int, loan Loan, userID int) (Loan, error) {
+	err := s.persister.Transaction(func(p Persister) error {
+		n, err := p.MoveItem(orgID, loan.ItemID, "out", loan.Quantity, userID)
+		if err != nil {
+			return err
+		}
+
+		loan.Quantity = n
+		loan, err = p.AddLoan(orgID, loan, userID)
+		return err
+	})
+
+	return loan, err
+}
+
+// CheckIn moves the quantity of the item back in and closes the loans it was
+// borrowed with, oldest first, in one transaction. When loanID is given only
+// that loan is returned. A quantity of 0 returns everything that is checked out.
+func (s *Service) CheckIn(orgID int, itemID string, loanID, quantity, userID int) error {
+	if loanID != 0 {
+		loan, err := s.persister.GetLoan(orgID, loanID)
+		if err != nil {
+			return err
+		}
+		if loan.ItemID != itemID || loan.ReturnedAt != nil {
+			return LoanNotFoundErr
+		}
+
+		if quantity == 0 {
+			quantity = loan.Outstanding()
+		} else if quantity > loan.Outstanding() {
+			return NotEnoughCheckedOutErr
+		}
+	}
+
+	return s.persister.Transaction(func(p Persister) error {
+		n, err := p.MoveItem(orgID, itemID, "in", quantity, userID)
+		if err != nil {
+			return err
+		}
+
+		return p.ReturnLoans(orgID, itemID, loanID, n)
+	})
+}
+
+// GetLoans returns the loans that are still open, oldest first
+func (s *Service) GetLoans(orgID int) (Loans, error) {
+	return s.getLoans(orgID, LoanFilter{OpenOnly: true}, false)
+}
+
+// GetOverdueLoans returns the open loans that are past their due date
+func (s *Service) GetOverdueLoans(orgID int) (Loans, error) {
+	return s.getLoans(orgID, LoanFilter{OpenOnly: true}, true)
+}
+
+// GetBorrowerLoans returns every loan of the borrower, including returned ones
+func (s *Service) GetBorrowerLoans(orgID int, borrower string) (Loans, error) {
+	return s.getLoans(orgID, LoanFilter{Borrower: borrower}, false)
+}
+
+func (s *Service) getLoans(orgID int, filter LoanFilter, overdueOnly bool) (Loans, error) {
+	ls, err := s.persister.GetLoans(orgID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	ret := Loans{}
+	for _, l := range ls {
+		l.Overdue = l.IsOverdue(now)
+		if overdueOnly && !l.Overdue {
+			continue
+		}
+		ret = append(ret, l)
+	}
+
+	return ret, nil
+}
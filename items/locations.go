@@ -0,0 +1,236 @@
+package items
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// LocationSeparator separates the names in the path of a location
+const LocationSeparator = " > "
+
+var LocationNotFoundErr = errors.New("location not found")
+var LocationAlreadyExistsErr = errors.New("a location with that name already exists there")
+var LocationInUseErr = errors.New("location still contains items or other locations")
+var InvalidLocationErr = errors.New("location names must not be blank or contain >")
+var LocationCycleErr = errors.New("a location cannot be moved under itself")
+
+type Locations []Location
+
+// Location is a place that items are kept in. Locations form a tree within an
+// org, so "Building A > Room 2 > Shelf 3" is three locations.
+type Location struct {
+	ID int `json:"id"`
+	// ParentID is 0 for top level locations
+	ParentID int    `json:"parentId"`
+	Name     string `json:"name"`
+	// Path is the names of the location and everything above it
+	Path string `json:"path"`
+}
+
+// WithPaths returns the locations with their paths set, sorted by path
+func (ls Locations) WithPaths() Locations {
+	byID := map[int]Location{}
+	for _, l := range ls {
+		byID[l.ID] = l
+	}
+
+	ret := Locations{}
+	for _, l := range ls {
+		names := []string{l.Name}
+		seen := map[int]bool{l.ID: true}
+		for p, ok := byID[l.ParentID]; ok && !seen[p.ID]; p, ok = byID[p.ParentID] {
+			names = append([]string{p.Name}, names...)
+			seen[p.ID] = true
+		}
+
+		l.Path = strings.Join(names, LocationSeparator)
+		ret = append(ret, l)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return strings.ToLower(ret[i].Path) < strings.ToLower(ret[j].Path)
+	})
+
+	return ret
+}
+
+// Find returns the location with the ID
+func (ls Locations) Find(id int) (Location, bool) {
+	for _, l := range ls {
+		if l.ID == id {
+			return l, true
+		}
+	}
+	return Location{}, false
+}
+
+// Subtree returns the ID of the location and of every location under it
+func (ls Locations) Subtree(id int) []int {
+	ret := []int{id}
+	seen := map[int]bool{id: true}
+	for i := 0; i < len(ret); i++ {
+		for _, l := range ls {
+			if l.ParentID == ret[i] && !seen[l.ID] {
+				ret = append(ret, l.ID)
+				seen[l.ID] = true
+			}
+		}
+	}
+	return ret
+}
+
+// child returns the location called name directly under parentID
+func (ls Locations) child(parentID int, name string) (Location, bool) {
+	for _, l := range ls {
+		if l.ParentID == parentID && strings.EqualFold(l.Name, name) {
+			return l, true
+		}
+	}
+	return Location{}, false
+}
+
+// validate checks that loc can be saved among the existing locations
+func (ls Locations) validate(loc Location) error {
+	if loc.Name == "" || strings.Contains(loc.Name, ">") {
+		return InvalidLocationErr
+	}
+
+	if loc.ID != 0 {
+		if _, ok := ls.Find(loc.ID); !ok {
+			return LocationNotFoundErr
+		}
+	}
+
+	if loc.ParentID != 0 {
+		if _, ok := ls.Find(loc.ParentID); !ok {
+			return LocationNotFoundErr
+		}
+		if loc.ID != 0 && containsInt(ls.Subtree(loc.ID), loc.ParentID) {
+			return LocationCycleErr
+		}
+	}
+
+	if c, ok := ls.child(loc.ParentID, loc.Name); ok && c.ID != loc.ID {
+		return LocationAlreadyExistsErr
+	}
+
+	return nil
+}
+
+func containsInt(s []int, v int) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) GetLocations(orgID int) (Locations, error) {
+	return s.persister.GetLocations(orgID)
+}
+
+func (s *Service) AddLocation(orgID int, loc Location, userID int) (Location, error) {
+	loc.ID = 0
+	loc.Name = strings.TrimSpace(loc.Name)
+
+	ls, err := s.persister.GetLocations(orgID)
+	if err != nil {
+		return loc, err
+	}
+	if err = ls.validate(loc); err != nil {
+		return loc, err
+	}
+
+	return s.persister.AddLocation(orgID, loc, userID)
+}
+
+// UpdateLocation renames the location or moves it under another parent
+func (s *Service) UpdateLocation(orgID int, loc Location, userID int) error {
+	loc.Name = strings.TrimSpace(loc.Name)
+
+	ls, err := s.persister.GetLocations(orgID)
+	if err != nil {
+		return err
+	}
+	if loc.ID == 0 {
+		return LocationNotFoundErr
+	}
+	if err = ls.validate(loc); err != nil {
+		return err
+	}
+
+	defer s.forget(orgID)
+	return s.persister.UpdateLocation(orgID, loc, userID)
+}
+
+func (s *Service) DeleteLocation(orgID, locationID, userID int) error {
+	return s.persister.DeleteLocation(orgID, locationID, userID)
+}
+
+// FindLocation returns the location with the path, such as "Building A > Room 2".
+// Names are matched case insensitively.
+func (s *Service) FindLocation(orgID int, path string) (Location, error) {
+	ls, err := s.persister.GetLocations(orgID)
+	if err != nil {
+		return Location{}, err
+	}
+
+	l, ok := ls.path(path)
+	if !ok {
+		return Location{}, LocationNotFoundErr
+	}
+
+	return l, nil
+}
+
+// path returns the location with the path, if every part of it exists
+func (ls Locations) path(path string) (Location, bool) {
+	var l Location
+	found := false
+	for _, name := range strings.Split(path, ">") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if l, found = ls.child(l.ID, name); !found {
+			return Location{}, false
+		}
+	}
+
+	return l, found
+}
+
+// ResolveLocation returns the ID of the location with the path, such as
+// "Building A > Room 2", creating any part of it that does not exist yet.
+// Names are matched case insensitively.
+func (s *Service) ResolveLocation(orgID int, path string, userID int) (int, error) {
+	ls, err := s.persister.GetLocations(orgID)
+	if err != nil {
+		return 0, err
+	}
+
+	parentID := 0
+	for _, name := range strings.Split(path, ">") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if l, ok := ls.child(parentID, name); ok {
+			parentID = l.ID
+			continue
+		}
+
+		l, err := s.persister.AddLocation(orgID, Location{ParentID: parentID, Name: name}, userID)
+		if err != nil {
+			return 0, err
+		}
+		ls = append(ls, l)
+		parentID = l.ID
+	}
+
+	return parentID, nil
+}
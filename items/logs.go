@@ -0,0 +1,96 @@
+package items
+
+import (
+	"time"
+)
+
+// Kinds of objects that log entries are about
+const (
+	LogObjectItem     = "item"
+	LogObjectUser     = "user"
+	LogObjectRole     = "role"
+	LogObjectOrg      = "org"
+	LogObjectLocation = "location"
+	LogObjectCategory = "category"
+)
+
+// Paging of log entries
+const (
+	DefaultLogLimit = 50
+	MaxLogLimit     = 500
+)
+
+type LogEntries []LogEntry
+
+// LogEntry is one action that somebody performed
+type LogEntry struct {
+	ID         int                    `json:"id"`
+	UserID     int                    `json:"userId"`
+	Username   string                 `json:"username"`
+	ObjectType string                 `json:"objectType"`
+	ObjectID   string                 `json:"objectId"`
+	Action     string                 `json:"action"`
+	Details    map[string]interface{} `json:"details"`
+	Date       time.Time              `json:"date"`
+}
+
+// LogFilter narrows down the log entries returned by GetLogs. Blank fields
+// match everything.
+type LogFilter struct {
+	ObjectType string
+	ObjectID   string
+	Username   string
+	Action     string
+	From       time.Time
+	To         time.Time
+	// Page starts at 1
+	Page  int
+	Limit int
+}
+
+// LogPage is one page of log entries, newest first
+type LogPage struct {
+	Entries LogEntries `json:"entries"`
+	Page    int        `json:"page"`
+	Limit   int        `json:"limit"`
+	Total   int        `json:"total"`
+}
+
+// GetHistory returns what happened to the item, newest first
+func (s *Service) GetHistory(orgID int, itemID string, page, limit int) (LogPage, error) {
+	itemID, err := s.ResolveID(orgID, itemID)
+	if err != nil {
+		return LogPage{}, err
+	}
+
+	return s.GetLogs(orgID, LogFilter{
+		ObjectType: LogObjectItem,
+		ObjectID:   itemID,
+		Page:       page,
+		Limit:      limit,
+	})
+}
+
+// GetLogs returns a page of the org's log entries that match the filter
+func (s *Service) GetLogs(orgID int, filter LogFilter) (LogPage, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = DefaultLogLimit
+	} else if filter.Limit > MaxLogLimit {
+		filter.Limit = MaxLogLimit
+	}
+
+	entries, total, err := s.persister.GetLogs(orgID, filter)
+	if err != nil {
+		return LogPage{}, err
+	}
+
+	return LogPage{
+		Entries: entries,
+		Page:    filter.Page,
+		Limit:   filter.Limit,
+		Total:   total,
+	}, nil
+}
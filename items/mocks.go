@@ -6,6 +6,7 @@ package items
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,50 +35,319 @@ func (m *MockPersister) EXPECT() *MockPersisterMockRecorder {
 }
 
 // MoveItem mocks base method
-func (m *MockPersister) MoveItem(ID, direction string, userID int) error {
-	ret := m.ctrl.Call(m, "MoveItem", ID, direction, userID)
-	ret0, _ := ret[0].(error)
-	return ret0
+func (m *MockPersister) MoveItem(orgID int, ID, direction string, quantity, userID int) (int, error) {
+	ret := m.ctrl.Call(m, "MoveItem", orgID, ID, direction, quantity, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // MoveItem indicates an expected call of MoveItem
-func (mr *MockPersisterMockRecorder) MoveItem(ID, direction, userID interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveItem", reflect.TypeOf((*MockPersister)(nil).MoveItem), ID, direction, userID)
+func (mr *MockPersisterMockRecorder) MoveItem(orgID, ID, direction, quantity, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveItem", reflect.TypeOf((*MockPersister)(nil).MoveItem), orgID, ID, direction, quantity, userID)
 }
 
 // DeleteItem mocks base method
-func (m *MockPersister) DeleteItem(ID string, userID int) error {
-	ret := m.ctrl.Call(m, "DeleteItem", ID, userID)
+func (m *MockPersister) DeleteItem(orgID int, ID string, userID int) error {
+	ret := m.ctrl.Call(m, "DeleteItem", orgID, ID, userID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteItem indicates an expected call of DeleteItem
-func (mr *MockPersisterMockRecorder) DeleteItem(ID, userID interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteItem", reflect.TypeOf((*MockPersister)(nil).DeleteItem), ID, userID)
+func (mr *MockPersisterMockRecorder) DeleteItem(orgID, ID, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteItem", reflect.TypeOf((*MockPersister)(nil).DeleteItem), orgID, ID, userID)
 }
 
 // SearchItems mocks base method
-func (m *MockPersister) SearchItems(search string) (ItemDetailList, error) {
-	ret := m.ctrl.Call(m, "SearchItems", search)
+func (m *MockPersister) SearchItems(orgID int, filter ItemFilter) (ItemDetailList, int, error) {
+	ret := m.ctrl.Call(m, "SearchItems", orgID, filter)
 	ret0, _ := ret[0].(ItemDetailList)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// SearchItems indicates an expected call of SearchItems
+func (mr *MockPersisterMockRecorder) SearchItems(orgID, filter interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchItems", reflect.TypeOf((*MockPersister)(nil).SearchItems), orgID, filter)
+}
+
+// ExportItems mocks base method
+func (m *MockPersister) ExportItems(orgID int, filter ItemFilter, fn func(ExportedItem) error) error {
+	ret := m.ctrl.Call(m, "ExportItems", orgID, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportItems indicates an expected call of ExportItems
+func (mr *MockPersisterMockRecorder) ExportItems(orgID, filter, fn interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportItems", reflect.TypeOf((*MockPersister)(nil).ExportItems), orgID, filter, fn)
+}
+
+// GroupItems mocks base method
+func (m *MockPersister) GroupItems(orgID int, by string, filter ItemFilter) (ItemGroups, error) {
+	ret := m.ctrl.Call(m, "GroupItems", orgID, by, filter)
+	ret0, _ := ret[0].(ItemGroups)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// SearchItems indicates an expected call of SearchItems
-func (mr *MockPersisterMockRecorder) SearchItems(search interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchItems", reflect.TypeOf((*MockPersister)(nil).SearchItems), search)
+// GroupItems indicates an expected call of GroupItems
+func (mr *MockPersisterMockRecorder) GroupItems(orgID, by, filter interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GroupItems", reflect.TypeOf((*MockPersister)(nil).GroupItems), orgID, by, filter)
 }
 
 // AddItem mocks base method
-func (m *MockPersister) AddItem(obj ItemDetail, overwrite bool) error {
-	ret := m.ctrl.Call(m, "AddItem", obj, overwrite)
+func (m *MockPersister) AddItem(orgID int, obj ItemDetail, overwrite bool) error {
+	ret := m.ctrl.Call(m, "AddItem", orgID, obj, overwrite)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddItem indicates an expected call of AddItem
-func (mr *MockPersisterMockRecorder) AddItem(obj, overwrite interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddItem", reflect.TypeOf((*MockPersister)(nil).AddItem), obj, overwrite)
+func (mr *MockPersisterMockRecorder) AddItem(orgID, obj, overwrite interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddItem", reflect.TypeOf((*MockPersister)(nil).AddItem), orgID, obj, overwrite)
+}
+
+// NextItemNumber mocks base method
+func (m *MockPersister) NextItemNumber(orgID int) (int, error) {
+	ret := m.ctrl.Call(m, "NextItemNumber", orgID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NextItemNumber indicates an expected call of NextItemNumber
+func (mr *MockPersisterMockRecorder) NextItemNumber(orgID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextItemNumber", reflect.TypeOf((*MockPersister)(nil).NextItemNumber), orgID)
+}
+
+// AddLoan mocks base method
+func (m *MockPersister) AddLoan(orgID int, loan Loan, userID int) (Loan, error) {
+	ret := m.ctrl.Call(m, "AddLoan", orgID, loan, userID)
+	ret0, _ := ret[0].(Loan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddLoan indicates an expected call of AddLoan
+func (mr *MockPersisterMockRecorder) AddLoan(orgID, loan, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLoan", reflect.TypeOf((*MockPersister)(nil).AddLoan), orgID, loan, userID)
+}
+
+// GetLoan mocks base method
+func (m *MockPersister) GetLoan(orgID, loanID int) (Loan, error) {
+	ret := m.ctrl.Call(m, "GetLoan", orgID, loanID)
+	ret0, _ := ret[0].(Loan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoan indicates an expected call of GetLoan
+func (mr *MockPersisterMockRecorder) GetLoan(orgID, loanID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoan", reflect.TypeOf((*MockPersister)(nil).GetLoan), orgID, loanID)
+}
+
+// GetLoans mocks base method
+func (m *MockPersister) GetLoans(orgID int, filter LoanFilter) (Loans, error) {
+	ret := m.ctrl.Call(m, "GetLoans", orgID, filter)
+	ret0, _ := ret[0].(Loans)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoans indicates an expected call of GetLoans
+func (mr *MockPersisterMockRecorder) GetLoans(orgID, filter interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoans", reflect.TypeOf((*MockPersister)(nil).GetLoans), orgID, filter)
+}
+
+// ReturnLoans mocks base method
+func (m *MockPersister) ReturnLoans(orgID int, itemID string, loanID, quantity int) error {
+	ret := m.ctrl.Call(m, "ReturnLoans", orgID, itemID, loanID, quantity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReturnLoans indicates an expected call of ReturnLoans
+func (mr *MockPersisterMockRecorder) ReturnLoans(orgID, itemID, loanID, quantity interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReturnLoans", reflect.TypeOf((*MockPersister)(nil).ReturnLoans), orgID, itemID, loanID, quantity)
+}
+
+// GetLogs mocks base method
+func (m *MockPersister) GetLogs(orgID int, filter LogFilter) (LogEntries, int, error) {
+	ret := m.ctrl.Call(m, "GetLogs", orgID, filter)
+	ret0, _ := ret[0].(LogEntries)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetLogs indicates an expected call of GetLogs
+func (mr *MockPersisterMockRecorder) GetLogs(orgID, filter interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogs", reflect.TypeOf((*MockPersister)(nil).GetLogs), orgID, filter)
+}
+
+// GetDeletedItems mocks base method
+func (m *MockPersister) GetDeletedItems(orgID int) (DeletedItems, error) {
+	ret := m.ctrl.Call(m, "GetDeletedItems", orgID)
+	ret0, _ := ret[0].(DeletedItems)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeletedItems indicates an expected call of GetDeletedItems
+func (mr *MockPersisterMockRecorder) GetDeletedItems(orgID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeletedItems", reflect.TypeOf((*MockPersister)(nil).GetDeletedItems), orgID)
+}
+
+// RestoreItem mocks base method
+func (m *MockPersister) RestoreItem(orgID int, ID string, userID int) error {
+	ret := m.ctrl.Call(m, "RestoreItem", orgID, ID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreItem indicates an expected call of RestoreItem
+func (mr *MockPersisterMockRecorder) RestoreItem(orgID, ID, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreItem", reflect.TypeOf((*MockPersister)(nil).RestoreItem), orgID, ID, userID)
+}
+
+// PurgeItem mocks base method
+func (m *MockPersister) PurgeItem(orgID int, ID string, userID int) ([]string, error) {
+	ret := m.ctrl.Call(m, "PurgeItem", orgID, ID, userID)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeItem indicates an expected call of PurgeItem
+func (mr *MockPersisterMockRecorder) PurgeItem(orgID, ID, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeItem", reflect.TypeOf((*MockPersister)(nil).PurgeItem), orgID, ID, userID)
+}
+
+// PurgeItems mocks base method
+func (m *MockPersister) PurgeItems(orgID int, deletedBefore time.Time, userID int) (int, []string, error) {
+	ret := m.ctrl.Call(m, "PurgeItems", orgID, deletedBefore, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].([]string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// PurgeItems indicates an expected call of PurgeItems
+func (mr *MockPersisterMockRecorder) PurgeItems(orgID, deletedBefore, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeItems", reflect.TypeOf((*MockPersister)(nil).PurgeItems), orgID, deletedBefore, userID)
+}
+
+// GetLocations mocks base method
+func (m *MockPersister) GetLocations(orgID int) (Locations, error) {
+	ret := m.ctrl.Call(m, "GetLocations", orgID)
+	ret0, _ := ret[0].(Locations)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLocations indicates an expected call of GetLocations
+func (mr *MockPersisterMockRecorder) GetLocations(orgID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLocations", reflect.TypeOf((*MockPersister)(nil).GetLocations), orgID)
+}
+
+// AddLocation mocks base method
+func (m *MockPersister) AddLocation(orgID int, loc Location, userID int) (Location, error) {
+	ret := m.ctrl.Call(m, "AddLocation", orgID, loc, userID)
+	ret0, _ := ret[0].(Location)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddLocation indicates an expected call of AddLocation
+func (mr *MockPersisterMockRecorder) AddLocation(orgID, loc, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLocation", reflect.TypeOf((*MockPersister)(nil).AddLocation), orgID, loc, userID)
+}
+
+// UpdateLocation mocks base method
+func (m *MockPersister) UpdateLocation(orgID int, loc Location, userID int) error {
+	ret := m.ctrl.Call(m, "UpdateLocation", orgID, loc, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateLocation indicates an expected call of UpdateLocation
+func (mr *MockPersisterMockRecorder) UpdateLocation(orgID, loc, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLocation", reflect.TypeOf((*MockPersister)(nil).UpdateLocation), orgID, loc, userID)
+}
+
+// DeleteLocation mocks base method
+func (m *MockPersister) DeleteLocation(orgID, locationID, userID int) error {
+	ret := m.ctrl.Call(m, "DeleteLocation", orgID, locationID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLocation indicates an expected call of DeleteLocation
+func (mr *MockPersisterMockRecorder) DeleteLocation(orgID, locationID, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLocation", reflect.TypeOf((*MockPersister)(nil).DeleteLocation), orgID, locationID, userID)
+}
+
+// GetCategories mocks base method
+func (m *MockPersister) GetCategories(orgID int) (Categories, error) {
+	ret := m.ctrl.Call(m, "GetCategories", orgID)
+	ret0, _ := ret[0].(Categories)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCategories indicates an expected call of GetCategories
+func (mr *MockPersisterMockRecorder) GetCategories(orgID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCategories", reflect.TypeOf((*MockPersister)(nil).GetCategories), orgID)
+}
+
+// AddCategory mocks base method
+func (m *MockPersister) AddCategory(orgID int, c Category, userID int) (Category, error) {
+	ret := m.ctrl.Call(m, "AddCategory", orgID, c, userID)
+	ret0, _ := ret[0].(Category)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddCategory indicates an expected call of AddCategory
+func (mr *MockPersisterMockRecorder) AddCategory(orgID, c, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCategory", reflect.TypeOf((*MockPersister)(nil).AddCategory), orgID, c, userID)
+}
+
+// UpdateCategory mocks base method
+func (m *MockPersister) UpdateCategory(orgID int, c Category, userID int) error {
+	ret := m.ctrl.Call(m, "UpdateCategory", orgID, c, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateCategory indicates an expected call of UpdateCategory
+func (mr *MockPersisterMockRecorder) UpdateCategory(orgID, c, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCategory", reflect.TypeOf((*MockPersister)(nil).UpdateCategory), orgID, c, userID)
+}
+
+// DeleteCategory mocks base method
+func (m *MockPersister) DeleteCategory(orgID, categoryID, userID int) error {
+	ret := m.ctrl.Call(m, "DeleteCategory", orgID, categoryID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCategory indicates an expected call of DeleteCategory
+func (mr *MockPersisterMockRecorder) DeleteCategory(orgID, categoryID, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCategory", reflect.TypeOf((*MockPersister)(nil).DeleteCategory), orgID, categoryID, userID)
+}
+
+// Transaction mocks base method
+func (m *MockPersister) Transaction(fn func(Persister) error) error {
+	ret := m.ctrl.Call(m, "Transaction", fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Transaction indicates an expected call of Transaction
+func (mr *MockPersisterMockRecorder) Transaction(fn interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transaction", reflect.TypeOf((*MockPersister)(nil).Transaction), fn)
 }
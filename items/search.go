@@ -0,0 +1,487 @@
+package items
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/Timothylock/inventory-management/barcode"
+)
+
+// Keys that items can be sorted by
+const (
+	SortID        = "id"
+	SortName      = "name"
+	SortCategory  = "category"
+	SortQuantity  = "quantity"
+	SortAvailable = "available"
+	SortStatus    = "status"
+	// SortRelevance puts the best matches of the search first
+	SortRelevance = "relevance"
+)
+
+// Paging of items
+const (
+	DefaultItemLimit = 50
+	MaxItemLimit     = 500
+)
+
+// What items can be grouped by in a summary
+const (
+	GroupByCategory = "category"
+	GroupByLocation = "location"
+	GroupByStatus   = "status"
+)
+
+var InvalidSortErr = errors.New("must be one of id, name, category, quantity, available or status, optionally prefixed with -, or relevance when searching")
+var InvalidStatusErr = errors.New("must be one of checked in, checked out or partially checked out")
+var InvalidCursorErr = errors.New("cursor is not valid for this search")
+var InvalidGroupErr = errors.New("must be one of category, location or status")
+
+// ItemFilter narrows down and orders the items returned by SearchItems. Blank
+// fields match everything.
+type ItemFilter struct {
+	// Search matches the text of the item, its custom fields or the name of
+	// its category or location
+	Search string
+	// SearchIDs are items that match Search along with those matching its
+	// text, such as the forms of a barcode being searched for
+	SearchIDs  []string
+	Status     string
+	CategoryID int
+	// LocationID matches items in the location or anywhere under it
+	LocationID int
+	// QuantityBelow matches items with fewer than this many in total
+	QuantityBelow int
+	// IDs matches only these items when it is not nil
+	IDs []string
+	// Deleted also matches items in the trash
+	Deleted    bool
+	SortBy     string
+	Descending bool
+	// After continues from the end of a previous page
+	After *ItemCursor
+	Limit int
+}
+
+// sort returns how the filter sorts, such as "-quantity"
+func (f ItemFilter) sort() string {
+	if f.Descending {
+		return "-" + f.SortBy
+	}
+	return f.SortBy
+}
+
+// cursorAfter returns the cursor of the page that starts after the item
+func (f ItemFilter) cursorAfter(item ItemDetail) ItemCursor {
+	c := ItemCursor{Sort: f.sort(), ID: item.ID}
+
+	switch f.SortBy {
+	case SortName:
+		c.Value = item.Name
+	case SortCategory:
+		c.Value = item.Category
+	case SortQuantity:
+		c.Value = item.Quantity
+	case SortAvailable:
+		c.Value = item.Available
+	case SortStatus:
+		c.Value = item.Status
+	}
+
+	return c
+}
+
+// ItemCursor marks where a page of items ends. The next page starts after the
+// item with the sort value and ID.
+type ItemCursor struct {
+	Sort  string      `json:"s"`
+	Value interface{} `json:"v"`
+	ID    string      `json:"id"`
+}
+
+// String returns the cursor in the opaque form given to clients
+func (c ItemCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseCursor reads a cursor returned by ItemCursor.String
+func ParseCursor(s string) (*ItemCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, InvalidCursorErr
+	}
+
+	c := ItemCursor{}
+	if err = json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return nil, InvalidCursorErr
+	}
+
+	return &c, nil
+}
+
+// ParseSort reads a sort key that is prefixed with - to sort descending
+func ParseSort(s string) (string, bool, error) {
+	desc := strings.HasPrefix(s, "-")
+	key := strings.ToLower(strings.TrimPrefix(s, "-"))
+
+	switch key {
+	case SortID, SortName, SortCategory, SortQuantity, SortAvailable, SortStatus:
+		return key, desc, nil
+	case SortRelevance:
+		if !desc {
+			return key, desc, nil
+		}
+	}
+
+	return "", false, InvalidSortErr
+}
+
+// validStatus returns whether items can be filtered by the status, which may
+// be blank to match every status
+func validStatus(status string) bool {
+	switch status {
+	case "", StatusCheckedIn, StatusCheckedOut, StatusPartiallyCheckedOut:
+		return true
+	}
+	return false
+}
+
+// Searcher is an index of the items of every org that finds the items matching
+// a search
+type Searcher interface {
+	// Search returns the items matching the query, best match first. The items
+	// of an org are read with load the first time it is searched.
+	Search(orgID int, query string, load func() (ItemDetailList, error)) (SearchHits, error)
+	// Forget drops the org so that it is loaded again on the next search
+	Forget(orgID int)
+}
+
+type SearchHits []SearchHit
+
+// SearchHit is an item matching a search. Higher scores are better matches.
+type SearchHit struct {
+	ID    string
+	Score float64
+}
+
+// ItemPage is one page of the items matching a filter
+type ItemPage struct {
+	Items ItemDetailList `json:"items"`
+	// Total is how many items match the filter across every page
+	Total int `json:"total"`
+	// NextCursor fetches the next page and is blank on the last page
+	NextCursor string `json:"nextCursor"`
+}
+
+// FetchItems returns a page of the org's items that match the filter. Searches
+// made with the index are sorted by relevance and everything else by name
+// unless the filter says otherwise.
+func (s *Service) FetchItems(orgID int, filter ItemFilter) (ItemPage, error) {
+	hits, err := s.search(orgID, &filter)
+	if err != nil {
+		return ItemPage{}, err
+	}
+
+	if filter.SortBy == "" {
+		filter.SortBy = SortName
+		if hits != nil {
+			filter.SortBy = SortRelevance
+		}
+	}
+	if _, _, err := ParseSort(filter.sort()); err != nil {
+		return ItemPage{}, err
+	}
+	if filter.SortBy == SortRelevance && hits == nil {
+		return ItemPage{}, InvalidSortErr
+	}
+
+	if !validStatus(filter.Status) {
+		return ItemPage{}, InvalidStatusErr
+	}
+
+	if filter.After != nil && filter.After.Sort != filter.sort() {
+		return ItemPage{}, InvalidCursorErr
+	}
+
+	limit := filter.Limit
+	if limit < 1 {
+		limit = DefaultItemLimit
+	} else if limit > MaxItemLimit {
+		limit = MaxItemLimit
+	}
+
+	if filter.SortBy == SortRelevance {
+		return s.pageByRelevance(orgID, filter, hits, limit)
+	}
+
+	// Fetching one more tells whether there is another page
+	filter.Limit = limit + 1
+
+	list, total, err := s.persister.SearchItems(orgID, filter)
+	if err != nil {
+		return ItemPage{}, err
+	}
+
+	page := ItemPage{Items: list, Total: total}
+	if len(list) > limit {
+		page.Items = list[:limit]
+		page.NextCursor = filter.cursorAfter(list[limit-1]).String()
+	}
+
+	return page, nil
+}
+
+// maxBoundIDs is the most item IDs that are looked up in one query, which
+// keeps under the limit databases put on bound variables
+const maxBoundIDs = 500
+
+// pageByRelevance returns the page of items matching the filter when they are
+// ranked by how well they match the search. Items with the same score are
+// ordered by ID. The hits are ranked and paged in memory so that only the IDs
+// of the page, and of any hits the other filters leave out, are looked up.
+func (s *Service) pageByRelevance(orgID int, filter ItemFilter, hits SearchHits, limit int) (ItemPage, error) {
+	var after float64
+	if filter.After != nil {
+		v, ok := filter.After.Value.(float64)
+		if !ok {
+			return ItemPage{}, InvalidCursorErr
+		}
+		after = v
+	}
+
+	ranked := append(SearchHits{}, hits...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+
+	start := 0
+	if a := filter.After; a != nil {
+		for start < len(ranked) {
+			h := ranked[start]
+			if h.Score < after || (h.Score == after && h.ID > a.ID) {
+				break
+			}
+			start++
+		}
+	}
+
+	query := filter
+	query.SortBy, query.Descending, query.After, query.Limit = SortID, false, nil, 0
+
+	// Fetching one more tells whether there is another page. The batches grow
+	// in case the other filters leave out many of the hits.
+	page := ItemPage{Items: ItemDetailList{}}
+	found := []SearchHit{}
+	batch := limit + 1
+	for next := start; next < len(ranked) && len(page.Items) <= limit; {
+		end := next + batch
+		if end > len(ranked) {
+			end = len(ranked)
+		}
+
+		list, err := s.findHits(orgID, query, ranked[next:end])
+		if err != nil {
+			return ItemPage{}, err
+		}
+		for _, h := range ranked[next:end] {
+			if item, ok := list[h.ID]; ok && len(page.Items) <= limit {
+				page.Items = append(page.Items, item)
+				found = append(found, h)
+			}
+		}
+
+		next = end
+		if batch *= 2; batch > maxBoundIDs {
+			batch = maxBoundIDs
+		}
+	}
+
+	total, err := s.countHits(orgID, query, ranked)
+	if err != nil {
+		return ItemPage{}, err
+	}
+	page.Total = total
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		last := found[limit-1]
+		page.NextCursor = ItemCursor{Sort: SortRelevance, Value: last.Score, ID: last.ID}.String()
+	}
+
+	return page, nil
+}
+
+// findHits returns the items of the hits that match the rest of the filter by
+// their ID
+func (s *Service) findHits(orgID int, filter ItemFilter, hits SearchHits) (map[string]ItemDetail, error) {
+	filter.IDs = make([]string, len(hits))
+	for i, h := range hits {
+		filter.IDs[i] = h.ID
+	}
+
+	list, _, err := s.persister.SearchItems(orgID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]ItemDetail, len(list))
+	for _, item := range list {
+		byID[item.ID] = item
+	}
+	return byID, nil
+}
+
+// countHits returns how many of the hits match the rest of the filter. Every
+// hit does when nothing else is filtered on.
+func (s *Service) countHits(orgID int, filter ItemFilter, hits SearchHits) (int, error) {
+	if filter.Status == "" && filter.CategoryID == 0 && filter.LocationID == 0 && filter.QuantityBelow == 0 {
+		return len(hits), nil
+	}
+
+	total := 0
+	filter.Limit = 1
+	for start := 0; start < len(hits); start += maxBoundIDs {
+		end := start + maxBoundIDs
+		if end > len(hits) {
+			end = len(hits)
+		}
+
+		filter.IDs = make([]string, 0, end-start)
+		for _, h := range hits[start:end] {
+			filter.IDs = append(filter.IDs, h.ID)
+		}
+
+		_, n, err := s.persister.SearchItems(orgID, filter)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+
+	return total, nil
+}
+
+// search looks up the text of the filter in the index and replaces it with the
+// IDs of the items that match. It returns nil when there is no search or no
+// index, in which case the database searches the text itself. Searching for a
+// barcode also matches the items stored under any form of it, ranked above
+// the items that only match its text.
+func (s *Service) search(orgID int, filter *ItemFilter) (SearchHits, error) {
+	var forms []string
+	if b, err := barcode.Parse(filter.Search); err == nil {
+		forms = b.Forms()
+	}
+
+	if s.searcher == nil || strings.TrimSpace(filter.Search) == "" {
+		filter.SearchIDs = forms
+		return nil, nil
+	}
+
+	hits, err := s.searcher.Search(orgID, filter.Search, func() (ItemDetailList, error) {
+		list, _, err := s.persister.SearchItems(orgID, ItemFilter{SortBy: SortID})
+		return list, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if hits == nil {
+		hits = SearchHits{}
+	}
+
+	if forms != nil {
+		if hits, err = s.addBarcodeHits(orgID, hits, forms); err != nil {
+			return nil, err
+		}
+	}
+
+	filter.Search = ""
+	filter.IDs = make([]string, len(hits))
+	for i, h := range hits {
+		filter.IDs[i] = h.ID
+	}
+
+	return hits, nil
+}
+
+// addBarcodeHits adds the items stored under any of the forms of a barcode to
+// the hits of the index, scored above every other hit
+func (s *Service) addBarcodeHits(orgID int, hits SearchHits, forms []string) (SearchHits, error) {
+	list, _, err := s.persister.SearchItems(orgID, ItemFilter{IDs: forms, SortBy: SortID})
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return hits, nil
+	}
+
+	top := 0.0
+	for _, h := range hits {
+		if h.Score > top {
+			top = h.Score
+		}
+	}
+
+	ret := SearchHits{}
+	found := map[string]bool{}
+	for _, item := range list {
+		ret = append(ret, SearchHit{ID: item.ID, Score: top + 1})
+		found[item.ID] = true
+	}
+	for _, h := range hits {
+		if !found[h.ID] {
+			ret = append(ret, h)
+		}
+	}
+	return ret, nil
+}
+
+// forget drops the org from the index after its items change so that it is
+// loaded again on the next search
+func (s *Service) forget(orgID int) {
+	if s.searcher != nil {
+		s.searcher.Forget(orgID)
+	}
+}
+
+type ItemGroups []ItemGroup
+
+// ItemGroup counts the items that share a category, location or status
+type ItemGroup struct {
+	// ID is the ID of the category or location, and is 0 for items without
+	// one or when grouping by status
+	ID int `json:"id"`
+	// Name is the name of the category, the path of the location or the status
+	Name string `json:"name"`
+	// Items is how many different items are in the group
+	Items int `json:"items"`
+	// Quantity is how many there are of all the items in the group together
+	Quantity int `json:"quantity"`
+}
+
+// SummarizeItems counts the org's items that match the filter by category,
+// location or status. Items are only counted in the location they are directly
+// in. Sorting and paging of the filter are ignored.
+func (s *Service) SummarizeItems(orgID int, by string, filter ItemFilter) (ItemGroups, error) {
+	switch by {
+	case GroupByCategory, GroupByLocation, GroupByStatus:
+	default:
+		return nil, InvalidGroupErr
+	}
+
+	if !validStatus(filter.Status) {
+		return nil, InvalidStatusErr
+	}
+
+	if _, err := s.search(orgID, &filter); err != nil {
+		return nil, err
+	}
+
+	return s.persister.GroupItems(orgID, by, filter)
+}
@@ -0,0 +1,74 @@
+package items
+
+import (
+	"log"
+	"time"
+)
+
+// DefaultTrashRetention is used when TRASH_RETENTION is not configured
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+type DeletedItems []DeletedItem
+
+// DeletedItem is an item in the trash. LastPerformedBy is who deleted it.
+type DeletedItem struct {
+	ItemDetail
+	DeletedAt time.Time `db:"DELETED_AT"`
+}
+
+// GetTrash returns the deleted items that have not been purged yet, most
+// recently deleted first
+func (s *Service) GetTrash(orgID int) (DeletedItems, error) {
+	return s.persister.GetDeletedItems(orgID)
+}
+
+func (s *Service) RestoreItem(orgID int, ID string, userID int) error {
+	ID, err := s.ResolveID(orgID, ID)
+	if err != nil {
+		return err
+	}
+
+	defer s.forget(orgID)
+	return s.persister.RestoreItem(orgID, ID, userID)
+}
+
+func (s *Service) PurgeItem(orgID int, ID string, userID int) error {
+	ID, err := s.ResolveID(orgID, ID)
+	if err != nil {
+		return err
+	}
+
+	keys, err := s.persister.PurgeItem(orgID, ID, userID)
+	if err != nil {
+		return err
+	}
+
+	s.deleteFiles(keys)
+	return nil
+}
+
+// PurgeTrash permanently removes the items that have been in the trash for
+// longer than the retention and returns how many there were
+func (s *Service) PurgeTrash(orgID int, userID int) (int, error) {
+	n, keys, err := s.persister.PurgeItems(orgID, time.Now().UTC().Add(-s.trashRetention), userID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.deleteFiles(keys)
+	return n, nil
+}
+
+// deleteFiles deletes the files of purged attachments. The rows are already
+// gone so a file that cannot be deleted is only logged.
+func (s *Service) deleteFiles(keys []string) {
+	if s.storage == nil {
+		return
+	}
+
+	for _, key := range keys {
+		if err := s.storage.Delete(key); err != nil {
+			log.Printf("error deleting attachment file %s: %s", key, err.Error())
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package labels
+
+import (
+	"errors"
+	"image/color"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+)
+
+var (
+	InvalidCode128Err = errors.New("code 128 can only hold up to 80 printable ASCII characters")
+	QRTooLongErr      = errors.New("too much data for a QR code")
+)
+
+// Quiet zones are how many empty modules are needed around the codes
+const (
+	quietZone128 = 10
+	quietZoneQR  = 4
+)
+
+// Code128 returns the modules of the barcode for the data, true for bars,
+// including the quiet zone on either side
+func Code128(data string) ([]bool, error) {
+	// The encoder also takes its own control characters, which IDs never
+	// have
+	for i := 0; i < len(data); i++ {
+		if data[i] < ' ' || data[i] > '~' {
+			return nil, InvalidCode128Err
+		}
+	}
+
+	code, err := code128.Encode(data)
+	if err != nil {
+		return nil, InvalidCode128Err
+	}
+
+	width := code.Bounds().Dx()
+	modules := make([]bool, width+2*quietZone128)
+	for x := 0; x < width; x++ {
+		modules[quietZone128+x] = dark(code, x, 0)
+	}
+
+	return modules, nil
+}
+
+// QR returns the modules of the smallest QR code that holds the data at error
+// correction level M, true for dark modules, including the quiet zone
+func QR(data string) ([][]bool, error) {
+	code, err := qr.Encode(data, qr.M, qr.Auto)
+	if err != nil {
+		return nil, QRTooLongErr
+	}
+
+	size := code.Bounds().Dx()
+	modules := make([][]bool, size+2*quietZoneQR)
+	for y := range modules {
+		modules[y] = make([]bool, size+2*quietZoneQR)
+		if y < quietZoneQR || y >= size+quietZoneQR {
+			continue
+		}
+		for x := 0; x < size; x++ {
+			modules[y][quietZoneQR+x] = dark(code, x, y-quietZoneQR)
+		}
+	}
+
+	return modules, nil
+}
+
+// dark is whether the module of the code is a bar rather than a space
+func dark(code barcode.Barcode, x, y int) bool {
+	return color.GrayModel.Convert(code.At(x, y)).(color.Gray).Y < 0x80
+}
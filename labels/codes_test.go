@@ -0,0 +1,103 @@
+package labels
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bars returns the widths of the runs of bars and spaces between the quiet
+// zones, starting with a bar
+func bars(modules []bool) []int {
+	modules = modules[quietZone128 : len(modules)-quietZone128]
+
+	widths := []int{}
+	for i := 0; i < len(modules); {
+		start := i
+		for i < len(modules) && modules[i] == modules[start] {
+			i++
+		}
+		widths = append(widths, i-start)
+	}
+	return widths
+}
+
+func TestCode128(t *testing.T) {
+	type testCase struct {
+		testName string
+		data     string
+		// symbols is how many symbols there are before the stop
+		symbols int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "letters take a symbol each",
+			data:     "PJJ123C",
+			symbols:  9,
+		},
+		{
+			testName: "digits are packed two to a symbol",
+			data:     "012345",
+			symbols:  5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			modules, err := Code128(tc.data)
+			assert.Nil(t, err)
+			assert.Equal(t, 11*tc.symbols+13+2*quietZone128, len(modules))
+
+			for _, m := range modules[:quietZone128] {
+				assert.False(t, m)
+			}
+			for _, m := range modules[len(modules)-quietZone128:] {
+				assert.False(t, m)
+			}
+
+			// The stop pattern ends with a bar two modules wide
+			widths := bars(modules)
+			assert.True(t, modules[quietZone128])
+			assert.Equal(t, 2, widths[len(widths)-1])
+		})
+	}
+}
+
+func TestCode128Invalid(t *testing.T) {
+	for _, data := range []string{"", "tab\there", "café", strings.Repeat("\x00", 2), strings.Repeat("A", 81)} {
+		_, err := Code128(data)
+		assert.Equal(t, InvalidCode128Err, err, data)
+	}
+}
+
+func TestQR(t *testing.T) {
+	modules, err := QR("INV000042")
+	assert.Nil(t, err)
+
+	// Version 1 is 21 modules across
+	size := 21 + 2*quietZoneQR
+	assert.Equal(t, size, len(modules))
+	for _, row := range modules {
+		assert.Equal(t, size, len(row))
+	}
+
+	for i := 0; i < size; i++ {
+		assert.False(t, modules[0][i])
+		assert.False(t, modules[i][0])
+		assert.False(t, modules[size-1][i])
+		assert.False(t, modules[i][size-1])
+	}
+
+	// The finders have a dark border around a light ring and dark centre
+	for _, corner := range [][2]int{{0, 0}, {14, 0}, {0, 14}} {
+		x, y := quietZoneQR+corner[0], quietZoneQR+corner[1]
+		assert.True(t, modules[y][x])
+		assert.False(t, modules[y+1][x+1])
+		assert.True(t, modules[y+3][x+3])
+	}
+
+	_, err = QR(strings.Repeat("é", 3000))
+	assert.Equal(t, QRTooLongErr, err)
+}
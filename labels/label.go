@@ -0,0 +1,166 @@
+package labels
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kinds of code a label can have
+const (
+	Code128Type = "code128"
+	QRType      = "qr"
+)
+
+var InvalidTypeErr = errors.New("must be one of code128 or qr")
+
+// Label is what is printed on one label
+type Label struct {
+	// Code is what the barcode holds, which is the ID of the item
+	Code string
+	// Text is printed next to or under the code, such as the name of the item
+	Text string
+	// Type is Code128Type or QRType
+	Type string
+}
+
+// Drawing is a page made of black rectangles and lines of text. Everything is
+// measured in points from the top left corner.
+type Drawing struct {
+	Width, Height float64
+	Rects         []Rect
+	Texts         []Text
+}
+
+type Rect struct {
+	X, Y, W, H float64
+}
+
+// Text is a line of text in a monospaced font whose characters are 0.6 of the
+// size wide. Y is the baseline.
+type Text struct {
+	X, Y, Size float64
+	S          string
+}
+
+// charWidth is how wide a character of the font is for each point of size
+const charWidth = 0.6
+
+// Width is how wide the text is
+func (t Text) Width() float64 {
+	return float64(utf8.RuneCountInString(t.S)) * t.Size * charWidth
+}
+
+// Render returns a drawing of the label on its own, the size of the label
+func Render(l Label, width, height float64) (*Drawing, error) {
+	d := &Drawing{Width: width, Height: height}
+	if err := d.draw(l, 0, 0, width, height); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// draw lays out the label in the box. Codes are drawn as large as they fit
+// with the text taking what is left.
+func (d *Drawing) draw(l Label, x, y, w, h float64) error {
+	pad := w
+	if h < w {
+		pad = h
+	}
+	pad *= 0.08
+	x, y, w, h = x+pad, y+pad, w-2*pad, h-2*pad
+
+	size := h * 0.16
+	if size < 5 {
+		size = 5
+	} else if size > 12 {
+		size = 12
+	}
+
+	switch l.Type {
+	case Code128Type:
+		modules, err := Code128(l.Code)
+		if err != nil {
+			return err
+		}
+
+		// The ID is printed under the bars and the text under that
+		barsHeight := h - 2.2*size
+		module := w / float64(len(modules))
+		for i := 0; i < len(modules); {
+			if !modules[i] {
+				i++
+				continue
+			}
+			start := i
+			for i < len(modules) && modules[i] {
+				i++
+			}
+			d.Rects = append(d.Rects, Rect{x + float64(start)*module, y, float64(i-start) * module, barsHeight})
+		}
+
+		d.centredText(l.Code, x, y+barsHeight+size, w, size*0.9)
+		d.centredText(l.Text, x, y+h-size*0.2, w, size)
+
+	case QRType:
+		modules, err := QR(l.Code)
+		if err != nil {
+			return err
+		}
+
+		// The code is square on the left and the text is to the right of it
+		side := h
+		if side > w*0.45 {
+			side = w * 0.45
+		}
+		module := side / float64(len(modules))
+		for row, line := range modules {
+			for col := 0; col < len(line); {
+				if !line[col] {
+					col++
+					continue
+				}
+				start := col
+				for col < len(line) && line[col] {
+					col++
+				}
+				d.Rects = append(d.Rects, Rect{x + float64(start)*module, y + float64(row)*module, float64(col-start) * module, module})
+			}
+		}
+
+		tx := x + side + pad
+		tw := w - side - pad
+		middle := y + h/2
+		d.Texts = append(d.Texts, fitText(l.Text, tx, middle-size*0.2, tw, size))
+		d.Texts = append(d.Texts, fitText(l.Code, tx, middle+size*1.1, tw, size*0.9))
+
+	default:
+		return InvalidTypeErr
+	}
+
+	return nil
+}
+
+// centredText adds the text centred in the width, shortened to fit
+func (d *Drawing) centredText(s string, x, y, w, size float64) {
+	t := fitText(s, x, y, w, size)
+	t.X += (w - t.Width()) / 2
+	d.Texts = append(d.Texts, t)
+}
+
+// fitText returns the text at the position, ending in "..." if it is too long
+// for the width
+func fitText(s string, x, y, w, size float64) Text {
+	s = strings.Join(strings.Fields(s), " ")
+	max := int(w / (size * charWidth))
+	if r := []rune(s); len(r) > max {
+		if max > 3 {
+			s = string(r[:max-3]) + "..."
+		} else if max > 0 {
+			s = string(r[:max])
+		} else {
+			s = ""
+		}
+	}
+	return Text{X: x, Y: y, Size: size, S: s}
+}
@@ -0,0 +1,123 @@
+package labels
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	for _, typ := range []string{Code128Type, QRType} {
+		t.Run(typ, func(t *testing.T) {
+			d, err := Render(Label{Code: "INV000042", Text: "Extension cord", Type: typ}, 189, 72)
+			assert.Nil(t, err)
+			assert.NotEmpty(t, d.Rects)
+			for _, r := range d.Rects {
+				assert.True(t, r.X >= 0 && r.Y >= 0 && r.X+r.W <= 189 && r.Y+r.H <= 72, "%+v is outside the label", r)
+			}
+			texts := []string{}
+			for _, tx := range d.Texts {
+				texts = append(texts, tx.S)
+				assert.True(t, tx.X >= 0 && tx.X+tx.Width() <= 189, "%+v is outside the label", tx)
+			}
+			assert.ElementsMatch(t, []string{"INV000042", "Extension cord"}, texts)
+		})
+	}
+
+	_, err := Render(Label{Code: "1", Type: "ean"}, 189, 72)
+	assert.Equal(t, InvalidTypeErr, err)
+}
+
+func TestFitText(t *testing.T) {
+	assert.Equal(t, "Short", fitText("Short", 0, 0, 60, 10).S)
+	assert.Equal(t, "A very...", fitText("A  very\tlong name", 0, 0, 54, 10).S)
+	assert.Equal(t, "", fitText("Name", 0, 0, 5, 10).S)
+}
+
+func TestWriteSVG(t *testing.T) {
+	d, err := Render(Label{Code: "INV000042", Text: "Pots & pans", Type: Code128Type}, 189, 72)
+	assert.Nil(t, err)
+
+	b := &bytes.Buffer{}
+	assert.Nil(t, d.WriteSVG(b))
+
+	var svg struct {
+		Width string   `xml:"width,attr"`
+		Texts []string `xml:"text"`
+	}
+	assert.Nil(t, xml.Unmarshal(b.Bytes(), &svg))
+	assert.Equal(t, "189pt", svg.Width)
+	assert.Equal(t, []string{"INV000042", "Pots & pans"}, svg.Texts)
+}
+
+func TestWritePNG(t *testing.T) {
+	d, err := Render(Label{Code: "INV000042", Text: "Café", Type: QRType}, 189, 72)
+	assert.Nil(t, err)
+
+	b := &bytes.Buffer{}
+	assert.Nil(t, d.WritePNG(b, 144))
+
+	img, err := png.Decode(b)
+	assert.Nil(t, err)
+	assert.Equal(t, 378, img.Bounds().Dx())
+	assert.Equal(t, 144, img.Bounds().Dy())
+
+	// The quiet zone is left white and the first finder is black
+	r, _, _, _ := img.At(2, 2).RGBA()
+	assert.Equal(t, uint32(0xFFFF), r)
+	first := d.Rects[0]
+	r, _, _, _ = img.At(int(first.X*2)+1, int(first.Y*2)+1).RGBA()
+	assert.Equal(t, uint32(0), r)
+}
+
+func TestWritePDF(t *testing.T) {
+	d, err := Render(Label{Code: "INV000042", Text: "Cable (HDMI)", Type: Code128Type}, 189, 72)
+	assert.Nil(t, err)
+
+	b := &bytes.Buffer{}
+	assert.Nil(t, WritePDF(b, []*Drawing{d, d}))
+	pdf := b.String()
+
+	assert.True(t, strings.HasPrefix(pdf, "%PDF-1."))
+	assert.True(t, strings.HasSuffix(pdf, "%%EOF\n"))
+	assert.Contains(t, pdf, "/Count 2")
+	assert.Contains(t, pdf, "/BaseFont /Courier")
+	assert.Equal(t, 2, strings.Count(pdf, "/MediaBox [0 0 189.00 72.00]"))
+}
+
+func TestSheets(t *testing.T) {
+	tmpl, err := AveryTemplates.Find("l7160")
+	assert.Nil(t, err)
+	assert.Equal(t, "L7160", tmpl.Name)
+	assert.Equal(t, 21, tmpl.PerSheet())
+
+	_, err = AveryTemplates.Find("1234")
+	assert.Equal(t, TemplateNotFoundErr, err)
+
+	ls := []Label{}
+	for i := 0; i < 20; i++ {
+		ls = append(ls, Label{Code: fmt.Sprintf("INV%06d", i), Text: "Item", Type: QRType})
+	}
+
+	// Skipping 5 places fills the first sheet with 16 labels
+	pages, err := Sheets(tmpl, ls, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(pages))
+	assert.Equal(t, 32, len(pages[0].Texts))
+	assert.Equal(t, 8, len(pages[1].Texts))
+
+	// The first label is in the third column of the second row
+	first := pages[0].Rects[0]
+	assert.True(t, first.X > tmpl.Left+2*tmpl.PitchX && first.X < tmpl.Left+2*tmpl.PitchX+tmpl.Width)
+	assert.True(t, first.Y > tmpl.Top+tmpl.PitchY && first.Y < tmpl.Top+tmpl.PitchY+tmpl.Height)
+
+	for _, tmpl := range AveryTemplates {
+		assert.True(t, tmpl.Left+float64(tmpl.Columns-1)*tmpl.PitchX+tmpl.Width <= tmpl.PageWidth, tmpl.Name)
+		assert.True(t, tmpl.Top+float64(tmpl.Rows-1)*tmpl.PitchY+tmpl.Height <= tmpl.PageHeight, tmpl.Name)
+	}
+}
@@ -0,0 +1,143 @@
+package labels
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Formats labels can be rendered in
+const (
+	PNGFormat = "png"
+	SVGFormat = "svg"
+	PDFFormat = "pdf"
+)
+
+// ContentTypes are the content types of the formats
+var ContentTypes = map[string]string{
+	PNGFormat: "image/png",
+	SVGFormat: "image/svg+xml",
+	PDFFormat: "application/pdf",
+}
+
+// num formats a measurement without needless digits
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// WriteSVG writes the drawing as an SVG measured in points
+func (d *Drawing) WriteSVG(w io.Writer) error {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%spt" height="%spt" viewBox="0 0 %s %s">`,
+		num(d.Width), num(d.Height), num(d.Width), num(d.Height))
+	fmt.Fprintf(b, `<rect width="%s" height="%s" fill="#fff"/>`, num(d.Width), num(d.Height))
+
+	b.WriteString(`<path fill="#000" d="`)
+	for i, r := range d.Rects {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		fmt.Fprintf(b, "M%s %sh%sv%sh-%sz", num(r.X), num(r.Y), num(r.W), num(r.H), num(r.W))
+	}
+	b.WriteString(`"/>`)
+
+	// The width is fixed so that the text fits however wide the font is
+	for _, t := range d.Texts {
+		if t.S == "" {
+			continue
+		}
+		fmt.Fprintf(b, `<text x="%s" y="%s" font-family="monospace" font-size="%s" textLength="%s" lengthAdjust="spacingAndGlyphs">`,
+			num(t.X), num(t.Y), num(t.Size), num(t.Width()))
+		xml.EscapeText(b, []byte(t.S))
+		b.WriteString(`</text>`)
+	}
+	b.WriteString(`</svg>`)
+
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// WritePNG writes the drawing as a black and white PNG at the resolution in
+// dots per inch
+func (d *Drawing) WritePNG(w io.Writer, dpi int) error {
+	scale := float64(dpi) / 72
+	img := image.NewGray(image.Rect(0, 0, int(math.Ceil(d.Width*scale)), int(math.Ceil(d.Height*scale))))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+
+	for _, r := range d.Rects {
+		x0, y0 := int(math.Round(r.X*scale)), int(math.Round(r.Y*scale))
+		x1, y1 := int(math.Round((r.X+r.W)*scale)), int(math.Round((r.Y+r.H)*scale))
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				img.SetGray(x, y, color.Gray{})
+			}
+		}
+	}
+
+	// The text is drawn in the 7 by 13 pixel font at its own size and then
+	// scaled up so that each character is as wide as in the other formats
+	face := basicfont.Face7x13
+	for _, t := range d.Texts {
+		if t.S == "" {
+			continue
+		}
+
+		glyphs := image.NewAlpha(image.Rect(0, 0, utf8.RuneCountInString(t.S)*face.Advance, face.Height))
+		drawer := font.Drawer{Dst: glyphs, Src: image.Opaque, Face: face, Dot: fixed.P(0, face.Ascent)}
+		drawer.DrawString(t.S)
+
+		px := t.Size * charWidth / float64(face.Advance) * scale
+		left, top := t.X*scale, t.Y*scale-float64(face.Ascent)*px
+		bounds := glyphs.Bounds()
+		for y := int(top); y < int(math.Ceil(top+float64(bounds.Dy())*px)); y++ {
+			for x := int(left); x < int(math.Ceil(left+float64(bounds.Dx())*px)); x++ {
+				gx := int(math.Floor((float64(x) + 0.5 - left) / px))
+				gy := int(math.Floor((float64(y) + 0.5 - top) / px))
+				if glyphs.AlphaAt(gx, gy).A >= 0x80 {
+					img.SetGray(x, y, color.Gray{})
+				}
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+// WritePDF writes the drawings as the pages of a PDF using the Courier font
+func WritePDF(w io.Writer, pages []*Drawing) error {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "pt"})
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetFillColor(0, 0, 0)
+
+	// The core fonts only have the characters of Windows-1252
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	for _, d := range pages {
+		pdf.AddPageFormat("P", gofpdf.SizeType{Wd: d.Width, Ht: d.Height})
+		for _, r := range d.Rects {
+			pdf.Rect(r.X, r.Y, r.W, r.H, "F")
+		}
+		for _, t := range d.Texts {
+			if t.S == "" {
+				continue
+			}
+			pdf.SetFont("Courier", "", t.Size)
+			pdf.Text(t.X, t.Y, tr(t.S))
+		}
+	}
+
+	return pdf.Output(w)
+}
@@ -0,0 +1,103 @@
+package labels
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var TemplateNotFoundErr = errors.New("label template not found")
+
+// Page sizes in points
+const (
+	letterWidth  = 612
+	letterHeight = 792
+	a4Width      = 595.28
+	a4Height     = 841.89
+)
+
+// mm converts millimetres to points
+func mm(v float64) float64 {
+	return v * 72 / 25.4
+}
+
+// Template is a sheet of labels. Measurements are in points.
+type Template struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	PageWidth   float64 `json:"pageWidth"`
+	PageHeight  float64 `json:"pageHeight"`
+	Columns     int     `json:"columns"`
+	Rows        int     `json:"rows"`
+	// Width and Height are the size of each label
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	// Left and Top are where the first label starts
+	Left float64 `json:"left"`
+	Top  float64 `json:"top"`
+	// PitchX and PitchY are how far apart the labels start
+	PitchX float64 `json:"pitchX"`
+	PitchY float64 `json:"pitchY"`
+}
+
+// PerSheet is how many labels fit on a sheet
+func (t Template) PerSheet() int {
+	return t.Columns * t.Rows
+}
+
+type Templates []Template
+
+// AveryTemplates are the common Avery sheets, by product number
+var AveryTemplates = Templates{
+	{"5160", "Address labels, 1 x 2 5/8 in, 30 per letter sheet", letterWidth, letterHeight, 3, 10, 189, 72, 13.5, 36, 198, 72},
+	{"5163", "Shipping labels, 2 x 4 in, 10 per letter sheet", letterWidth, letterHeight, 2, 5, 288, 144, 11.25, 36, 301.5, 144},
+	{"5167", "Return address labels, 1/2 x 1 3/4 in, 80 per letter sheet", letterWidth, letterHeight, 4, 20, 126, 36, 20.25, 36, 148.5, 36},
+	{"L7160", "Address labels, 63.5 x 38.1 mm, 21 per A4 sheet", a4Width, a4Height, 3, 7, mm(63.5), mm(38.1), mm(7.2), mm(15.15), mm(66.04), mm(38.1)},
+	{"L7651", "Mini labels, 38.1 x 21.2 mm, 65 per A4 sheet", a4Width, a4Height, 5, 13, mm(38.1), mm(21.2), mm(4.75), mm(10.7), mm(40.64), mm(21.2)},
+}
+
+// DefaultTemplate is used for labels rendered on their own
+const DefaultTemplate = "5160"
+
+// Find returns the template with the name, ignoring case
+func (ts Templates) Find(name string) (Template, error) {
+	for _, t := range ts {
+		if strings.EqualFold(t.Name, name) {
+			return t, nil
+		}
+	}
+	return Template{}, TemplateNotFoundErr
+}
+
+// Names returns the names of the templates in order
+func (ts Templates) Names() []string {
+	names := make([]string, len(ts))
+	for i, t := range ts {
+		names[i] = t.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Sheets lays out the labels in order across and then down the sheets of the
+// template, one drawing per page. The first skip places are left blank so
+// that partly used sheets can be printed on.
+func Sheets(t Template, ls []Label, skip int) ([]*Drawing, error) {
+	pages := []*Drawing{}
+	var page *Drawing
+	for i, l := range ls {
+		place := (i + skip) % t.PerSheet()
+		if page == nil || place == 0 {
+			page = &Drawing{Width: t.PageWidth, Height: t.PageHeight}
+			pages = append(pages, page)
+		}
+
+		x := t.Left + float64(place%t.Columns)*t.PitchX
+		y := t.Top + float64(place/t.Columns)*t.PitchY
+		if err := page.draw(l, x, y, t.Width, t.Height); err != nil {
+			return nil, err
+		}
+	}
+
+	return pages, nil
+}
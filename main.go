@@ -5,12 +5,19 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/Timothylock/inventory-management/attachments"
 	"github.com/Timothylock/inventory-management/config"
 	"github.com/Timothylock/inventory-management/email"
 	"github.com/Timothylock/inventory-management/items"
 	"github.com/Timothylock/inventory-management/persistence"
+	"github.com/Timothylock/inventory-management/pictures"
+	"github.com/Timothylock/inventory-management/search"
 	"github.com/Timothylock/inventory-management/service"
+	"github.com/Timothylock/inventory-management/storage"
 	"github.com/Timothylock/inventory-management/upc"
 	"github.com/Timothylock/inventory-management/users"
 	"gopkg.in/gomail.v2"
@@ -23,7 +30,23 @@ func main() {
 		os.Exit(1)
 	}
 
-	persister, err := persistence.NewMySQL(cfg)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err = runMigrate(cfg, os.Args[2:]); err != nil {
+			fmt.Printf("error running migrations %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "import-products" {
+		if err = runImportProducts(cfg, os.Args[2:]); err != nil {
+			fmt.Printf("error importing products %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	persister, err := newPersister(cfg)
 	if err != nil {
 		fmt.Printf("error initializing database %s", err.Error())
 		os.Exit(1)
@@ -31,14 +54,125 @@ func main() {
 
 	emailDialer := gomail.NewPlainDialer(cfg.EmailSmtpServ, cfg.EmailSmtpPort, cfg.EmailUsername, cfg.EmailPassword)
 
-	is := items.NewService(persister)
-	us := upc.NewService(*cfg)
-	user := users.NewService(persister)
+	var searcher items.Searcher
+	if cfg.SearchIndex {
+		searcher = search.NewIndex()
+	}
+
+	st, err := storage.New(*cfg)
+	if err != nil {
+		fmt.Printf("error initializing storage %s", err.Error())
+		os.Exit(1)
+	}
+
+	is := items.NewService(persister, searcher, st, *cfg)
+	us := upc.NewService(persister, *cfg)
+	user := users.NewService(persister, *cfg)
 	es := email.NewService(*cfg, emailDialer)
+	ps := pictures.NewService(st, *cfg)
+	as := attachments.NewService(persister, st, *cfg)
 
-	api := service.NewAPI(is, us, user, es)
+	api := service.NewAPI(is, us, user, es, ps, as)
 
 	router := service.NewRouter(&api, *cfg)
 
 	log.Fatal(http.ListenAndServe(":9090", router))
 }
+
+type persister interface {
+	items.Persister
+	users.Persister
+	upc.Persister
+	attachments.Persister
+}
+
+func newPersister(cfg *config.Config) (persister, error) {
+	if cfg.DbDriver == config.DriverSQLite {
+		return persistence.NewSQLite(cfg)
+	}
+
+	return persistence.NewMySQL(cfg)
+}
+
+// runMigrate handles "migrate status" and "migrate rollback". Pending
+// migrations are applied automatically on boot so there is no "up" command.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s migrate status|rollback", os.Args[0])
+	}
+
+	m, err := persistence.NewMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "status":
+		st, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		for _, s := range st {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-30s %s\n", s.Version, s.Name, applied)
+		}
+	case "rollback":
+		mig, err := m.Rollback()
+		if err != nil {
+			return err
+		}
+
+		if mig == nil {
+			fmt.Println("no migrations to roll back")
+		} else {
+			fmt.Printf("rolled back %04d %s\n", mig.Version, mig.Name)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %s, expected status or rollback", args[0])
+	}
+
+	return nil
+}
+
+// runImportProducts imports a barcode dataset from a file ending in .csv, or
+// .jsonl or .ndjson for JSON lines, so that barcodes can be looked up offline
+func runImportProducts(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s import-products <file.csv|file.jsonl>", os.Args[0])
+	}
+
+	var format string
+	switch strings.ToLower(filepath.Ext(args[0])) {
+	case ".csv":
+		format = upc.DatasetCSV
+	case ".jsonl", ".ndjson":
+		format = upc.DatasetJSONL
+	default:
+		return upc.InvalidDatasetFormatErr
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	persister, err := newPersister(cfg)
+	if err != nil {
+		return err
+	}
+
+	us := upc.NewService(persister, *cfg)
+	report, err := us.ImportDataset(f, format)
+	for _, e := range report.Errors {
+		fmt.Printf("line %d %s: %s\n", e.Line, e.GTIN, e.Reason)
+	}
+	fmt.Printf("imported %d products, skipped %d\n", report.Imported, report.Skipped)
+
+	return err
+}
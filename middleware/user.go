@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/Timothylock/inventory-management/responses"
@@ -50,3 +51,18 @@ func UserOptional(us users.Service, next func(users.User) http.Handler) http.Han
 		next(u).ServeHTTP(w, r)
 	})
 }
+
+// RequirePermission only calls next if the user's role grants the permission. It
+// is meant to be wrapped by UserRequired.
+func RequirePermission(permission string, next func(users.User) http.Handler) func(users.User) http.Handler {
+	return func(u users.User) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !u.Can(permission) {
+				responses.SendError(w, responses.Forbidden(fmt.Errorf("you need the %s permission to perform this action", permission)))
+				return
+			}
+
+			next(u).ServeHTTP(w, r)
+		})
+	}
+}
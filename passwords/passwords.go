@@ -0,0 +1,127 @@
+package passwords
+
+import (
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher is a single password hashing scheme. Hashes must be self-describing
+// so that Handles can tell which Hasher produced a stored hash.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(password, hash string) (bool, error)
+	Handles(hash string) bool
+	// NeedsRehash returns true if hash was made with weaker parameters than
+	// the Hasher is currently configured with
+	NeedsRehash(hash string) bool
+}
+
+var UnknownHashErr = errors.New("unknown password hash format")
+
+type Service struct {
+	preferred Hasher
+	others    []Hasher
+}
+
+// NewService hashes new passwords with preferred. Existing hashes made by any of
+// the others can still be verified but should be rehashed with preferred.
+func NewService(preferred Hasher, others ...Hasher) Service {
+	return Service{
+		preferred: preferred,
+		others:    others,
+	}
+}
+
+// NewDefaultService uses bcrypt with the given cost and still accepts the
+// unsalted SHA-1 hashes from before bcrypt was introduced.
+func NewDefaultService(bcryptCost int) Service {
+	return NewService(Bcrypt{Cost: bcryptCost}, LegacySHA1{})
+}
+
+func (s *Service) Hash(password string) (string, error) {
+	return s.preferred.Hash(password)
+}
+
+// Verify checks password against hash. When the password is correct but the
+// hash should be upgraded, rehash is true.
+func (s *Service) Verify(password, hash string) (ok bool, rehash bool, err error) {
+	if s.preferred.Handles(hash) {
+		ok, err = s.preferred.Verify(password, hash)
+		return ok, ok && s.preferred.NeedsRehash(hash), err
+	}
+
+	for _, h := range s.others {
+		if h.Handles(hash) {
+			ok, err = h.Verify(password, hash)
+			return ok, ok, err
+		}
+	}
+
+	return false, false, UnknownHashErr
+}
+
+// Bcrypt hashes are stored in the standard $2a$ modular crypt format
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) cost() int {
+	if b.Cost < bcrypt.MinCost {
+		return bcrypt.DefaultCost
+	}
+	return b.Cost
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(password), b.cost())
+	return string(h), err
+}
+
+func (b Bcrypt) Verify(password, hash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (b Bcrypt) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (b Bcrypt) NeedsRehash(hash string) bool {
+	c, err := bcrypt.Cost([]byte(hash))
+	return err != nil || c < b.cost()
+}
+
+// LegacySHA1 is the original base64(SHA1(password)) scheme. It has no prefix
+// so it handles anything that is not in modular crypt format.
+type LegacySHA1 struct{}
+
+var LegacyHashErr = errors.New("new passwords cannot be hashed with sha1")
+
+func (LegacySHA1) Hash(password string) (string, error) {
+	return "", LegacyHashErr
+}
+
+func (LegacySHA1) Verify(password, hash string) (bool, error) {
+	hasher := sha1.New()
+	hasher.Write([]byte(password))
+	sha := base64.URLEncoding.EncodeToString(hasher.Sum(nil))
+
+	return subtle.ConstantTimeCompare([]byte(sha), []byte(hash)) == 1, nil
+}
+
+func (LegacySHA1) Handles(hash string) bool {
+	return hash != "" && !strings.HasPrefix(hash, "$")
+}
+
+func (LegacySHA1) NeedsRehash(hash string) bool {
+	return true
+}
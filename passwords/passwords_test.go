@@ -0,0 +1,77 @@
+package passwords
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	s := NewDefaultService(4)
+
+	h, err := s.Hash("pass")
+	assert.NoError(t, err)
+	assert.True(t, Bcrypt{}.Handles(h))
+
+	ok, rehash, err := s.Verify("pass", h)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+
+	ok, rehash, err = s.Verify("wrong", h)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, rehash)
+}
+
+func TestHashIsSalted(t *testing.T) {
+	s := NewDefaultService(4)
+
+	h1, err := s.Hash("pass")
+	assert.NoError(t, err)
+	h2, err := s.Hash("pass")
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, h1, h2)
+}
+
+func TestVerifyLegacy(t *testing.T) {
+	s := NewDefaultService(4)
+
+	ok, rehash, err := s.Verify("pass", "nU4eI71bcnBGqeO0t9tXvY1u5oQ=")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	ok, rehash, err = s.Verify("wrong", "nU4eI71bcnBGqeO0t9tXvY1u5oQ=")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, rehash)
+}
+
+func TestVerifyWeakerCost(t *testing.T) {
+	weak := NewDefaultService(4)
+	h, err := weak.Hash("pass")
+	assert.NoError(t, err)
+
+	s := NewDefaultService(5)
+	ok, rehash, err := s.Verify("pass", h)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+}
+
+func TestVerifyUnknownFormat(t *testing.T) {
+	s := NewDefaultService(4)
+
+	for _, h := range []string{"", "$argon2id$v=19$m=65536,t=1,p=4$abc$def"} {
+		ok, _, err := s.Verify("pass", h)
+		assert.Equal(t, UnknownHashErr, err)
+		assert.False(t, ok)
+	}
+}
+
+func TestLegacyCannotHash(t *testing.T) {
+	_, err := LegacySHA1{}.Hash("pass")
+	assert.Equal(t, LegacyHashErr, err)
+}
@@ -0,0 +1,320 @@
+package persistence
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Timothylock/inventory-management/config"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a single numbered schema change. Up applies it and Down reverts it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the migrations embedded in the binary and records them in
+// the schema_version table.
+type Migrator struct {
+	conn       *sqlx.DB
+	dialect    dialect
+	migrations []Migration
+}
+
+// NewMigrator connects to the database configured in cfg without applying
+// anything so that the schema can be inspected or rolled back.
+func NewMigrator(cfg *config.Config) (*Migrator, error) {
+	var conn *sqlx.DB
+	var err error
+	d := mysqlDialect
+
+	if cfg.DbDriver == config.DriverSQLite {
+		d = sqliteDialect
+		conn, err = connectSQLite(cfg)
+	} else {
+		conn, err = connectMySQL(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newMigrator(conn, d)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func migrate(conn *sqlx.DB, d dialect) error {
+	m, err := newMigrator(conn, d)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.Up()
+	return err
+}
+
+func newMigrator(conn *sqlx.DB, d dialect) (*Migrator, error) {
+	ms, err := loadMigrations(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		conn:       conn,
+		dialect:    d,
+		migrations: ms,
+	}, nil
+}
+
+func (d dialect) String() string {
+	if d == sqliteDialect {
+		return "sqlite"
+	}
+	return "mysql"
+}
+
+// loadMigrations reads the migrations for the dialect sorted by version
+func loadMigrations(d dialect) ([]Migration, error) {
+	dir := path.Join("migrations", d.String())
+	files, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, f := range files {
+		match := migrationName.FindStringSubmatch(f.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", f.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, m.Name, match[2])
+		}
+
+		b, err := migrationFiles.ReadFile(path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		if match[3] == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	ms := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		ms = append(ms, *m)
+	}
+
+	sort.Slice(ms, func(i, j int) bool {
+		return ms[i].Version < ms[j].Version
+	})
+
+	for i, m := range ms {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+	}
+
+	return ms, nil
+}
+
+func (m *Migrator) Close() error {
+	return m.conn.Close()
+}
+
+func (m *Migrator) ensureVersionTable() error {
+	_, err := m.conn.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		VERSION int NOT NULL PRIMARY KEY,
+		NAME varchar(255) NOT NULL,
+		APPLIED_AT datetime NOT NULL
+	)`)
+	return err
+}
+
+type versionDB struct {
+	Version   int       `db:"VERSION"`
+	AppliedAt time.Time `db:"APPLIED_AT"`
+}
+
+func (m *Migrator) appliedVersions() (map[int]time.Time, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return nil, err
+	}
+
+	var vs []versionDB
+	if err := m.conn.Select(&vs, "SELECT VERSION, APPLIED_AT FROM schema_version"); err != nil {
+		return nil, err
+	}
+
+	applied := map[int]time.Time{}
+	for _, v := range vs {
+		applied[v.Version] = v.AppliedAt
+	}
+
+	return applied, nil
+}
+
+// Up applies every pending migration in order and returns how many were applied
+func (m *Migrator) Up() (int, error) {
+	applied, err := m.appliedVersions()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+
+		err = m.run(mig.Up, func(tx *sqlx.Tx) error {
+			_, err := tx.Exec("INSERT INTO schema_version (VERSION, NAME, APPLIED_AT) VALUES (?, ?, ?)",
+				mig.Version, mig.Name, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("applying migration %d_%s: %s", mig.Version, mig.Name, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// Rollback reverts the most recently applied migration. It returns nil if
+// nothing has been applied.
+func (m *Migrator) Rollback() (*Migration, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return nil, err
+	}
+
+	var version int
+	err := m.conn.Get(&version, "SELECT VERSION FROM schema_version ORDER BY VERSION DESC LIMIT 1")
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if version > len(m.migrations) {
+		return nil, fmt.Errorf("database is at version %d which is newer than this binary knows about", version)
+	}
+
+	mig := m.migrations[version-1]
+	err = m.run(mig.Down, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("DELETE FROM schema_version WHERE VERSION = ?", mig.Version)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rolling back migration %d_%s: %s", mig.Version, mig.Name, err)
+	}
+
+	return &mig, nil
+}
+
+// Status returns every known migration and whether it has been applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	st := []MigrationStatus{}
+	for _, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		st = append(st, MigrationStatus{
+			Migration: mig,
+			Applied:   ok,
+			AppliedAt: at,
+		})
+	}
+
+	return st, nil
+}
+
+// run executes every statement of script followed by record in one
+// transaction. MySQL commits DDL implicitly so this is only atomic on SQLite.
+func (m *Migrator) run(script string, record func(*sqlx.Tx) error) error {
+	tx, err := m.conn.Beginx()
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range splitStatements(script) {
+		if _, err = tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err = record(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// splitStatements splits a script on semicolons that end a line, skipping
+// comment lines. This is enough for the migrations we write ourselves.
+func splitStatements(script string) []string {
+	var stmts []string
+	var cur []string
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		cur = append(cur, line)
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(strings.Join(cur, "\n")), ";"))
+			cur = nil
+		}
+	}
+
+	if len(cur) > 0 {
+		stmts = append(stmts, strings.TrimSpace(strings.Join(cur, "\n")))
+	}
+
+	return stmts
+}
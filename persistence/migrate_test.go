@@ -0,0 +1,101 @@
+package persistence
+
+import (
+	"testing"
+
+	"github.com/Timothylock/inventory-management/users"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	for _, d := range []dialect{mysqlDialect, sqliteDialect} {
+		t.Run(d.String(), func(t *testing.T) {
+			ms, err := loadMigrations(d)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, ms)
+
+			for i, m := range ms {
+				assert.Equal(t, i+1, m.Version)
+				assert.NotEmpty(t, splitStatements(m.Up))
+				assert.NotEmpty(t, splitStatements(m.Down))
+			}
+		})
+	}
+}
+
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	mysql, err := loadMigrations(mysqlDialect)
+	assert.NoError(t, err)
+	sqlite, err := loadMigrations(sqliteDialect)
+	assert.NoError(t, err)
+
+	assert.Equal(t, len(mysql), len(sqlite))
+	for i := range mysql {
+		assert.Equal(t, mysql[i].Name, sqlite[i].Name)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- a comment; with a semicolon
+CREATE TABLE foo (
+  ID int
+);
+
+INSERT INTO foo VALUES (1);
+INSERT INTO foo VALUES (2)`
+
+	assert.Equal(t, []string{
+		"CREATE TABLE foo (\n  ID int\n)",
+		"INSERT INTO foo VALUES (1)",
+		"INSERT INTO foo VALUES (2)",
+	}, splitStatements(script))
+}
+
+func TestMigratorStatusAndRollback(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	m, err := newMigrator(db.conn, sqliteDialect)
+	assert.NoError(t, err)
+
+	st, err := m.Status()
+	assert.NoError(t, err)
+	assert.Len(t, st, len(m.migrations))
+	for _, s := range st {
+		assert.True(t, s.Applied, s.Name)
+		assert.False(t, s.AppliedAt.IsZero(), s.Name)
+	}
+
+	// Everything was applied when the database was opened
+	n, err := m.Up()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	for i := len(m.migrations); i > 0; i-- {
+		mig, err := m.Rollback()
+		assert.NoError(t, err)
+		assert.Equal(t, i, mig.Version)
+	}
+
+	mig, err := m.Rollback()
+	assert.NoError(t, err)
+	assert.Nil(t, mig)
+
+	st, err = m.Status()
+	assert.NoError(t, err)
+	for _, s := range st {
+		assert.False(t, s.Applied, s.Name)
+	}
+
+	_, err = db.GetUsers(users.DefaultOrgID)
+	assert.Error(t, err)
+
+	n, err = m.Up()
+	assert.NoError(t, err)
+	assert.Equal(t, len(m.migrations), n)
+
+	us, err := db.GetUsers(users.DefaultOrgID)
+	assert.NoError(t, err)
+	assert.Empty(t, us)
+}
@@ -0,0 +1,3 @@
+DROP TABLE `logs`;
+DROP TABLE `users`;
+DROP TABLE `items`;
@@ -1,6 +1,6 @@
--- This file contains all of the schemas for the tables. Import this into your blank mysql database
+-- IF NOT EXISTS lets databases that were set up by hand from the old schema.sql adopt migrations
 
-CREATE TABLE `items` (
+CREATE TABLE IF NOT EXISTS `items` (
   `ID` text NOT NULL,
   `NAME` text NOT NULL,
   `CATEGORY` text NOT NULL,
@@ -15,7 +15,7 @@ CREATE TABLE `items` (
   FULLTEXT KEY `search` (`ID`,`NAME`,`CATEGORY`,`DETAILS`,`LOCATION`)
 );
 
-CREATE TABLE `users` (
+CREATE TABLE IF NOT EXISTS `users` (
   `ID` int(11) unsigned NOT NULL AUTO_INCREMENT,
   `USERNAME` text NOT NULL,
   `ACTIVE` int(1) NOT NULL DEFAULT '1',
@@ -28,10 +28,10 @@ CREATE TABLE `users` (
   KEY `token` (`TOKEN`(128))
 );
 
-CREATE TABLE `logs` (
+CREATE TABLE IF NOT EXISTS `logs` (
   `USERID` int(11) NOT NULL,
   `OBJECTID` text NOT NULL,
   `ACTION` text NOT NULL,
   `DETAILS` blob,
   `DATE` datetime NOT NULL
-);
\ No newline at end of file
+);
@@ -0,0 +1,14 @@
+CREATE TABLE `sessions` (
+  `ID` int(11) unsigned NOT NULL AUTO_INCREMENT,
+  `TOKEN` varchar(64) NOT NULL,
+  `USERID` int(11) NOT NULL,
+  `CREATED_AT` datetime NOT NULL,
+  `LAST_SEEN_AT` datetime NOT NULL,
+  `EXPIRES_AT` datetime NOT NULL,
+  `USER_AGENT` text NOT NULL,
+  `IP` varchar(64) NOT NULL,
+  `REVOKED` int(1) NOT NULL DEFAULT '0',
+  PRIMARY KEY (`ID`),
+  UNIQUE KEY `token` (`TOKEN`),
+  KEY `user` (`USERID`, `REVOKED`)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE `roles` (
+  `NAME` varchar(64) NOT NULL,
+  `PERMISSIONS` text NOT NULL,
+  PRIMARY KEY (`NAME`)
+);
+
+INSERT INTO `roles` (`NAME`, `PERMISSIONS`) VALUES
+  ('viewer', 'item.view'),
+  ('borrower', 'item.view item.move upc.lookup'),
+  ('stock-keeper', 'item.view item.move item.add item.edit item.delete upc.lookup'),
+  ('admin', 'item.view item.move item.add item.edit item.delete upc.lookup user.view user.manage role.manage');
+
+-- Everyone who is not an admin could previously manage every item
+ALTER TABLE `users` ADD COLUMN `ROLE` varchar(64) NOT NULL DEFAULT 'stock-keeper';
+UPDATE `users` SET `ROLE` = 'admin' WHERE `ISSYSADMIN` = 1;
@@ -0,0 +1,22 @@
+-- Only the Default organization survives a rollback
+
+ALTER TABLE `sessions` DROP COLUMN `ORGID`;
+
+DELETE FROM `logs` WHERE `ORGID` > 1;
+ALTER TABLE `logs` DROP COLUMN `ORGID`;
+
+DELETE FROM `items` WHERE `ORGID` <> 1;
+ALTER TABLE `items` DROP KEY `org`;
+ALTER TABLE `items` DROP COLUMN `ORGID`;
+
+DELETE FROM `roles` WHERE `ORGID` <> 1;
+UPDATE `roles` SET `PERMISSIONS` = REPLACE(`PERMISSIONS`, ' org.create', '');
+ALTER TABLE `roles` DROP PRIMARY KEY, ADD PRIMARY KEY (`NAME`);
+ALTER TABLE `roles` DROP COLUMN `ORGID`;
+
+ALTER TABLE `users` ADD COLUMN `ROLE` varchar(64) NOT NULL DEFAULT 'viewer';
+UPDATE `users` JOIN `org_members` ON `org_members`.`USERID` = `users`.`ID` AND `org_members`.`ORGID` = 1 SET `users`.`ROLE` = `org_members`.`ROLE`;
+ALTER TABLE `users` ALTER COLUMN `ROLE` SET DEFAULT 'stock-keeper';
+
+DROP TABLE `org_members`;
+DROP TABLE `orgs`;
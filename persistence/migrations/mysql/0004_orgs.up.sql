@@ -0,0 +1,37 @@
+-- Everything that existed before organizations belongs to the Default organization
+
+CREATE TABLE `orgs` (
+  `ID` int(11) unsigned NOT NULL AUTO_INCREMENT,
+  `NAME` varchar(128) NOT NULL,
+  PRIMARY KEY (`ID`)
+);
+
+INSERT INTO `orgs` (`ID`, `NAME`) VALUES (1, 'Default');
+
+CREATE TABLE `org_members` (
+  `ORGID` int(11) NOT NULL,
+  `USERID` int(11) NOT NULL,
+  `ROLE` varchar(64) NOT NULL,
+  PRIMARY KEY (`ORGID`, `USERID`),
+  KEY `user` (`USERID`)
+);
+
+INSERT INTO `org_members` (`ORGID`, `USERID`, `ROLE`) SELECT 1, `ID`, `ROLE` FROM `users` WHERE `ACTIVE` = 1;
+ALTER TABLE `users` DROP COLUMN `ROLE`;
+
+-- Every organization configures its own roles
+ALTER TABLE `roles` ADD COLUMN `ORGID` int(11) NOT NULL DEFAULT '1' FIRST;
+ALTER TABLE `roles` DROP PRIMARY KEY, ADD PRIMARY KEY (`ORGID`, `NAME`);
+ALTER TABLE `roles` ALTER COLUMN `ORGID` DROP DEFAULT;
+UPDATE `roles` SET `PERMISSIONS` = CONCAT(`PERMISSIONS`, ' org.create') WHERE `NAME` = 'admin';
+
+ALTER TABLE `items` ADD COLUMN `ORGID` int(11) NOT NULL DEFAULT '1' FIRST;
+ALTER TABLE `items` ALTER COLUMN `ORGID` DROP DEFAULT;
+ALTER TABLE `items` ADD KEY `org` (`ORGID`, `ID`(32), `DELETED`);
+
+ALTER TABLE `logs` ADD COLUMN `ORGID` int(11) NOT NULL DEFAULT '0' FIRST;
+UPDATE `logs` SET `ORGID` = 1;
+
+-- The organization the session is currently working in
+ALTER TABLE `sessions` ADD COLUMN `ORGID` int(11) NOT NULL DEFAULT '0';
+UPDATE `sessions` SET `ORGID` = 1;
@@ -0,0 +1,2 @@
+UPDATE `items` SET `STATUS` = 'checked out' WHERE `CHECKED_OUT` > 0;
+ALTER TABLE `items` DROP COLUMN `CHECKED_OUT`;
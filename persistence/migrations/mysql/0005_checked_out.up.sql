@@ -0,0 +1,4 @@
+-- Items that were checked out before partial moves existed are checked out completely
+
+ALTER TABLE `items` ADD COLUMN `CHECKED_OUT` int(11) NOT NULL DEFAULT '0' AFTER `QUANTITY`;
+UPDATE `items` SET `CHECKED_OUT` = `QUANTITY` WHERE `STATUS` = 'checked out';
@@ -0,0 +1,17 @@
+CREATE TABLE `loans` (
+  `ID` int(11) unsigned NOT NULL AUTO_INCREMENT,
+  `ORGID` int(11) NOT NULL,
+  `ITEMID` varchar(255) NOT NULL,
+  `QUANTITY` int(11) NOT NULL,
+  `RETURNED` int(11) NOT NULL DEFAULT '0',
+  `BORROWER_USERID` int(11) NOT NULL DEFAULT '0',
+  `BORROWER_NAME` varchar(255) NOT NULL,
+  `DUE_AT` datetime NULL,
+  `NOTES` text NOT NULL,
+  `CHECKED_OUT_AT` datetime NOT NULL,
+  `CHECKED_OUT_BY` int(11) NOT NULL,
+  `RETURNED_AT` datetime NULL,
+  PRIMARY KEY (`ID`),
+  KEY `item` (`ORGID`, `ITEMID`, `RETURNED_AT`),
+  KEY `borrower` (`ORGID`, `BORROWER_NAME`)
+);
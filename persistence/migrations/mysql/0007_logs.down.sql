@@ -0,0 +1,6 @@
+UPDATE `roles` SET `PERMISSIONS` = REPLACE(`PERMISSIONS`, ' log.view', '');
+
+ALTER TABLE `logs` DROP KEY `object`, DROP KEY `date`;
+ALTER TABLE `logs` MODIFY `DETAILS` blob;
+ALTER TABLE `logs` DROP COLUMN `OBJECTTYPE`;
+ALTER TABLE `logs` DROP COLUMN `ID`;
@@ -0,0 +1,16 @@
+-- Logs get an ID to page through them and say what kind of object they are about.
+-- DETAILS becomes a JSON object and the old free text details are kept as its message.
+
+ALTER TABLE `logs` ADD COLUMN `ID` int(11) unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY FIRST;
+ALTER TABLE `logs` ADD COLUMN `OBJECTTYPE` varchar(32) NOT NULL DEFAULT 'item' AFTER `USERID`;
+UPDATE `logs` SET `OBJECTTYPE` = 'user' WHERE `ACTION` IN ('user created', 'user updated', 'password reset', 'delete user', 'member updated');
+UPDATE `logs` SET `OBJECTTYPE` = 'role' WHERE `ACTION` = 'role updated';
+UPDATE `logs` SET `OBJECTTYPE` = 'org' WHERE `ACTION` = 'org created';
+
+UPDATE `logs` SET `DETAILS` = NULL WHERE `DETAILS` = '';
+UPDATE `logs` SET `DETAILS` = JSON_OBJECT('message', CONVERT(`DETAILS` USING utf8mb4)) WHERE `DETAILS` IS NOT NULL;
+ALTER TABLE `logs` MODIFY `DETAILS` json NULL;
+
+ALTER TABLE `logs` ADD KEY `object` (`ORGID`, `OBJECTTYPE`, `OBJECTID`(255)), ADD KEY `date` (`ORGID`, `DATE`);
+
+UPDATE `roles` SET `PERMISSIONS` = CONCAT(`PERMISSIONS`, ' log.view') WHERE `NAME` = 'admin';
@@ -0,0 +1,3 @@
+UPDATE `roles` SET `PERMISSIONS` = REPLACE(`PERMISSIONS`, ' item.purge', '');
+
+ALTER TABLE `items` DROP COLUMN `DELETED_AT`;
@@ -0,0 +1,6 @@
+-- Items that were deleted before the trash existed are treated as deleted now
+
+ALTER TABLE `items` ADD COLUMN `DELETED_AT` datetime NULL AFTER `DELETED`;
+UPDATE `items` SET `DELETED_AT` = UTC_TIMESTAMP() WHERE `DELETED` = 1;
+
+UPDATE `roles` SET `PERMISSIONS` = CONCAT(`PERMISSIONS`, ' item.purge') WHERE `NAME` = 'admin';
@@ -0,0 +1,10 @@
+-- Only the name of the innermost location is kept
+
+ALTER TABLE `items` ADD COLUMN `LOCATION` text NOT NULL AFTER `DETAILS`;
+UPDATE `items` JOIN `locations` ON `locations`.`ID` = `items`.`LOCATIONID` SET `items`.`LOCATION` = `locations`.`NAME`;
+
+ALTER TABLE `items` DROP INDEX `search`;
+ALTER TABLE `items` DROP COLUMN `LOCATIONID`;
+ALTER TABLE `items` ADD FULLTEXT KEY `search` (`ID`, `NAME`, `CATEGORY`, `DETAILS`, `LOCATION`);
+
+DROP TABLE `locations`;
@@ -0,0 +1,23 @@
+-- Every distinct free text location becomes a top level location. Values that only differ by case or surrounding
+-- spaces are the same location.
+
+CREATE TABLE `locations` (
+  `ID` int(11) unsigned NOT NULL AUTO_INCREMENT,
+  `ORGID` int(11) NOT NULL,
+  `PARENTID` int(11) unsigned NOT NULL DEFAULT '0',
+  `NAME` varchar(255) NOT NULL,
+  PRIMARY KEY (`ID`),
+  KEY `parent` (`ORGID`, `PARENTID`)
+);
+
+INSERT INTO `locations` (`ORGID`, `PARENTID`, `NAME`)
+SELECT `ORGID`, 0, MIN(TRIM(`LOCATION`)) FROM `items` WHERE TRIM(`LOCATION`) <> '' GROUP BY `ORGID`, TRIM(`LOCATION`);
+
+ALTER TABLE `items` ADD COLUMN `LOCATIONID` int(11) unsigned NOT NULL DEFAULT '0' AFTER `DETAILS`;
+UPDATE `items` JOIN `locations` ON `locations`.`ORGID` = `items`.`ORGID` AND `locations`.`PARENTID` = 0
+  AND `locations`.`NAME` = TRIM(`items`.`LOCATION`)
+SET `items`.`LOCATIONID` = `locations`.`ID`;
+
+ALTER TABLE `items` DROP INDEX `search`;
+ALTER TABLE `items` DROP COLUMN `LOCATION`;
+ALTER TABLE `items` ADD FULLTEXT KEY `search` (`ID`, `NAME`, `CATEGORY`, `DETAILS`);
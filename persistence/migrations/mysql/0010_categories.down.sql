@@ -0,0 +1,11 @@
+-- The values of custom fields are lost
+
+ALTER TABLE `items` ADD COLUMN `CATEGORY` text NOT NULL AFTER `NAME`;
+UPDATE `items` JOIN `categories` ON `categories`.`ID` = `items`.`CATEGORYID` SET `items`.`CATEGORY` = `categories`.`NAME`;
+
+ALTER TABLE `items` DROP INDEX `search`;
+ALTER TABLE `items` DROP COLUMN `CATEGORYID`;
+ALTER TABLE `items` DROP COLUMN `FIELDS`;
+ALTER TABLE `items` ADD FULLTEXT KEY `search` (`ID`, `NAME`, `CATEGORY`, `DETAILS`);
+
+DROP TABLE `categories`;
@@ -0,0 +1,24 @@
+-- Every distinct free text category becomes a category without custom fields. Values that only differ by case or
+-- surrounding spaces are the same category. FIELDS of items is text rather than json so that it can be searched.
+
+CREATE TABLE `categories` (
+  `ID` int(11) unsigned NOT NULL AUTO_INCREMENT,
+  `ORGID` int(11) NOT NULL,
+  `NAME` varchar(255) NOT NULL,
+  `FIELDS` json NOT NULL,
+  PRIMARY KEY (`ID`),
+  KEY `org` (`ORGID`, `NAME`)
+);
+
+INSERT INTO `categories` (`ORGID`, `NAME`, `FIELDS`)
+SELECT `ORGID`, MIN(TRIM(`CATEGORY`)), JSON_ARRAY() FROM `items` WHERE TRIM(`CATEGORY`) <> '' GROUP BY `ORGID`, TRIM(`CATEGORY`);
+
+ALTER TABLE `items` ADD COLUMN `CATEGORYID` int(11) unsigned NOT NULL DEFAULT '0' AFTER `NAME`;
+ALTER TABLE `items` ADD COLUMN `FIELDS` text NOT NULL AFTER `DETAILS`;
+UPDATE `items` SET `FIELDS` = '{}';
+UPDATE `items` JOIN `categories` ON `categories`.`ORGID` = `items`.`ORGID` AND `categories`.`NAME` = TRIM(`items`.`CATEGORY`)
+SET `items`.`CATEGORYID` = `categories`.`ID`;
+
+ALTER TABLE `items` DROP INDEX `search`;
+ALTER TABLE `items` DROP COLUMN `CATEGORY`;
+ALTER TABLE `items` ADD FULLTEXT KEY `search` (`ID`, `NAME`, `DETAILS`, `FIELDS`);
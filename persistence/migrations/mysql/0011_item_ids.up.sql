@@ -0,0 +1,7 @@
+-- The next number used to make up an ID for items that do not have a barcode of their own
+
+CREATE TABLE `item_ids` (
+  `ORGID` int(11) NOT NULL,
+  `NEXT` int(11) unsigned NOT NULL DEFAULT '1',
+  PRIMARY KEY (`ORGID`)
+);
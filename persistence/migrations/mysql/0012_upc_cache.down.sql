@@ -0,0 +1,3 @@
+UPDATE `roles` SET `PERMISSIONS` = REPLACE(`PERMISSIONS`, ' upc.manage', '');
+
+DROP TABLE `upc_cache`;
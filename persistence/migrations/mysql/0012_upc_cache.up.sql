@@ -0,0 +1,14 @@
+-- Results of barcode lookups, including barcodes the lookup API did not know,
+-- so that the API is not asked again until they expire
+
+CREATE TABLE `upc_cache` (
+  `BARCODE` varchar(32) NOT NULL,
+  `FOUND` int(1) NOT NULL,
+  `NAME` text NOT NULL,
+  `CATEGORY` text NOT NULL,
+  `PICTURE_URL` text NOT NULL,
+  `FETCHED_AT` datetime NOT NULL,
+  PRIMARY KEY (`BARCODE`)
+);
+
+UPDATE `roles` SET `PERMISSIONS` = CONCAT(`PERMISSIONS`, ' upc.manage') WHERE `NAME` = 'admin';
@@ -0,0 +1,11 @@
+-- Products imported from a barcode dataset so that barcodes can be looked up
+-- without the internet
+
+CREATE TABLE `upc_products` (
+  `GTIN` varchar(14) NOT NULL,
+  `NAME` text NOT NULL,
+  `BRAND` text NOT NULL,
+  `CATEGORY` text NOT NULL,
+  `PICTURE_URL` text NOT NULL,
+  PRIMARY KEY (`GTIN`)
+);
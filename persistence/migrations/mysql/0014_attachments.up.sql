@@ -0,0 +1,16 @@
+-- Files such as manuals and receipts attached to items. The file itself is
+-- kept in storage under STORAGE_KEY.
+
+CREATE TABLE `attachments` (
+  `ID` int(11) unsigned NOT NULL AUTO_INCREMENT,
+  `ORGID` int(11) NOT NULL,
+  `ITEMID` varchar(255) NOT NULL,
+  `FILENAME` varchar(255) NOT NULL,
+  `CONTENT_TYPE` varchar(255) NOT NULL,
+  `SIZE` bigint(20) NOT NULL,
+  `STORAGE_KEY` varchar(255) NOT NULL,
+  `UPLOADED_BY` int(11) NOT NULL,
+  `UPLOADED_AT` datetime NOT NULL,
+  PRIMARY KEY (`ID`),
+  KEY `item` (`ORGID`, `ITEMID`)
+);
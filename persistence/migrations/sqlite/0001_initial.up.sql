@@ -0,0 +1,35 @@
+-- Mirrors the mysql schema. Text columns compare case-insensitively to match the default MySQL collation.
+
+CREATE TABLE IF NOT EXISTS items (
+  ID TEXT NOT NULL COLLATE NOCASE,
+  NAME TEXT NOT NULL COLLATE NOCASE,
+  CATEGORY TEXT NOT NULL COLLATE NOCASE,
+  PICTURE_URL TEXT NOT NULL,
+  DETAILS TEXT NOT NULL COLLATE NOCASE,
+  LOCATION TEXT NOT NULL COLLATE NOCASE,
+  LAST_PERFORMED_BY INTEGER NOT NULL DEFAULT 0,
+  QUANTITY INTEGER NOT NULL DEFAULT 1,
+  STATUS TEXT NOT NULL,
+  DELETED INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS ID_2 ON items (ID, DELETED);
+
+CREATE TABLE IF NOT EXISTS users (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  USERNAME TEXT NOT NULL COLLATE NOCASE,
+  ACTIVE INTEGER NOT NULL DEFAULT 1,
+  PASSWORD TEXT NOT NULL,
+  ISSYSADMIN INTEGER NOT NULL DEFAULT 1,
+  TOKEN TEXT NOT NULL,
+  EMAIL TEXT NOT NULL COLLATE NOCASE
+);
+CREATE INDEX IF NOT EXISTS upass ON users (USERNAME, PASSWORD);
+CREATE INDEX IF NOT EXISTS token ON users (TOKEN);
+
+CREATE TABLE IF NOT EXISTS logs (
+  USERID INTEGER NOT NULL,
+  OBJECTID TEXT NOT NULL,
+  ACTION TEXT NOT NULL,
+  DETAILS BLOB,
+  DATE DATETIME NOT NULL
+);
@@ -0,0 +1,12 @@
+CREATE TABLE sessions (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  TOKEN TEXT NOT NULL UNIQUE,
+  USERID INTEGER NOT NULL,
+  CREATED_AT DATETIME NOT NULL,
+  LAST_SEEN_AT DATETIME NOT NULL,
+  EXPIRES_AT DATETIME NOT NULL,
+  USER_AGENT TEXT NOT NULL,
+  IP TEXT NOT NULL,
+  REVOKED INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX sessions_user ON sessions (USERID, REVOKED);
@@ -0,0 +1,14 @@
+CREATE TABLE roles (
+  NAME TEXT NOT NULL PRIMARY KEY,
+  PERMISSIONS TEXT NOT NULL
+);
+
+INSERT INTO roles (NAME, PERMISSIONS) VALUES
+  ('viewer', 'item.view'),
+  ('borrower', 'item.view item.move upc.lookup'),
+  ('stock-keeper', 'item.view item.move item.add item.edit item.delete upc.lookup'),
+  ('admin', 'item.view item.move item.add item.edit item.delete upc.lookup user.view user.manage role.manage');
+
+-- Everyone who is not an admin could previously manage every item
+ALTER TABLE users ADD COLUMN ROLE TEXT NOT NULL DEFAULT 'stock-keeper';
+UPDATE users SET ROLE = 'admin' WHERE ISSYSADMIN = 1;
@@ -0,0 +1,24 @@
+-- Only the Default organization survives a rollback
+
+ALTER TABLE sessions DROP COLUMN ORGID;
+
+DELETE FROM logs WHERE ORGID > 1;
+ALTER TABLE logs DROP COLUMN ORGID;
+
+DELETE FROM items WHERE ORGID <> 1;
+DROP INDEX items_org;
+ALTER TABLE items DROP COLUMN ORGID;
+
+CREATE TABLE global_roles (
+  NAME TEXT NOT NULL PRIMARY KEY,
+  PERMISSIONS TEXT NOT NULL
+);
+INSERT INTO global_roles (NAME, PERMISSIONS) SELECT NAME, REPLACE(PERMISSIONS, ' org.create', '') FROM roles WHERE ORGID = 1;
+DROP TABLE roles;
+ALTER TABLE global_roles RENAME TO roles;
+
+ALTER TABLE users ADD COLUMN ROLE TEXT NOT NULL DEFAULT 'stock-keeper';
+UPDATE users SET ROLE = COALESCE((SELECT ROLE FROM org_members WHERE org_members.USERID = users.ID AND org_members.ORGID = 1), 'viewer');
+
+DROP TABLE org_members;
+DROP TABLE orgs;
@@ -0,0 +1,41 @@
+-- Everything that existed before organizations belongs to the Default organization
+
+CREATE TABLE orgs (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  NAME TEXT NOT NULL COLLATE NOCASE
+);
+
+INSERT INTO orgs (ID, NAME) VALUES (1, 'Default');
+
+CREATE TABLE org_members (
+  ORGID INTEGER NOT NULL,
+  USERID INTEGER NOT NULL,
+  ROLE TEXT NOT NULL,
+  PRIMARY KEY (ORGID, USERID)
+);
+CREATE INDEX org_members_user ON org_members (USERID);
+
+INSERT INTO org_members (ORGID, USERID, ROLE) SELECT 1, ID, ROLE FROM users WHERE ACTIVE = 1;
+ALTER TABLE users DROP COLUMN ROLE;
+
+-- Every organization configures its own roles. SQLite cannot change a primary key in place.
+CREATE TABLE org_roles (
+  ORGID INTEGER NOT NULL,
+  NAME TEXT NOT NULL,
+  PERMISSIONS TEXT NOT NULL,
+  PRIMARY KEY (ORGID, NAME)
+);
+INSERT INTO org_roles (ORGID, NAME, PERMISSIONS) SELECT 1, NAME, PERMISSIONS FROM roles;
+DROP TABLE roles;
+ALTER TABLE org_roles RENAME TO roles;
+UPDATE roles SET PERMISSIONS = PERMISSIONS || ' org.create' WHERE NAME = 'admin';
+
+ALTER TABLE items ADD COLUMN ORGID INTEGER NOT NULL DEFAULT 1;
+CREATE INDEX items_org ON items (ORGID, ID, DELETED);
+
+ALTER TABLE logs ADD COLUMN ORGID INTEGER NOT NULL DEFAULT 0;
+UPDATE logs SET ORGID = 1;
+
+-- The organization the session is currently working in
+ALTER TABLE sessions ADD COLUMN ORGID INTEGER NOT NULL DEFAULT 0;
+UPDATE sessions SET ORGID = 1;
@@ -0,0 +1,16 @@
+CREATE TABLE loans (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  ORGID INTEGER NOT NULL,
+  ITEMID TEXT NOT NULL COLLATE NOCASE,
+  QUANTITY INTEGER NOT NULL,
+  RETURNED INTEGER NOT NULL DEFAULT 0,
+  BORROWER_USERID INTEGER NOT NULL DEFAULT 0,
+  BORROWER_NAME TEXT NOT NULL COLLATE NOCASE,
+  DUE_AT DATETIME NULL,
+  NOTES TEXT NOT NULL,
+  CHECKED_OUT_AT DATETIME NOT NULL,
+  CHECKED_OUT_BY INTEGER NOT NULL,
+  RETURNED_AT DATETIME NULL
+);
+CREATE INDEX loans_item ON loans (ORGID, ITEMID, RETURNED_AT);
+CREATE INDEX loans_borrower ON loans (ORGID, BORROWER_NAME);
@@ -0,0 +1,16 @@
+UPDATE roles SET PERMISSIONS = REPLACE(PERMISSIONS, ' log.view', '');
+
+CREATE TABLE old_logs (
+  ORGID INTEGER NOT NULL DEFAULT 0,
+  USERID INTEGER NOT NULL,
+  OBJECTID TEXT NOT NULL,
+  ACTION TEXT NOT NULL,
+  DETAILS BLOB,
+  DATE DATETIME NOT NULL
+);
+
+INSERT INTO old_logs (ORGID, USERID, OBJECTID, ACTION, DETAILS, DATE)
+SELECT ORGID, USERID, OBJECTID, ACTION, DETAILS, DATE FROM logs ORDER BY ID;
+
+DROP TABLE logs;
+ALTER TABLE old_logs RENAME TO logs;
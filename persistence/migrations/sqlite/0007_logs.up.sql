@@ -0,0 +1,34 @@
+-- Logs get an ID to page through them and say what kind of object they are about.
+-- DETAILS becomes a JSON object and the old free text details are kept as its message.
+-- SQLite cannot add a primary key in place.
+
+CREATE TABLE new_logs (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  ORGID INTEGER NOT NULL DEFAULT 0,
+  USERID INTEGER NOT NULL,
+  OBJECTTYPE TEXT NOT NULL DEFAULT 'item',
+  OBJECTID TEXT NOT NULL,
+  ACTION TEXT NOT NULL,
+  DETAILS TEXT,
+  DATE DATETIME NOT NULL
+);
+
+INSERT INTO new_logs (ORGID, USERID, OBJECTTYPE, OBJECTID, ACTION, DETAILS, DATE)
+SELECT ORGID, USERID,
+  CASE
+    WHEN ACTION IN ('user created', 'user updated', 'password reset', 'delete user', 'member updated') THEN 'user'
+    WHEN ACTION = 'role updated' THEN 'role'
+    WHEN ACTION = 'org created' THEN 'org'
+    ELSE 'item'
+  END,
+  OBJECTID, ACTION,
+  CASE WHEN DETAILS IS NULL OR DETAILS = '' THEN NULL ELSE json_object('message', CAST(DETAILS AS TEXT)) END,
+  DATE
+FROM logs ORDER BY rowid;
+
+DROP TABLE logs;
+ALTER TABLE new_logs RENAME TO logs;
+CREATE INDEX logs_object ON logs (ORGID, OBJECTTYPE, OBJECTID);
+CREATE INDEX logs_date ON logs (ORGID, DATE);
+
+UPDATE roles SET PERMISSIONS = PERMISSIONS || ' log.view' WHERE NAME = 'admin';
@@ -0,0 +1,6 @@
+-- Items that were deleted before the trash existed are treated as deleted now
+
+ALTER TABLE items ADD COLUMN DELETED_AT DATETIME NULL;
+UPDATE items SET DELETED_AT = CURRENT_TIMESTAMP WHERE DELETED = 1;
+
+UPDATE roles SET PERMISSIONS = PERMISSIONS || ' item.purge' WHERE NAME = 'admin';
@@ -0,0 +1,7 @@
+-- Only the name of the innermost location is kept
+
+ALTER TABLE items ADD COLUMN LOCATION TEXT NOT NULL DEFAULT '' COLLATE NOCASE;
+UPDATE items SET LOCATION = COALESCE((SELECT NAME FROM locations WHERE locations.ID = items.LOCATIONID), '');
+ALTER TABLE items DROP COLUMN LOCATIONID;
+
+DROP TABLE locations;
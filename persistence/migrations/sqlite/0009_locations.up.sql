@@ -0,0 +1,20 @@
+-- Every distinct free text location becomes a top level location. Values that only differ by case or surrounding
+-- spaces are the same location.
+
+CREATE TABLE locations (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  ORGID INTEGER NOT NULL,
+  PARENTID INTEGER NOT NULL DEFAULT 0,
+  NAME TEXT NOT NULL COLLATE NOCASE
+);
+CREATE INDEX locations_parent ON locations (ORGID, PARENTID);
+
+INSERT INTO locations (ORGID, PARENTID, NAME)
+SELECT ORGID, 0, MIN(TRIM(LOCATION)) FROM items WHERE TRIM(LOCATION) <> '' GROUP BY ORGID, TRIM(LOCATION) COLLATE NOCASE;
+
+ALTER TABLE items ADD COLUMN LOCATIONID INTEGER NOT NULL DEFAULT 0;
+UPDATE items SET LOCATIONID = COALESCE((
+  SELECT ID FROM locations WHERE locations.ORGID = items.ORGID AND locations.PARENTID = 0 AND locations.NAME = TRIM(items.LOCATION)
+), 0);
+
+ALTER TABLE items DROP COLUMN LOCATION;
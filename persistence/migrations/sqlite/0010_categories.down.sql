@@ -0,0 +1,8 @@
+-- The values of custom fields are lost
+
+ALTER TABLE items ADD COLUMN CATEGORY TEXT NOT NULL DEFAULT '' COLLATE NOCASE;
+UPDATE items SET CATEGORY = COALESCE((SELECT NAME FROM categories WHERE categories.ID = items.CATEGORYID), '');
+ALTER TABLE items DROP COLUMN CATEGORYID;
+ALTER TABLE items DROP COLUMN FIELDS;
+
+DROP TABLE categories;
@@ -0,0 +1,21 @@
+-- Every distinct free text category becomes a category without custom fields. Values that only differ by case or
+-- surrounding spaces are the same category.
+
+CREATE TABLE categories (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  ORGID INTEGER NOT NULL,
+  NAME TEXT NOT NULL COLLATE NOCASE,
+  FIELDS TEXT NOT NULL DEFAULT '[]'
+);
+CREATE INDEX categories_org ON categories (ORGID, NAME);
+
+INSERT INTO categories (ORGID, NAME)
+SELECT ORGID, MIN(TRIM(CATEGORY)) FROM items WHERE TRIM(CATEGORY) <> '' GROUP BY ORGID, TRIM(CATEGORY) COLLATE NOCASE;
+
+ALTER TABLE items ADD COLUMN CATEGORYID INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE items ADD COLUMN FIELDS TEXT NOT NULL DEFAULT '{}' COLLATE NOCASE;
+UPDATE items SET CATEGORYID = COALESCE((
+  SELECT ID FROM categories WHERE categories.ORGID = items.ORGID AND categories.NAME = TRIM(items.CATEGORY)
+), 0);
+
+ALTER TABLE items DROP COLUMN CATEGORY;
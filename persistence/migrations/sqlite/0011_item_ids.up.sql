@@ -0,0 +1,6 @@
+-- The next number used to make up an ID for items that do not have a barcode of their own
+
+CREATE TABLE item_ids (
+  ORGID INTEGER PRIMARY KEY,
+  NEXT INTEGER NOT NULL DEFAULT 1
+);
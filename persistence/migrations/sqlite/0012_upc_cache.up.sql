@@ -0,0 +1,13 @@
+-- Results of barcode lookups, including barcodes the lookup API did not know,
+-- so that the API is not asked again until they expire
+
+CREATE TABLE upc_cache (
+  BARCODE TEXT PRIMARY KEY,
+  FOUND INTEGER NOT NULL,
+  NAME TEXT NOT NULL DEFAULT '',
+  CATEGORY TEXT NOT NULL DEFAULT '',
+  PICTURE_URL TEXT NOT NULL DEFAULT '',
+  FETCHED_AT DATETIME NOT NULL
+);
+
+UPDATE roles SET PERMISSIONS = PERMISSIONS || ' upc.manage' WHERE NAME = 'admin';
@@ -0,0 +1 @@
+DROP TABLE upc_products;
@@ -0,0 +1,10 @@
+-- Products imported from a barcode dataset so that barcodes can be looked up
+-- without the internet
+
+CREATE TABLE upc_products (
+  GTIN TEXT PRIMARY KEY,
+  NAME TEXT NOT NULL,
+  BRAND TEXT NOT NULL DEFAULT '',
+  CATEGORY TEXT NOT NULL DEFAULT '',
+  PICTURE_URL TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,15 @@
+-- Files such as manuals and receipts attached to items. The file itself is
+-- kept in storage under STORAGE_KEY.
+
+CREATE TABLE attachments (
+  ID INTEGER PRIMARY KEY AUTOINCREMENT,
+  ORGID INTEGER NOT NULL,
+  ITEMID TEXT NOT NULL COLLATE NOCASE,
+  FILENAME TEXT NOT NULL,
+  CONTENT_TYPE TEXT NOT NULL,
+  SIZE INTEGER NOT NULL,
+  STORAGE_KEY TEXT NOT NULL,
+  UPLOADED_BY INTEGER NOT NULL,
+  UPLOADED_AT DATETIME NOT NULL
+);
+CREATE INDEX attachments_item ON attachments (ORGID, ITEMID);
@@ -5,9 +5,11 @@ import (
 	"testing"
 
 	"errors"
+	"time"
 
 	"github.com/Timothylock/inventory-management/config"
 	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/passwords"
 	"github.com/Timothylock/inventory-management/users"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
@@ -17,14 +19,30 @@ import (
 const (
 	updateItem       = `UPDATE items.+`
 	doesItemExist    = `SELECT count\(1\) FROM items.+`
+	isInTrash        = `SELECT count\(1\) FROM items WHERE .+ AND DELETED = 1`
+	getQuantity      = `SELECT QUANTITY, CHECKED_OUT FROM items.+`
 	deleteItem       = `UPDATE items SET DELETED=1.+`
-	GetUser          = `SELECT ID\, ISSYSADMIN\, EMAIL\, TOKEN\, USERNAME FROM users.+`
+	GetUser          = `SELECT users.ID AS ID, EMAIL, users.TOKEN AS TOKEN, USERNAME, COALESCE\(org_members.ROLE, ''\) AS ROLE, COALESCE\(PERMISSIONS, ''\) AS PERMISSIONS FROM users.+`
+	GetUserPassword  = `SELECT users.ID AS ID, EMAIL, users.TOKEN AS TOKEN, USERNAME, COALESCE\(org_members.ROLE, ''\) AS ROLE, COALESCE\(PERMISSIONS, ''\) AS PERMISSIONS, COALESCE\(org_members.ORGID, 0\) AS ORGID, PASSWORD FROM users.+`
+	doesRoleExist    = `SELECT count\(1\) FROM roles.+`
+	setPassword      = `UPDATE users SET PASSWORD = \? WHERE ID = \?`
+	getSessionUser   = `SELECT users.ID AS ID, EMAIL, users.TOKEN AS TOKEN, USERNAME, COALESCE\(org_members.ROLE, ''\) AS ROLE, COALESCE\(PERMISSIONS, ''\) AS PERMISSIONS, sessions.ORGID AS ORGID, sessions.ID AS SESSIONID, EXPIRES_AT.+`
+	touchSession     = `UPDATE sessions SET LAST_SEEN_AT = \? WHERE ID = \?`
+	revokeSessions   = `UPDATE sessions SET REVOKED=1 WHERE USERID=\?.+`
 	addItem          = `INSERT INTO items`
 	addUser          = `INSERT INTO users.+`
 	addUserOverwrite = `UPDATE users.+`
 	addItemOverwrite = `UPDATE items.+`
-	searchItems      = `SELECT search.ID AS ID, NAME, CATEGORY, PICTURE_URL, DETAILS, LOCATION, USERNAME, QUANTITY, STATUS FROM.+`
-	deleteUser       = `UPDATE users SET ACTIVE=0.+`
+	searchItems      = `SELECT search.ID AS ID, search.NAME AS NAME, CATEGORYID, PICTURE_URL, DETAILS, search.FIELDS AS FIELDS, LOCATIONID, USERNAME,\s+QUANTITY, QUANTITY - CHECKED_OUT AS AVAILABLE, CHECKED_OUT, STATUS FROM.+`
+	getLocations     = `SELECT ID, PARENTID, NAME FROM locations.+`
+	getCategories    = `SELECT ID, NAME, FIELDS FROM categories.+`
+	countItems       = `SELECT count\(1\) FROM items AS search.+`
+	deleteUser       = `DELETE FROM org_members.+`
+	deactivateUser   = `UPDATE users SET ACTIVE=0.+`
+	countMemberships = `SELECT count\(1\) FROM org_members.+`
+	getMemberRole    = `SELECT ROLE FROM org_members.+`
+	addMember        = `INSERT INTO org_members.+`
+	updateMember     = `UPDATE org_members SET ROLE.+`
 )
 
 func newTestDB(t *testing.T) (*MySQL, sqlmock.Sqlmock) {
@@ -34,7 +52,10 @@ func newTestDB(t *testing.T) (*MySQL, sqlmock.Sqlmock) {
 	}
 
 	return &MySQL{
-		conn: sqlx.NewDb(db, "sqlmock"),
+		store{
+			conn:      sqlx.NewDb(db, "sqlmock"),
+			passwords: passwords.NewDefaultService(4),
+		},
 	}, mock
 }
 
@@ -55,77 +76,140 @@ func TestMoveItem(t *testing.T) {
 	defer db.conn.Close()
 
 	type testCase struct {
-		testName    string
-		direction   string
-		directionDB string
+		testName     string
+		direction    string
+		quantity     int
+		checkedOut   int
+		expectedOut  int
+		expectMoved  int
+		expectStatus string
+		expectErr    error
 	}
 
 	testCases := []testCase{
 		{
-			testName:    "check in item",
-			direction:   "in",
-			directionDB: "checked in",
+			testName:     "check in item",
+			direction:    "in",
+			checkedOut:   10,
+			expectedOut:  0,
+			expectMoved:  10,
+			expectStatus: "checked in",
+		},
+		{
+			testName:     "check out item",
+			direction:    "out",
+			expectedOut:  10,
+			expectMoved:  10,
+			expectStatus: "checked out",
+		},
+		{
+			testName:     "check out some",
+			direction:    "out",
+			quantity:     3,
+			checkedOut:   2,
+			expectedOut:  5,
+			expectMoved:  3,
+			expectStatus: "partially checked out",
 		},
 		{
-			testName:    "check out item",
-			direction:   "out",
-			directionDB: "checked out",
+			testName:     "check in some",
+			direction:    "in",
+			quantity:     3,
+			checkedOut:   10,
+			expectedOut:  7,
+			expectMoved:  3,
+			expectStatus: "partially checked out",
 		},
 		{
-			testName:  "invalid direction",
-			direction: "outtt",
+			testName:   "check out too many",
+			direction:  "out",
+			quantity:   9,
+			checkedOut: 2,
+			expectErr:  items.NotEnoughAvailableErr,
+		},
+		{
+			testName:   "check in too many",
+			direction:  "in",
+			quantity:   3,
+			checkedOut: 2,
+			expectErr:  items.NotEnoughCheckedOutErr,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.testName, func(t *testing.T) {
-			rows := sqlmock.NewRows([]string{"COUNT(1)"})
-			rows.AddRow(1)
-
-			if tc.direction != "in" && tc.direction != "out" {
-				err := db.MoveItem("1234", tc.direction, 123)
-				assert.Error(t, err)
-			} else {
-				mock.ExpectQuery(doesItemExist).
-					WithArgs("1234").
-					WillReturnRows(rows)
+			rows := sqlmock.NewRows([]string{"QUANTITY", "CHECKED_OUT"})
+			rows.AddRow(10, tc.checkedOut)
+
+			mock.ExpectQuery(getQuantity).
+				WithArgs(1, "1234").
+				WillReturnRows(rows)
+			if tc.expectErr == nil {
 				mock.ExpectExec(updateItem).
-					WithArgs(tc.directionDB, 123, "1234").
+					WithArgs(tc.expectStatus, tc.expectedOut, 123, 1, "1234", tc.checkedOut).
 					WillReturnResult(sqlmock.NewResult(1234, 1))
-
-				err := db.MoveItem("1234", tc.direction, 123)
-				assert.NoError(t, err)
-				assert.NoError(t, mock.ExpectationsWereMet())
 			}
+
+			n, err := db.MoveItem(1, "1234", tc.direction, tc.quantity, 123)
+			assert.Equal(t, tc.expectErr, err)
+			assert.Equal(t, tc.expectMoved, n)
+			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
 
-func TestMoveItemNotFound(t *testing.T) {
+func TestMoveItemInvalidDirection(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	rows := sqlmock.NewRows([]string{"COUNT(1)"})
-	rows.AddRow(0)
+	_, err := db.MoveItem(1, "1234", "outtt", 0, 123)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	mock.ExpectQuery(doesItemExist).
-		WithArgs("1234").
+func TestMoveItemConcurrentMove(t *testing.T) {
+	db, mock := newTestDB(t)
+	defer db.conn.Close()
+
+	rows := sqlmock.NewRows([]string{"QUANTITY", "CHECKED_OUT"})
+	rows.AddRow(10, 0)
+
+	mock.ExpectQuery(getQuantity).
+		WithArgs(1, "1234").
 		WillReturnRows(rows)
+	mock.ExpectExec(updateItem).
+		WithArgs("checked out", 10, 123, 1, "1234", 0).
+		WillReturnResult(sqlmock.NewResult(0, 0))
 
-	err := db.MoveItem("1234", "in", 123)
+	_, err := db.MoveItem(1, "1234", "out", 0, 123)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestMoveItemNotFound(t *testing.T) {
+	db, mock := newTestDB(t)
+	defer db.conn.Close()
+
+	rows := sqlmock.NewRows([]string{"QUANTITY", "CHECKED_OUT"})
+
+	mock.ExpectQuery(getQuantity).
+		WithArgs(1, "1234").
+		WillReturnRows(rows)
+
+	_, err := db.MoveItem(1, "1234", "in", 0, 123)
+	assert.Equal(t, items.ItemNotFoundErr, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestMoveItemInternalErr(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	mock.ExpectQuery(doesItemExist).
-		WithArgs("1234").
+	mock.ExpectQuery(getQuantity).
+		WithArgs(1, "1234").
 		WillReturnError(errors.New("sorry"))
 
-	err := db.MoveItem("1234", "in", 123)
+	_, err := db.MoveItem(1, "1234", "in", 0, 123)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -135,10 +219,10 @@ func TestDeleteItemInternalErr(t *testing.T) {
 	defer db.conn.Close()
 
 	mock.ExpectExec(deleteItem).
-		WithArgs(123, "1234").
+		WithArgs(sqlmock.AnyArg(), 123, 1, "1234").
 		WillReturnError(errors.New("sorry"))
 
-	err := db.DeleteItem("1234", 123)
+	err := db.DeleteItem(1, "1234", 123)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -148,10 +232,10 @@ func TestDeleteItemNoRowsAff(t *testing.T) {
 	defer db.conn.Close()
 
 	mock.ExpectExec(deleteItem).
-		WithArgs(123, "1234").
+		WithArgs(sqlmock.AnyArg(), 123, 1, "1234").
 		WillReturnResult(sqlmock.NewResult(1, 0))
 
-	err := db.DeleteItem("1234", 123)
+	err := db.DeleteItem(1, "1234", 123)
 	assert.Equal(t, items.ItemNotFoundErr, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -161,10 +245,10 @@ func TestDeleteItemSuccess(t *testing.T) {
 	defer db.conn.Close()
 
 	mock.ExpectExec(deleteItem).
-		WithArgs(123, "1234").
+		WithArgs(sqlmock.AnyArg(), 123, 1, "1234").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := db.DeleteItem("1234", 123)
+	err := db.DeleteItem(1, "1234", 123)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -183,18 +267,22 @@ func TestSearchItems(t *testing.T) {
 		{
 			testName: "find 1 item",
 			addRows: func(rows *sqlmock.Rows) {
-				rows.AddRow("1", "foo", "fi", "bar", "fum", "bah", "humbug", 1, "checked in")
+				rows.AddRow("1", "foo", 3, "bar", "fum", `{"Serial": "A1", "Removed": "x"}`, 7, "humbug", 1, 1, 0, "checked in")
 			},
 			expected: items.ItemDetailList{
 				{
 					ID:              "1",
 					Name:            "foo",
+					CategoryID:      3,
 					Category:        "fi",
 					PictureURL:      "bar",
 					Details:         "fum",
+					LocationID:      7,
 					Location:        "bah",
+					Fields:          map[string]string{"Serial": "A1"},
 					LastPerformedBy: "humbug",
 					Quantity:        1,
+					Available:       1,
 					Status:          "checked in",
 				},
 			},
@@ -202,30 +290,38 @@ func TestSearchItems(t *testing.T) {
 		{
 			testName: "find multiple items",
 			addRows: func(rows *sqlmock.Rows) {
-				rows.AddRow("1", "foo", "fi", "bar", "fum", "bah", "humbug", 1, "checked in")
-				rows.AddRow("2", "foo", "fi", "bar", "fum", "bah", "humbug", 1, "checked in")
+				rows.AddRow("1", "foo", 3, "bar", "fum", `{"Serial": "A1", "Removed": "x"}`, 7, "humbug", 1, 1, 0, "checked in")
+				rows.AddRow("2", "foo", 3, "bar", "fum", `{}`, 7, "humbug", 1, 1, 0, "checked in")
 			},
 			expected: items.ItemDetailList{
 				{
 					ID:              "1",
 					Name:            "foo",
+					CategoryID:      3,
 					Category:        "fi",
 					PictureURL:      "bar",
 					Details:         "fum",
+					LocationID:      7,
 					Location:        "bah",
+					Fields:          map[string]string{"Serial": "A1"},
 					LastPerformedBy: "humbug",
 					Quantity:        1,
+					Available:       1,
 					Status:          "checked in",
 				},
 				{
 					ID:              "2",
 					Name:            "foo",
+					CategoryID:      3,
 					Category:        "fi",
 					PictureURL:      "bar",
 					Details:         "fum",
+					LocationID:      7,
 					Location:        "bah",
+					Fields:          map[string]string{},
 					LastPerformedBy: "humbug",
 					Quantity:        1,
+					Available:       1,
 					Status:          "checked in",
 				},
 			},
@@ -239,17 +335,27 @@ func TestSearchItems(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.testName, func(t *testing.T) {
-			rows := sqlmock.NewRows([]string{"ID", "NAME", "CATEGORY", "PICTURE_URL", "DETAILS", "LOCATION", "USERNAME", "QUANTITY", "STATUS"})
+			rows := sqlmock.NewRows([]string{"ID", "NAME", "CATEGORYID", "PICTURE_URL", "DETAILS", "FIELDS", "LOCATIONID", "USERNAME", "QUANTITY", "AVAILABLE", "CHECKED_OUT", "STATUS"})
 			tc.addRows(rows)
 
+			mock.ExpectQuery(getLocations).
+				WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"ID", "PARENTID", "NAME"}).AddRow(7, 0, "bah"))
+			mock.ExpectQuery(getCategories).
+				WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"ID", "NAME", "FIELDS"}).AddRow(3, "fi", `[{"name": "Serial", "type": "text"}]`))
+			mock.ExpectQuery(countItems).
+				WithArgs(1, "foo", "foo").
+				WillReturnRows(sqlmock.NewRows([]string{"count(1)"}).AddRow(len(tc.expected)))
 			mock.ExpectQuery(searchItems).
-				WithArgs("foo", "foo").
+				WithArgs(1, "foo", "foo", 10).
 				WillReturnRows(rows)
 
-			r, err := db.SearchItems("foo")
+			r, total, err := db.SearchItems(1, items.ItemFilter{Search: "foo", Limit: 10})
 			assert.NoError(t, err)
 			assert.NoError(t, mock.ExpectationsWereMet())
 			assert.Equal(t, tc.expected, r)
+			assert.Equal(t, len(tc.expected), total)
 		})
 	}
 }
@@ -262,10 +368,10 @@ func TestAddItemAlreadyExists(t *testing.T) {
 	rows.AddRow(1)
 
 	mock.ExpectQuery(doesItemExist).
-		WithArgs("1234").
+		WithArgs(1, "1234").
 		WillReturnRows(rows)
 
-	err := db.AddItem(items.ItemDetail{ID: "1234"}, false)
+	err := db.AddItem(1, items.ItemDetail{ID: "1234"}, false)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -278,10 +384,10 @@ func TestAddItemAlreadyExistsError(t *testing.T) {
 	rows.AddRow(1)
 
 	mock.ExpectQuery(doesItemExist).
-		WithArgs("1234").
+		WithArgs(1, "1234").
 		WillReturnError(errors.New("some error"))
 
-	err := db.AddItem(items.ItemDetail{ID: "1234"}, false)
+	err := db.AddItem(1, items.ItemDetail{ID: "1234"}, false)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -296,23 +402,26 @@ func TestAddItemSuccess(t *testing.T) {
 	item := items.ItemDetail{
 		ID:              "ID",
 		Name:            "NAME",
-		Category:        "CATEGORY",
+		CategoryID:      2,
 		PictureURL:      "PICTURE_URL",
 		Details:         "DETAILS",
-		Location:        "LOCATION",
+		Fields:          map[string]string{"Serial": "A1"},
 		LastPerformedBy: "1",
 		Quantity:        1,
 		Status:          "checked in",
 	}
 
 	mock.ExpectQuery(doesItemExist).
-		WithArgs("ID").
+		WithArgs(1, "ID").
 		WillReturnRows(rows)
+	mock.ExpectQuery(isInTrash).
+		WithArgs(1, "ID").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(1)"}).AddRow(0))
 	mock.ExpectExec(addItem).
-		WithArgs("ID", "NAME", "CATEGORY", "PICTURE_URL", "DETAILS", "LOCATION", "1", 1, "checked in").
+		WithArgs(1, "ID", "NAME", 2, "PICTURE_URL", "DETAILS", `{"Serial":"A1"}`, 0, "1", 1, "checked in").
 		WillReturnResult(sqlmock.NewResult(123, 1))
 
-	err := db.AddItem(item, false)
+	err := db.AddItem(1, item, false)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -327,27 +436,49 @@ func TestAddItemOverwriteSuccess(t *testing.T) {
 	item := items.ItemDetail{
 		ID:              "ID",
 		Name:            "NAME",
-		Category:        "CATEGORY",
+		CategoryID:      2,
 		PictureURL:      "PICTURE_URL",
 		Details:         "DETAILS",
-		Location:        "LOCATION",
+		Fields:          map[string]string{"Serial": "A1"},
 		LastPerformedBy: "1",
 		Quantity:        1,
 		Status:          "checked in",
 	}
 
 	mock.ExpectQuery(doesItemExist).
-		WithArgs("ID").
+		WithArgs(1, "ID").
 		WillReturnRows(rows)
+	mock.ExpectQuery(getQuantity).
+		WithArgs(1, "ID").
+		WillReturnRows(sqlmock.NewRows([]string{"QUANTITY", "CHECKED_OUT"}).AddRow(3, 0))
 	mock.ExpectExec(addItemOverwrite).
-		WithArgs("ID", "NAME", "CATEGORY", "PICTURE_URL", "DETAILS", "LOCATION", "1", 1, "ID").
+		WithArgs("ID", "NAME", 2, "PICTURE_URL", "DETAILS", `{"Serial":"A1"}`, 0, "1", 1, "checked in", 1, "ID").
 		WillReturnResult(sqlmock.NewResult(123, 1))
 
-	err := db.AddItem(item, true)
+	err := db.AddItem(1, item, true)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestAddItemOverwriteBelowCheckedOut(t *testing.T) {
+	db, mock := newTestDB(t)
+	defer db.conn.Close()
+
+	rows := sqlmock.NewRows([]string{"COUNT(1)"})
+	rows.AddRow(1)
+
+	mock.ExpectQuery(doesItemExist).
+		WithArgs(1, "ID").
+		WillReturnRows(rows)
+	mock.ExpectQuery(getQuantity).
+		WithArgs(1, "ID").
+		WillReturnRows(sqlmock.NewRows([]string{"QUANTITY", "CHECKED_OUT"}).AddRow(3, 2))
+
+	err := db.AddItem(1, items.ItemDetail{ID: "ID", Quantity: 1}, true)
+	assert.Equal(t, items.QuantityBelowCheckedOutErr, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestAddItemOverwriteFailure(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
@@ -358,20 +489,20 @@ func TestAddItemOverwriteFailure(t *testing.T) {
 	item := items.ItemDetail{
 		ID:              "ID",
 		Name:            "NAME",
-		Category:        "CATEGORY",
+		CategoryID:      2,
 		PictureURL:      "PICTURE_URL",
 		Details:         "DETAILS",
-		Location:        "LOCATION",
+		Fields:          map[string]string{"Serial": "A1"},
 		LastPerformedBy: "1",
 		Quantity:        1,
 		Status:          "checked in",
 	}
 
 	mock.ExpectQuery(doesItemExist).
-		WithArgs("ID").
+		WithArgs(1, "ID").
 		WillReturnRows(rows)
 
-	err := db.AddItem(item, true)
+	err := db.AddItem(1, item, true)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -380,15 +511,36 @@ func TestIsValidToken(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	rows := sqlmock.NewRows([]string{"ID", "ISSYSADMIN", "EMAIL", "TOKEN", "USERNAME"})
-	rows.AddRow(0, 1, "foo@bar.com", "someToken", "someUser")
+	rows := sqlmock.NewRows([]string{"ID", "ROLE", "EMAIL", "TOKEN", "USERNAME", "SESSIONID", "EXPIRES_AT"})
+	rows.AddRow(0, "admin", "foo@bar.com", "foo", "someUser", 5, time.Now().Add(time.Hour))
 
-	mock.ExpectQuery(GetUser).
+	mock.ExpectQuery(getSessionUser).
 		WithArgs("foo").
 		WillReturnRows(rows)
+	mock.ExpectExec(touchSession).
+		WithArgs(sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	user, err := db.GetUserByToken("foo")
 	assert.True(t, user.Valid)
+	assert.Equal(t, 5, user.SessionID)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsExpiredToken(t *testing.T) {
+	db, mock := newTestDB(t)
+	defer db.conn.Close()
+
+	rows := sqlmock.NewRows([]string{"ID", "ROLE", "EMAIL", "TOKEN", "USERNAME", "SESSIONID", "EXPIRES_AT"})
+	rows.AddRow(0, "admin", "foo@bar.com", "foo", "someUser", 5, time.Now().Add(-time.Hour))
+
+	mock.ExpectQuery(getSessionUser).
+		WithArgs("foo").
+		WillReturnRows(rows)
+
+	user, err := db.GetUserByToken("foo")
+	assert.False(t, user.Valid)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -397,9 +549,9 @@ func TestIsNotValidToken(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	rows := sqlmock.NewRows([]string{"ID", "ISSYSADMIN", "EMAIL", "TOKEN", "USERNAME"})
+	rows := sqlmock.NewRows([]string{"ID", "ROLE", "EMAIL", "TOKEN", "USERNAME", "SESSIONID", "EXPIRES_AT"})
 
-	mock.ExpectQuery(GetUser).
+	mock.ExpectQuery(getSessionUser).
 		WithArgs("foo").
 		WillReturnRows(rows)
 
@@ -409,11 +561,21 @@ func TestIsNotValidToken(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestIsEmptyToken(t *testing.T) {
+	db, mock := newTestDB(t)
+	defer db.conn.Close()
+
+	user, err := db.GetUserByToken("")
+	assert.False(t, user.Valid)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestIsValidTokenFail(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	mock.ExpectQuery(GetUser).
+	mock.ExpectQuery(getSessionUser).
 		WithArgs("foo").
 		WillReturnError(errors.New("sorry"))
 
@@ -426,20 +588,26 @@ func TestGetUserSuccess(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	rows := sqlmock.NewRows([]string{"ID", "ISSYSADMIN", "EMAIL", "TOKEN", "USERNAME"})
-	rows.AddRow(123, 1, "foo@bar.com", "someToken", "someUser")
+	hash, err := db.passwords.Hash("pass")
+	assert.NoError(t, err)
 
-	mock.ExpectQuery(GetUser).
-		WithArgs("someUser", "nU4eI71bcnBGqeO0t9tXvY1u5oQ=").
+	rows := sqlmock.NewRows([]string{"ID", "ORGID", "ROLE", "EMAIL", "TOKEN", "USERNAME", "PASSWORD"})
+	rows.AddRow(123, 1, "admin", "foo@bar.com", "someToken", "someUser", hash)
+
+	mock.ExpectQuery(GetUserPassword).
+		WithArgs("someUser").
 		WillReturnRows(rows)
 
 	expectedUser := users.User{
-		Valid:      true,
-		ID:         123,
-		Token:      "someToken",
-		Username:   "someUser",
-		IsSysAdmin: true,
-		Email:      "foo@bar.com",
+		Valid:       true,
+		ID:          123,
+		Token:       "someToken",
+		Username:    "someUser",
+		IsSysAdmin:  true,
+		Email:       "foo@bar.com",
+		OrgID:       1,
+		Role:        "admin",
+		Permissions: []string{},
 	}
 	expectedUserJson, err := json.Marshal(expectedUser)
 	assert.NoError(t, err)
@@ -452,14 +620,70 @@ func TestGetUserSuccess(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestGetUserLegacyHashIsUpgraded(t *testing.T) {
+	db, mock := newTestDB(t)
+	defer db.conn.Close()
+
+	rows := sqlmock.NewRows([]string{"ID", "ORGID", "ROLE", "EMAIL", "TOKEN", "USERNAME", "PASSWORD"})
+	rows.AddRow(123, 1, "admin", "foo@bar.com", "someToken", "someUser", "nU4eI71bcnBGqeO0t9tXvY1u5oQ=")
+
+	mock.ExpectQuery(GetUserPassword).
+		WithArgs("someUser").
+		WillReturnRows(rows)
+	mock.ExpectExec(setPassword).
+		WithArgs(sqlmock.AnyArg(), 123).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	u, err := db.GetUser("someUser", "pass")
+	assert.NoError(t, err)
+	assert.True(t, u.Valid)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserLegacyHashUpgradeFails(t *testing.T) {
+	db, mock := newTestDB(t)
+	defer db.conn.Close()
+
+	rows := sqlmock.NewRows([]string{"ID", "ORGID", "ROLE", "EMAIL", "TOKEN", "USERNAME", "PASSWORD"})
+	rows.AddRow(123, 1, "admin", "foo@bar.com", "someToken", "someUser", "nU4eI71bcnBGqeO0t9tXvY1u5oQ=")
+
+	mock.ExpectQuery(GetUserPassword).
+		WithArgs("someUser").
+		WillReturnRows(rows)
+	mock.ExpectExec(setPassword).
+		WithArgs(sqlmock.AnyArg(), 123).
+		WillReturnError(errors.New("sorry"))
+
+	_, err := db.GetUser("someUser", "pass")
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserWrongPassword(t *testing.T) {
+	db, mock := newTestDB(t)
+	defer db.conn.Close()
+
+	rows := sqlmock.NewRows([]string{"ID", "ORGID", "ROLE", "EMAIL", "TOKEN", "USERNAME", "PASSWORD"})
+	rows.AddRow(123, 1, "admin", "foo@bar.com", "someToken", "someUser", "nU4eI71bcnBGqeO0t9tXvY1u5oQ=")
+
+	mock.ExpectQuery(GetUserPassword).
+		WithArgs("someUser").
+		WillReturnRows(rows)
+
+	u, err := db.GetUser("someUser", "wrong")
+	assert.NoError(t, err)
+	assert.False(t, u.Valid)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetUserNotFound(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	rows := sqlmock.NewRows([]string{"ID", "ISSYSADMIN", "EMAIL", "TOKEN"})
+	rows := sqlmock.NewRows([]string{"ID", "ROLE", "EMAIL", "TOKEN"})
 
-	mock.ExpectQuery(GetUser).
-		WithArgs("user", "nU4eI71bcnBGqeO0t9tXvY1u5oQ=").
+	mock.ExpectQuery(GetUserPassword).
+		WithArgs("user").
 		WillReturnRows(rows)
 
 	expectedUser := users.User{
@@ -480,8 +704,8 @@ func TestGetUserErr(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	mock.ExpectQuery(GetUser).
-		WithArgs("user", "nU4eI71bcnBGqeO0t9tXvY1u5oQ=").
+	mock.ExpectQuery(GetUserPassword).
+		WithArgs("user").
 		WillReturnError(errors.New("error"))
 
 	_, err := db.GetUser("user", "pass")
@@ -493,25 +717,28 @@ func TestGetUserByUsernameSuccess(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	rows := sqlmock.NewRows([]string{"ID", "ISSYSADMIN", "EMAIL", "TOKEN", "USERNAME"})
-	rows.AddRow(123, 1, "foo@bar.com", "someToken", "someUser")
+	rows := sqlmock.NewRows([]string{"ID", "ROLE", "EMAIL", "TOKEN", "USERNAME"})
+	rows.AddRow(123, "admin", "foo@bar.com", "someToken", "someUser")
 
 	mock.ExpectQuery(GetUser).
-		WithArgs("someUser").
+		WithArgs(1, "someUser").
 		WillReturnRows(rows)
 
 	expectedUser := users.User{
-		Valid:      true,
-		ID:         123,
-		Token:      "someToken",
-		Username:   "someUser",
-		IsSysAdmin: true,
-		Email:      "foo@bar.com",
+		Valid:       true,
+		ID:          123,
+		Token:       "someToken",
+		Username:    "someUser",
+		IsSysAdmin:  true,
+		Email:       "foo@bar.com",
+		OrgID:       1,
+		Role:        "admin",
+		Permissions: []string{},
 	}
 	expectedUserJson, err := json.Marshal(expectedUser)
 	assert.NoError(t, err)
 
-	u, err := db.GetUserByUsername("someUser", 123)
+	u, err := db.GetUserByUsername("someUser", 1)
 	assert.NoError(t, err)
 	uJson, err := json.Marshal(u)
 
@@ -523,10 +750,10 @@ func TestGetUserByUsernameNotFound(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	rows := sqlmock.NewRows([]string{"ID", "ISSYSADMIN", "EMAIL", "TOKEN"})
+	rows := sqlmock.NewRows([]string{"ID", "ROLE", "EMAIL", "TOKEN"})
 
 	mock.ExpectQuery(GetUser).
-		WithArgs("user").
+		WithArgs(1, "user").
 		WillReturnRows(rows)
 
 	expectedUser := users.User{
@@ -535,7 +762,7 @@ func TestGetUserByUsernameNotFound(t *testing.T) {
 	expectedUserJson, err := json.Marshal(expectedUser)
 	assert.NoError(t, err)
 
-	u, err := db.GetUserByUsername("user", 123)
+	u, err := db.GetUserByUsername("user", 1)
 	assert.NoError(t, err)
 	uJson, err := json.Marshal(u)
 
@@ -548,10 +775,10 @@ func TestGetUserByUsernameErr(t *testing.T) {
 	defer db.conn.Close()
 
 	mock.ExpectQuery(GetUser).
-		WithArgs("user").
+		WithArgs(1, "user").
 		WillReturnError(errors.New("error"))
 
-	_, err := db.GetUserByUsername("user", 123)
+	_, err := db.GetUserByUsername("user", 1)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -560,35 +787,42 @@ func TestGetUsersSuccess(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	rows := sqlmock.NewRows([]string{"ID", "ISSYSADMIN", "EMAIL", "TOKEN", "USERNAME"})
-	rows.AddRow(123, 1, "foo@bar.com", "someToken", "someUser")
-	rows.AddRow(124, 0, "foo2@bar.com", "someToken2", "someUser2")
+	rows := sqlmock.NewRows([]string{"ID", "ROLE", "EMAIL", "TOKEN", "USERNAME"})
+	rows.AddRow(123, "admin", "foo@bar.com", "someToken", "someUser")
+	rows.AddRow(124, "viewer", "foo2@bar.com", "someToken2", "someUser2")
 
 	mock.ExpectQuery(GetUser).
+		WithArgs(1).
 		WillReturnRows(rows)
 
 	expectedUsers := users.MultipleUsers{
 		{
-			Valid:      true,
-			ID:         123,
-			Token:      "someToken",
-			Username:   "someUser",
-			IsSysAdmin: true,
-			Email:      "foo@bar.com",
+			Valid:       true,
+			ID:          123,
+			Token:       "someToken",
+			Username:    "someUser",
+			IsSysAdmin:  true,
+			Email:       "foo@bar.com",
+			OrgID:       1,
+			Role:        "admin",
+			Permissions: []string{},
 		},
 		{
-			Valid:      true,
-			ID:         124,
-			Token:      "someToken2",
-			Username:   "someUser2",
-			IsSysAdmin: false,
-			Email:      "foo2@bar.com",
+			Valid:       true,
+			ID:          124,
+			Token:       "someToken2",
+			Username:    "someUser2",
+			IsSysAdmin:  false,
+			Email:       "foo2@bar.com",
+			OrgID:       1,
+			Role:        "viewer",
+			Permissions: []string{},
 		},
 	}
 	expectedUserJson, err := json.Marshal(expectedUsers)
 	assert.NoError(t, err)
 
-	u, err := db.GetUsers()
+	u, err := db.GetUsers(1)
 	assert.NoError(t, err)
 	uJson, err := json.Marshal(u)
 
@@ -603,7 +837,7 @@ func TestGetUsersErr(t *testing.T) {
 	mock.ExpectQuery(GetUser).
 		WillReturnError(errors.New("error"))
 
-	_, err := db.GetUsers()
+	_, err := db.GetUsers(1)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -612,14 +846,20 @@ func TestAddUserAlreadyExists(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	rows := sqlmock.NewRows([]string{"ID", "ISSYSADMIN", "EMAIL", "TOKEN"})
-	rows.AddRow(123, 1, "foo@bar.com", "someToken")
+	roleRows := sqlmock.NewRows([]string{"COUNT(1)"})
+	roleRows.AddRow(1)
+	mock.ExpectQuery(doesRoleExist).
+		WithArgs(1, "admin").
+		WillReturnRows(roleRows)
+
+	rows := sqlmock.NewRows([]string{"ID", "ROLE", "EMAIL", "TOKEN"})
+	rows.AddRow(123, "admin", "foo@bar.com", "someToken")
 
 	mock.ExpectQuery(GetUser).
-		WithArgs("user").
+		WithArgs(1, "user").
 		WillReturnRows(rows)
 
-	err := db.AddUser("user", "email", "password", true, false)
+	err := db.AddUser(1, "user", "email", "password", "admin", false)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -628,17 +868,29 @@ func TestAddUserSuccess(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	rows := sqlmock.NewRows([]string{"ID", "ISSYSADMIN", "EMAIL", "TOKEN"})
+	roleRows := sqlmock.NewRows([]string{"COUNT(1)"})
+	roleRows.AddRow(1)
+	mock.ExpectQuery(doesRoleExist).
+		WithArgs(1, "admin").
+		WillReturnRows(roleRows)
+
+	rows := sqlmock.NewRows([]string{"ID", "ROLE", "EMAIL", "TOKEN"})
 
 	mock.ExpectQuery(GetUser).
-		WithArgs("user").
+		WithArgs(1, "user").
 		WillReturnRows(rows)
 
 	mock.ExpectExec(addUser).
-		WithArgs("user", "email", "W6ph5Mm5Pz8GgiULbPgzG37mj9g=", sqlmock.AnyArg(), true).
+		WithArgs("user", "email", sqlmock.AnyArg(), sqlmock.AnyArg(), true).
 		WillReturnResult(sqlmock.NewResult(123, 1))
-
-	err := db.AddUser("user", "email", "password", true, false)
+	mock.ExpectQuery(getMemberRole).
+		WithArgs(1, 123).
+		WillReturnRows(sqlmock.NewRows([]string{"ROLE"}))
+	mock.ExpectExec(addMember).
+		WithArgs(1, 123, "admin").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := db.AddUser(1, "user", "email", "password", "admin", false)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -647,13 +899,19 @@ func TestAddUserOverwriteNotExists(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	rows := sqlmock.NewRows([]string{"ID", "ISSYSADMIN", "EMAIL", "TOKEN"})
+	roleRows := sqlmock.NewRows([]string{"COUNT(1)"})
+	roleRows.AddRow(1)
+	mock.ExpectQuery(doesRoleExist).
+		WithArgs(1, "admin").
+		WillReturnRows(roleRows)
+
+	rows := sqlmock.NewRows([]string{"ID", "ROLE", "EMAIL", "TOKEN"})
 
 	mock.ExpectQuery(GetUser).
-		WithArgs("user").
+		WithArgs(1, "user").
 		WillReturnRows(rows)
 
-	err := db.AddUser("user", "email", "password", true, true)
+	err := db.AddUser(1, "user", "email", "password", "admin", true)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -662,11 +920,17 @@ func TestAddUserGetUsernameFail(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
+	roleRows := sqlmock.NewRows([]string{"COUNT(1)"})
+	roleRows.AddRow(1)
+	mock.ExpectQuery(doesRoleExist).
+		WithArgs(1, "admin").
+		WillReturnRows(roleRows)
+
 	mock.ExpectQuery(GetUser).
-		WithArgs("user").
+		WithArgs(1, "user").
 		WillReturnError(errors.New("uh oh"))
 
-	err := db.AddUser("user", "email", "password", true, true)
+	err := db.AddUser(1, "user", "email", "password", "admin", true)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -675,18 +939,33 @@ func TestAddUserOverwriteSuccess(t *testing.T) {
 	db, mock := newTestDB(t)
 	defer db.conn.Close()
 
-	rows := sqlmock.NewRows([]string{"ID", "ISSYSADMIN", "EMAIL", "TOKEN"})
-	rows.AddRow(123, 1, "foo@bar.com", "someToken")
+	roleRows := sqlmock.NewRows([]string{"COUNT(1)"})
+	roleRows.AddRow(1)
+	mock.ExpectQuery(doesRoleExist).
+		WithArgs(1, "admin").
+		WillReturnRows(roleRows)
+
+	rows := sqlmock.NewRows([]string{"ID", "ROLE", "EMAIL", "TOKEN"})
+	rows.AddRow(123, "admin", "foo@bar.com", "someToken")
 
 	mock.ExpectQuery(GetUser).
-		WithArgs("user").
+		WithArgs(1, "user").
 		WillReturnRows(rows)
 
 	mock.ExpectExec(addUserOverwrite).
-		WithArgs("user", "email", "W6ph5Mm5Pz8GgiULbPgzG37mj9g=", sqlmock.AnyArg(), true, "user").
+		WithArgs("user", "email", sqlmock.AnyArg(), sqlmock.AnyArg(), true, "user").
 		WillReturnResult(sqlmock.NewResult(123, 1))
-
-	err := db.AddUser("user", "email", "password", true, true)
+	mock.ExpectQuery(getMemberRole).
+		WithArgs(1, 123).
+		WillReturnRows(sqlmock.NewRows([]string{"ROLE"}).AddRow("viewer"))
+	mock.ExpectExec(updateMember).
+		WithArgs("admin", 1, 123).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(revokeSessions).
+		WithArgs(123).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err := db.AddUser(1, "user", "email", "password", "admin", true)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -696,10 +975,10 @@ func TestDeleteUserInternalErr(t *testing.T) {
 	defer db.conn.Close()
 
 	mock.ExpectExec(deleteUser).
-		WithArgs(1234).
+		WithArgs(1, 1234).
 		WillReturnError(errors.New("sorry"))
 
-	err := db.DeleteUser(1234, 123)
+	err := db.DeleteUser(1, 1234, 123)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -709,10 +988,10 @@ func TestDeleteUserNoRowsAff(t *testing.T) {
 	defer db.conn.Close()
 
 	mock.ExpectExec(deleteUser).
-		WithArgs(1234).
+		WithArgs(1, 1234).
 		WillReturnResult(sqlmock.NewResult(1, 0))
 
-	err := db.DeleteUser(1234, 123)
+	err := db.DeleteUser(1, 1234, 123)
 	assert.Equal(t, items.ItemNotFoundErr, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -722,10 +1001,47 @@ func TestDeleteUserSuccess(t *testing.T) {
 	defer db.conn.Close()
 
 	mock.ExpectExec(deleteUser).
+		WithArgs(1, 1234).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(countMemberships).
+		WithArgs(1234).
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(1)"}).AddRow(0))
+	mock.ExpectExec(deactivateUser).
 		WithArgs(1234).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := db.DeleteUser(1234, 123)
+	err := db.DeleteUser(1, 1234, 123)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestDeleteUserStillInOtherOrg(t *testing.T) {
+	db, mock := newTestDB(t)
+	defer db.conn.Close()
+
+	mock.ExpectExec(deleteUser).
+		WithArgs(1, 1234).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(countMemberships).
+		WithArgs(1234).
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(1)"}).AddRow(1))
+
+	err := db.DeleteUser(1, 1234, 123)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddUserRoleNotFound(t *testing.T) {
+	db, mock := newTestDB(t)
+	defer db.conn.Close()
+
+	roleRows := sqlmock.NewRows([]string{"COUNT(1)"})
+	roleRows.AddRow(0)
+	mock.ExpectQuery(doesRoleExist).
+		WithArgs(1, "admin").
+		WillReturnRows(roleRows)
+
+	err := db.AddUser(1, "user", "email", "password", "admin", false)
+	assert.Equal(t, users.RoleNotFoundErr, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
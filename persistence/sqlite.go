@@ -0,0 +1,48 @@
+package persistence
+
+import (
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/Timothylock/inventory-management/passwords"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLite is an embedded alternative to MySQL. The whole database lives in the
+// single file at DB_PATH, which is created along with its tables if missing.
+type SQLite struct {
+	store
+}
+
+// NewSQLite opens the database file and applies any pending migrations
+func NewSQLite(cfg *config.Config) (*SQLite, error) {
+	conn, err := connectSQLite(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = migrate(conn, sqliteDialect); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &SQLite{
+		store{
+			conn:      conn,
+			dialect:   sqliteDialect,
+			passwords: passwords.NewDefaultService(cfg.BcryptCost),
+		},
+	}, nil
+}
+
+func connectSQLite(cfg *config.Config) (*sqlx.DB, error) {
+	conn, err := sqlx.Connect("sqlite3", cfg.DbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows a single writer at a time
+	conn.SetMaxOpenConns(1)
+
+	return conn, nil
+}
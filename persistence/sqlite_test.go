@@ -0,0 +1,1516 @@
+package persistence
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Timothylock/inventory-management/attachments"
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/search"
+	"github.com/Timothylock/inventory-management/storage"
+	"github.com/Timothylock/inventory-management/upc"
+	"github.com/Timothylock/inventory-management/users"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSQLite(t *testing.T) (*SQLite, func()) {
+	dir, err := ioutil.TempDir("", "inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := NewSQLite(&config.Config{DbPath: filepath.Join(dir, "inventory.db"), BcryptCost: 4})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return db, func() {
+		db.conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func addTestUser(t *testing.T, db *SQLite, username string) int {
+	assert.NoError(t, db.AddUser(1, username, username+"@bar.com", "pass", "admin", false))
+
+	u, err := db.GetUserByUsername(username, 1)
+	assert.NoError(t, err)
+	assert.True(t, u.Valid)
+
+	return u.ID
+}
+
+func countLogs(t *testing.T, db *SQLite, action string) int {
+	var count int
+	assert.NoError(t, db.conn.Get(&count, "SELECT count(1) FROM logs WHERE ACTION = ?", action))
+	return count
+}
+
+func TestSQLiteOpenTwice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inventory")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{DbPath: filepath.Join(dir, "inventory.db"), BcryptCost: 4}
+
+	db, err := NewSQLite(cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, db.AddUser(1, "someUser", "foo@bar.com", "pass", "admin", false))
+	db.conn.Close()
+
+	db, err = NewSQLite(cfg)
+	assert.NoError(t, err)
+	defer db.conn.Close()
+
+	u, err := db.GetUser("someUser", "pass")
+	assert.NoError(t, err)
+	assert.True(t, u.Valid)
+}
+
+func TestSQLiteOpenFail(t *testing.T) {
+	_, err := NewSQLite(&config.Config{DbPath: "/nonexistent/dir/inventory.db"})
+	assert.Error(t, err)
+}
+
+func TestSQLiteItems(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+
+	shed, err := db.AddLocation(1, items.Location{Name: "Shed"}, uid)
+	assert.NoError(t, err)
+	electrical, err := db.AddCategory(1, items.Category{Name: "Electrical", Fields: []items.Field{{Name: "Serial", Type: items.FieldText}}}, uid)
+	assert.NoError(t, err)
+
+	item := items.ItemDetail{
+		ID:              "1234",
+		Name:            "Extension cord",
+		CategoryID:      electrical.ID,
+		PictureURL:      "http://foo/bar.png",
+		Details:         "25 feet, orange",
+		Fields:          map[string]string{"Serial": "SN-42"},
+		LocationID:      shed.ID,
+		LastPerformedBy: strconv.Itoa(uid),
+		Quantity:        3,
+	}
+
+	assert.NoError(t, db.AddItem(1, item, false))
+	assert.Equal(t, items.ItemAlreadyExistsErr, db.AddItem(1, item, false))
+	assert.Equal(t, items.ItemNotFoundErr, db.AddItem(1, items.ItemDetail{ID: "5678"}, true))
+	assert.Equal(t, 1, countLogs(t, db, "add"))
+
+	expected := items.ItemDetailList{
+		{
+			ID:              "1234",
+			Name:            "Extension cord",
+			CategoryID:      electrical.ID,
+			Category:        "Electrical",
+			PictureURL:      "http://foo/bar.png",
+			Details:         "25 feet, orange",
+			Fields:          map[string]string{"Serial": "SN-42"},
+			LocationID:      shed.ID,
+			Location:        "Shed",
+			LastPerformedBy: "someUser",
+			Quantity:        3,
+			Available:       3,
+			Status:          "checked in",
+		},
+	}
+
+	for _, search := range []string{"1234", "cord", "EXTENSION", "orange", "shed", "missing shed", "electrical", "sn-42"} {
+		r, _, err := db.SearchItems(1, items.ItemFilter{Search: search})
+		assert.NoError(t, err, search)
+		assert.Equal(t, expected, r, search)
+	}
+
+	r, _, err := db.SearchItems(1, items.ItemFilter{Search: "hammer"})
+	assert.NoError(t, err)
+	assert.Equal(t, items.ItemDetailList{}, r)
+
+	assert.Equal(t, items.LocationNotFoundErr, db.AddItem(1, items.ItemDetail{ID: "5678", LocationID: 99}, false))
+
+	item.Name = "Long extension cord"
+	assert.NoError(t, db.AddItem(1, item, true))
+	r, _, err = db.SearchItems(1, items.ItemFilter{Search: "long"})
+	assert.NoError(t, err)
+	assert.Len(t, r, 1)
+
+	n, err := db.MoveItem(1, "1234", "out", 0, uid)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	_, err = db.MoveItem(1, "5678", "out", 0, uid)
+	assert.Equal(t, items.ItemNotFoundErr, err)
+	_, err = db.MoveItem(1, "1234", "sideways", 0, uid)
+	assert.Error(t, err)
+	assert.Equal(t, 1, countLogs(t, db, "checked out"))
+
+	r, _, err = db.SearchItems(1, items.ItemFilter{Search: "1234"})
+	assert.NoError(t, err)
+	assert.Equal(t, "checked out", r[0].Status)
+
+	assert.NoError(t, db.DeleteItem(1, "1234", uid))
+	assert.Equal(t, items.ItemNotFoundErr, db.DeleteItem(1, "5678", uid))
+	assert.Equal(t, 1, countLogs(t, db, "delete"))
+
+	r, _, err = db.SearchItems(1, items.ItemFilter{Search: "1234"})
+	assert.NoError(t, err)
+	assert.Equal(t, items.ItemDetailList{}, r)
+}
+
+func TestSQLitePartialMoves(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+
+	item := items.ItemDetail{ID: "1234", Name: "Extension cord", LastPerformedBy: strconv.Itoa(uid), Quantity: 10}
+	assert.NoError(t, db.AddItem(1, item, false))
+
+	move := func(direction string, quantity, expectMoved int, expectErr error) {
+		n, err := db.MoveItem(1, "1234", direction, quantity, uid)
+		assert.Equal(t, expectErr, err)
+		assert.Equal(t, expectMoved, n)
+	}
+
+	check := func(available, checkedOut int, status string) {
+		r, _, err := db.SearchItems(1, items.ItemFilter{Search: "1234"})
+		assert.NoError(t, err)
+		assert.Equal(t, available, r[0].Available)
+		assert.Equal(t, checkedOut, r[0].CheckedOut)
+		assert.Equal(t, status, r[0].Status)
+	}
+
+	move("out", 3, 3, nil)
+	check(7, 3, items.StatusPartiallyCheckedOut)
+
+	move("out", 8, 0, items.NotEnoughAvailableErr)
+	move("in", 4, 0, items.NotEnoughCheckedOutErr)
+	check(7, 3, items.StatusPartiallyCheckedOut)
+
+	// Everything that is left
+	move("out", 0, 7, nil)
+	check(0, 10, items.StatusCheckedOut)
+	move("out", 1, 0, items.NotEnoughAvailableErr)
+	move("out", 0, 0, items.NotEnoughAvailableErr)
+
+	move("in", 9, 9, nil)
+	check(9, 1, items.StatusPartiallyCheckedOut)
+
+	// The quantity cannot drop below what is checked out
+	item.Quantity = 0
+	assert.Equal(t, items.QuantityBelowCheckedOutErr, db.AddItem(1, item, true))
+	item.Quantity = 1
+	assert.NoError(t, db.AddItem(1, item, true))
+	check(0, 1, items.StatusCheckedOut)
+
+	move("in", 0, 1, nil)
+	check(1, 0, items.StatusCheckedIn)
+	assert.Equal(t, 2, countLogs(t, db, "checked out"))
+	assert.Equal(t, 2, countLogs(t, db, "checked in"))
+}
+
+func TestSQLiteLoans(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+
+	item := items.ItemDetail{ID: "1234", Name: "Extension cord", LastPerformedBy: strconv.Itoa(uid), Quantity: 10}
+	assert.NoError(t, db.AddItem(1, item, false))
+
+	due := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	first, err := db.AddLoan(1, items.Loan{ItemID: "1234", Quantity: 3, BorrowerUserID: uid, Borrower: "someUser", DueAt: &due, Notes: "demo"}, uid)
+	assert.NoError(t, err)
+	assert.NotZero(t, first.ID)
+	assert.Equal(t, "Extension cord", first.ItemName)
+	assert.Equal(t, "someUser", first.CheckedOutBy)
+	assert.Equal(t, due, *first.DueAt)
+	assert.Nil(t, first.ReturnedAt)
+	assert.Equal(t, 1, countLogs(t, db, "loan"))
+
+	second, err := db.AddLoan(1, items.Loan{ItemID: "1234", Quantity: 2, Borrower: "Bob"}, uid)
+	assert.NoError(t, err)
+	assert.Nil(t, second.DueAt)
+
+	_, err = db.GetLoan(1, 999)
+	assert.Equal(t, items.LoanNotFoundErr, err)
+	_, err = db.GetLoan(2, first.ID)
+	assert.Equal(t, items.LoanNotFoundErr, err)
+
+	// Returns go to the oldest loan first
+	assert.NoError(t, db.ReturnLoans(1, "1234", 0, 4))
+	ls, err := db.GetLoans(1, items.LoanFilter{OpenOnly: true})
+	assert.NoError(t, err)
+	assert.Len(t, ls, 1)
+	assert.Equal(t, second.ID, ls[0].ID)
+	assert.Equal(t, 1, ls[0].Returned)
+
+	ls, err = db.GetLoans(1, items.LoanFilter{Borrower: "someuser"})
+	assert.NoError(t, err)
+	assert.Len(t, ls, 1)
+	assert.Equal(t, 3, ls[0].Returned)
+	assert.NotNil(t, ls[0].ReturnedAt)
+
+	// Only the given loan is returned and anything left over is ignored
+	assert.NoError(t, db.ReturnLoans(1, "1234", first.ID, 1))
+	assert.NoError(t, db.ReturnLoans(1, "1234", second.ID, 5))
+	l, err := db.GetLoan(1, second.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, l.Returned)
+	assert.NotNil(t, l.ReturnedAt)
+
+	ls, err = db.GetLoans(1, items.LoanFilter{OpenOnly: true})
+	assert.NoError(t, err)
+	assert.Empty(t, ls)
+}
+
+func TestSQLiteTrash(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+
+	item := items.ItemDetail{ID: "1234", Name: "Extension cord", LastPerformedBy: strconv.Itoa(uid), Quantity: 10}
+	assert.NoError(t, db.AddItem(1, item, false))
+	assert.NoError(t, db.DeleteItem(1, "1234", uid))
+	assert.Equal(t, items.ItemNotFoundErr, db.DeleteItem(1, "1234", uid))
+
+	_, err := db.MoveItem(1, "1234", "out", 0, uid)
+	assert.Equal(t, items.ItemNotFoundErr, err)
+	assert.Equal(t, items.ItemNotFoundErr, db.AddItem(1, item, true))
+
+	trash, err := db.GetDeletedItems(1)
+	assert.NoError(t, err)
+	assert.Len(t, trash, 1)
+	assert.Equal(t, "Extension cord", trash[0].Name)
+	assert.Equal(t, "someUser", trash[0].LastPerformedBy)
+	assert.WithinDuration(t, time.Now(), trash[0].DeletedAt, time.Minute)
+
+	assert.NoError(t, db.RestoreItem(1, "1234", uid))
+	assert.Equal(t, items.ItemNotFoundErr, db.RestoreItem(1, "1234", uid))
+	r, _, err := db.SearchItems(1, items.ItemFilter{Search: "1234"})
+	assert.NoError(t, err)
+	assert.Len(t, r, 1)
+
+	// Adding an item that is in the trash replaces it
+	assert.NoError(t, db.DeleteItem(1, "1234", uid))
+	item.Name = "Drill"
+	assert.NoError(t, db.AddItem(1, item, false))
+	r, _, err = db.SearchItems(1, items.ItemFilter{Search: "1234"})
+	assert.NoError(t, err)
+	assert.Len(t, r, 1)
+	assert.Equal(t, "Drill", r[0].Name)
+	trash, err = db.GetDeletedItems(1)
+	assert.NoError(t, err)
+	assert.Empty(t, trash)
+
+	assert.NoError(t, db.DeleteItem(1, "1234", uid))
+	n, _, err := db.PurgeItems(1, time.Now().Add(-time.Hour), uid)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	n, keys, err := db.PurgeItems(1, time.Now().Add(time.Hour), uid)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, keys)
+	_, err = db.PurgeItem(1, "1234", uid)
+	assert.Equal(t, items.ItemNotFoundErr, err)
+	assert.Equal(t, items.ItemNotFoundErr, db.RestoreItem(1, "1234", uid))
+
+	trash, err = db.GetDeletedItems(1)
+	assert.NoError(t, err)
+	assert.Empty(t, trash)
+	assert.Equal(t, 1, countLogs(t, db, "restore"))
+	assert.Equal(t, 1, countLogs(t, db, "purge"))
+}
+
+func TestSQLiteLocations(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+
+	// Locations used to be free text on the item
+	m, err := newMigrator(db.conn, sqliteDialect)
+	assert.NoError(t, err)
+	for {
+		mig, err := m.Rollback()
+		assert.NoError(t, err)
+		if mig.Name == "locations" {
+			break
+		}
+	}
+
+	_, err = db.conn.Exec(`INSERT INTO items (ID, NAME, CATEGORY, PICTURE_URL, DETAILS, LOCATION, LAST_PERFORMED_BY, STATUS) VALUES
+		('1', 'Drill', '', '', '', 'Shed', ?, 'checked in'),
+		('2', 'Saw', '', '', '', ' shed ', ?, 'checked in'),
+		('3', 'Hammer', '', '', '', '', ?, 'checked in')`, uid, uid, uid)
+	assert.NoError(t, err)
+
+	_, err = m.Up()
+	assert.NoError(t, err)
+
+	ls, err := db.GetLocations(1)
+	assert.NoError(t, err)
+	assert.Equal(t, items.Locations{{ID: ls[0].ID, Name: "Shed", Path: "Shed"}}, ls)
+	shed := ls[0]
+
+	r, _, err := db.SearchItems(1, items.ItemFilter{Search: "shed"})
+	assert.NoError(t, err)
+	assert.Len(t, r, 2)
+	assert.Equal(t, "Shed", r[0].Location)
+
+	shelf, err := db.AddLocation(1, items.Location{ParentID: shed.ID, Name: "Shelf 1"}, uid)
+	assert.NoError(t, err)
+	assert.Equal(t, "Shed > Shelf 1", shelf.Path)
+
+	item := items.ItemDetail{ID: "4", Name: "Level", LocationID: shelf.ID, LastPerformedBy: strconv.Itoa(uid), Quantity: 1}
+	assert.NoError(t, db.AddItem(1, item, false))
+
+	// Searching a location includes everything under it
+	r, _, err = db.SearchItems(1, items.ItemFilter{LocationID: shed.ID})
+	assert.NoError(t, err)
+	assert.Len(t, r, 3)
+	r, _, err = db.SearchItems(1, items.ItemFilter{LocationID: shelf.ID})
+	assert.NoError(t, err)
+	assert.Len(t, r, 1)
+	assert.Equal(t, "Shed > Shelf 1", r[0].Location)
+	r, _, err = db.SearchItems(1, items.ItemFilter{Search: "level", LocationID: shed.ID})
+	assert.NoError(t, err)
+	assert.Len(t, r, 1)
+
+	shelf.Name = "Top shelf"
+	shelf.ParentID = 0
+	assert.NoError(t, db.UpdateLocation(1, shelf, uid))
+	r, _, err = db.SearchItems(1, items.ItemFilter{Search: "4"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Top shelf", r[0].Location)
+
+	assert.Equal(t, items.LocationInUseErr, db.DeleteLocation(1, shed.ID, uid))
+	assert.NoError(t, db.DeleteItem(1, "4", uid))
+	assert.NoError(t, db.DeleteLocation(1, shelf.ID, uid))
+	assert.Equal(t, items.LocationNotFoundErr, db.DeleteLocation(1, shelf.ID, uid))
+
+	trash, err := db.GetDeletedItems(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, trash[0].LocationID)
+	assert.Equal(t, 1, countLogs(t, db, "location added"))
+	assert.Equal(t, 1, countLogs(t, db, "location updated"))
+	assert.Equal(t, 1, countLogs(t, db, "location deleted"))
+}
+
+func TestSQLiteCategories(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+
+	// Categories used to be free text on the item
+	m, err := newMigrator(db.conn, sqliteDialect)
+	assert.NoError(t, err)
+	for {
+		mig, err := m.Rollback()
+		assert.NoError(t, err)
+		if mig.Name == "categories" {
+			break
+		}
+	}
+
+	_, err = db.conn.Exec(`INSERT INTO items (ID, NAME, CATEGORY, PICTURE_URL, DETAILS, LAST_PERFORMED_BY, STATUS) VALUES
+		('1', 'Drill', 'Tools', '', '', ?, 'checked in'),
+		('2', 'Saw', ' tools ', '', '', ?, 'checked in'),
+		('3', 'Milk', 'Food', '', '', ?, 'checked in')`, uid, uid, uid)
+	assert.NoError(t, err)
+
+	_, err = m.Up()
+	assert.NoError(t, err)
+
+	cs, err := db.GetCategories(1)
+	assert.NoError(t, err)
+	assert.Len(t, cs, 2)
+
+	r, _, err := db.SearchItems(1, items.ItemFilter{Search: "tools"})
+	assert.NoError(t, err)
+	assert.Len(t, r, 2)
+	assert.Equal(t, "Tools", r[0].Category)
+	assert.Equal(t, map[string]string{}, r[0].Fields)
+
+	foodID := 0
+	for _, c := range cs {
+		if c.Name == "Food" {
+			foodID = c.ID
+		}
+	}
+	assert.NoError(t, db.UpdateCategory(1, items.Category{ID: foodID, Name: "Food", Fields: []items.Field{
+		{Name: "Expiry", Type: items.FieldDate},
+		{Name: "Brand", Type: items.FieldText},
+	}}, uid))
+
+	food := items.ItemDetail{
+		ID:              "3",
+		Name:            "Milk",
+		CategoryID:      foodID,
+		Fields:          map[string]string{"Expiry": "2020-01-02", "Brand": "Acme"},
+		LastPerformedBy: strconv.Itoa(uid),
+		Quantity:        1,
+	}
+	assert.NoError(t, db.AddItem(1, food, true))
+
+	r, _, err = db.SearchItems(1, items.ItemFilter{Search: "acme"})
+	assert.NoError(t, err)
+	assert.Len(t, r, 1)
+	assert.Equal(t, map[string]string{"Expiry": "2020-01-02", "Brand": "Acme"}, r[0].Fields)
+
+	// Values of fields that were removed are no longer returned
+	assert.NoError(t, db.UpdateCategory(1, items.Category{ID: foodID, Name: "Groceries", Fields: []items.Field{
+		{Name: "Expiry", Type: items.FieldDate},
+	}}, uid))
+	r, _, err = db.SearchItems(1, items.ItemFilter{Search: "3"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Groceries", r[0].Category)
+	assert.Equal(t, map[string]string{"Expiry": "2020-01-02"}, r[0].Fields)
+
+	assert.Equal(t, items.CategoryInUseErr, db.DeleteCategory(1, foodID, uid))
+	assert.NoError(t, db.DeleteItem(1, "3", uid))
+	assert.NoError(t, db.DeleteCategory(1, foodID, uid))
+	assert.Equal(t, items.CategoryNotFoundErr, db.DeleteCategory(1, foodID, uid))
+
+	trash, err := db.GetDeletedItems(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, trash[0].CategoryID)
+	assert.Equal(t, 2, countLogs(t, db, "category updated"))
+	assert.Equal(t, 1, countLogs(t, db, "category deleted"))
+
+	// Categories and locations added along with an item are not kept when the
+	// item cannot be added
+	s := items.NewService(db, nil, nil, config.Config{})
+	err = s.AddItem(1, items.ItemDetail{ID: "9", Name: "Ghost", Category: "Spooky", Location: "Attic > Box", LastPerformedBy: strconv.Itoa(uid), Quantity: -1}, false, uid)
+	assert.Equal(t, items.NegativeQuantityErr, err)
+	_, err = s.FindCategory(1, "Spooky")
+	assert.Equal(t, items.CategoryNotFoundErr, err)
+	_, err = s.FindLocation(1, "Attic")
+	assert.Equal(t, items.LocationNotFoundErr, err)
+
+	assert.NoError(t, s.AddItem(1, items.ItemDetail{ID: "9", Name: "Ghost", Category: "Spooky", Location: "Attic > Box", LastPerformedBy: strconv.Itoa(uid), Quantity: 1}, false, uid))
+	r, _, err = db.SearchItems(1, items.ItemFilter{IDs: []string{"9"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Spooky", r[0].Category)
+	assert.Equal(t, "Attic > Box", r[0].Location)
+}
+
+func TestSQLiteSearchFilters(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+	tools, err := db.AddCategory(1, items.Category{Name: "Tools"}, uid)
+	assert.NoError(t, err)
+	shed, err := db.AddLocation(1, items.Location{Name: "Shed"}, uid)
+	assert.NoError(t, err)
+
+	for i, name := range []string{"Drill", "Saw", "Hammer", "Level", "Wrench"} {
+		item := items.ItemDetail{ID: strconv.Itoa(i + 1), Name: name, LastPerformedBy: strconv.Itoa(uid), Quantity: i + 1}
+		if i%2 == 0 {
+			item.CategoryID = tools.ID
+			item.LocationID = shed.ID
+		}
+		assert.NoError(t, db.AddItem(1, item, false))
+	}
+	_, err = db.MoveItem(1, "2", "out", 0, uid)
+	assert.NoError(t, err)
+
+	names := func(list items.ItemDetailList) []string {
+		ret := []string{}
+		for _, item := range list {
+			ret = append(ret, item.Name)
+		}
+		return ret
+	}
+
+	r, total, err := db.SearchItems(1, items.ItemFilter{CategoryID: tools.ID, SortBy: items.SortName})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"Drill", "Hammer", "Wrench"}, names(r))
+
+	r, _, err = db.SearchItems(1, items.ItemFilter{Status: items.StatusCheckedOut})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Saw"}, names(r))
+
+	r, _, err = db.SearchItems(1, items.ItemFilter{LocationID: shed.ID, QuantityBelow: 5, SortBy: items.SortQuantity, Descending: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Hammer", "Drill"}, names(r))
+
+	r, _, err = db.SearchItems(1, items.ItemFilter{SortBy: items.SortCategory})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Saw", "Level", "Drill", "Hammer", "Wrench"}, names(r))
+
+	// Paging through with cursors returns every item once
+	s := items.NewService(db, nil, nil, config.Config{})
+	filter := items.ItemFilter{SortBy: items.SortQuantity, Descending: true, Limit: 2}
+	all := []string{}
+	for {
+		page, err := s.FetchItems(1, filter)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, page.Total)
+		all = append(all, names(page.Items)...)
+		if page.NextCursor == "" {
+			break
+		}
+		filter.After, err = items.ParseCursor(page.NextCursor)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"Wrench", "Level", "Hammer", "Saw", "Drill"}, all)
+
+	groups, err := db.GroupItems(1, items.GroupByCategory, items.ItemFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, items.ItemGroups{
+		{Name: "", Items: 2, Quantity: 6},
+		{ID: tools.ID, Name: "Tools", Items: 3, Quantity: 9},
+	}, groups)
+
+	groups, err = db.GroupItems(1, items.GroupByLocation, items.ItemFilter{QuantityBelow: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, items.ItemGroups{
+		{Name: "", Items: 2, Quantity: 6},
+		{ID: shed.ID, Name: "Shed", Items: 2, Quantity: 4},
+	}, groups)
+
+	groups, err = db.GroupItems(1, items.GroupByStatus, items.ItemFilter{Search: "saw"})
+	assert.NoError(t, err)
+	assert.Equal(t, items.ItemGroups{{Name: items.StatusCheckedOut, Items: 1, Quantity: 2}}, groups)
+
+	// A barcode finds the item stored under another form of it as well as the
+	// items that mention it
+	assert.NoError(t, db.AddItem(1, items.ItemDetail{ID: "00036000291452", Name: "Tape", LastPerformedBy: strconv.Itoa(uid), Quantity: 1}, false))
+	assert.NoError(t, db.AddItem(1, items.ItemDetail{ID: "7", Name: "Tape refill", Details: "fits 036000291452", LastPerformedBy: strconv.Itoa(uid), Quantity: 1}, false))
+	page, err := s.FetchItems(1, items.ItemFilter{Search: "036000291452"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Tape", "Tape refill"}, names(page.Items))
+}
+
+func TestSQLiteSearchIndex(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+	s := items.NewService(db, search.NewIndex(), nil, config.Config{})
+
+	tools, err := s.AddCategory(1, items.Category{Name: "Tools"}, uid)
+	assert.NoError(t, err)
+
+	for i, name := range []string{"Extension Cord", "Extension Ladder", "Saw", "Hammer", "Cord Reel"} {
+		item := items.ItemDetail{ID: strconv.Itoa(i + 1), Name: name, LastPerformedBy: strconv.Itoa(uid), Quantity: 1}
+		if i > 1 {
+			item.CategoryID = tools.ID
+		}
+		assert.NoError(t, s.AddItem(1, item, false, uid))
+	}
+
+	names := func(page items.ItemPage) []string {
+		ret := []string{}
+		for _, item := range page.Items {
+			ret = append(ret, item.Name)
+		}
+		return ret
+	}
+
+	// Typos and short words are found, best match first
+	page, err := s.FetchItems(1, items.ItemFilter{Search: "extention cord"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Extension Cord"}, names(page))
+
+	page, err = s.FetchItems(1, items.ItemFilter{Search: "saw"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Saw"}, names(page))
+
+	// Filters still apply and other sorts can be used
+	page, err = s.FetchItems(1, items.ItemFilter{Search: "cord", CategoryID: tools.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Cord Reel"}, names(page))
+
+	page, err = s.FetchItems(1, items.ItemFilter{Search: "ext", SortBy: items.SortName, Descending: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Extension Ladder", "Extension Cord"}, names(page))
+
+	// Paging by relevance returns every match once
+	filter := items.ItemFilter{Search: "cord extension", Limit: 1}
+	all := []string{}
+	for {
+		page, err := s.FetchItems(1, filter)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		all = append(all, names(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		filter.After, err = items.ParseCursor(page.NextCursor)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"Extension Cord"}, all)
+
+	filter = items.ItemFilter{Search: "cord", Limit: 1}
+	all = []string{}
+	for {
+		page, err := s.FetchItems(1, filter)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, page.Total)
+		all = append(all, names(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		filter.After, err = items.ParseCursor(page.NextCursor)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"Extension Cord", "Cord Reel"}, all)
+
+	groups, err := s.SummarizeItems(1, items.GroupByCategory, items.ItemFilter{Search: "cord"})
+	assert.NoError(t, err)
+	assert.Equal(t, items.ItemGroups{
+		{Name: "", Items: 1, Quantity: 1},
+		{ID: tools.ID, Name: "Tools", Items: 1, Quantity: 1},
+	}, groups)
+
+	// Changes are picked up by the next search
+	assert.NoError(t, s.AddItem(1, items.ItemDetail{ID: "6", Name: "Hammer Drill", LastPerformedBy: strconv.Itoa(uid), Quantity: 1}, false, uid))
+	assert.NoError(t, s.DeleteItem(1, "4", uid))
+	assert.NoError(t, s.UpdateCategory(1, items.Category{ID: tools.ID, Name: "Workshop"}, uid))
+
+	page, err = s.FetchItems(1, items.ItemFilter{Search: "hammer"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Hammer Drill"}, names(page))
+
+	page, err = s.FetchItems(1, items.ItemFilter{Search: "workshop", SortBy: items.SortName})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Cord Reel", "Saw"}, names(page))
+
+	page, err = s.FetchItems(1, items.ItemFilter{Search: "nothing"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, page.Total)
+
+	// A barcode ranks the item stored under another form of it above the
+	// items that mention it
+	assert.NoError(t, s.AddItem(1, items.ItemDetail{ID: "7", Name: "Tape refill", Details: "fits 036000291452", LastPerformedBy: strconv.Itoa(uid), Quantity: 1}, false, uid))
+	assert.NoError(t, s.AddItem(1, items.ItemDetail{ID: "036000291452", Name: "Tape", LastPerformedBy: strconv.Itoa(uid), Quantity: 1}, false, uid))
+	page, err = s.FetchItems(1, items.ItemFilter{Search: "036000291452"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Tape", "Tape refill"}, names(page))
+	assert.Equal(t, 2, page.Total)
+}
+
+// boundIDsPersister records the most item IDs that were looked up at once
+type boundIDsPersister struct {
+	*SQLite
+	most int
+}
+
+func (p *boundIDsPersister) SearchItems(orgID int, filter items.ItemFilter) (items.ItemDetailList, int, error) {
+	if len(filter.IDs) > p.most {
+		p.most = len(filter.IDs)
+	}
+	return p.SQLite.SearchItems(orgID, filter)
+}
+
+func TestSQLiteSearchIndexManyMatches(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+	p := &boundIDsPersister{SQLite: db}
+	s := items.NewService(p, search.NewIndex(), nil, config.Config{})
+
+	tools, err := s.AddCategory(1, items.Category{Name: "Tools"}, uid)
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.Transaction(func(tx items.Persister) error {
+		for i := 0; i < 1200; i++ {
+			item := items.ItemDetail{ID: "W" + strconv.Itoa(10000+i), Name: "Widget", LastPerformedBy: strconv.Itoa(uid), Quantity: 1}
+			if i%3 == 0 {
+				item.CategoryID = tools.ID
+			}
+			if err := tx.AddItem(1, item, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	// Only the IDs of each page are looked up, however many items match
+	for _, tc := range []struct {
+		filter items.ItemFilter
+		total  int
+	}{
+		{items.ItemFilter{Search: "widget", Limit: 100}, 1200},
+		{items.ItemFilter{Search: "widget", CategoryID: tools.ID, Limit: 100}, 400},
+	} {
+		p.most = 0
+		seen := map[string]bool{}
+		filter := tc.filter
+		for {
+			page, err := s.FetchItems(1, filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.total, page.Total)
+			for _, item := range page.Items {
+				assert.False(t, seen[item.ID], item.ID)
+				seen[item.ID] = true
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			filter.After, err = items.ParseCursor(page.NextCursor)
+			assert.NoError(t, err)
+		}
+		assert.Len(t, seen, tc.total)
+		assert.True(t, p.most <= 500, p.most)
+	}
+}
+
+func TestSQLiteImport(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+	s := items.NewService(db, nil, nil, config.Config{})
+	by := strconv.Itoa(uid)
+
+	assert.NoError(t, db.AddItem(1, items.ItemDetail{ID: "1", Name: "Old hammer", LastPerformedBy: by, Quantity: 1}, false))
+
+	rows := []items.ImportRow{
+		{Line: 2, Mode: items.ImportOverwrite, Item: items.ItemDetail{ID: "1", Name: "Hammer", LastPerformedBy: by, Quantity: 2}, Category: "Tools", Location: "Shed > Shelf"},
+		{Line: 3, Item: items.ItemDetail{ID: "2", Name: "Saw", LastPerformedBy: by, Quantity: 1}, Category: "tools", Location: "Shed > Shelf"},
+		{Line: 4, Item: items.ItemDetail{ID: "3", Name: "Bread", LastPerformedBy: by, Quantity: 1}, Category: "Food"},
+	}
+
+	report, err := s.ImportItems(1, rows, true, uid)
+	assert.NoError(t, err)
+	assert.Equal(t, items.ImportReport{DryRun: true, Created: 2, Overwritten: 1, Errors: items.ImportErrors{}}, report)
+
+	// Dry runs do not change anything
+	cs, err := db.GetCategories(1)
+	assert.NoError(t, err)
+	assert.Empty(t, cs)
+
+	report, err = s.ImportItems(1, rows, false, uid)
+	assert.NoError(t, err)
+	assert.Equal(t, items.ImportReport{Created: 2, Overwritten: 1, Errors: items.ImportErrors{}}, report)
+
+	r, total, err := db.SearchItems(1, items.ItemFilter{SortBy: items.SortID})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, "Hammer", r[0].Name)
+	assert.Equal(t, "Tools", r[0].Category)
+	assert.Equal(t, "Shed > Shelf", r[0].Location)
+	assert.Equal(t, r[0].CategoryID, r[1].CategoryID)
+	assert.Equal(t, r[0].LocationID, r[1].LocationID)
+	assert.Equal(t, "Food", r[2].Category)
+
+	cs, err = db.GetCategories(1)
+	assert.NoError(t, err)
+	assert.Len(t, cs, 2)
+
+	// Nothing is kept when the transaction fails part way through
+	err = db.Transaction(func(p items.Persister) error {
+		if err := p.AddItem(1, items.ItemDetail{ID: "4", Name: "Drill", LastPerformedBy: by, Quantity: 1}, false); err != nil {
+			return err
+		}
+		_, err := p.AddLocation(1, items.Location{Name: "Garage"}, uid)
+		assert.NoError(t, err)
+		return p.AddItem(1, items.ItemDetail{ID: "1", Name: "Hammer", LastPerformedBy: by, Quantity: 1}, false)
+	})
+	assert.Equal(t, items.ItemAlreadyExistsErr, err)
+
+	_, total, err = db.SearchItems(1, items.ItemFilter{SortBy: items.SortID})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+
+	ls, err := db.GetLocations(1)
+	assert.NoError(t, err)
+	assert.Len(t, ls, 2)
+
+	// Rows in overwrite mode with a new ID are created
+	rows = []items.ImportRow{
+		{Line: 2, Mode: items.ImportOverwrite, Item: items.ItemDetail{ID: "5", Name: "Level", LastPerformedBy: by, Quantity: 1}, Category: "Tools"},
+	}
+	report, err = s.ImportItems(1, rows, true, uid)
+	assert.NoError(t, err)
+	assert.Equal(t, items.ImportReport{DryRun: true, Created: 1, Errors: items.ImportErrors{}}, report)
+
+	report, err = s.ImportItems(1, rows, false, uid)
+	assert.NoError(t, err)
+	assert.Equal(t, items.ImportReport{Created: 1, Errors: items.ImportErrors{}}, report)
+
+	r, _, err = db.SearchItems(1, items.ItemFilter{IDs: []string{"5"}})
+	assert.NoError(t, err)
+	assert.Len(t, r, 1)
+}
+
+func TestSQLiteExport(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+	by := strconv.Itoa(uid)
+	for i, name := range []string{"Drill", "Saw", "Hammer"} {
+		assert.NoError(t, db.AddItem(1, items.ItemDetail{ID: strconv.Itoa(i + 1), Name: name, LastPerformedBy: by, Quantity: i + 1}, false))
+	}
+	assert.NoError(t, db.DeleteItem(1, "2", uid))
+
+	export := func(filter items.ItemFilter) []items.ExportedItem {
+		ret := []items.ExportedItem{}
+		assert.NoError(t, db.ExportItems(1, filter, func(item items.ExportedItem) error {
+			ret = append(ret, item)
+			return nil
+		}))
+		return ret
+	}
+
+	r := export(items.ItemFilter{SortBy: items.SortName})
+	assert.Len(t, r, 2)
+	assert.Equal(t, "Drill", r[0].Name)
+	assert.Equal(t, "someUser", r[0].LastPerformedBy)
+	assert.Equal(t, items.StatusCheckedIn, r[0].Status)
+	assert.Nil(t, r[0].DeletedAt)
+	assert.Equal(t, "Hammer", r[1].Name)
+
+	r = export(items.ItemFilter{SortBy: items.SortQuantity, Descending: true, Deleted: true})
+	assert.Len(t, r, 3)
+	assert.Equal(t, "Hammer", r[0].Name)
+	assert.Equal(t, "Saw", r[1].Name)
+	assert.NotNil(t, r[1].DeletedAt)
+	assert.Nil(t, r[2].DeletedAt)
+
+	// Errors stop the export
+	calls := 0
+	err := db.ExportItems(1, items.ItemFilter{}, func(item items.ExportedItem) error {
+		calls++
+		return errors.New("some error")
+	})
+	assert.EqualError(t, err, "some error")
+	assert.Equal(t, 1, calls)
+}
+
+func TestSQLiteItemIDs(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	// Every org has its own sequence
+	for _, expected := range []int{1, 2, 3} {
+		n, err := db.NextItemNumber(1)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, n)
+	}
+	n, err := db.NextItemNumber(2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// IDs taken by items, even ones in the trash, are skipped
+	uid := addTestUser(t, db, "someUser")
+	assert.NoError(t, db.AddItem(1, items.ItemDetail{ID: "TL004", Name: "Cable", LastPerformedBy: strconv.Itoa(uid), Quantity: 1}, false))
+	assert.NoError(t, db.AddItem(1, items.ItemDetail{ID: "TL005", Name: "Kit", LastPerformedBy: strconv.Itoa(uid), Quantity: 1}, false))
+	assert.NoError(t, db.DeleteItem(1, "TL005", uid))
+
+	s := items.NewService(db, nil, nil, config.Config{ItemIdPrefix: "TL", ItemIdDigits: 3})
+	id, err := s.ReserveItemID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "TL006", id)
+
+	list, err := s.FindItems(1, []string{"TL004", "TL004"})
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "Cable", list[1].Name)
+
+	_, err = s.FindItems(1, []string{"TL004", "TL005"})
+	assert.Equal(t, items.ItemNotFoundErr, err)
+}
+
+func TestSQLiteLegacyItemIDs(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	// Items added before IDs were normalized keep the form they were scanned as
+	uid := addTestUser(t, db, "someUser")
+	item := items.ItemDetail{ID: "036000291452", Name: "Tissues", LastPerformedBy: strconv.Itoa(uid), Quantity: 1}
+	assert.NoError(t, db.AddItem(1, item, false))
+
+	s := items.NewService(db, nil, nil, config.Config{})
+
+	// Scanning the barcode in another form finds the same item
+	item.ID = "0036000291452"
+	item.Quantity = 5
+	assert.Equal(t, items.ItemAlreadyExistsErr, s.AddItem(1, item, false, uid))
+	assert.NoError(t, s.AddItem(1, item, true, uid))
+
+	list, err := s.FindItems(1, []string{"00036000291452"})
+	assert.NoError(t, err)
+	assert.Equal(t, "036000291452", list[0].ID)
+	assert.Equal(t, 5, list[0].Quantity)
+
+	history, err := s.GetHistory(1, "00036000291452", 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, history.Total)
+
+	assert.NoError(t, s.DeleteItem(1, "00036000291452", uid))
+	assert.NoError(t, s.RestoreItem(1, "0036000291452", uid))
+	assert.NoError(t, s.DeleteItem(1, "0036000291452", uid))
+	assert.NoError(t, s.PurgeItem(1, "00036000291452", uid))
+
+	r, _, err := db.SearchItems(1, items.ItemFilter{Deleted: true})
+	assert.NoError(t, err)
+	assert.Empty(t, r)
+}
+
+func TestSQLiteUpcCache(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	_, err := db.GetCachedLookup("00036000291452")
+	assert.Equal(t, upc.CacheMissErr, err)
+
+	fetched := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := upc.CachedLookup{
+		Barcode:   "00036000291452",
+		Found:     true,
+		Item:      upc.ItemDetail{ID: "00036000291452", Name: "Tissues", Category: "Household", PictureURL: "someURL"},
+		FetchedAt: fetched,
+	}
+	assert.NoError(t, db.CacheLookup(c))
+	got, err := db.GetCachedLookup("00036000291452")
+	assert.NoError(t, err)
+	assert.Equal(t, c.Item, got.Item)
+	assert.True(t, got.Found)
+	assert.True(t, fetched.Equal(got.FetchedAt))
+
+	// Caching again replaces the earlier result
+	assert.NoError(t, db.CacheLookup(upc.CachedLookup{Barcode: "00036000291452", FetchedAt: fetched.Add(time.Hour)}))
+	got, err = db.GetCachedLookup("00036000291452")
+	assert.NoError(t, err)
+	assert.False(t, got.Found)
+	assert.Equal(t, "", got.Item.Name)
+
+	assert.NoError(t, db.DeleteCachedLookup("00036000291452"))
+	_, err = db.GetCachedLookup("00036000291452")
+	assert.Equal(t, upc.CacheMissErr, err)
+}
+
+func TestSQLiteUpcProducts(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	_, err := db.GetProduct("00036000291452")
+	assert.Equal(t, upc.ProductNotFoundErr, err)
+
+	// Saving a product forgets what was cached about it
+	assert.NoError(t, db.CacheLookup(upc.CachedLookup{Barcode: "00036000291452", FetchedAt: time.Now()}))
+	assert.NoError(t, db.CacheLookup(upc.CachedLookup{Barcode: "04006381333931", FetchedAt: time.Now()}))
+
+	tissues := upc.Product{GTIN: "00036000291452", Name: "Tissues", Brand: "Kleenex", Category: "Paper", PictureURL: "someURL"}
+	assert.NoError(t, db.SaveProducts([]upc.Product{tissues, {GTIN: "09780306406157", Name: "Modern physics"}}))
+
+	p, err := db.GetProduct("00036000291452")
+	assert.NoError(t, err)
+	assert.Equal(t, tissues, p)
+
+	_, err = db.GetCachedLookup("00036000291452")
+	assert.Equal(t, upc.CacheMissErr, err)
+	_, err = db.GetCachedLookup("04006381333931")
+	assert.NoError(t, err)
+
+	// Saving it again replaces it
+	tissues.Name = "Facial tissues"
+	assert.NoError(t, db.SaveProducts([]upc.Product{tissues}))
+	p, err = db.GetProduct("00036000291452")
+	assert.NoError(t, err)
+	assert.Equal(t, "Facial tissues", p.Name)
+}
+
+func TestSQLiteAttachments(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+
+	size, err := db.GetAttachmentsSize(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+
+	manual, err := db.AddAttachment(1, attachments.Attachment{ItemID: "1234", Filename: "manual.pdf", ContentType: "application/pdf", Size: 100, Key: "attachments/1/a"}, uid)
+	assert.NoError(t, err)
+	assert.NotZero(t, manual.ID)
+	assert.Equal(t, "someUser", manual.UploadedBy)
+	assert.Equal(t, "attachments/1/a", manual.Key)
+	assert.False(t, manual.UploadedAt.IsZero())
+	assert.Equal(t, 1, countLogs(t, db, "attachment added"))
+
+	_, err = db.AddAttachment(1, attachments.Attachment{ItemID: "1234", Filename: "receipt.jpg", ContentType: "image/jpeg", Size: 50, Key: "attachments/1/b"}, uid)
+	assert.NoError(t, err)
+	_, err = db.AddAttachment(2, attachments.Attachment{ItemID: "1234", Filename: "other.pdf", ContentType: "application/pdf", Size: 1000, Key: "attachments/2/c"}, uid)
+	assert.NoError(t, err)
+
+	list, err := db.GetAttachments(1, "1234")
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "manual.pdf", list[0].Filename)
+	assert.Equal(t, "receipt.jpg", list[1].Filename)
+
+	size, err = db.GetAttachmentsSize(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(150), size)
+
+	// Attachments of other orgs cannot be seen
+	_, err = db.GetAttachment(2, manual.ID)
+	assert.Equal(t, attachments.AttachmentNotFoundErr, err)
+	assert.Equal(t, attachments.AttachmentNotFoundErr, db.DeleteAttachment(2, manual.ID, uid))
+
+	assert.NoError(t, db.LogAttachmentDownload(1, manual, uid))
+	assert.Equal(t, 1, countLogs(t, db, "attachment downloaded"))
+
+	assert.NoError(t, db.DeleteAttachment(1, manual.ID, uid))
+	assert.Equal(t, 1, countLogs(t, db, "attachment deleted"))
+	_, err = db.GetAttachment(1, manual.ID)
+	assert.Equal(t, attachments.AttachmentNotFoundErr, err)
+
+	list, err = db.GetAttachments(1, "1234")
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestSQLitePurgeAttachments(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "storage")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	st, err := storage.NewLocal(dir)
+	assert.NoError(t, err)
+
+	uid := addTestUser(t, db, "someUser")
+	s := items.NewService(db, nil, st, config.Config{})
+
+	attach := func(key string) {
+		assert.NoError(t, st.Put(key, strings.NewReader("some file")))
+		_, err := db.AddAttachment(1, attachments.Attachment{ItemID: "1234", Filename: "manual.pdf", ContentType: "application/pdf", Size: 9, Key: key}, uid)
+		assert.NoError(t, err)
+	}
+	gone := func(key string) {
+		list, err := db.GetAttachments(1, "1234")
+		assert.NoError(t, err)
+		assert.Empty(t, list)
+		_, err = st.Get(key)
+		assert.Equal(t, storage.NotFoundErr, err)
+	}
+
+	// Purging an item takes its attachments and their files with it
+	item := items.ItemDetail{ID: "1234", Name: "Drill", LastPerformedBy: strconv.Itoa(uid), Quantity: 1}
+	assert.NoError(t, s.AddItem(1, item, false, uid))
+	attach("attachments/1/a")
+	assert.NoError(t, s.DeleteItem(1, "1234", uid))
+	assert.NoError(t, s.PurgeItem(1, "1234", uid))
+	gone("attachments/1/a")
+
+	// So does emptying the trash
+	assert.NoError(t, s.AddItem(1, item, false, uid))
+	attach("attachments/1/b")
+	attach("attachments/1/c")
+	assert.NoError(t, s.DeleteItem(1, "1234", uid))
+	n, keys, err := db.PurgeItems(1, time.Now().Add(time.Hour), uid)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"attachments/1/b", "attachments/1/c"}, keys)
+	list, err := db.GetAttachments(1, "1234")
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	// And replacing an item in the trash with a new one
+	assert.NoError(t, s.AddItem(1, item, false, uid))
+	attach("attachments/1/d")
+	assert.NoError(t, s.DeleteItem(1, "1234", uid))
+	assert.NoError(t, s.AddItem(1, item, false, uid))
+	gone("attachments/1/d")
+
+	size, err := db.GetAttachmentsSize(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+	assert.Equal(t, 3, countLogs(t, db, "purge"))
+}
+
+func TestSQLiteLogs(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+
+	// Details used to be free text
+	m, err := newMigrator(db.conn, sqliteDialect)
+	assert.NoError(t, err)
+	for {
+		mig, err := m.Rollback()
+		assert.NoError(t, err)
+		if mig.Name == "logs" {
+			break
+		}
+	}
+
+	_, err = db.conn.Exec(`INSERT INTO logs (ORGID, USERID, OBJECTID, ACTION, DETAILS, DATE) VALUES
+		(1, ?, '1234', 'add', 'overwrite/skip exist check flag was recieved as false', '2020-01-02 03:04:05'),
+		(1, ?, '1234', 'delete', '', '2020-01-03 03:04:05')`, uid, uid)
+	assert.NoError(t, err)
+
+	_, err = m.Up()
+	assert.NoError(t, err)
+
+	item := items.ItemDetail{ID: "1234", Name: "Extension cord", LastPerformedBy: strconv.Itoa(uid), Quantity: 10}
+	assert.NoError(t, db.AddItem(1, item, false))
+	_, err = db.MoveItem(1, "1234", "out", 4, uid)
+	assert.NoError(t, err)
+	assert.NoError(t, db.AddItem(2, item, false))
+
+	history := items.LogFilter{ObjectType: items.LogObjectItem, ObjectID: "1234", Page: 1, Limit: 10}
+	es, total, err := db.GetLogs(1, history)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Len(t, es, 4)
+
+	assert.Equal(t, "checked out", es[0].Action)
+	assert.Equal(t, "someUser", es[0].Username)
+	assert.Equal(t, map[string]interface{}{"quantity": 4.0, "checkedOut": 4.0, "total": 10.0}, es[0].Details)
+	assert.Equal(t, "add", es[1].Action)
+	assert.Equal(t, map[string]interface{}{}, es[2].Details)
+	assert.Equal(t, map[string]interface{}{"message": "overwrite/skip exist check flag was recieved as false"}, es[3].Details)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), es[3].Date)
+
+	history.Page, history.Limit = 2, 3
+	es, total, err = db.GetLogs(1, history)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Len(t, es, 1)
+	assert.Equal(t, "add", es[0].Action)
+
+	es, total, err = db.GetLogs(1, items.LogFilter{Action: "add", To: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "1234", es[0].ObjectID)
+
+	es, total, err = db.GetLogs(1, items.LogFilter{Username: "someUser", From: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Len(t, es, 2)
+
+	es, total, err = db.GetLogs(1, items.LogFilter{ObjectType: items.LogObjectUser, Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "user created", es[0].Action)
+	assert.Equal(t, strconv.Itoa(uid), es[0].ObjectID)
+
+	es, total, err = db.GetLogs(3, items.LogFilter{Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, total)
+	assert.Empty(t, es)
+}
+
+func TestSQLiteUsers(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	assert.NoError(t, db.AddUser(1, "someUser", "foo@bar.com", "pass", "admin", false))
+	assert.NoError(t, db.AddUser(1, "someUser2", "foo2@bar.com", "pass2", "stock-keeper", false))
+	assert.Error(t, db.AddUser(1, "someUser", "foo@bar.com", "pass", "admin", false))
+	assert.Error(t, db.AddUser(1, "nobody", "foo@bar.com", "pass", "admin", true))
+	assert.Equal(t, users.RoleNotFoundErr, db.AddUser(1, "nobody", "foo@bar.com", "pass", "janitor", false))
+
+	u, err := db.GetUser("someUser", "wrong")
+	assert.NoError(t, err)
+	assert.False(t, u.Valid)
+
+	u, err = db.GetUser("someUser", "pass")
+	assert.NoError(t, err)
+	assert.True(t, u.Valid)
+	assert.True(t, u.IsSysAdmin)
+	assert.Equal(t, "foo@bar.com", u.Email)
+	assert.Equal(t, "admin", u.Role)
+	assert.Equal(t, users.AllPermissions, u.Permissions)
+
+	us, err := db.GetUsers(1)
+	assert.NoError(t, err)
+	assert.Len(t, us, 2)
+
+	assert.NoError(t, db.AddUser(1, "someUser2", "new@bar.com", "newpass", "admin", true))
+	u2, err := db.GetUser("someUser2", "newpass")
+	assert.NoError(t, err)
+	assert.True(t, u2.Valid)
+	assert.True(t, u2.IsSysAdmin)
+	assert.Equal(t, "new@bar.com", u2.Email)
+
+	assert.NoError(t, db.DeleteUser(1, u2.ID, u.ID))
+	assert.Equal(t, items.ItemNotFoundErr, db.DeleteUser(1, 9999, u.ID))
+
+	u2, err = db.GetUser("someUser2", "newpass")
+	assert.NoError(t, err)
+	assert.False(t, u2.Valid)
+
+	us, err = db.GetUsers(1)
+	assert.NoError(t, err)
+	assert.Len(t, us, 1)
+	assert.Equal(t, 1, countLogs(t, db, "delete user"))
+}
+
+func TestSQLiteLegacyPasswordIsUpgraded(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	_, err := db.conn.Exec(`INSERT INTO users (USERNAME, EMAIL, PASSWORD, TOKEN, ISSYSADMIN) VALUES (?, ?, ?, ?, ?)`,
+		"someUser", "foo@bar.com", "nU4eI71bcnBGqeO0t9tXvY1u5oQ=", "someToken", 1)
+	assert.NoError(t, err)
+
+	u, err := db.GetUser("someUser", "pass")
+	assert.NoError(t, err)
+	assert.True(t, u.Valid)
+
+	var hash string
+	assert.NoError(t, db.conn.Get(&hash, "SELECT PASSWORD FROM users WHERE ID = ?", u.ID))
+	assert.True(t, strings.HasPrefix(hash, "$2a$"), hash)
+
+	u, err = db.GetUser("someUser", "pass")
+	assert.NoError(t, err)
+	assert.True(t, u.Valid)
+}
+
+func TestSQLiteSessions(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+	uid2 := addTestUser(t, db, "someUser2")
+
+	phone, err := db.AddSession(uid, 1, "phone", "1.2.3.4", time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	laptop, err := db.AddSession(uid, 1, "laptop", "1.2.3.5", time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	_, err = db.AddSession(uid, 1, "expired", "1.2.3.6", time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	other, err := db.AddSession(uid2, 1, "other", "1.2.3.7", time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+
+	u, err := db.GetUserByToken(phone.Token)
+	assert.NoError(t, err)
+	assert.True(t, u.Valid)
+	assert.Equal(t, uid, u.ID)
+	assert.Equal(t, phone.ID, u.SessionID)
+	assert.Equal(t, "someUser", u.Username)
+
+	ss, err := db.GetSessions(uid)
+	assert.NoError(t, err)
+	assert.Len(t, ss, 2)
+	assert.Equal(t, phone.ID, ss[0].ID)
+	assert.Equal(t, "phone", ss[0].UserAgent)
+	assert.Equal(t, "1.2.3.4", ss[0].IP)
+	assert.True(t, ss[0].ExpiresAt.After(time.Now()))
+
+	// Sessions of other users cannot be revoked
+	assert.Equal(t, users.SessionNotFoundErr, db.RevokeSession(other.ID, uid))
+
+	assert.NoError(t, db.RevokeSession(phone.ID, uid))
+	assert.Equal(t, users.SessionNotFoundErr, db.RevokeSession(phone.ID, uid))
+
+	u, err = db.GetUserByToken(phone.Token)
+	assert.NoError(t, err)
+	assert.False(t, u.Valid)
+
+	u, err = db.GetUserByToken(laptop.Token)
+	assert.NoError(t, err)
+	assert.True(t, u.Valid)
+
+	// Changing the password logs out everywhere
+	assert.NoError(t, db.AddUser(1, "someUser", "foo@bar.com", "newpass", "admin", true))
+	ss, err = db.GetSessions(uid)
+	assert.NoError(t, err)
+	assert.Empty(t, ss)
+
+	u, err = db.GetUserByToken(other.Token)
+	assert.NoError(t, err)
+	assert.True(t, u.Valid)
+
+	assert.NoError(t, db.RevokeSessions(uid2))
+	u, err = db.GetUserByToken(other.Token)
+	assert.NoError(t, err)
+	assert.False(t, u.Valid)
+
+	u, err = db.GetUserByToken("")
+	assert.NoError(t, err)
+	assert.False(t, u.Valid)
+}
+
+func TestSQLiteRoles(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	rs, err := db.GetRoles(1)
+	assert.NoError(t, err)
+	assert.Equal(t, users.Roles{
+		{Name: "admin", Permissions: users.AllPermissions},
+		{Name: "borrower", Permissions: []string{"item.view", "item.move", "upc.lookup"}},
+		{Name: "stock-keeper", Permissions: []string{"item.view", "item.move", "item.add", "item.edit", "item.delete", "upc.lookup"}},
+		{Name: "viewer", Permissions: []string{"item.view"}},
+	}, rs)
+
+	assert.NoError(t, db.AddUser(1, "someUser", "foo@bar.com", "pass", "borrower", false))
+	u, err := db.GetUser("someUser", "pass")
+	assert.NoError(t, err)
+	assert.False(t, u.IsSysAdmin)
+	assert.True(t, u.Can(users.PermItemMove))
+	assert.False(t, u.Can(users.PermItemDelete))
+
+	assert.NoError(t, db.SetRole(1, users.Role{Name: "borrower", Permissions: []string{"item.view", "item.move", "item.delete"}}, u.ID))
+	assert.NoError(t, db.SetRole(1, users.Role{Name: "auditor", Permissions: []string{"item.view", "user.view"}}, u.ID))
+
+	u, err = db.GetUserByUsername("someUser", 1)
+	assert.NoError(t, err)
+	assert.True(t, u.Can(users.PermItemDelete))
+
+	rs, err = db.GetRoles(1)
+	assert.NoError(t, err)
+	assert.Len(t, rs, 5)
+	assert.Equal(t, users.Role{Name: "auditor", Permissions: []string{"item.view", "user.view"}}, rs[1])
+	assert.Equal(t, 2, countLogs(t, db, "role updated"))
+}
+
+func TestSQLiteMigrateExistingUsers(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	m, err := newMigrator(db.conn, sqliteDialect)
+	assert.NoError(t, err)
+
+	// Roll back to before roles existed and add users the old way
+	for {
+		mig, err := m.Rollback()
+		assert.NoError(t, err)
+		if mig.Name == "roles" {
+			break
+		}
+	}
+
+	_, err = db.conn.Exec(`INSERT INTO users (USERNAME, EMAIL, PASSWORD, TOKEN, ISSYSADMIN) VALUES
+		('admin', 'a@bar.com', 'x', 'a', 1), ('member', 'm@bar.com', 'x', 'm', 0)`)
+	assert.NoError(t, err)
+
+	_, err = m.Up()
+	assert.NoError(t, err)
+
+	admin, err := db.GetUserByUsername("admin", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, users.RoleAdmin, admin.Role)
+
+	member, err := db.GetUserByUsername("member", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, users.RoleStockKeeper, member.Role)
+	assert.True(t, member.Can(users.PermItemDelete))
+	assert.False(t, member.Can(users.PermUserManage))
+}
+
+func TestSQLiteOrgs(t *testing.T) {
+	db, cleanup := newTestSQLite(t)
+	defer cleanup()
+
+	uid := addTestUser(t, db, "someUser")
+	uid2 := addTestUser(t, db, "someUser2")
+
+	orgs, err := db.GetOrgs(uid)
+	assert.NoError(t, err)
+	assert.Equal(t, users.Orgs{{ID: 1, Name: "Default", Role: "admin"}}, orgs)
+
+	org, err := db.AddOrg("Chess Club", uid)
+	assert.NoError(t, err)
+	assert.Equal(t, "Chess Club", org.Name)
+
+	orgs, err = db.GetOrgs(uid)
+	assert.NoError(t, err)
+	assert.Len(t, orgs, 2)
+
+	// Orgs start with the default roles
+	rs, err := db.GetRoles(org.ID)
+	assert.NoError(t, err)
+	assert.Len(t, rs, len(users.DefaultRoles))
+	assert.NoError(t, db.SetRole(org.ID, users.Role{Name: "viewer", Permissions: []string{}}, uid))
+	rs, err = db.GetRoles(1)
+	assert.NoError(t, err)
+	assert.Equal(t, users.Role{Name: "viewer", Permissions: []string{"item.view"}}, rs[3])
+
+	// Item IDs only need to be unique within an org
+	item := items.ItemDetail{ID: "1234", Name: "Board", LastPerformedBy: strconv.Itoa(uid), Quantity: 1}
+	assert.NoError(t, db.AddItem(1, item, false))
+	item.Name = "Clock"
+	assert.NoError(t, db.AddItem(org.ID, item, false))
+
+	r, _, err := db.SearchItems(org.ID, items.ItemFilter{Search: "1234"})
+	assert.NoError(t, err)
+	assert.Len(t, r, 1)
+	assert.Equal(t, "Clock", r[0].Name)
+
+	r, _, err = db.SearchItems(org.ID, items.ItemFilter{Search: "board"})
+	assert.NoError(t, err)
+	assert.Empty(t, r)
+
+	_, err = db.MoveItem(org.ID, "1234", "out", 0, uid)
+	assert.NoError(t, err)
+	r, _, err = db.SearchItems(1, items.ItemFilter{Search: "1234"})
+	assert.NoError(t, err)
+	assert.Equal(t, "checked in", r[0].Status)
+
+	assert.NoError(t, db.DeleteItem(org.ID, "1234", uid))
+	r, _, err = db.SearchItems(1, items.ItemFilter{Search: "1234"})
+	assert.NoError(t, err)
+	assert.Len(t, r, 1)
+
+	// Members only see the orgs they were added to
+	us, err := db.GetUsers(org.ID)
+	assert.NoError(t, err)
+	assert.Len(t, us, 1)
+
+	assert.Equal(t, users.UserNotFoundErr, db.AddMember(org.ID, "nobody", "viewer", uid))
+	assert.Equal(t, users.RoleNotFoundErr, db.AddMember(org.ID, "someUser2", "janitor", uid))
+	assert.NoError(t, db.AddMember(org.ID, "someUser2", "borrower", uid))
+
+	u2, err := db.GetUserByUsername("someUser2", org.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "borrower", u2.Role)
+	assert.Equal(t, org.ID, u2.OrgID)
+
+	// Switching org changes the role the session works with
+	sess, err := db.AddSession(uid2, 1, "phone", "1.2.3.4", time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+
+	u2, err = db.GetUserByToken(sess.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, u2.OrgID)
+	assert.Equal(t, "admin", u2.Role)
+
+	assert.Equal(t, users.OrgNotFoundErr, db.SwitchOrg(sess.ID, uid2, 9999))
+	assert.Equal(t, users.SessionNotFoundErr, db.SwitchOrg(sess.ID, uid, org.ID))
+	assert.NoError(t, db.SwitchOrg(sess.ID, uid2, org.ID))
+
+	u2, err = db.GetUserByToken(sess.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, org.ID, u2.OrgID)
+	assert.Equal(t, "borrower", u2.Role)
+	assert.False(t, u2.Can(users.PermItemDelete))
+
+	// Removing a user from one org keeps them active in the others
+	assert.NoError(t, db.DeleteUser(org.ID, uid2, uid))
+	u2, err = db.GetUser("someUser2", "pass")
+	assert.NoError(t, err)
+	assert.True(t, u2.Valid)
+	assert.Equal(t, 1, u2.OrgID)
+
+	assert.NoError(t, db.DeleteUser(1, uid2, uid))
+	u2, err = db.GetUser("someUser2", "pass")
+	assert.NoError(t, err)
+	assert.False(t, u2.Valid)
+}
@@ -0,0 +1,967 @@
+package persistence
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/passwords"
+	"github.com/Timothylock/inventory-management/users"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type dialect int
+
+const (
+	mysqlDialect dialect = iota
+	sqliteDialect
+)
+
+// store holds the queries shared by every SQL backend. Anything that differs
+// between databases is switched on the dialect.
+type store struct {
+	conn *sqlx.DB
+	// tx is set on the copy of the store used inside a transaction
+	tx        *sqlx.Tx
+	dialect   dialect
+	passwords passwords.Service
+}
+
+// queryer runs queries on the database or inside a transaction
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	Queryx(query string, args ...interface{}) (*sqlx.Rows, error)
+}
+
+// db returns what queries are run on, which is the transaction when inside one
+func (s *store) db() queryer {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.conn
+}
+
+// Transaction runs fn with a persister that makes every change in one
+// transaction. The changes are rolled back if fn returns an error.
+func (s *store) Transaction(fn func(items.Persister) error) error {
+	return s.inTx(func(ts *store) error {
+		return fn(ts)
+	})
+}
+
+// inTx runs fn with a copy of the store that makes every query in one
+// transaction, or with the store itself if it is already in one
+func (s *store) inTx(fn func(*store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx, err := s.conn.Beginx()
+	if err != nil {
+		return err
+	}
+
+	ts := *s
+	ts.tx = tx
+	if err = fn(&ts); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// doesIDExist returns whether the ID is found in the org, not counting the trash
+func (s *store) doesIDExist(orgID int, ID string) (bool, error) {
+	var count int
+
+	err := s.db().Get(
+		&count,
+		"SELECT count(1) FROM items WHERE ORGID = ? AND ID = ? AND DELETED = 0",
+		orgID, ID,
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, err
+}
+
+const itemColumns = `search.ID AS ID, search.NAME AS NAME, CATEGORYID, PICTURE_URL, DETAILS, search.FIELDS AS FIELDS, LOCATIONID, USERNAME,
+	QUANTITY, QUANTITY - CHECKED_OUT AS AVAILABLE, CHECKED_OUT, STATUS`
+
+const itemTables = `items AS search JOIN users ON search.LAST_PERFORMED_BY = users.ID
+	LEFT JOIN categories ON categories.ID = search.CATEGORYID`
+
+// sortColumns are what items are ordered by for each sort key
+var sortColumns = map[string]string{
+	items.SortName:      "search.NAME",
+	items.SortCategory:  "COALESCE(categories.NAME, '')",
+	items.SortQuantity:  "QUANTITY",
+	items.SortAvailable: "QUANTITY - CHECKED_OUT",
+	items.SortStatus:    "STATUS",
+}
+
+// SearchItems returns a page of the items matching the filter. Pages are
+// ordered by the sort key and then the ID so that a cursor can pick up right
+// after the last item of the previous page.
+func (s *store) SearchItems(orgID int, filter items.ItemFilter) (items.ItemDetailList, int, error) {
+	ls, err := s.GetLocations(orgID)
+	if err != nil {
+		return nil, 0, err
+	}
+	cs, err := s.GetCategories(orgID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conds, args := s.itemConds(orgID, filter, ls, cs)
+
+	var total int
+	err = s.db().Get(&total, "SELECT count(1) FROM "+itemTables+" WHERE "+strings.Join(conds, " AND "), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order, col, cmp := itemOrder(filter)
+	if a := filter.After; a != nil {
+		if col != "" {
+			conds = append(conds, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND search.ID %[2]s ?))", col, cmp))
+			args = append(args, a.Value, a.Value, a.ID)
+		} else {
+			conds = append(conds, "search.ID "+cmp+" ?")
+			args = append(args, a.ID)
+		}
+	}
+
+	query := "SELECT " + itemColumns + " FROM " + itemTables + " WHERE " + strings.Join(conds, " AND ") + " ORDER BY " + order
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	dl := []itemDB{}
+	err = s.db().Select(&dl, query, args...)
+
+	ret := items.ItemDetailList{}
+	paths := locationPaths(ls)
+	for _, d := range dl {
+		ret = append(ret, d.toItem(cs, paths))
+	}
+
+	return ret, total, err
+}
+
+// ExportItems reads the items matching the filter one at a time in the order of
+// the sort key, including the items in the trash if the filter says so
+func (s *store) ExportItems(orgID int, filter items.ItemFilter, fn func(items.ExportedItem) error) error {
+	ls, err := s.GetLocations(orgID)
+	if err != nil {
+		return err
+	}
+	cs, err := s.GetCategories(orgID)
+	if err != nil {
+		return err
+	}
+
+	conds, args := s.itemConds(orgID, filter, ls, cs)
+	order, _, _ := itemOrder(filter)
+
+	rows, err := s.db().Queryx(
+		"SELECT "+itemColumns+", DELETED_AT FROM "+itemTables+" WHERE "+strings.Join(conds, " AND ")+" ORDER BY "+order,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	paths := locationPaths(ls)
+	for rows.Next() {
+		d := exportedItemDB{}
+		if err = rows.StructScan(&d); err != nil {
+			return err
+		}
+
+		if err = fn(items.ExportedItem{ItemDetail: d.toItem(cs, paths), DeletedAt: d.DeletedAt}); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+type exportedItemDB struct {
+	itemDB
+	DeletedAt *time.Time `db:"DELETED_AT"`
+}
+
+// itemOrder returns the ORDER BY for the filter along with the column of its
+// sort key and how to compare it to continue after a cursor. The column is
+// blank when items are sorted by ID.
+func itemOrder(filter items.ItemFilter) (string, string, string) {
+	dir, cmp := "ASC", ">"
+	if filter.Descending {
+		dir, cmp = "DESC", "<"
+	}
+
+	order := "search.ID " + dir
+	col, sorted := sortColumns[filter.SortBy]
+	if sorted {
+		order = col + " " + dir + ", " + order
+	}
+
+	return order, col, cmp
+}
+
+// itemConds returns the conditions that select the org's items matching the
+// filter, leaving out the cursor
+func (s *store) itemConds(orgID int, filter items.ItemFilter, ls items.Locations, cs items.Categories) ([]string, []interface{}) {
+	conds := []string{"search.ORGID = ?"}
+	args := []interface{}{orgID}
+	if !filter.Deleted {
+		conds = append(conds, "search.DELETED = 0")
+	}
+	if filter.LocationID != 0 {
+		ids := ls.Subtree(filter.LocationID)
+		conds = append(conds, "search.LOCATIONID IN "+placeholders(len(ids)))
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	if filter.CategoryID != 0 {
+		conds = append(conds, "search.CATEGORYID = ?")
+		args = append(args, filter.CategoryID)
+	}
+	if filter.Status != "" {
+		conds = append(conds, "STATUS = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.QuantityBelow > 0 {
+		conds = append(conds, "QUANTITY < ?")
+		args = append(args, filter.QuantityBelow)
+	}
+	if filter.IDs != nil {
+		if len(filter.IDs) == 0 {
+			conds = append(conds, "1 = 0")
+		} else {
+			conds = append(conds, "search.ID IN "+placeholders(len(filter.IDs)))
+			for _, id := range filter.IDs {
+				args = append(args, id)
+			}
+		}
+	}
+	if filter.Search != "" {
+		cond, condArgs := s.searchCond(filter.Search, filter.SearchIDs, ls, cs)
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+	}
+
+	return conds, args
+}
+
+type itemGroupDB struct {
+	Key      string `db:"GROUPKEY"`
+	Items    int    `db:"ITEMS"`
+	Quantity int    `db:"QUANTITY"`
+}
+
+// groupColumns are what items are grouped by for each kind of summary
+var groupColumns = map[string]string{
+	items.GroupByCategory: "search.CATEGORYID",
+	items.GroupByLocation: "search.LOCATIONID",
+	items.GroupByStatus:   "STATUS",
+}
+
+// GroupItems counts the items matching the filter by category, location or
+// status, sorted by the name of the group
+func (s *store) GroupItems(orgID int, by string, filter items.ItemFilter) (items.ItemGroups, error) {
+	col, ok := groupColumns[by]
+	if !ok {
+		return nil, items.InvalidGroupErr
+	}
+
+	ls, err := s.GetLocations(orgID)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := s.GetCategories(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	conds, args := s.itemConds(orgID, filter, ls, cs)
+
+	dl := []itemGroupDB{}
+	err = s.db().Select(
+		&dl,
+		"SELECT "+col+" AS GROUPKEY, count(1) AS ITEMS, COALESCE(SUM(QUANTITY), 0) AS QUANTITY FROM "+itemTables+
+			" WHERE "+strings.Join(conds, " AND ")+" GROUP BY "+col,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := locationPaths(ls)
+	ret := items.ItemGroups{}
+	for _, g := range dl {
+		group := items.ItemGroup{Name: g.Key, Items: g.Items, Quantity: g.Quantity}
+		if by != items.GroupByStatus {
+			group.ID, _ = strconv.Atoi(g.Key)
+			group.Name = paths[group.ID]
+			if by == items.GroupByCategory {
+				c, _ := cs.Find(group.ID)
+				group.Name = c.Name
+			}
+		}
+		ret = append(ret, group)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return strings.ToLower(ret[i].Name) < strings.ToLower(ret[j].Name)
+	})
+
+	return ret, nil
+}
+
+// searchCond matches items by their text columns, the values of their custom
+// fields, the name of their category or location or by being one of the IDs. Databases without a
+// FULLTEXT index return an item if any of the words appear in any of the
+// searchable columns, like a natural language MATCH.
+func (s *store) searchCond(search string, ids []string, ls items.Locations, cs items.Categories) (string, []interface{}) {
+	conds := []string{"search.ID = ?"}
+	args := []interface{}{search}
+
+	if len(ids) > 0 {
+		conds = append(conds, "search.ID IN "+placeholders(len(ids)))
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	if s.dialect == mysqlDialect {
+		conds = append(conds, "MATCH (search.ID, search.NAME, DETAILS, search.FIELDS) AGAINST (? IN NATURAL LANGUAGE MODE)")
+		args = append(args, search)
+	}
+
+	var locIDs, catIDs []interface{}
+	for _, word := range strings.Fields(search) {
+		if s.dialect == sqliteDialect {
+			for _, col := range []string{"search.ID", "search.NAME", "DETAILS", "search.FIELDS"} {
+				conds = append(conds, col+" LIKE ?")
+				args = append(args, "%"+word+"%")
+			}
+		}
+
+		for _, l := range ls {
+			if strings.Contains(strings.ToLower(l.Name), strings.ToLower(word)) {
+				locIDs = append(locIDs, l.ID)
+			}
+		}
+		for _, c := range cs {
+			if strings.Contains(strings.ToLower(c.Name), strings.ToLower(word)) {
+				catIDs = append(catIDs, c.ID)
+			}
+		}
+	}
+	if len(locIDs) > 0 {
+		conds = append(conds, "search.LOCATIONID IN "+placeholders(len(locIDs)))
+		args = append(args, locIDs...)
+	}
+	if len(catIDs) > 0 {
+		conds = append(conds, "search.CATEGORYID IN "+placeholders(len(catIDs)))
+		args = append(args, catIDs...)
+	}
+
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+type quantityDB struct {
+	Quantity   int `db:"QUANTITY"`
+	CheckedOut int `db:"CHECKED_OUT"`
+}
+
+// getQuantity returns how many of the item there are and how many are checked out
+func (s *store) getQuantity(orgID int, ID string) (quantityDB, error) {
+	var q quantityDB
+
+	err := s.db().Get(
+		&q,
+		"SELECT QUANTITY, CHECKED_OUT FROM items WHERE ORGID = ? AND ID = ? AND DELETED = 0",
+		orgID, ID,
+	)
+	if err == sql.ErrNoRows {
+		return q, items.ItemNotFoundErr
+	}
+
+	return q, err
+}
+
+func (s *store) MoveItem(orgID int, ID, direction string, quantity, userID int) (int, error) {
+	if direction != "in" && direction != "out" {
+		return 0, errors.New("invalid direction")
+	}
+
+	q, err := s.getQuantity(orgID, ID)
+	if err != nil {
+		return 0, err
+	}
+
+	checkedOut := q.CheckedOut
+	if direction == "out" {
+		if quantity == 0 {
+			quantity = q.Quantity - q.CheckedOut
+		}
+		if quantity <= 0 || quantity > q.Quantity-q.CheckedOut {
+			return 0, items.NotEnoughAvailableErr
+		}
+		checkedOut += quantity
+	} else {
+		if quantity == 0 {
+			quantity = q.CheckedOut
+		}
+		if quantity > q.CheckedOut {
+			return 0, items.NotEnoughCheckedOutErr
+		}
+		checkedOut -= quantity
+	}
+	status := items.StatusFor(q.Quantity, checkedOut)
+
+	// Only update the row if nobody else moved the item in the meantime
+	r, err := s.db().Exec(
+		"UPDATE items SET STATUS = ?, CHECKED_OUT = ?, LAST_PERFORMED_BY = ? WHERE ORGID = ? AND ID = ? AND CHECKED_OUT = ?",
+		status, checkedOut, userID, orgID, ID, q.CheckedOut,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if ra <= 0 {
+		return 0, errors.New("the item was moved by someone else at the same time, please try again")
+	}
+
+	s.addLog(orgID, userID, items.LogObjectItem, ID, "checked "+direction, logDetails{
+		"quantity":   quantity,
+		"checkedOut": checkedOut,
+		"total":      q.Quantity,
+	})
+
+	return quantity, nil
+}
+
+func (s *store) AddItem(orgID int, obj items.ItemDetail, overwrite bool) error {
+	exist, err := s.doesIDExist(orgID, obj.ID)
+	if err != nil {
+		return err
+	}
+	if exist && !overwrite {
+		return items.ItemAlreadyExistsErr
+	}
+	if !exist && overwrite {
+		return items.ItemNotFoundErr
+	}
+
+	if obj.LocationID != 0 {
+		found, err := s.doesLocationExist(orgID, obj.LocationID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return items.LocationNotFoundErr
+		}
+	}
+
+	// A new item replaces anything with the same ID in the trash, whose
+	// attachments go with it. The items service purges it first instead so
+	// that their files are deleted too.
+	inTrash := false
+	if !overwrite {
+		inTrash, err = s.isInTrash(orgID, obj.ID)
+		if err != nil {
+			return err
+		}
+	}
+	if inTrash {
+		if _, err = s.db().Exec("DELETE FROM items WHERE ORGID = ? AND ID = ? AND DELETED = 1", orgID, obj.ID); err != nil {
+			return err
+		}
+		if _, err = s.deleteItemAttachments(orgID, obj.ID); err != nil {
+			return err
+		}
+	}
+
+	fields, err := encodeFields(obj.Fields)
+	if err != nil {
+		return err
+	}
+
+	checkedOut := 0
+	if overwrite {
+		q, err := s.getQuantity(orgID, obj.ID)
+		if err != nil {
+			return err
+		}
+		if obj.Quantity < q.CheckedOut {
+			return items.QuantityBelowCheckedOutErr
+		}
+		checkedOut = q.CheckedOut
+	}
+
+	if !overwrite {
+		_, err = s.db().Exec(
+			`INSERT INTO items (ORGID, ID, NAME, CATEGORYID, PICTURE_URL, DETAILS, FIELDS, LOCATIONID, LAST_PERFORMED_BY, QUANTITY, STATUS)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) `,
+			orgID, obj.ID, obj.Name, obj.CategoryID, obj.PictureURL, obj.Details, fields, obj.LocationID, obj.LastPerformedBy, obj.Quantity, items.StatusCheckedIn)
+	} else if overwrite {
+		_, err = s.db().Exec(
+			`UPDATE items SET ID = ?, NAME = ?, CATEGORYID = ?, PICTURE_URL = ?, DETAILS = ?, FIELDS = ?, LOCATIONID = ?, LAST_PERFORMED_BY = ?, QUANTITY = ?, STATUS = ? WHERE ORGID = ? AND ID = ?`,
+			obj.ID, obj.Name, obj.CategoryID, obj.PictureURL, obj.Details, fields, obj.LocationID, obj.LastPerformedBy, obj.Quantity, items.StatusFor(obj.Quantity, checkedOut), orgID, obj.ID)
+	}
+
+	if err == nil {
+		uid, err := strconv.Atoi(obj.LastPerformedBy)
+		if err == nil {
+			s.addLog(orgID, uid, items.LogObjectItem, obj.ID, "add", logDetails{"overwrite": overwrite, "quantity": obj.Quantity, "replacedDeleted": inTrash})
+		}
+	}
+
+	return err
+}
+
+func (s *store) DeleteItem(orgID int, ID string, userID int) error {
+	r, err := s.db().Exec(`UPDATE items SET DELETED=1, DELETED_AT=?, LAST_PERFORMED_BY=? WHERE ORGID=? AND ID=? AND DELETED=0`,
+		time.Now().UTC(), userID, orgID, ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra <= 0 {
+		return items.ItemNotFoundErr
+	}
+
+	s.addLog(orgID, userID, items.LogObjectItem, ID, "delete", nil)
+
+	return err
+}
+
+// userColumns selects a user along with their role in an org and its
+// permissions. The org membership must be joined with memberJoin.
+const userColumns = `users.ID AS ID, EMAIL, users.TOKEN AS TOKEN, USERNAME, COALESCE(org_members.ROLE, '') AS ROLE, COALESCE(PERMISSIONS, '') AS PERMISSIONS`
+
+// defaultOrg is the org users work in right after logging in
+const defaultOrg = `(SELECT MIN(m.ORGID) FROM org_members AS m WHERE m.USERID = users.ID)`
+
+// memberJoin joins the membership of users in the org given by the SQL
+// expression, along with the permissions of their role there
+func memberJoin(org string) string {
+	return ` LEFT JOIN org_members ON org_members.USERID = users.ID AND org_members.ORGID = ` + org +
+		` LEFT JOIN roles ON roles.ORGID = org_members.ORGID AND roles.NAME = org_members.ROLE`
+}
+
+type MultiUserDB []UserDB
+type UserDB struct {
+	ID          int    `db:"ID"`
+	OrgID       int    `db:"ORGID"`
+	Email       string `db:"EMAIL"`
+	Token       string `db:"TOKEN"`
+	Username    string `db:"USERNAME"`
+	Password    string `db:"PASSWORD"`
+	Role        string `db:"ROLE"`
+	Permissions string `db:"PERMISSIONS"`
+}
+
+func (u UserDB) toUser() users.User {
+	return users.User{
+		Valid:       true,
+		ID:          u.ID,
+		IsSysAdmin:  u.Role == users.RoleAdmin,
+		Email:       u.Email,
+		Token:       u.Token,
+		Username:    u.Username,
+		OrgID:       u.OrgID,
+		Role:        u.Role,
+		Permissions: strings.Fields(u.Permissions),
+	}
+}
+
+// GetUser gets the given user in their default org if the password matches.
+// Passwords stored with an outdated hash are upgraded in place.
+func (s *store) GetUser(username, password string) (users.User, error) {
+	var user users.User
+	user.Valid = false
+
+	var userdb UserDB
+	err := s.db().Get(
+		&userdb,
+		"SELECT "+userColumns+", COALESCE(org_members.ORGID, 0) AS ORGID, PASSWORD FROM users"+memberJoin(defaultOrg)+" WHERE USERNAME = ? AND ACTIVE = 1",
+		username,
+	)
+	if err == sql.ErrNoRows {
+		return user, nil
+	} else if err != nil {
+		return user, err
+	}
+
+	ok, rehash, err := s.passwords.Verify(password, userdb.Password)
+	if err != nil || !ok {
+		return user, err
+	}
+
+	if rehash {
+		if err = s.setPassword(userdb.ID, password); err != nil {
+			return user, err
+		}
+	}
+
+	user = userdb.toUser()
+
+	return user, err
+}
+
+func (s *store) setPassword(userID int, password string) error {
+	hash, err := s.passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db().Exec("UPDATE users SET PASSWORD = ? WHERE ID = ?", hash, userID)
+	return err
+}
+
+// GetUserByUsername returns the user given the username with their role in the
+// org. To be used internally or by admins only!
+func (s *store) GetUserByUsername(username string, orgID int) (users.User, error) {
+	var user users.User
+	user.Valid = false
+
+	var userdb UserDB
+	err := s.db().Get(
+		&userdb,
+		"SELECT "+userColumns+" FROM users"+memberJoin("?")+" WHERE USERNAME = ? AND ACTIVE = 1",
+		orgID, username,
+	)
+	if err == sql.ErrNoRows {
+		return user, nil
+	} else if err != nil {
+		return user, err
+	}
+
+	userdb.OrgID = orgID
+	user = userdb.toUser()
+
+	return user, err
+}
+
+type sessionUserDB struct {
+	UserDB
+	SessionID int       `db:"SESSIONID"`
+	ExpiresAt time.Time `db:"EXPIRES_AT"`
+}
+
+// GetUserByToken returns the user owning the given session token if the session
+// is still valid, and marks the session as seen. The user is given their role in
+// the org that the session is working in.
+func (s *store) GetUserByToken(token string) (users.User, error) {
+	var user users.User
+	user.Valid = false
+	if token == "" {
+		return user, nil
+	}
+
+	var userdb sessionUserDB
+	err := s.db().Get(
+		&userdb,
+		`SELECT `+userColumns+`, sessions.ORGID AS ORGID, sessions.ID AS SESSIONID, EXPIRES_AT
+		FROM sessions JOIN users ON sessions.USERID = users.ID`+memberJoin("sessions.ORGID")+`
+		WHERE sessions.TOKEN = ? AND REVOKED = 0 AND ACTIVE = 1`,
+		token,
+	)
+	if err == sql.ErrNoRows {
+		return user, nil
+	} else if err != nil {
+		return user, err
+	}
+
+	now := time.Now().UTC()
+	if !now.Before(userdb.ExpiresAt) {
+		return user, nil
+	}
+
+	_, err = s.db().Exec("UPDATE sessions SET LAST_SEEN_AT = ? WHERE ID = ?", now, userdb.SessionID)
+	if err != nil {
+		return user, err
+	}
+
+	user = userdb.toUser()
+	user.Token = token
+	user.SessionID = userdb.SessionID
+
+	return user, err
+}
+
+type MultiSessionDB []SessionDB
+type SessionDB struct {
+	ID         int       `db:"ID"`
+	UserID     int       `db:"USERID"`
+	OrgID      int       `db:"ORGID"`
+	Token      string    `db:"TOKEN"`
+	CreatedAt  time.Time `db:"CREATED_AT"`
+	LastSeenAt time.Time `db:"LAST_SEEN_AT"`
+	ExpiresAt  time.Time `db:"EXPIRES_AT"`
+	UserAgent  string    `db:"USER_AGENT"`
+	IP         string    `db:"IP"`
+}
+
+// AddSession starts a new session for the user working in the org
+func (s *store) AddSession(userID, orgID int, userAgent, ip string, expiresAt time.Time) (users.Session, error) {
+	now := time.Now().UTC()
+	session := users.Session{
+		UserID:     userID,
+		OrgID:      orgID,
+		Token:      generateToken(),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt.UTC(),
+		UserAgent:  userAgent,
+		IP:         ip,
+	}
+
+	r, err := s.db().Exec(
+		`INSERT INTO sessions (TOKEN, USERID, ORGID, CREATED_AT, LAST_SEEN_AT, EXPIRES_AT, USER_AGENT, IP) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.Token, session.UserID, session.OrgID, session.CreatedAt, session.LastSeenAt, session.ExpiresAt, session.UserAgent, session.IP,
+	)
+	if err != nil {
+		return session, err
+	}
+
+	id, err := r.LastInsertId()
+	session.ID = int(id)
+
+	return session, err
+}
+
+// GetSessions returns the unexpired and unrevoked sessions of the user, most recently used first
+func (s *store) GetSessions(userID int) (users.Sessions, error) {
+	dl := MultiSessionDB{}
+	err := s.db().Select(
+		&dl,
+		`SELECT ID, USERID, ORGID, TOKEN, CREATED_AT, LAST_SEEN_AT, EXPIRES_AT, USER_AGENT, IP FROM sessions
+		WHERE USERID = ? AND REVOKED = 0 ORDER BY LAST_SEEN_AT DESC`,
+		userID,
+	)
+
+	ret := users.Sessions{}
+	now := time.Now().UTC()
+
+	for _, sess := range dl {
+		if !now.Before(sess.ExpiresAt) {
+			continue
+		}
+
+		ret = append(ret, users.Session{
+			ID:         sess.ID,
+			UserID:     sess.UserID,
+			OrgID:      sess.OrgID,
+			Token:      sess.Token,
+			CreatedAt:  sess.CreatedAt,
+			LastSeenAt: sess.LastSeenAt,
+			ExpiresAt:  sess.ExpiresAt,
+			UserAgent:  sess.UserAgent,
+			IP:         sess.IP,
+		})
+	}
+
+	return ret, err
+}
+
+// RevokeSession revokes one of the sessions belonging to the user
+func (s *store) RevokeSession(sessionID, userID int) error {
+	r, err := s.db().Exec(`UPDATE sessions SET REVOKED=1 WHERE ID=? AND USERID=? AND REVOKED=0`,
+		sessionID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra <= 0 {
+		return users.SessionNotFoundErr
+	}
+
+	return err
+}
+
+// RevokeSessions revokes every session belonging to the user
+func (s *store) RevokeSessions(userID int) error {
+	_, err := s.db().Exec(`UPDATE sessions SET REVOKED=1 WHERE USERID=? AND REVOKED=0`, userID)
+	return err
+}
+
+// AddUser adds a new user to the org or updates an existing one along with
+// their role in the org
+func (s *store) AddUser(orgID int, username, email, password, role string, overwrite bool) error {
+	exist, err := s.doesRoleExist(orgID, role)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return users.RoleNotFoundErr
+	}
+
+	hash, err := s.passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+	token := generateToken()
+	isSysAdmin := role == users.RoleAdmin
+
+	u, err := s.GetUserByUsername(username, orgID)
+	if err != nil {
+		return err
+	} else if overwrite && !u.Valid {
+		return errors.New("user does not exist")
+	} else if !overwrite && u.Valid {
+		return errors.New("user already exists")
+	}
+
+	if !overwrite {
+		r, err := s.db().Exec(
+			`INSERT INTO users (USERNAME, EMAIL, PASSWORD, TOKEN, ISSYSADMIN) VALUES (?, ?, ?, ?, ?)`,
+			username, email, hash, token, isSysAdmin,
+		)
+		if err != nil {
+			return err
+		}
+
+		id, err := r.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		if err = s.setMember(orgID, int(id), role); err != nil {
+			return err
+		}
+
+		s.addLog(orgID, 0, items.LogObjectUser, strconv.Itoa(int(id)), "user created", logDetails{"username": username})
+	} else {
+		_, err = s.db().Exec(
+			`UPDATE users SET USERNAME = ?, EMAIL = ?, PASSWORD = ?, TOKEN = ?, ISSYSADMIN = ? WHERE USERNAME = ?`,
+			username, email, hash, token, isSysAdmin, username)
+		if err != nil {
+			return err
+		}
+
+		if err = s.setMember(orgID, u.ID, role); err != nil {
+			return err
+		}
+
+		// The password may have changed so log out everywhere
+		err = s.RevokeSessions(u.ID)
+
+		s.addLog(orgID, 0, items.LogObjectUser, strconv.Itoa(u.ID), "user updated", logDetails{"username": username})
+	}
+
+	return err
+}
+
+// SetPassword replaces the password of the user and revokes all of their sessions
+func (s *store) SetPassword(userID int, password string) error {
+	if err := s.setPassword(userID, password); err != nil {
+		return err
+	}
+
+	err := s.RevokeSessions(userID)
+	if err == nil {
+		s.addLog(0, 0, items.LogObjectUser, strconv.Itoa(userID), "password reset", nil)
+	}
+
+	return err
+}
+
+// DeleteUser removes the user from the org and deactivates them once they are
+// not a member of any org
+func (s *store) DeleteUser(orgID, targetID, userID int) error {
+	r, err := s.db().Exec(`DELETE FROM org_members WHERE ORGID=? AND USERID=?`,
+		orgID, targetID,
+	)
+	if err != nil {
+		return err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra <= 0 {
+		return items.ItemNotFoundErr
+	}
+
+	var count int
+	err = s.db().Get(&count, "SELECT count(1) FROM org_members WHERE USERID = ?", targetID)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		_, err = s.db().Exec(`UPDATE users SET ACTIVE=0 WHERE ID=?`, targetID)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.addLog(orgID, userID, items.LogObjectUser, strconv.Itoa(targetID), "delete user", nil)
+
+	return err
+}
+
+func generateToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// GetUsers gets all the active members of the org
+func (s *store) GetUsers(orgID int) (users.MultipleUsers, error) {
+	dl := MultiUserDB{}
+	err := s.db().Select(
+		&dl,
+		"SELECT "+userColumns+" FROM users"+memberJoin("?")+" WHERE ACTIVE = 1 AND org_members.ROLE IS NOT NULL",
+		orgID,
+	)
+
+	ret := users.MultipleUsers{}
+
+	for _, u := range dl {
+		u.OrgID = orgID
+		ret = append(ret, u.toUser())
+	}
+
+	return ret, err
+}
@@ -0,0 +1,144 @@
+package persistence
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Timothylock/inventory-management/attachments"
+	"github.com/Timothylock/inventory-management/items"
+)
+
+const attachmentColumns = `attachments.ID AS ID, ITEMID, FILENAME, CONTENT_TYPE, SIZE, STORAGE_KEY,
+	COALESCE(users.USERNAME, '') AS UPLOADED_BY, UPLOADED_AT
+	FROM attachments LEFT JOIN users ON users.ID = attachments.UPLOADED_BY`
+
+type MultiAttachmentDB []AttachmentDB
+type AttachmentDB struct {
+	ID          int       `db:"ID"`
+	ItemID      string    `db:"ITEMID"`
+	Filename    string    `db:"FILENAME"`
+	ContentType string    `db:"CONTENT_TYPE"`
+	Size        int64     `db:"SIZE"`
+	Key         string    `db:"STORAGE_KEY"`
+	UploadedBy  string    `db:"UPLOADED_BY"`
+	UploadedAt  time.Time `db:"UPLOADED_AT"`
+}
+
+func (a AttachmentDB) toAttachment() attachments.Attachment {
+	return attachments.Attachment{
+		ID:          a.ID,
+		ItemID:      a.ItemID,
+		Filename:    a.Filename,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		Key:         a.Key,
+		UploadedBy:  a.UploadedBy,
+		UploadedAt:  a.UploadedAt,
+	}
+}
+
+// AddAttachment records the attachment and returns it with its ID
+func (s *store) AddAttachment(orgID int, a attachments.Attachment, userID int) (attachments.Attachment, error) {
+	var id int64
+	err := s.inTx(func(ts *store) error {
+		r, err := ts.db().Exec(
+			`INSERT INTO attachments (ORGID, ITEMID, FILENAME, CONTENT_TYPE, SIZE, STORAGE_KEY, UPLOADED_BY, UPLOADED_AT)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			orgID, a.ItemID, a.Filename, a.ContentType, a.Size, a.Key, userID, time.Now().UTC(),
+		)
+		if err != nil {
+			return err
+		}
+
+		if id, err = r.LastInsertId(); err != nil {
+			return err
+		}
+
+		return ts.addLog(orgID, userID, items.LogObjectItem, a.ItemID, "attachment added", logDetails{
+			"attachmentId": id,
+			"filename":     a.Filename,
+			"size":         a.Size,
+		})
+	})
+	if err != nil {
+		return a, err
+	}
+
+	return s.GetAttachment(orgID, int(id))
+}
+
+func (s *store) GetAttachment(orgID, attachmentID int) (attachments.Attachment, error) {
+	var a AttachmentDB
+	err := s.db().Get(&a, "SELECT "+attachmentColumns+" WHERE attachments.ORGID = ? AND attachments.ID = ?", orgID, attachmentID)
+	if err == sql.ErrNoRows {
+		return attachments.Attachment{}, attachments.AttachmentNotFoundErr
+	} else if err != nil {
+		return attachments.Attachment{}, err
+	}
+
+	return a.toAttachment(), nil
+}
+
+// GetAttachments returns the attachments of the item, oldest first
+func (s *store) GetAttachments(orgID int, itemID string) (attachments.Attachments, error) {
+	da := MultiAttachmentDB{}
+	err := s.db().Select(
+		&da,
+		"SELECT "+attachmentColumns+" WHERE attachments.ORGID = ? AND ITEMID = ? ORDER BY UPLOADED_AT, attachments.ID",
+		orgID, itemID,
+	)
+
+	ret := attachments.Attachments{}
+	for _, a := range da {
+		ret = append(ret, a.toAttachment())
+	}
+
+	return ret, err
+}
+
+func (s *store) DeleteAttachment(orgID, attachmentID, userID int) error {
+	a, err := s.GetAttachment(orgID, attachmentID)
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(func(ts *store) error {
+		_, err := ts.db().Exec("DELETE FROM attachments WHERE ORGID = ? AND ID = ?", orgID, attachmentID)
+		if err != nil {
+			return err
+		}
+
+		return ts.addLog(orgID, userID, items.LogObjectItem, a.ItemID, "attachment deleted", logDetails{
+			"attachmentId": a.ID,
+			"filename":     a.Filename,
+		})
+	})
+}
+
+// deleteItemAttachments removes the attachments of the item and returns the
+// storage keys of their files
+func (s *store) deleteItemAttachments(orgID int, itemID string) ([]string, error) {
+	keys := []string{}
+	err := s.db().Select(&keys, "SELECT STORAGE_KEY FROM attachments WHERE ORGID = ? AND ITEMID = ? ORDER BY ID", orgID, itemID)
+	if err != nil || len(keys) == 0 {
+		return keys, err
+	}
+
+	_, err = s.db().Exec("DELETE FROM attachments WHERE ORGID = ? AND ITEMID = ?", orgID, itemID)
+	return keys, err
+}
+
+// LogAttachmentDownload records that the user downloaded the attachment
+func (s *store) LogAttachmentDownload(orgID int, a attachments.Attachment, userID int) error {
+	return s.addLog(orgID, userID, items.LogObjectItem, a.ItemID, "attachment downloaded", logDetails{
+		"attachmentId": a.ID,
+		"filename":     a.Filename,
+	})
+}
+
+// GetAttachmentsSize returns how many bytes the org's attachments take up
+func (s *store) GetAttachmentsSize(orgID int) (int64, error) {
+	var size int64
+	err := s.db().Get(&size, "SELECT COALESCE(SUM(SIZE), 0) FROM attachments WHERE ORGID = ?", orgID)
+	return size, err
+}
@@ -0,0 +1,165 @@
+package persistence
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/Timothylock/inventory-management/items"
+)
+
+type MultiCategoryDB []CategoryDB
+type CategoryDB struct {
+	ID     int    `db:"ID"`
+	Name   string `db:"NAME"`
+	Fields []byte `db:"FIELDS"`
+}
+
+// itemDB is an item along with the JSON encoded values of its custom fields
+type itemDB struct {
+	items.ItemDetail
+	Fields []byte `db:"FIELDS"`
+}
+
+// toItem fills in the names of the item's category and location. Only the
+// values of fields that the category still has are returned.
+func (i itemDB) toItem(cs items.Categories, paths map[int]string) items.ItemDetail {
+	item := i.ItemDetail
+	item.Location = paths[item.LocationID]
+	item.Fields = map[string]string{}
+
+	c, ok := cs.Find(item.CategoryID)
+	if !ok {
+		return item
+	}
+	item.Category = c.Name
+
+	values := map[string]string{}
+	if len(i.Fields) > 0 && json.Unmarshal(i.Fields, &values) != nil {
+		return item
+	}
+	for _, f := range c.Fields {
+		if v, ok := values[f.Name]; ok {
+			item.Fields[f.Name] = v
+		}
+	}
+
+	return item
+}
+
+// encodeFields returns the values of an item's custom fields as stored in FIELDS
+func encodeFields(values map[string]string) (string, error) {
+	if values == nil {
+		values = map[string]string{}
+	}
+	b, err := json.Marshal(values)
+	return string(b), err
+}
+
+func (s *store) GetCategories(orgID int) (items.Categories, error) {
+	dl := MultiCategoryDB{}
+	err := s.db().Select(&dl, "SELECT ID, NAME, FIELDS FROM categories WHERE ORGID = ?", orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := items.Categories{}
+	for _, c := range dl {
+		cat := items.Category{ID: c.ID, Name: c.Name, Fields: []items.Field{}}
+		if err = json.Unmarshal(c.Fields, &cat.Fields); err != nil {
+			return nil, err
+		}
+		ret = append(ret, cat)
+	}
+
+	return ret, nil
+}
+
+func (s *store) AddCategory(orgID int, c items.Category, userID int) (items.Category, error) {
+	if c.Fields == nil {
+		c.Fields = []items.Field{}
+	}
+	fields, err := json.Marshal(c.Fields)
+	if err != nil {
+		return c, err
+	}
+
+	r, err := s.db().Exec("INSERT INTO categories (ORGID, NAME, FIELDS) VALUES (?, ?, ?)", orgID, c.Name, string(fields))
+	if err != nil {
+		return c, err
+	}
+
+	id, err := r.LastInsertId()
+	if err != nil {
+		return c, err
+	}
+	c.ID = int(id)
+
+	s.addLog(orgID, userID, items.LogObjectCategory, strconv.Itoa(c.ID), "category added", logDetails{
+		"name":   c.Name,
+		"fields": c.Fields,
+	})
+
+	return c, nil
+}
+
+func (s *store) UpdateCategory(orgID int, c items.Category, userID int) error {
+	if c.Fields == nil {
+		c.Fields = []items.Field{}
+	}
+	fields, err := json.Marshal(c.Fields)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db().Exec(
+		"UPDATE categories SET NAME = ?, FIELDS = ? WHERE ORGID = ? AND ID = ?",
+		c.Name, string(fields), orgID, c.ID,
+	)
+	if err == nil {
+		s.addLog(orgID, userID, items.LogObjectCategory, strconv.Itoa(c.ID), "category updated", logDetails{
+			"name":   c.Name,
+			"fields": c.Fields,
+		})
+	}
+
+	return err
+}
+
+func (s *store) DeleteCategory(orgID, categoryID, userID int) error {
+	var count int
+	err := s.db().Get(
+		&count,
+		"SELECT count(1) FROM items WHERE ORGID = ? AND CATEGORYID = ? AND DELETED = 0",
+		orgID, categoryID,
+	)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return items.CategoryInUseErr
+	}
+
+	r, err := s.db().Exec("DELETE FROM categories WHERE ORGID = ? AND ID = ?", orgID, categoryID)
+	if err != nil {
+		return err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra <= 0 {
+		return items.CategoryNotFoundErr
+	}
+
+	// Items in the trash lose their category
+	_, err = s.db().Exec("UPDATE items SET CATEGORYID = 0 WHERE ORGID = ? AND CATEGORYID = ?", orgID, categoryID)
+	if err != nil {
+		return err
+	}
+
+	s.addLog(orgID, userID, items.LogObjectCategory, strconv.Itoa(categoryID), "category deleted", nil)
+
+	return nil
+}
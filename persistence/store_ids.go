@@ -0,0 +1,26 @@
+package persistence
+
+// NextItemNumber returns the next number of the org's sequence of item IDs and
+// moves the sequence past it
+func (s *store) NextItemNumber(orgID int) (int, error) {
+	insert := "INSERT IGNORE INTO item_ids (ORGID) VALUES (?)"
+	if s.dialect == sqliteDialect {
+		insert = "INSERT OR IGNORE INTO item_ids (ORGID) VALUES (?)"
+	}
+
+	var n int
+	err := s.inTx(func(ts *store) error {
+		if _, err := ts.db().Exec(insert, orgID); err != nil {
+			return err
+		}
+
+		// Updating first locks the row until the number is read back
+		if _, err := ts.db().Exec("UPDATE item_ids SET NEXT = NEXT + 1 WHERE ORGID = ?", orgID); err != nil {
+			return err
+		}
+
+		return ts.db().Get(&n, "SELECT NEXT - 1 FROM item_ids WHERE ORGID = ?", orgID)
+	})
+
+	return n, err
+}
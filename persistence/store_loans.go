@@ -0,0 +1,169 @@
+package persistence
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/Timothylock/inventory-management/items"
+)
+
+const loanColumns = `loans.ID AS ID, ITEMID, COALESCE(items.NAME, '') AS ITEMNAME, loans.QUANTITY AS QUANTITY, RETURNED,
+	BORROWER_USERID, BORROWER_NAME, DUE_AT, NOTES, CHECKED_OUT_AT, COALESCE(users.USERNAME, '') AS CHECKED_OUT_BY, RETURNED_AT
+	FROM loans LEFT JOIN items ON items.ORGID = loans.ORGID AND items.ID = loans.ITEMID
+	LEFT JOIN users ON users.ID = loans.CHECKED_OUT_BY`
+
+type MultiLoanDB []LoanDB
+type LoanDB struct {
+	ID             int        `db:"ID"`
+	ItemID         string     `db:"ITEMID"`
+	ItemName       string     `db:"ITEMNAME"`
+	Quantity       int        `db:"QUANTITY"`
+	Returned       int        `db:"RETURNED"`
+	BorrowerUserID int        `db:"BORROWER_USERID"`
+	Borrower       string     `db:"BORROWER_NAME"`
+	DueAt          *time.Time `db:"DUE_AT"`
+	Notes          string     `db:"NOTES"`
+	CheckedOutAt   time.Time  `db:"CHECKED_OUT_AT"`
+	CheckedOutBy   string     `db:"CHECKED_OUT_BY"`
+	ReturnedAt     *time.Time `db:"RETURNED_AT"`
+}
+
+func (l LoanDB) toLoan() items.Loan {
+	return items.Loan{
+		ID:             l.ID,
+		ItemID:         l.ItemID,
+		ItemName:       l.ItemName,
+		Quantity:       l.Quantity,
+		Returned:       l.Returned,
+		BorrowerUserID: l.BorrowerUserID,
+		Borrower:       l.Borrower,
+		DueAt:          l.DueAt,
+		Notes:          l.Notes,
+		CheckedOutAt:   l.CheckedOutAt,
+		CheckedOutBy:   l.CheckedOutBy,
+		ReturnedAt:     l.ReturnedAt,
+	}
+}
+
+// AddLoan records that the quantity of the item was lent out
+func (s *store) AddLoan(orgID int, loan items.Loan, userID int) (items.Loan, error) {
+	var due *time.Time
+	if loan.DueAt != nil {
+		d := loan.DueAt.UTC()
+		due = &d
+	}
+
+	r, err := s.db().Exec(
+		`INSERT INTO loans (ORGID, ITEMID, QUANTITY, BORROWER_USERID, BORROWER_NAME, DUE_AT, NOTES, CHECKED_OUT_AT, CHECKED_OUT_BY)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		orgID, loan.ItemID, loan.Quantity, loan.BorrowerUserID, loan.Borrower, due, loan.Notes, time.Now().UTC(), userID,
+	)
+	if err != nil {
+		return loan, err
+	}
+
+	id, err := r.LastInsertId()
+	if err != nil {
+		return loan, err
+	}
+
+	s.addLog(orgID, userID, items.LogObjectItem, loan.ItemID, "loan", logDetails{
+		"loanId":   id,
+		"quantity": loan.Quantity,
+		"borrower": loan.Borrower,
+	})
+
+	return s.GetLoan(orgID, int(id))
+}
+
+func (s *store) GetLoan(orgID, loanID int) (items.Loan, error) {
+	var l LoanDB
+	err := s.db().Get(&l, "SELECT "+loanColumns+" WHERE loans.ORGID = ? AND loans.ID = ?", orgID, loanID)
+	if err == sql.ErrNoRows {
+		return items.Loan{}, items.LoanNotFoundErr
+	} else if err != nil {
+		return items.Loan{}, err
+	}
+
+	return l.toLoan(), nil
+}
+
+// GetLoans returns the loans of the org matching the filter, oldest first
+func (s *store) GetLoans(orgID int, filter items.LoanFilter) (items.Loans, error) {
+	conds := []string{"loans.ORGID = ?"}
+	args := []interface{}{orgID}
+	if filter.OpenOnly {
+		conds = append(conds, "RETURNED_AT IS NULL")
+	}
+	if filter.Borrower != "" {
+		conds = append(conds, "BORROWER_NAME = ?")
+		args = append(args, filter.Borrower)
+	}
+
+	dl := MultiLoanDB{}
+	err := s.db().Select(
+		&dl,
+		"SELECT "+loanColumns+" WHERE "+strings.Join(conds, " AND ")+" ORDER BY CHECKED_OUT_AT, loans.ID",
+		args...,
+	)
+
+	ret := items.Loans{}
+	for _, l := range dl {
+		ret = append(ret, l.toLoan())
+	}
+
+	return ret, err
+}
+
+type openLoanDB struct {
+	ID       int `db:"ID"`
+	Quantity int `db:"QUANTITY"`
+	Returned int `db:"RETURNED"`
+}
+
+// ReturnLoans marks quantity as returned on the open loans of the item, oldest
+// first, or only on loanID if it is not 0. Loans are closed once everything
+// has been returned. Anything left over was checked out without a loan.
+func (s *store) ReturnLoans(orgID int, itemID string, loanID, quantity int) error {
+	if quantity <= 0 {
+		return nil
+	}
+
+	query := "SELECT ID, QUANTITY, RETURNED FROM loans WHERE ORGID = ? AND ITEMID = ? AND RETURNED_AT IS NULL"
+	args := []interface{}{orgID, itemID}
+	if loanID != 0 {
+		query += " AND ID = ?"
+		args = append(args, loanID)
+	}
+
+	dl := []openLoanDB{}
+	if err := s.db().Select(&dl, query+" ORDER BY CHECKED_OUT_AT, ID", args...); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, l := range dl {
+		if quantity <= 0 {
+			break
+		}
+
+		n := l.Quantity - l.Returned
+		if n > quantity {
+			n = quantity
+		}
+		quantity -= n
+
+		var returnedAt *time.Time
+		if l.Returned+n >= l.Quantity {
+			returnedAt = &now
+		}
+
+		_, err := s.db().Exec("UPDATE loans SET RETURNED = ?, RETURNED_AT = ? WHERE ID = ?", l.Returned+n, returnedAt, l.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package persistence
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Timothylock/inventory-management/items"
+)
+
+type MultiLocationDB []LocationDB
+type LocationDB struct {
+	ID       int    `db:"ID"`
+	ParentID int    `db:"PARENTID"`
+	Name     string `db:"NAME"`
+}
+
+// placeholders returns a parenthesised list of n bind variables for an IN clause
+func placeholders(n int) string {
+	return "(" + strings.TrimSuffix(strings.Repeat("?, ", n), ", ") + ")"
+}
+
+// locationPaths returns the path of every location by its ID
+func locationPaths(ls items.Locations) map[int]string {
+	paths := map[int]string{}
+	for _, l := range ls {
+		paths[l.ID] = l.Path
+	}
+	return paths
+}
+
+func (s *store) doesLocationExist(orgID, locationID int) (bool, error) {
+	var count int
+
+	err := s.db().Get(
+		&count,
+		"SELECT count(1) FROM locations WHERE ORGID = ? AND ID = ?",
+		orgID, locationID,
+	)
+
+	return count > 0, err
+}
+
+func (s *store) GetLocations(orgID int) (items.Locations, error) {
+	dl := MultiLocationDB{}
+	err := s.db().Select(&dl, "SELECT ID, PARENTID, NAME FROM locations WHERE ORGID = ?", orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := items.Locations{}
+	for _, l := range dl {
+		ret = append(ret, items.Location{
+			ID:       l.ID,
+			ParentID: l.ParentID,
+			Name:     l.Name,
+		})
+	}
+
+	return ret.WithPaths(), nil
+}
+
+func (s *store) AddLocation(orgID int, loc items.Location, userID int) (items.Location, error) {
+	r, err := s.db().Exec(
+		"INSERT INTO locations (ORGID, PARENTID, NAME) VALUES (?, ?, ?)",
+		orgID, loc.ParentID, loc.Name,
+	)
+	if err != nil {
+		return loc, err
+	}
+
+	id, err := r.LastInsertId()
+	if err != nil {
+		return loc, err
+	}
+	loc.ID = int(id)
+
+	s.addLog(orgID, userID, items.LogObjectLocation, strconv.Itoa(loc.ID), "location added", logDetails{
+		"name":     loc.Name,
+		"parentId": loc.ParentID,
+	})
+
+	ls, err := s.GetLocations(orgID)
+	if err != nil {
+		return loc, err
+	}
+	if l, ok := ls.Find(loc.ID); ok {
+		loc = l
+	}
+
+	return loc, nil
+}
+
+func (s *store) UpdateLocation(orgID int, loc items.Location, userID int) error {
+	_, err := s.db().Exec(
+		"UPDATE locations SET PARENTID = ?, NAME = ? WHERE ORGID = ? AND ID = ?",
+		loc.ParentID, loc.Name, orgID, loc.ID,
+	)
+	if err == nil {
+		s.addLog(orgID, userID, items.LogObjectLocation, strconv.Itoa(loc.ID), "location updated", logDetails{
+			"name":     loc.Name,
+			"parentId": loc.ParentID,
+		})
+	}
+
+	return err
+}
+
+func (s *store) DeleteLocation(orgID, locationID, userID int) error {
+	var count int
+	err := s.db().Get(
+		&count,
+		`SELECT (SELECT count(1) FROM locations WHERE ORGID = ? AND PARENTID = ?) +
+		(SELECT count(1) FROM items WHERE ORGID = ? AND LOCATIONID = ? AND DELETED = 0)`,
+		orgID, locationID, orgID, locationID,
+	)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return items.LocationInUseErr
+	}
+
+	r, err := s.db().Exec("DELETE FROM locations WHERE ORGID = ? AND ID = ?", orgID, locationID)
+	if err != nil {
+		return err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra <= 0 {
+		return items.LocationNotFoundErr
+	}
+
+	// Items in the trash lose their location
+	_, err = s.db().Exec("UPDATE items SET LOCATIONID = 0 WHERE ORGID = ? AND LOCATIONID = ?", orgID, locationID)
+	if err != nil {
+		return err
+	}
+
+	s.addLog(orgID, userID, items.LogObjectLocation, strconv.Itoa(locationID), "location deleted", nil)
+
+	return nil
+}
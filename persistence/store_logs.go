@@ -0,0 +1,112 @@
+package persistence
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Timothylock/inventory-management/items"
+)
+
+// logDetails is stored as a JSON object alongside a log entry
+type logDetails map[string]interface{}
+
+// addLog records an action. Actions that do not belong to an org use org 0.
+func (s *store) addLog(orgID, uID int, objType, objID, action string, details logDetails) error {
+	var d interface{}
+	if details != nil {
+		b, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		d = string(b)
+	}
+
+	_, err := s.db().Exec(`INSERT INTO logs (ORGID, USERID, OBJECTTYPE, OBJECTID, ACTION, DETAILS, DATE) VALUES
+	(?, ?, ?, ?, ?, ?, ?)`, orgID, uID, objType, objID, action, d, time.Now().UTC())
+	return err
+}
+
+type MultiLogDB []LogDB
+type LogDB struct {
+	ID         int       `db:"ID"`
+	UserID     int       `db:"USERID"`
+	Username   string    `db:"USERNAME"`
+	ObjectType string    `db:"OBJECTTYPE"`
+	ObjectID   string    `db:"OBJECTID"`
+	Action     string    `db:"ACTION"`
+	Details    []byte    `db:"DETAILS"`
+	Date       time.Time `db:"DATE"`
+}
+
+func (l LogDB) toLogEntry() items.LogEntry {
+	e := items.LogEntry{
+		ID:         l.ID,
+		UserID:     l.UserID,
+		Username:   l.Username,
+		ObjectType: l.ObjectType,
+		ObjectID:   l.ObjectID,
+		Action:     l.Action,
+		Details:    map[string]interface{}{},
+		Date:       l.Date,
+	}
+
+	if len(l.Details) > 0 && json.Unmarshal(l.Details, &e.Details) != nil {
+		e.Details = map[string]interface{}{"message": string(l.Details)}
+	}
+
+	return e
+}
+
+// GetLogs returns the page of the org's log entries given by the filter,
+// newest first, along with how many entries match the filter
+func (s *store) GetLogs(orgID int, filter items.LogFilter) (items.LogEntries, int, error) {
+	conds := []string{"logs.ORGID = ?"}
+	args := []interface{}{orgID}
+	if filter.ObjectType != "" {
+		conds = append(conds, "OBJECTTYPE = ?")
+		args = append(args, filter.ObjectType)
+	}
+	if filter.ObjectID != "" {
+		conds = append(conds, "OBJECTID = ?")
+		args = append(args, filter.ObjectID)
+	}
+	if filter.Username != "" {
+		conds = append(conds, "USERNAME = ?")
+		args = append(args, filter.Username)
+	}
+	if filter.Action != "" {
+		conds = append(conds, "ACTION = ?")
+		args = append(args, filter.Action)
+	}
+	if !filter.From.IsZero() {
+		conds = append(conds, "DATE >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conds = append(conds, "DATE < ?")
+		args = append(args, filter.To.UTC())
+	}
+
+	from := " FROM logs LEFT JOIN users ON users.ID = logs.USERID WHERE " + strings.Join(conds, " AND ")
+
+	var total int
+	if err := s.db().Get(&total, "SELECT count(1)"+from, args...); err != nil {
+		return nil, 0, err
+	}
+
+	dl := MultiLogDB{}
+	err := s.db().Select(
+		&dl,
+		`SELECT logs.ID AS ID, USERID, COALESCE(USERNAME, '') AS USERNAME, OBJECTTYPE, OBJECTID, ACTION, DETAILS, DATE`+from+
+			" ORDER BY DATE DESC, logs.ID DESC LIMIT ? OFFSET ?",
+		append(args, filter.Limit, (filter.Page-1)*filter.Limit)...,
+	)
+
+	ret := items.LogEntries{}
+	for _, l := range dl {
+		ret = append(ret, l.toLogEntry())
+	}
+
+	return ret, total, err
+}
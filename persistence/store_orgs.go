@@ -0,0 +1,155 @@
+package persistence
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+type MultiOrgDB []OrgDB
+type OrgDB struct {
+	ID   int    `db:"ID"`
+	Name string `db:"NAME"`
+	Role string `db:"ROLE"`
+}
+
+// memberRole returns the role of the user in the org, or sql.ErrNoRows if they
+// are not a member
+func (s *store) memberRole(orgID, userID int) (string, error) {
+	var role string
+
+	err := s.db().Get(
+		&role,
+		"SELECT ROLE FROM org_members WHERE ORGID = ? AND USERID = ?",
+		orgID, userID,
+	)
+
+	return role, err
+}
+
+// setMember adds the user to the org or changes their role if they already are a member
+func (s *store) setMember(orgID, userID int, role string) error {
+	_, err := s.memberRole(orgID, userID)
+	if err == sql.ErrNoRows {
+		_, err = s.db().Exec("INSERT INTO org_members (ORGID, USERID, ROLE) VALUES (?, ?, ?)", orgID, userID, role)
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	_, err = s.db().Exec("UPDATE org_members SET ROLE = ? WHERE ORGID = ? AND USERID = ?", role, orgID, userID)
+	return err
+}
+
+// GetOrgs returns the orgs the user is a member of along with their role in each
+func (s *store) GetOrgs(userID int) (users.Orgs, error) {
+	dl := MultiOrgDB{}
+	err := s.db().Select(
+		&dl,
+		`SELECT orgs.ID AS ID, NAME, ROLE FROM orgs JOIN org_members ON orgs.ID = org_members.ORGID
+		WHERE USERID = ? ORDER BY orgs.ID`,
+		userID,
+	)
+
+	ret := users.Orgs{}
+	for _, o := range dl {
+		ret = append(ret, users.Org{
+			ID:   o.ID,
+			Name: o.Name,
+			Role: o.Role,
+		})
+	}
+
+	return ret, err
+}
+
+// AddOrg creates an org with the default roles and makes the user its admin
+func (s *store) AddOrg(name string, userID int) (users.Org, error) {
+	org := users.Org{Name: name, Role: users.RoleAdmin}
+
+	r, err := s.db().Exec("INSERT INTO orgs (NAME) VALUES (?)", name)
+	if err != nil {
+		return org, err
+	}
+
+	id, err := r.LastInsertId()
+	if err != nil {
+		return org, err
+	}
+	org.ID = int(id)
+
+	for _, role := range users.DefaultRoles {
+		_, err = s.db().Exec(
+			"INSERT INTO roles (ORGID, NAME, PERMISSIONS) VALUES (?, ?, ?)",
+			org.ID, role.Name, strings.Join(role.Permissions, " "),
+		)
+		if err != nil {
+			return org, err
+		}
+	}
+
+	if err = s.setMember(org.ID, userID, users.RoleAdmin); err != nil {
+		return org, err
+	}
+
+	s.addLog(org.ID, userID, items.LogObjectOrg, strconv.Itoa(org.ID), "org created", logDetails{"name": name})
+
+	return org, nil
+}
+
+// AddMember adds an existing user to the org or changes their role in it
+func (s *store) AddMember(orgID int, username, role string, userID int) error {
+	exist, err := s.doesRoleExist(orgID, role)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return users.RoleNotFoundErr
+	}
+
+	u, err := s.GetUserByUsername(username, orgID)
+	if err != nil {
+		return err
+	}
+	if !u.Valid {
+		return users.UserNotFoundErr
+	}
+
+	err = s.setMember(orgID, u.ID, role)
+	if err == nil {
+		s.addLog(orgID, userID, items.LogObjectUser, strconv.Itoa(u.ID), "member updated", logDetails{"role": role})
+	}
+
+	return err
+}
+
+// SwitchOrg moves one of the user's sessions to another org they are a member of
+func (s *store) SwitchOrg(sessionID, userID, orgID int) error {
+	_, err := s.memberRole(orgID, userID)
+	if err == sql.ErrNoRows {
+		return users.OrgNotFoundErr
+	} else if err != nil {
+		return err
+	}
+
+	r, err := s.db().Exec("UPDATE sessions SET ORGID = ? WHERE ID = ? AND USERID = ? AND REVOKED = 0",
+		orgID, sessionID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra <= 0 {
+		return users.SessionNotFoundErr
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package persistence
+
+import (
+	"strings"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+type MultiRoleDB []RoleDB
+type RoleDB struct {
+	Name        string `db:"NAME"`
+	Permissions string `db:"PERMISSIONS"`
+}
+
+func (s *store) doesRoleExist(orgID int, name string) (bool, error) {
+	var count int
+
+	err := s.db().Get(
+		&count,
+		"SELECT count(1) FROM roles WHERE ORGID = ? AND NAME = ?",
+		orgID, name,
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, err
+}
+
+// GetRoles returns every role of the org and its permissions
+func (s *store) GetRoles(orgID int) (users.Roles, error) {
+	dl := MultiRoleDB{}
+	err := s.db().Select(&dl, "SELECT NAME, PERMISSIONS FROM roles WHERE ORGID = ? ORDER BY NAME", orgID)
+
+	ret := users.Roles{}
+	for _, r := range dl {
+		ret = append(ret, users.Role{
+			Name:        r.Name,
+			Permissions: strings.Fields(r.Permissions),
+		})
+	}
+
+	return ret, err
+}
+
+// SetRole creates the role or replaces the permissions of an existing one
+func (s *store) SetRole(orgID int, role users.Role, userID int) error {
+	perms := strings.Join(role.Permissions, " ")
+
+	exist, err := s.doesRoleExist(orgID, role.Name)
+	if err != nil {
+		return err
+	}
+
+	if exist {
+		_, err = s.db().Exec("UPDATE roles SET PERMISSIONS = ? WHERE ORGID = ? AND NAME = ?", perms, orgID, role.Name)
+	} else {
+		_, err = s.db().Exec("INSERT INTO roles (ORGID, NAME, PERMISSIONS) VALUES (?, ?, ?)", orgID, role.Name, perms)
+	}
+
+	if err == nil {
+		s.addLog(orgID, userID, items.LogObjectRole, role.Name, "role updated", logDetails{"permissions": role.Permissions})
+	}
+
+	return err
+}
@@ -0,0 +1,139 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/Timothylock/inventory-management/items"
+)
+
+// isInTrash returns whether an item with the ID was deleted but not purged yet
+func (s *store) isInTrash(orgID int, ID string) (bool, error) {
+	var count int
+
+	err := s.db().Get(
+		&count,
+		"SELECT count(1) FROM items WHERE ORGID = ? AND ID = ? AND DELETED = 1",
+		orgID, ID,
+	)
+
+	return count > 0, err
+}
+
+type deletedItemDB struct {
+	itemDB
+	DeletedAt time.Time `db:"DELETED_AT"`
+}
+
+// GetDeletedItems returns the items in the trash, most recently deleted first
+func (s *store) GetDeletedItems(orgID int) (items.DeletedItems, error) {
+	ls, err := s.GetLocations(orgID)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := s.GetCategories(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	dl := []deletedItemDB{}
+	err = s.db().Select(
+		&dl,
+		`SELECT items.ID AS ID, NAME, CATEGORYID, PICTURE_URL, DETAILS, FIELDS, LOCATIONID, COALESCE(USERNAME, '') AS USERNAME, QUANTITY,
+		QUANTITY - CHECKED_OUT AS AVAILABLE, CHECKED_OUT, STATUS, DELETED_AT
+		FROM items LEFT JOIN users ON items.LAST_PERFORMED_BY = users.ID
+		WHERE items.ORGID = ? AND DELETED = 1 ORDER BY DELETED_AT DESC`,
+		orgID,
+	)
+
+	ret := items.DeletedItems{}
+	paths := locationPaths(ls)
+	for _, d := range dl {
+		ret = append(ret, items.DeletedItem{
+			ItemDetail: d.itemDB.toItem(cs, paths),
+			DeletedAt:  d.DeletedAt,
+		})
+	}
+
+	return ret, err
+}
+
+func (s *store) RestoreItem(orgID int, ID string, userID int) error {
+	r, err := s.db().Exec(
+		"UPDATE items SET DELETED = 0, DELETED_AT = NULL, LAST_PERFORMED_BY = ? WHERE ORGID = ? AND ID = ? AND DELETED = 1",
+		userID, orgID, ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	ra, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if ra <= 0 {
+		return items.ItemNotFoundErr
+	}
+
+	s.addLog(orgID, userID, items.LogObjectItem, ID, "restore", nil)
+
+	return nil
+}
+
+// PurgeItem permanently removes an item that is in the trash along with its
+// attachments and returns the storage keys of their files, which are left for
+// the caller to delete once the purge is committed
+func (s *store) PurgeItem(orgID int, ID string, userID int) ([]string, error) {
+	var keys []string
+	err := s.inTx(func(ts *store) error {
+		r, err := ts.db().Exec("DELETE FROM items WHERE ORGID = ? AND ID = ? AND DELETED = 1", orgID, ID)
+		if err != nil {
+			return err
+		}
+
+		ra, err := r.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if ra <= 0 {
+			return items.ItemNotFoundErr
+		}
+
+		if keys, err = ts.deleteItemAttachments(orgID, ID); err != nil {
+			return err
+		}
+
+		return ts.addLog(orgID, userID, items.LogObjectItem, ID, "purge", logDetails{"attachments": len(keys)})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// PurgeItems purges the items that were put in the trash before the time and
+// returns how many there were and the storage keys of their attachments' files
+func (s *store) PurgeItems(orgID int, deletedBefore time.Time, userID int) (int, []string, error) {
+	var ids []string
+	err := s.db().Select(
+		&ids,
+		"SELECT ID FROM items WHERE ORGID = ? AND DELETED = 1 AND DELETED_AT < ?",
+		orgID, deletedBefore.UTC(),
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	keys := []string{}
+	for i, id := range ids {
+		k, err := s.PurgeItem(orgID, id, userID)
+		if err != nil && err != items.ItemNotFoundErr {
+			return i, keys, err
+		}
+		keys = append(keys, k...)
+	}
+
+	return len(ids), keys, nil
+}
@@ -0,0 +1,96 @@
+package persistence
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Timothylock/inventory-management/upc"
+)
+
+type upcCacheDB struct {
+	Barcode    string    `db:"BARCODE"`
+	Found      bool      `db:"FOUND"`
+	Name       string    `db:"NAME"`
+	Category   string    `db:"CATEGORY"`
+	PictureURL string    `db:"PICTURE_URL"`
+	FetchedAt  time.Time `db:"FETCHED_AT"`
+}
+
+func (s *store) GetCachedLookup(barcode string) (upc.CachedLookup, error) {
+	var c upcCacheDB
+	err := s.db().Get(&c, "SELECT BARCODE, FOUND, NAME, CATEGORY, PICTURE_URL, FETCHED_AT FROM upc_cache WHERE BARCODE = ?", barcode)
+	if err == sql.ErrNoRows {
+		return upc.CachedLookup{}, upc.CacheMissErr
+	} else if err != nil {
+		return upc.CachedLookup{}, err
+	}
+
+	return upc.CachedLookup{
+		Barcode: c.Barcode,
+		Found:   c.Found,
+		Item: upc.ItemDetail{
+			ID:         c.Barcode,
+			Name:       c.Name,
+			Category:   c.Category,
+			PictureURL: c.PictureURL,
+		},
+		FetchedAt: c.FetchedAt,
+	}, nil
+}
+
+// CacheLookup works the same on both databases since SQLite also understands
+// REPLACE INTO
+func (s *store) CacheLookup(c upc.CachedLookup) error {
+	_, err := s.db().Exec(
+		"REPLACE INTO upc_cache (BARCODE, FOUND, NAME, CATEGORY, PICTURE_URL, FETCHED_AT) VALUES (?, ?, ?, ?, ?, ?)",
+		c.Barcode, c.Found, c.Item.Name, c.Item.Category, c.Item.PictureURL, c.FetchedAt.UTC(),
+	)
+	return err
+}
+
+func (s *store) DeleteCachedLookup(barcode string) error {
+	_, err := s.db().Exec("DELETE FROM upc_cache WHERE BARCODE = ?", barcode)
+	return err
+}
+
+type upcProductDB struct {
+	GTIN       string `db:"GTIN"`
+	Name       string `db:"NAME"`
+	Brand      string `db:"BRAND"`
+	Category   string `db:"CATEGORY"`
+	PictureURL string `db:"PICTURE_URL"`
+}
+
+func (s *store) GetProduct(gtin string) (upc.Product, error) {
+	var p upcProductDB
+	err := s.db().Get(&p, "SELECT GTIN, NAME, BRAND, CATEGORY, PICTURE_URL FROM upc_products WHERE GTIN = ?", gtin)
+	if err == sql.ErrNoRows {
+		return upc.Product{}, upc.ProductNotFoundErr
+	} else if err != nil {
+		return upc.Product{}, err
+	}
+
+	return upc.Product(p), nil
+}
+
+// SaveProducts replaces any products with the same GTINs and forgets their
+// cached lookups so that the next lookup finds the new details
+func (s *store) SaveProducts(ps []upc.Product) error {
+	return s.inTx(func(ts *store) error {
+		for _, p := range ps {
+			_, err := ts.db().Exec(
+				"REPLACE INTO upc_products (GTIN, NAME, BRAND, CATEGORY, PICTURE_URL) VALUES (?, ?, ?, ?, ?)",
+				p.GTIN, p.Name, p.Brand, p.Category, p.PictureURL,
+			)
+			if err != nil {
+				return err
+			}
+
+			if err = ts.DeleteCachedLookup(p.GTIN); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
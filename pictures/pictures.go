@@ -0,0 +1,308 @@
+package pictures
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/Timothylock/inventory-management/storage"
+)
+
+// Limits of uploaded pictures. Pixels are limited as well as bytes because a
+// small file can decode to a huge image, which is held in memory while its
+// thumbnail is made.
+const (
+	MaxSize   = 10 << 20
+	MaxPixels = 16000000
+)
+
+// ThumbnailSize is the most pixels wide or high thumbnails are
+const ThumbnailSize = 256
+
+var (
+	InvalidPictureErr  = errors.New("picture must be a JPEG, PNG or GIF image")
+	PictureTooLargeErr = fmt.Errorf("picture must be at most %d MB and %d megapixels", MaxSize>>20, MaxPixels/1000000)
+	PictureNotFoundErr = errors.New("picture not found")
+	NoStorageErr       = errors.New("there is no storage for pictures")
+	PrivateAddressErr  = errors.New("pictures are not copied from private addresses")
+)
+
+// extensions of the content types of pictures that can be uploaded
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// namePattern matches the names pictures are stored under
+var namePattern = regexp.MustCompile(`^[0-9a-f]{32}\.(jpg|png|gif)$`)
+
+// Picture is an uploaded picture and where it can be fetched from
+type Picture struct {
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+}
+
+type Service struct {
+	storage     storage.Storage
+	client      *http.Client
+	cacheRemote bool
+}
+
+// NewService returns a service that keeps pictures in st
+func NewService(st storage.Storage, c config.Config) Service {
+	// Remote pictures are only copied from public addresses so that the
+	// server cannot be used to reach ones that only it can. The address is
+	// checked when connecting, after the name is looked up, which covers
+	// redirects and names that resolve differently each time.
+	dialer := &net.Dialer{
+		Timeout: 5000 * time.Millisecond,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return PrivateAddressErr
+			}
+			return nil
+		},
+	}
+
+	return NewServiceWithClient(st, c, &http.Client{
+		Timeout:   10000 * time.Millisecond,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	})
+}
+
+// NewServiceWithClient returns a service that keeps pictures in st and copies
+// remote ones with the client, which is trusted with the addresses it connects
+// to
+func NewServiceWithClient(st storage.Storage, c config.Config, client *http.Client) Service {
+	return Service{
+		storage:     st,
+		client:      client,
+		cacheRemote: c.CachePictures,
+	}
+}
+
+// publicIP returns whether the address can be reached from the internet
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !sharedAddresses.Contains(ip)
+}
+
+// sharedAddresses are the carrier-grade NAT range, which is not reachable from
+// the internet either
+var sharedAddresses = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// CachesRemote returns whether CacheURL copies remote pictures
+func (s *Service) CachesRemote() bool {
+	return s.cacheRemote && s.storage != nil
+}
+
+// URL returns where the picture with the name can be fetched from, relative
+// to the frontend like the URLs it calls
+func URL(name string) string {
+	return "api/picture?name=" + name
+}
+
+// ThumbnailURL returns where the thumbnail of the picture can be fetched from
+func ThumbnailURL(name string) string {
+	return URL(name) + "&size=thumb"
+}
+
+// Save checks that the picture is an image of a type that can be uploaded and
+// stores it along with its thumbnail. Pictures are named after their contents
+// so the same picture is only stored once per org.
+func (s *Service) Save(orgID int, r io.Reader) (Picture, error) {
+	if s.storage == nil {
+		return Picture{}, NoStorageErr
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxSize+1))
+	if err != nil {
+		return Picture{}, err
+	}
+	if len(data) > MaxSize {
+		return Picture{}, PictureTooLargeErr
+	}
+
+	ext, ok := extensions[http.DetectContentType(data)]
+	if !ok {
+		return Picture{}, InvalidPictureErr
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Picture{}, InvalidPictureErr
+	} else if cfg.Width*cfg.Height > MaxPixels {
+		return Picture{}, PictureTooLargeErr
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Picture{}, InvalidPictureErr
+	}
+
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:16]) + ext
+
+	thumb := &bytes.Buffer{}
+	if err = jpeg.Encode(thumb, Thumbnail(img, ThumbnailSize), &jpeg.Options{Quality: 85}); err != nil {
+		return Picture{}, err
+	}
+	if err = s.storage.Put(key(orgID, name, false), bytes.NewReader(data)); err != nil {
+		return Picture{}, err
+	}
+	if err = s.storage.Put(key(orgID, name, true), thumb); err != nil {
+		return Picture{}, err
+	}
+
+	return Picture{Name: name, URL: URL(name), ThumbnailURL: ThumbnailURL(name)}, nil
+}
+
+// Open returns the picture of the org with the name, or its thumbnail, and its
+// content type
+func (s *Service) Open(orgID int, name string, thumbnail bool) (io.ReadCloser, string, error) {
+	if s.storage == nil {
+		return nil, "", NoStorageErr
+	}
+	if !namePattern.MatchString(name) {
+		return nil, "", PictureNotFoundErr
+	}
+
+	f, err := s.storage.Get(key(orgID, name, thumbnail))
+	if err == storage.NotFoundErr {
+		return nil, "", PictureNotFoundErr
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	if thumbnail {
+		return f, "image/jpeg", nil
+	}
+	for ct, ext := range extensions {
+		if strings.HasSuffix(name, ext) {
+			return f, ct, nil
+		}
+	}
+
+	return f, "application/octet-stream", nil
+}
+
+// CacheURL stores a copy of the remote picture at the URL and returns the URL
+// of the copy, so that the picture keeps working when the site it came from
+// moves it. It does nothing unless caching pictures is turned on. The URL is
+// returned as it is if the picture cannot be copied.
+func (s *Service) CacheURL(orgID int, u string) string {
+	if !s.CachesRemote() {
+		return u
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return u
+	}
+
+	res, err := s.client.Get(u)
+	if err != nil {
+		return u
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return u
+	}
+
+	p, err := s.Save(orgID, res.Body)
+	if err != nil {
+		return u
+	}
+
+	return p.URL
+}
+
+// key returns where the picture of the org is stored
+func key(orgID int, name string, thumbnail bool) string {
+	if thumbnail {
+		name = strings.TrimSuffix(name, name[strings.LastIndex(name, "."):]) + "_thumb.jpg"
+	}
+	return fmt.Sprintf("pictures/%d/%s", orgID, name)
+}
+
+// Thumbnail shrinks the image to fit in a square of the size, averaging the
+// pixels that make up each pixel of the thumbnail. Images that already fit
+// are not enlarged. Transparent parts are made white since thumbnails are
+// JPEGs. The pixels are read from the image as they are averaged so that no
+// copy of a large image is made.
+func Thumbnail(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, atLeastOne(h*size/b.Dx())
+		} else {
+			w, h = atLeastOne(w*size/b.Dy()), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*b.Dy()/h, (y+1)*b.Dy()/h
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := x*b.Dx()/w, (x+1)*b.Dx()/w
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			// The colours are premultiplied, so laying them over white adds
+			// the part of white that shows through. Doing it before averaging
+			// keeps edges from darkening.
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(b.Min.X+sx, b.Min.Y+sy).RGBA()
+					r += uint64(cr + 0xFFFF - ca)
+					g += uint64(cg + 0xFFFF - ca)
+					bl += uint64(cb + 0xFFFF - ca)
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = 255
+		}
+	}
+
+	return dst
+}
+
+// atLeastOne returns n, or 1 if n is smaller so that thin images keep a row of pixels
+func atLeastOne(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
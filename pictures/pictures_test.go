@@ -0,0 +1,182 @@
+package pictures
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/Timothylock/inventory-management/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestService(t *testing.T, c config.Config) (Service, func()) {
+	dir, err := ioutil.TempDir("", "pictures")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := storage.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewService(st, c), func() { os.RemoveAll(dir) }
+}
+
+// testPNG returns a PNG of the size that is red on the left half and blue on
+// the right
+func testPNG(w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	b := &bytes.Buffer{}
+	png.Encode(b, img)
+	return b.Bytes()
+}
+
+func TestSave(t *testing.T) {
+	s, cleanup := newTestService(t, config.Config{})
+	defer cleanup()
+
+	data := testPNG(600, 300)
+	p, err := s.Save(1, bytes.NewReader(data))
+	assert.Nil(t, err)
+	assert.Regexp(t, `^[0-9a-f]{32}\.png$`, p.Name)
+	assert.Equal(t, "api/picture?name="+p.Name, p.URL)
+	assert.Equal(t, "api/picture?name="+p.Name+"&size=thumb", p.ThumbnailURL)
+
+	// The same picture is stored under the same name
+	again, err := s.Save(1, bytes.NewReader(data))
+	assert.Nil(t, err)
+	assert.Equal(t, p, again)
+
+	f, ct, err := s.Open(1, p.Name, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "image/png", ct)
+	got, _ := ioutil.ReadAll(f)
+	f.Close()
+	assert.Equal(t, data, got)
+
+	f, ct, err = s.Open(1, p.Name, true)
+	assert.Nil(t, err)
+	assert.Equal(t, "image/jpeg", ct)
+	thumb, err := jpeg.Decode(f)
+	f.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, 256, 128), thumb.Bounds())
+
+	// Pictures belong to the org that uploaded them
+	_, _, err = s.Open(2, p.Name, false)
+	assert.Equal(t, PictureNotFoundErr, err)
+	_, _, err = s.Open(1, "../../etc/passwd", false)
+	assert.Equal(t, PictureNotFoundErr, err)
+}
+
+func TestSaveInvalid(t *testing.T) {
+	s, cleanup := newTestService(t, config.Config{})
+	defer cleanup()
+
+	_, err := s.Save(1, strings.NewReader("%PDF-1.4 not a picture"))
+	assert.Equal(t, InvalidPictureErr, err)
+
+	// A PNG header followed by garbage
+	_, err = s.Save(1, bytes.NewReader(append(testPNG(4, 4)[:16], 0, 1, 2, 3)))
+	assert.Equal(t, InvalidPictureErr, err)
+
+	_, err = s.Save(1, bytes.NewReader(make([]byte, MaxSize+1)))
+	assert.Equal(t, PictureTooLargeErr, err)
+
+	// GIFs are fine
+	b := &bytes.Buffer{}
+	assert.Nil(t, gif.Encode(b, image.NewPaletted(image.Rect(0, 0, 10, 10), color.Palette{color.Black, color.White}), nil))
+	p, err := s.Save(1, b)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(p.Name, ".gif"))
+
+	none := NewService(nil, config.Config{})
+	_, err = none.Save(1, bytes.NewReader(testPNG(4, 4)))
+	assert.Equal(t, NoStorageErr, err)
+}
+
+func TestThumbnail(t *testing.T) {
+	img, _ := png.Decode(bytes.NewReader(testPNG(100, 1000)))
+	thumb := Thumbnail(img, 64)
+	assert.Equal(t, image.Rect(0, 0, 6, 64), thumb.Bounds())
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, thumb.At(0, 10))
+	assert.Equal(t, color.RGBA{B: 255, A: 255}, thumb.At(5, 10))
+
+	// Small images are not enlarged and transparency becomes white
+	small := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	thumb = Thumbnail(small, 64)
+	assert.Equal(t, image.Rect(0, 0, 3, 2), thumb.Bounds())
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, thumb.At(1, 1))
+}
+
+func TestCacheURL(t *testing.T) {
+	data := testPNG(10, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/picture.png":
+			w.Write(data)
+		case "/page.html":
+			w.Write([]byte("<html></html>"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	s, cleanup := newTestService(t, config.Config{CachePictures: true})
+	defer cleanup()
+
+	// The test server is on a private address, which is refused
+	assert.Equal(t, ts.URL+"/picture.png", s.CacheURL(1, ts.URL+"/picture.png"))
+	_, err := s.client.Get(ts.URL + "/picture.png")
+	assert.Contains(t, err.Error(), PrivateAddressErr.Error())
+	s = NewServiceWithClient(s.storage, config.Config{CachePictures: true}, ts.Client())
+
+	u := s.CacheURL(1, ts.URL+"/picture.png")
+	assert.Regexp(t, `^api/picture\?name=[0-9a-f]{32}\.png$`, u)
+	f, _, err := s.Open(1, strings.TrimPrefix(u, "api/picture?name="), false)
+	assert.Nil(t, err)
+	f.Close()
+
+	// URLs that cannot be copied are kept
+	for _, u := range []string{"", "api/picture?name=abc.png", ts.URL + "/page.html", ts.URL + "/gone.png", "ftp://example.com/a.png"} {
+		assert.Equal(t, u, s.CacheURL(1, u))
+	}
+
+	// Nothing is copied unless caching is turned on
+	off, cleanup := newTestService(t, config.Config{})
+	defer cleanup()
+	assert.Equal(t, ts.URL+"/picture.png", off.CacheURL(1, ts.URL+"/picture.png"))
+}
+
+func TestPublicIP(t *testing.T) {
+	for _, ip := range []string{"93.184.216.34", "2606:2800:220:1::248"} {
+		assert.True(t, publicIP(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1",
+		"0.0.0.0", "::1", "::", "fe80::1", "fd00::1", "::ffff:127.0.0.1"} {
+		assert.False(t, publicIP(net.ParseIP(ip)), ip)
+	}
+}
@@ -3,6 +3,7 @@ package responses
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 )
 
@@ -18,7 +19,7 @@ func SendError(w http.ResponseWriter, err httpError) {
 
 	b, e := json.Marshal(err)
 	if e != nil {
-		fmt.Sprintf("failed marshalling error - %s", e)
+		log.Printf("failed marshalling error - %s", e)
 	}
 
 	w.Write(b)
@@ -40,6 +41,14 @@ func MissingParamError(param string) httpError {
 	}
 }
 
+func InvalidParamError(param string, err error) httpError {
+	return httpError{
+		StatusCode: http.StatusBadRequest,
+		ErrorCode:  1003,
+		Message:    fmt.Sprintf("Invalid param %s - %s", param, err.Error()),
+	}
+}
+
 func Unauthorized(err error) httpError {
 	return httpError{
 		StatusCode: http.StatusUnauthorized,
@@ -48,6 +57,14 @@ func Unauthorized(err error) httpError {
 	}
 }
 
+func Forbidden(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusForbidden,
+		ErrorCode:  1004,
+		Message:    err.Error(),
+	}
+}
+
 func ItemNotFound(err error) httpError {
 	return httpError{
 		StatusCode: http.StatusNotFound,
@@ -63,3 +80,173 @@ func ItemAlreadyExists(err error) httpError {
 		Message:    err.Error(),
 	}
 }
+
+func NotEnoughQuantity(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusConflict,
+		ErrorCode:  1102,
+		Message:    err.Error(),
+	}
+}
+
+func SessionNotFound(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusNotFound,
+		ErrorCode:  1200,
+		Message:    err.Error(),
+	}
+}
+
+func RoleNotFound(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusBadRequest,
+		ErrorCode:  1201,
+		Message:    err.Error(),
+	}
+}
+
+func InvalidRole(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusBadRequest,
+		ErrorCode:  1202,
+		Message:    err.Error(),
+	}
+}
+
+func OrgNotFound(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusNotFound,
+		ErrorCode:  1203,
+		Message:    err.Error(),
+	}
+}
+
+func UserNotFound(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusNotFound,
+		ErrorCode:  1204,
+		Message:    err.Error(),
+	}
+}
+
+func LoanNotFound(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusNotFound,
+		ErrorCode:  1103,
+		Message:    err.Error(),
+	}
+}
+
+func LocationNotFound(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusNotFound,
+		ErrorCode:  1104,
+		Message:    err.Error(),
+	}
+}
+
+func LocationAlreadyExists(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusBadRequest,
+		ErrorCode:  1105,
+		Message:    err.Error(),
+	}
+}
+
+func LocationInUse(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusConflict,
+		ErrorCode:  1106,
+		Message:    err.Error(),
+	}
+}
+
+func CategoryNotFound(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusNotFound,
+		ErrorCode:  1107,
+		Message:    err.Error(),
+	}
+}
+
+func CategoryAlreadyExists(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusBadRequest,
+		ErrorCode:  1108,
+		Message:    err.Error(),
+	}
+}
+
+func CategoryInUse(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusConflict,
+		ErrorCode:  1109,
+		Message:    err.Error(),
+	}
+}
+
+func PictureNotFound(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusNotFound,
+		ErrorCode:  1110,
+		Message:    err.Error(),
+	}
+}
+
+func AttachmentNotFound(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusNotFound,
+		ErrorCode:  1111,
+		Message:    err.Error(),
+	}
+}
+
+func AttachmentTooLarge(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		ErrorCode:  1112,
+		Message:    err.Error(),
+	}
+}
+
+func AttachmentQuotaExceeded(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		ErrorCode:  1113,
+		Message:    err.Error(),
+	}
+}
+
+func BarcodeNotFound(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusNotFound,
+		ErrorCode:  1300,
+		Message:    err.Error(),
+	}
+}
+
+func LookupRateLimited(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusTooManyRequests,
+		ErrorCode:  1301,
+		Message:    err.Error(),
+	}
+}
+
+func LookupUnavailable(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusServiceUnavailable,
+		ErrorCode:  1302,
+		Message:    err.Error(),
+	}
+}
+
+// LookupUnauthorized is a bad gateway rather than unauthorized so that clients
+// do not take it to mean that their own login expired
+func LookupUnauthorized(err error) httpError {
+	return httpError{
+		StatusCode: http.StatusBadGateway,
+		ErrorCode:  1303,
+		Message:    err.Error(),
+	}
+}
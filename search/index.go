@@ -0,0 +1,274 @@
+// Package search is an embedded full text index of items. Unlike the database
+// full text search it matches words of any length, prefixes of words and words
+// with typos in them, and ranks the results.
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Timothylock/inventory-management/items"
+)
+
+// How much a word counts for depending on where it is found in the item
+const (
+	weightID       = 5
+	weightName     = 4
+	weightCategory = 2
+	weightLocation = 2
+	weightFields   = 1
+	weightDetails  = 1
+)
+
+// How good a match of a word is depending on how it matches
+const (
+	scoreExact  = 1.0
+	scorePrefix = 0.8
+	scoreTypo   = 0.6
+)
+
+// Index keeps the items of every org in memory. Each org is loaded the first
+// time it is searched and is loaded again after it is forgotten.
+type Index struct {
+	mu   sync.Mutex
+	orgs map[int]*orgIndex
+	// gens counts how many times each org has been forgotten so that a load
+	// that raced with a change is not kept
+	gens map[int]int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		orgs: map[int]*orgIndex{},
+		gens: map[int]int{},
+	}
+}
+
+// Search returns the items of the org that match every word of the query, best
+// match first
+func (ix *Index) Search(orgID int, query string, load func() (items.ItemDetailList, error)) (items.SearchHits, error) {
+	ix.mu.Lock()
+	o, ok := ix.orgs[orgID]
+	gen := ix.gens[orgID]
+	ix.mu.Unlock()
+
+	if !ok {
+		list, err := load()
+		if err != nil {
+			return nil, err
+		}
+		o = newOrgIndex(list)
+
+		ix.mu.Lock()
+		if ix.gens[orgID] == gen {
+			ix.orgs[orgID] = o
+		}
+		ix.mu.Unlock()
+	}
+
+	return o.search(query), nil
+}
+
+func (ix *Index) Forget(orgID int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	delete(ix.orgs, orgID)
+	ix.gens[orgID]++
+}
+
+// orgIndex holds the words of the items of one org. It is not changed once
+// it is built.
+type orgIndex struct {
+	// postings are the items each word is found in
+	postings map[string][]posting
+	// words are every word in postings in order
+	words []string
+}
+
+// posting is an item a word is found in and the weight of the best place it
+// was found
+type posting struct {
+	id     string
+	weight float64
+}
+
+func newOrgIndex(list items.ItemDetailList) *orgIndex {
+	o := &orgIndex{postings: map[string][]posting{}}
+
+	for _, item := range list {
+		weights := map[string]float64{}
+		add := func(text string, weight float64) {
+			for _, w := range tokenize(text) {
+				if weight > weights[w] {
+					weights[w] = weight
+				}
+			}
+		}
+
+		add(item.ID, weightID)
+		weights[strings.ToLower(item.ID)] = weightID
+		add(item.Name, weightName)
+		add(item.Category, weightCategory)
+		add(item.Location, weightLocation)
+		for _, v := range item.Fields {
+			add(v, weightFields)
+		}
+		add(item.Details, weightDetails)
+
+		for w, weight := range weights {
+			o.postings[w] = append(o.postings[w], posting{id: item.ID, weight: weight})
+		}
+	}
+
+	for w := range o.postings {
+		o.words = append(o.words, w)
+	}
+	sort.Strings(o.words)
+
+	return o
+}
+
+// search scores every item that matches all the words of the query by adding
+// up how well each word matches
+func (o *orgIndex) search(query string) items.SearchHits {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return items.SearchHits{}
+	}
+
+	totals := map[string]float64{}
+	for i, term := range terms {
+		best := o.match(term)
+
+		for id := range totals {
+			if _, ok := best[id]; !ok {
+				delete(totals, id)
+			}
+		}
+		for id, score := range best {
+			if _, ok := totals[id]; ok || i == 0 {
+				totals[id] += score
+			}
+		}
+	}
+
+	hits := make(items.SearchHits, 0, len(totals))
+	for id, score := range totals {
+		hits = append(hits, items.SearchHit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	return hits
+}
+
+// match returns the score of the best match of the term in each item it
+// matches
+func (o *orgIndex) match(term string) map[string]float64 {
+	best := map[string]float64{}
+	add := func(word string, score float64) {
+		for _, p := range o.postings[word] {
+			if s := score * p.weight; s > best[p.id] {
+				best[p.id] = s
+			}
+		}
+	}
+
+	add(term, scoreExact)
+
+	// Words starting with the term are next to each other in order
+	for i := sort.SearchStrings(o.words, term); i < len(o.words) && strings.HasPrefix(o.words[i], term); i++ {
+		if o.words[i] != term {
+			add(o.words[i], scorePrefix)
+		}
+	}
+
+	t := []rune(term)
+	maxEdits := allowedEdits(len(t))
+	if maxEdits == 0 {
+		return best
+	}
+	for _, word := range o.words {
+		w := []rune(word)
+		if abs(len(w)-len(t)) > maxEdits || strings.HasPrefix(word, term) {
+			continue
+		}
+		if d := distance(t, w); d <= maxEdits {
+			add(word, scoreTypo/float64(d))
+		}
+	}
+
+	return best
+}
+
+// allowedEdits is how many typos a word of the length can have and still
+// match. Short words have to be spelled right.
+func allowedEdits(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 7:
+		return 1
+	}
+	return 2
+}
+
+// tokenize splits the text into lower case words of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// distance is the number of letters that have to be added, removed, changed or
+// swapped with the next one to turn a into b
+func distance(a, b []rune) int {
+	// Only the last three rows are needed
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(b)]
+}
+
+func min(v int, vs ...int) int {
+	for _, o := range vs {
+		if o < v {
+			v = o
+		}
+	}
+	return v
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
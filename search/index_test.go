@@ -0,0 +1,173 @@
+package search
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/stretchr/testify/assert"
+)
+
+var testItems = items.ItemDetailList{
+	{ID: "1", Name: "Extension Cord", Category: "Electrical", Location: "Garage > Shelf 2", Details: "25 ft, orange"},
+	{ID: "2", Name: "Hammer", Category: "Tools", Location: "Shed", Details: "Claw hammer"},
+	{ID: "3", Name: "Saw", Category: "Tools", Location: "Shed", Details: "Hand saw for the extension"},
+	{ID: "4", Name: "Power Drill", Category: "Tools", Location: "Garage", Fields: map[string]string{"Voltage": "18"}},
+	{ID: "072-ABC", Name: "Ink", Category: "Office", Details: "Black toner"},
+}
+
+func load() (items.ItemDetailList, error) {
+	return testItems, nil
+}
+
+func ids(hits items.SearchHits) []string {
+	ret := []string{}
+	for _, h := range hits {
+		ret = append(ret, h.ID)
+	}
+	return ret
+}
+
+func TestSearch(t *testing.T) {
+	type testCase struct {
+		testName string
+		query    string
+		expected []string
+	}
+
+	testCases := []testCase{
+		{
+			testName: "exact word",
+			query:    "hammer",
+			expected: []string{"2"},
+		},
+		{
+			testName: "short word",
+			query:    "saw",
+			expected: []string{"3"},
+		},
+		{
+			testName: "prefix",
+			query:    "ham",
+			expected: []string{"2"},
+		},
+		{
+			testName: "typo",
+			query:    "extention",
+			expected: []string{"1", "3"},
+		},
+		{
+			testName: "swapped letters",
+			query:    "hmamer",
+			expected: []string{"2"},
+		},
+		{
+			testName: "short words need to be spelled right",
+			query:    "sae",
+			expected: []string{},
+		},
+		{
+			testName: "name ranks above category",
+			query:    "tools drill",
+			expected: []string{"4"},
+		},
+		{
+			testName: "every word has to match",
+			query:    "hammer garage",
+			expected: []string{},
+		},
+		{
+			testName: "location and category",
+			query:    "shed tool",
+			expected: []string{"2", "3"},
+		},
+		{
+			testName: "custom fields",
+			query:    "18",
+			expected: []string{"4"},
+		},
+		{
+			testName: "ID",
+			query:    "072-abc",
+			expected: []string{"072-ABC"},
+		},
+		{
+			testName: "nothing to search",
+			query:    " - ",
+			expected: []string{},
+		},
+	}
+
+	ix := NewIndex()
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			hits, err := ix.Search(1, tc.query, load)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, ids(hits))
+		})
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	hits, err := NewIndex().Search(1, "extension", load)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "3"}, ids(hits))
+	assert.True(t, hits[0].Score > hits[1].Score)
+
+	// Exact words beat prefixes, which beat typos
+	hits, err = NewIndex().Search(1, "ink", func() (items.ItemDetailList, error) {
+		return items.ItemDetailList{{ID: "a", Name: "Inks"}, {ID: "b", Name: "Ink"}}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "a"}, ids(hits))
+}
+
+func TestForget(t *testing.T) {
+	ix := NewIndex()
+	loads := 0
+	list := items.ItemDetailList{{ID: "1", Name: "Hammer"}}
+	loader := func() (items.ItemDetailList, error) {
+		loads++
+		return list, nil
+	}
+
+	hits, err := ix.Search(1, "hammer", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids(hits))
+
+	_, err = ix.Search(1, "hammer", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, loads)
+
+	// Other orgs are loaded separately
+	_, err = ix.Search(2, "hammer", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, loads)
+
+	list = append(list, items.ItemDetail{ID: "2", Name: "Sledge hammer"})
+	ix.Forget(1)
+
+	hits, err = ix.Search(1, "hammer", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids(hits))
+	assert.Equal(t, 3, loads)
+
+	// Errors are returned and nothing is kept
+	ix.Forget(1)
+	_, err = ix.Search(1, "hammer", func() (items.ItemDetailList, error) {
+		return nil, errors.New("some error")
+	})
+	assert.EqualError(t, err, "some error")
+
+	_, err = ix.Search(1, "hammer", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, loads)
+}
+
+func TestDistance(t *testing.T) {
+	assert.Equal(t, 0, distance([]rune("saw"), []rune("saw")))
+	assert.Equal(t, 1, distance([]rune("extention"), []rune("extension")))
+	assert.Equal(t, 1, distance([]rune("hmamer"), []rune("hammer")))
+	assert.Equal(t, 2, distance([]rune("hamer"), []rune("hammers")))
+	assert.Equal(t, 3, distance([]rune(""), []rune("ink")))
+}
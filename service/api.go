@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/Timothylock/inventory-management/attachments"
 	"github.com/Timothylock/inventory-management/config"
 	"github.com/Timothylock/inventory-management/email"
 	"github.com/Timothylock/inventory-management/items"
 	"github.com/Timothylock/inventory-management/middleware"
+	"github.com/Timothylock/inventory-management/pictures"
 	"github.com/Timothylock/inventory-management/responses"
 	"github.com/Timothylock/inventory-management/upc"
 	"github.com/Timothylock/inventory-management/users"
@@ -20,18 +22,22 @@ import (
 )
 
 type API struct {
-	itemsService items.Service
-	upcService   upc.Service
-	userService  users.Service
-	emailService email.Service
+	itemsService       items.Service
+	upcService         upc.Service
+	userService        users.Service
+	emailService       email.Service
+	picturesService    pictures.Service
+	attachmentsService attachments.Service
 }
 
-func NewAPI(is items.Service, us upc.Service, user users.Service, es email.Service) API {
+func NewAPI(is items.Service, us upc.Service, user users.Service, es email.Service, ps pictures.Service, as attachments.Service) API {
 	return API{
-		itemsService: is,
-		upcService:   us,
-		userService:  user,
-		emailService: es,
+		itemsService:       is,
+		upcService:         us,
+		userService:        user,
+		emailService:       es,
+		picturesService:    ps,
+		attachmentsService: as,
 	}
 }
 
@@ -39,21 +45,80 @@ func NewRouter(api *API, cfg config.Config) http.Handler {
 	router := httprouter.New()
 
 	// Items
-	router.Handler("GET", "/api/item/info", middleware.UserRequired(api.userService, api.SearchItems))
-	router.Handler("POST", "/api/item/move", middleware.UserRequired(api.userService, api.MoveItem))
-	router.Handler("POST", "/api/item", middleware.UserRequired(api.userService, api.AddItem))
-	router.Handler("DELETE", "/api/item", middleware.UserRequired(api.userService, api.DeleteItem))
+	router.Handler("GET", "/api/item/info", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.SearchItems)))
+	router.Handler("POST", "/api/item/move", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemMove, api.MoveItem)))
+	router.Handler("POST", "/api/item", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemAdd, api.AddItem)))
+	router.Handler("DELETE", "/api/item", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemDelete, api.DeleteItem)))
+	router.Handler("GET", "/api/item/history", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.FetchItemHistory)))
+	router.Handler("GET", "/api/items", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.ListItems)))
+	router.Handler("POST", "/api/items/import", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemAdd, api.ImportItems)))
+	router.Handler("GET", "/api/items/export", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.ExportItems)))
+	router.Handler("GET", "/api/items/summary", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.SummarizeItems)))
+
+	// Attachments
+	router.Handler("GET", "/api/item/attachments", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.FetchAttachments)))
+	router.Handler("POST", "/api/item/attachments", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemEdit, api.UploadAttachment)))
+	router.Handler("GET", "/api/attachment", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.FetchAttachment)))
+	router.Handler("DELETE", "/api/attachment", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemEdit, api.DeleteAttachment)))
+
+	// Labels
+	router.Handler("POST", "/api/item/id", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemAdd, api.ReserveItemID)))
+	router.Handler("GET", "/api/item/label", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.FetchItemLabel)))
+	router.Handler("POST", "/api/items/labels", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.PrintLabels)))
+	router.Handler("GET", "/api/labels/templates", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.FetchLabelTemplates)))
+
+	// Trash
+	router.Handler("GET", "/api/items/trash", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemDelete, api.FetchTrash)))
+	router.Handler("POST", "/api/item/restore", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemDelete, api.RestoreItem)))
+	router.Handler("DELETE", "/api/items/trash", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemPurge, api.PurgeTrash)))
+
+	// Locations
+	router.Handler("GET", "/api/locations", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.FetchLocations)))
+	router.Handler("POST", "/api/location", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemEdit, api.SetLocation)))
+	router.Handler("DELETE", "/api/location", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemEdit, api.DeleteLocation)))
+
+	// Categories
+	router.Handler("GET", "/api/categories", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.FetchCategories)))
+	router.Handler("POST", "/api/category", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemEdit, api.SetCategory)))
+	router.Handler("DELETE", "/api/category", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemEdit, api.DeleteCategory)))
+
+	// Loans
+	router.Handler("GET", "/api/loans", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.FetchLoans)))
+	router.Handler("GET", "/api/loans/overdue", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.FetchOverdueLoans)))
+	router.Handler("GET", "/api/loans/borrower", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.FetchBorrowerLoans)))
+
+	// Logs
+	router.Handler("GET", "/api/logs", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermLogView, api.FetchLogs)))
 
 	// UPC
-	router.Handler("GET", "/api/lookup", middleware.UserRequired(api.userService, api.LookupBarcode))
+	router.Handler("GET", "/api/lookup", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermUpcLookup, api.LookupBarcode)))
+	router.Handler("POST", "/api/lookup/refresh", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermUpcManage, api.RefreshBarcode)))
+	router.Handler("DELETE", "/api/lookup/cache", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermUpcManage, api.InvalidateBarcode)))
+	router.Handler("POST", "/api/lookup/dataset", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermUpcManage, api.ImportDataset)))
+	router.Handler("POST", "/api/pictures", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemAdd, api.UploadPicture)))
+	router.Handler("GET", "/api/picture", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermItemView, api.FetchPicture)))
 
 	// User
-	router.Handler("GET", "/api/users", middleware.UserRequired(api.userService, api.FetchUsers))
+	router.Handler("GET", "/api/users", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermUserView, api.FetchUsers)))
 	router.Handler("POST", "/api/user/login", api.Login())
 	router.Handler("GET", "/api/user/logincheck", middleware.UserRequired(api.userService, api.LoginCheck))
-	router.Handler("POST", "/api/user/add", middleware.UserRequired(api.userService, api.AddUser))
-	router.Handler("DELETE", "/api/user/delete", middleware.UserRequired(api.userService, api.DeleteUser))
+	router.Handler("POST", "/api/user/add", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermUserManage, api.AddUser)))
+	router.Handler("DELETE", "/api/user/delete", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermUserManage, api.DeleteUser)))
 	router.Handler("GET", "/api/user/resetPassword", api.ForgotPassword())
+	router.Handler("POST", "/api/user/logout", middleware.UserRequired(api.userService, api.Logout))
+	router.Handler("GET", "/api/user/sessions", middleware.UserRequired(api.userService, api.FetchSessions))
+	router.Handler("DELETE", "/api/user/session", middleware.UserRequired(api.userService, api.RevokeSession))
+	router.Handler("DELETE", "/api/user/sessions", middleware.UserRequired(api.userService, api.RevokeSessions))
+
+	// Roles
+	router.Handler("GET", "/api/roles", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermUserView, api.FetchRoles)))
+	router.Handler("POST", "/api/role", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermRoleManage, api.SetRole)))
+
+	// Organizations
+	router.Handler("GET", "/api/orgs", middleware.UserRequired(api.userService, api.FetchOrgs))
+	router.Handler("POST", "/api/org", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermOrgCreate, api.AddOrg)))
+	router.Handler("POST", "/api/org/switch", middleware.UserRequired(api.userService, api.SwitchOrg))
+	router.Handler("POST", "/api/org/member", middleware.UserRequired(api.userService, middleware.RequirePermission(users.PermUserManage, api.AddMember)))
 
 	// Frontend
 	mux := http.NewServeMux()
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/Timothylock/inventory-management/attachments"
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+// FetchAttachments returns the attachments of an item, oldest first
+func (a *API) FetchAttachments(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getRequiredParam(r, "id")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("id"))
+			return
+		}
+
+		// Scanned barcodes find the item whichever form it was stored under
+		id, err = a.itemsService.ResolveID(u.OrgID, id)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		list, err := a.attachmentsService.GetAttachments(u.OrgID, id)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(list, w)
+	})
+}
+
+// UploadAttachment stores the body as an attachment of the item. The
+// filename is given as a parameter and the Content-Type header is kept to
+// send the file back with.
+func (a *API) UploadAttachment(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getRequiredParam(r, "id")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("id"))
+			return
+		}
+
+		filename, err := getRequiredParam(r, "filename")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("filename"))
+			return
+		}
+
+		found, err := a.itemsService.FindItems(u.OrgID, []string{id})
+		if err != nil && err == items.ItemNotFoundErr {
+			responses.SendError(w, responses.ItemNotFound(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		at, err := a.attachmentsService.Upload(u.OrgID, found[0].ID, filename, r.Header.Get("Content-Type"), r.Body, u.ID)
+		if err != nil && err == attachments.InvalidFilenameErr {
+			responses.SendError(w, responses.InvalidParamError("filename", err))
+			return
+		} else if err != nil && err == attachments.AttachmentTooLargeErr {
+			responses.SendError(w, responses.AttachmentTooLarge(err))
+			return
+		} else if err != nil && err == attachments.QuotaExceededErr {
+			responses.SendError(w, responses.AttachmentQuotaExceeded(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(at, w)
+	})
+}
+
+// FetchAttachment sends the file of an attachment as a download
+func (a *API) FetchAttachment(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := getAttachmentID(w, r)
+		if !ok {
+			return
+		}
+
+		at, f, err := a.attachmentsService.Open(u.OrgID, id, u.ID)
+		if err != nil && err == attachments.AttachmentNotFoundErr {
+			responses.SendError(w, responses.AttachmentNotFound(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+		defer f.Close()
+
+		w.Header().Set("Content-Type", at.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(at.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": at.Filename}))
+		// Stop browsers from running uploaded HTML or scripts as part of the site
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		io.Copy(w, f)
+	})
+}
+
+func (a *API) DeleteAttachment(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := getAttachmentID(w, r)
+		if !ok {
+			return
+		}
+
+		err := a.attachmentsService.Delete(u.OrgID, id, u.ID)
+		if err != nil && err == attachments.AttachmentNotFoundErr {
+			responses.SendError(w, responses.AttachmentNotFound(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(responses.Success{Success: true}, w)
+	})
+}
+
+// getAttachmentID reads the id parameter, sending an error and returning
+// false if it is missing or not a number
+func getAttachmentID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	idStr, err := getRequiredParam(r, "id")
+	if err != nil {
+		responses.SendError(w, responses.MissingParamError("id"))
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		responses.SendError(w, responses.InvalidParamError("id", err))
+		return 0, false
+	}
+
+	return id, true
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/attachments"
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachments(t *testing.T) {
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+
+	st, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	ip := items.NewMockPersister(mc)
+	ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"1234"}, SortBy: items.SortID}).Return(items.ItemDetailList{{ID: "1234"}}, 1, nil)
+
+	var stored attachments.Attachment
+	ap := attachments.NewMockPersister(mc)
+	ap.EXPECT().GetAttachmentsSize(1).Return(int64(0), nil)
+	ap.EXPECT().AddAttachment(1, gomock.Any(), 123).DoAndReturn(func(orgID int, a attachments.Attachment, userID int) (attachments.Attachment, error) {
+		a.ID = 5
+		a.UploadedBy = "someUser"
+		stored = a
+		return a, nil
+	})
+
+	server := setupServerAuthenticatedWith(t, testDeps{items: ip, attachments: ap, storage: st, config: config.Config{AttachmentQuotaMb: 1}})
+	defer server.Close()
+
+	resp, err := sendPostRaw(server.URL+"/api/item/attachments?id=1234&filename=warranty.txt", "text/plain; charset=utf-8", "two years")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	a := attachments.Attachment{}
+	assert.NoError(t, json.Unmarshal([]byte(getBody(t, resp)), &a))
+	assert.Equal(t, 5, a.ID)
+	assert.Equal(t, "warranty.txt", a.Filename)
+	assert.Equal(t, int64(9), a.Size)
+	assert.Equal(t, "someUser", a.UploadedBy)
+	// Where the file is stored is not sent
+	assert.Empty(t, a.Key)
+
+	ap.EXPECT().GetAttachments(1, "1234").Return(attachments.Attachments{stored}, nil)
+	resp, err = sendGet(server.URL + "/api/item/attachments?id=1234")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	list := attachments.Attachments{}
+	assert.NoError(t, json.Unmarshal([]byte(getBody(t, resp)), &list))
+	assert.Len(t, list, 1)
+
+	ap.EXPECT().GetAttachment(1, 5).Return(stored, nil)
+	ap.EXPECT().LogAttachmentDownload(1, stored, 123).Return(nil)
+	resp, err = sendGet(server.URL + "/api/attachment?id=5")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=warranty.txt`, resp.Header.Get("Content-Disposition"))
+	assert.Equal(t, "two years", getBody(t, resp))
+
+	ap.EXPECT().GetAttachment(1, 5).Return(stored, nil)
+	ap.EXPECT().DeleteAttachment(1, 5, 123).Return(nil)
+	resp, err = sendDelete(server.URL + "/api/attachment?id=5")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = st.Get(stored.Key)
+	assert.Error(t, err)
+}
+
+func TestAttachmentErrors(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		method             string
+		url                string
+		body               string
+		setupMocks         func(*items.MockPersister, *attachments.MockPersister)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			desc:               "upload without a filename",
+			method:             "POST",
+			url:                "/api/item/attachments?id=1234",
+			setupMocks:         func(ip *items.MockPersister, ap *attachments.MockPersister) {},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"code":1002,"details":"Missing param - filename"}`,
+		},
+		{
+			desc:   "upload to an item that does not exist",
+			method: "POST",
+			url:    "/api/item/attachments?id=1234&filename=a.txt",
+			setupMocks: func(ip *items.MockPersister, ap *attachments.MockPersister) {
+				ip.EXPECT().SearchItems(1, gomock.Any()).Return(items.ItemDetailList{}, 0, nil)
+			},
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"code":1100,"details":"item not found"}`,
+		},
+		{
+			desc:   "upload over the quota",
+			method: "POST",
+			url:    "/api/item/attachments?id=1234&filename=a.txt",
+			body:   "abc",
+			setupMocks: func(ip *items.MockPersister, ap *attachments.MockPersister) {
+				ip.EXPECT().SearchItems(1, gomock.Any()).Return(items.ItemDetailList{{ID: "1234"}}, 1, nil)
+				ap.EXPECT().GetAttachmentsSize(1).Return(int64(1<<20), nil)
+			},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			expectedBody:       `{"code":1113,"details":"attachment does not fit in what is left of the org's quota"}`,
+		},
+		{
+			desc:               "download with an invalid id",
+			method:             "GET",
+			url:                "/api/attachment?id=abc",
+			setupMocks:         func(ip *items.MockPersister, ap *attachments.MockPersister) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:   "download an attachment that does not exist",
+			method: "GET",
+			url:    "/api/attachment?id=5",
+			setupMocks: func(ip *items.MockPersister, ap *attachments.MockPersister) {
+				ap.EXPECT().GetAttachment(1, 5).Return(attachments.Attachment{}, attachments.AttachmentNotFoundErr)
+			},
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"code":1111,"details":"attachment not found"}`,
+		},
+		{
+			desc:   "delete an attachment that does not exist",
+			method: "DELETE",
+			url:    "/api/attachment?id=5",
+			setupMocks: func(ip *items.MockPersister, ap *attachments.MockPersister) {
+				ap.EXPECT().GetAttachment(1, 5).Return(attachments.Attachment{}, attachments.AttachmentNotFoundErr)
+			},
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"code":1111,"details":"attachment not found"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			st, cleanup := newTestStorage(t)
+			defer cleanup()
+
+			ip := items.NewMockPersister(mc)
+			ap := attachments.NewMockPersister(mc)
+			tc.setupMocks(ip, ap)
+
+			server := setupServerAuthenticatedWith(t, testDeps{items: ip, attachments: ap, storage: st, config: config.Config{AttachmentQuotaMb: 1}})
+			defer server.Close()
+
+			var resp *http.Response
+			var err error
+			switch tc.method {
+			case "POST":
+				resp, err = sendPostRaw(server.URL+tc.url, "text/plain", tc.body)
+			case "DELETE":
+				resp, err = sendDelete(server.URL + tc.url)
+			default:
+				resp, err = sendGet(server.URL + tc.url)
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStatusCode, resp.StatusCode)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, strings.TrimSpace(getBody(t, resp)))
+			}
+		})
+	}
+}
@@ -8,9 +8,12 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/Timothylock/inventory-management/attachments"
 	"github.com/Timothylock/inventory-management/config"
 	"github.com/Timothylock/inventory-management/email"
 	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/pictures"
+	"github.com/Timothylock/inventory-management/storage"
 	"github.com/Timothylock/inventory-management/upc"
 	"github.com/Timothylock/inventory-management/users"
 	"github.com/golang/mock/gomock"
@@ -23,14 +26,16 @@ func setupServerAuthenticated(ip items.Persister, t *testing.T) *httptest.Server
 	mc := gomock.NewController(t)
 	defer mc.Finish()
 	up := users.NewMockPersister(mc)
-	up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, IsSysAdmin: true}, nil).AnyTimes()
+	up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 1, IsSysAdmin: true, Permissions: users.AllPermissions}, nil).AnyTimes()
 
-	is := items.NewService(ip)
-	us := upc.NewService(cfg)
-	user := users.NewService(up)
+	is := items.NewService(ip, nil, nil, cfg)
+	us := upc.NewService(nil, cfg)
+	user := users.NewService(up, cfg)
 	es := email.NewService(cfg, nil)
+	ps := pictures.NewService(nil, cfg)
+	as := attachments.NewService(nil, nil, cfg)
 
-	serv := NewAPI(is, us, user, es)
+	serv := NewAPI(is, us, user, es, ps, as)
 
 	return httptest.NewServer(NewRouter(&serv, cfg))
 }
@@ -38,12 +43,14 @@ func setupServerAuthenticated(ip items.Persister, t *testing.T) *httptest.Server
 func setupServer(ip items.Persister, up users.Persister, t *testing.T) *httptest.Server {
 	cfg := config.Config{}
 
-	is := items.NewService(ip)
-	us := upc.NewService(cfg)
-	user := users.NewService(up)
+	is := items.NewService(ip, nil, nil, cfg)
+	us := upc.NewService(nil, cfg)
+	user := users.NewService(up, cfg)
 	es := email.NewService(cfg, nil)
+	ps := pictures.NewService(nil, cfg)
+	as := attachments.NewService(nil, nil, cfg)
 
-	serv := NewAPI(is, us, user, es)
+	serv := NewAPI(is, us, user, es, ps, as)
 
 	return httptest.NewServer(NewRouter(&serv, cfg))
 }
@@ -51,28 +58,49 @@ func setupServer(ip items.Persister, up users.Persister, t *testing.T) *httptest
 func setupServerCustomEmail(ip items.Persister, up users.Persister, em email.Sender, t *testing.T) *httptest.Server {
 	cfg := config.Config{}
 
-	is := items.NewService(ip)
-	us := upc.NewService(cfg)
-	user := users.NewService(up)
+	is := items.NewService(ip, nil, nil, cfg)
+	us := upc.NewService(nil, cfg)
+	user := users.NewService(up, cfg)
 	es := email.NewService(cfg, em)
+	ps := pictures.NewService(nil, cfg)
+	as := attachments.NewService(nil, nil, cfg)
 
-	serv := NewAPI(is, us, user, es)
+	serv := NewAPI(is, us, user, es, ps, as)
 
 	return httptest.NewServer(NewRouter(&serv, cfg))
 }
 
-func setupServerWithConfigAuthenticated(ip items.Persister, cfg config.Config, t *testing.T) *httptest.Server {
+// testDeps are the dependencies of a test server. Those left out are nil,
+// and the pictures service uses the storage when it is not given.
+type testDeps struct {
+	items       items.Persister
+	upc         upc.Persister
+	attachments attachments.Persister
+	storage     storage.Storage
+	pictures    *pictures.Service
+	config      config.Config
+}
+
+func setupServerAuthenticatedWith(t *testing.T, deps testDeps) *httptest.Server {
+	cfg := deps.config
+
 	mc := gomock.NewController(t)
 	defer mc.Finish()
 	up := users.NewMockPersister(mc)
-	up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true}, nil).AnyTimes()
+	up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 1, Permissions: users.AllPermissions}, nil).AnyTimes()
 
-	is := items.NewService(ip)
-	us := upc.NewService(cfg)
-	user := users.NewService(up)
+	ps := pictures.NewService(deps.storage, cfg)
+	if deps.pictures != nil {
+		ps = *deps.pictures
+	}
+
+	is := items.NewService(deps.items, nil, deps.storage, cfg)
+	us := upc.NewService(deps.upc, cfg)
+	user := users.NewService(up, cfg)
 	es := email.NewService(cfg, nil)
+	as := attachments.NewService(deps.attachments, deps.storage, cfg)
 
-	serv := NewAPI(is, us, user, es)
+	serv := NewAPI(is, us, user, es, ps, as)
 
 	return httptest.NewServer(NewRouter(&serv, cfg))
 }
@@ -94,6 +122,18 @@ func sendPost(url string, body interface{}) (*http.Response, error) {
 	return client.Do(req)
 }
 
+func sendPostRaw(url, contentType, body string) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewBufferString(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	client := &http.Client{}
+	return client.Do(req)
+}
+
 func sendDelete(url string) (*http.Response, error) {
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+type CategoryBody struct {
+	// ID is 0 to add a new category
+	ID     int           `json:"id"`
+	Name   string        `json:"name"`
+	Fields []items.Field `json:"fields"`
+}
+
+// FetchCategories returns every category of the org sorted by name
+func (a *API) FetchCategories(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := a.itemsService.GetCategories(u.OrgID)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(res, w)
+	})
+}
+
+// SetCategory adds a category, or renames an existing one or changes its fields
+func (a *API) SetCategory(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cb := CategoryBody{}
+		err := parseBody(r, &cb)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		c := items.Category{
+			ID:     cb.ID,
+			Name:   cb.Name,
+			Fields: cb.Fields,
+		}
+
+		if c.ID == 0 {
+			c, err = a.itemsService.AddCategory(u.OrgID, c, u.ID)
+		} else {
+			err = a.itemsService.UpdateCategory(u.OrgID, c, u.ID)
+		}
+
+		if err != nil && err == items.CategoryNotFoundErr {
+			responses.SendError(w, responses.CategoryNotFound(err))
+			return
+		} else if err != nil && err == items.CategoryAlreadyExistsErr {
+			responses.SendError(w, responses.CategoryAlreadyExists(err))
+			return
+		} else if err != nil && err == items.InvalidCategoryErr {
+			responses.SendError(w, responses.InvalidParamError("name", err))
+			return
+		} else if fe, ok := err.(items.FieldError); ok {
+			responses.SendError(w, responses.InvalidParamError("fields", fe))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(c, w)
+	})
+}
+
+// DeleteCategory removes a category that no items are in
+func (a *API) DeleteCategory(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idStr, err := getRequiredParam(r, "id")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("id"))
+			return
+		}
+
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			responses.SendError(w, responses.InvalidParamError("id", err))
+			return
+		}
+
+		err = a.itemsService.DeleteCategory(u.OrgID, id, u.ID)
+		if err != nil && err == items.CategoryNotFoundErr {
+			responses.SendError(w, responses.CategoryNotFound(err))
+			return
+		} else if err != nil && err == items.CategoryInUseErr {
+			responses.SendError(w, responses.CategoryInUse(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(responses.Success{Success: true}, w)
+	})
+}
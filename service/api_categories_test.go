@@ -0,0 +1,206 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchCategories(t *testing.T) {
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+
+	electronics := items.Category{ID: 2, Name: "electronics", Fields: []items.Field{{Name: "Voltage", Type: items.FieldNumber}}}
+	food := items.Category{ID: 1, Name: "Food", Fields: []items.Field{{Name: "Expiry", Type: items.FieldDate, Required: true}}}
+
+	ip := items.NewMockPersister(mc)
+	ip.EXPECT().GetCategories(1).Return(items.Categories{food, electronics}, nil)
+
+	server := setupServerAuthenticated(ip, t)
+	defer server.Close()
+
+	resp, err := sendGet(server.URL + "/api/categories")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	b, err := json.Marshal(items.Categories{electronics, food})
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(b), string(getBody(t, resp)))
+}
+
+func TestSetCategory(t *testing.T) {
+	type testCase struct {
+		testName   string
+		sendBody   CategoryBody
+		setMock    func(*items.MockPersister)
+		expectCode int
+	}
+
+	existing := items.Categories{
+		{ID: 1, Name: "Food", Fields: []items.Field{{Name: "Expiry", Type: items.FieldDate}}},
+		{ID: 2, Name: "Electronics", Fields: []items.Field{}},
+	}
+
+	testCases := []testCase{
+		{
+			testName: "add",
+			sendBody: CategoryBody{Name: " Paint ", Fields: []items.Field{
+				{Name: "Finish", Type: "Enum", Options: []string{"Matte", "Gloss"}},
+				{Name: " Litres ", Type: "number", Options: []string{"ignored"}},
+			}},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetCategories(1).Return(existing, nil)
+				ip.EXPECT().AddCategory(1, items.Category{Name: "Paint", Fields: []items.Field{
+					{Name: "Finish", Type: items.FieldEnum, Options: []string{"Matte", "Gloss"}},
+					{Name: "Litres", Type: items.FieldNumber},
+				}}, 123).Return(items.Category{ID: 3, Name: "Paint"}, nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "already exists",
+			sendBody: CategoryBody{Name: "food"},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetCategories(1).Return(existing, nil)
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "blank name",
+			sendBody: CategoryBody{Name: " "},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetCategories(1).Return(existing, nil)
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "unknown field type",
+			sendBody: CategoryBody{Name: "Paint", Fields: []items.Field{{Name: "Colour", Type: "colour"}}},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetCategories(1).Return(existing, nil)
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "enum without options",
+			sendBody: CategoryBody{Name: "Paint", Fields: []items.Field{{Name: "Finish", Type: items.FieldEnum}}},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetCategories(1).Return(existing, nil)
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "duplicate field",
+			sendBody: CategoryBody{Name: "Paint", Fields: []items.Field{{Name: "Colour", Type: items.FieldText}, {Name: "colour", Type: items.FieldText}}},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetCategories(1).Return(existing, nil)
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "update",
+			sendBody: CategoryBody{ID: 2, Name: "Electronics", Fields: []items.Field{{Name: "Voltage", Type: items.FieldNumber}}},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetCategories(1).Return(existing, nil)
+				ip.EXPECT().UpdateCategory(1, items.Category{ID: 2, Name: "Electronics", Fields: []items.Field{{Name: "Voltage", Type: items.FieldNumber}}}, 123).Return(nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "update not found",
+			sendBody: CategoryBody{ID: 9, Name: "Paint"},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetCategories(1).Return(existing, nil)
+			},
+			expectCode: 404,
+		},
+		{
+			testName: "internal error",
+			sendBody: CategoryBody{Name: "Paint"},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetCategories(1).Return(nil, errors.New("sorry"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+"/api/category", tc.sendBody)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestDeleteCategory(t *testing.T) {
+	type testCase struct {
+		testName   string
+		url        string
+		setMock    func(*items.MockPersister)
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			url:      "/api/category?id=2",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().DeleteCategory(1, 2, 123).Return(nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "in use",
+			url:      "/api/category?id=2",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().DeleteCategory(1, 2, 123).Return(items.CategoryInUseErr)
+			},
+			expectCode: 409,
+		},
+		{
+			testName: "not found",
+			url:      "/api/category?id=2",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().DeleteCategory(1, 2, 123).Return(items.CategoryNotFoundErr)
+			},
+			expectCode: 404,
+		},
+		{
+			testName:   "invalid id",
+			url:        "/api/category?id=food",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendDelete(server.URL + tc.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
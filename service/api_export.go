@@ -0,0 +1,379 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+// Formats items can be exported in
+const (
+	exportCSV  = "csv"
+	exportJSON = "json"
+	exportXLSX = "xlsx"
+)
+
+var exportContentTypes = map[string]string{
+	exportCSV:  "text/csv",
+	exportJSON: "application/json",
+	exportXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ExportItems sends every item matching the same filters as search as a file.
+// deleted=1 includes the items in the trash and loans=1 their open loans.
+func (a *API) ExportItems(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format, err := getRequiredParam(r, "format")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("format"))
+			return
+		}
+		format = strings.ToLower(format)
+		contentType, ok := exportContentTypes[format]
+		if !ok {
+			responses.SendError(w, responses.InvalidParamError("format", errors.New("must be one of csv, json or xlsx")))
+			return
+		}
+
+		filter, ok := a.getItemFilter(w, r, u)
+		if !ok {
+			return
+		}
+		filter.Search = getOptionalParam(r, "q")
+		opts := items.ExportOptions{
+			Deleted: getOptionalParam(r, "deleted") == "1",
+			Loans:   getOptionalParam(r, "loans") == "1",
+		}
+		if opts.Deleted && !u.Can(users.PermItemDelete) {
+			responses.SendError(w, responses.Forbidden(fmt.Errorf("you need the %s permission to export deleted items", users.PermItemDelete)))
+			return
+		}
+
+		cs, err := a.itemsService.GetCategories(u.OrgID)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		// Nothing is written until the first item so that errors found before
+		// then can still be sent as errors
+		var ew exportWriter
+		err = a.itemsService.ExportItems(u.OrgID, filter, opts, func(item items.ExportedItem) error {
+			if ew == nil {
+				ew = startExport(w, format, contentType, exportColumns(cs, opts))
+			}
+			return ew.write(item)
+		})
+		if err != nil && ew == nil {
+			if err == items.InvalidSortErr {
+				responses.SendError(w, responses.InvalidParamError("sort", err))
+			} else if err == items.InvalidStatusErr {
+				responses.SendError(w, responses.InvalidParamError("status", err))
+			} else {
+				responses.SendError(w, responses.InternalError(err))
+			}
+			return
+		} else if err != nil {
+			// The response has started so all that can be done is to stop
+			return
+		}
+
+		if ew == nil {
+			ew = startExport(w, format, contentType, exportColumns(cs, opts))
+		}
+		ew.close()
+	})
+}
+
+// exportColumn is a column of a CSV or XLSX export
+type exportColumn struct {
+	name  string
+	value func(items.ExportedItem) interface{}
+}
+
+// exportColumns returns the columns exported with the options. Every custom
+// field of every category gets a column named like the ones imported.
+func exportColumns(cs items.Categories, opts items.ExportOptions) []exportColumn {
+	cols := []exportColumn{
+		{"id", func(i items.ExportedItem) interface{} { return i.ID }},
+		{"name", func(i items.ExportedItem) interface{} { return i.Name }},
+		{"details", func(i items.ExportedItem) interface{} { return i.Details }},
+		{"category", func(i items.ExportedItem) interface{} { return i.Category }},
+		{"location", func(i items.ExportedItem) interface{} { return i.Location }},
+		{"pictureURL", func(i items.ExportedItem) interface{} { return i.PictureURL }},
+		{"quantity", func(i items.ExportedItem) interface{} { return i.Quantity }},
+		{"available", func(i items.ExportedItem) interface{} { return i.Available }},
+		{"checkedOut", func(i items.ExportedItem) interface{} { return i.CheckedOut }},
+		{"status", func(i items.ExportedItem) interface{} { return i.Status }},
+		{"lastPerformedBy", func(i items.ExportedItem) interface{} { return i.LastPerformedBy }},
+	}
+
+	names := []string{}
+	seen := map[string]bool{}
+	for _, c := range cs {
+		for _, f := range c.Fields {
+			if !seen[strings.ToLower(f.Name)] {
+				seen[strings.ToLower(f.Name)] = true
+				names = append(names, f.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		name := name
+		cols = append(cols, exportColumn{fieldColumnPrefix + name, func(i items.ExportedItem) interface{} {
+			for k, v := range i.Fields {
+				if strings.EqualFold(k, name) {
+					return v
+				}
+			}
+			return ""
+		}})
+	}
+
+	if opts.Deleted {
+		cols = append(cols, exportColumn{"deletedAt", func(i items.ExportedItem) interface{} {
+			if i.DeletedAt == nil {
+				return ""
+			}
+			return i.DeletedAt.Format(time.RFC3339)
+		}})
+	}
+	if opts.Loans {
+		cols = append(cols, exportColumn{"loans", func(i items.ExportedItem) interface{} {
+			return describeLoans(i.Loans)
+		}})
+	}
+
+	return cols
+}
+
+// describeLoans sums up loans in one cell, such as "bob x2 due 2020-01-02"
+func describeLoans(ls items.Loans) string {
+	parts := []string{}
+	for _, l := range ls {
+		p := fmt.Sprintf("%s x%d", l.Borrower, l.Outstanding())
+		if l.DueAt != nil {
+			p += " due " + l.DueAt.Format(items.FieldDateLayout)
+		}
+		if l.Overdue {
+			p += " (overdue)"
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// exportWriter writes exported items to the response as they are read
+type exportWriter interface {
+	write(items.ExportedItem) error
+	// close finishes the file once every item is written
+	close() error
+}
+
+// startExport sets the headers of the response and returns the writer for the
+// format
+func startExport(w http.ResponseWriter, format, contentType string, cols []exportColumn) exportWriter {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="items.%s"`, format))
+
+	switch format {
+	case exportJSON:
+		return &jsonExport{w: w}
+	case exportXLSX:
+		return newXLSXExport(w, cols)
+	}
+	return newCSVExport(w, cols)
+}
+
+type csvExport struct {
+	w    *csv.Writer
+	cols []exportColumn
+}
+
+func newCSVExport(w io.Writer, cols []exportColumn) *csvExport {
+	e := &csvExport{w: csv.NewWriter(w), cols: cols}
+
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.name
+	}
+	e.w.Write(header)
+
+	return e
+}
+
+func (e *csvExport) write(item items.ExportedItem) error {
+	record := make([]string, len(e.cols))
+	for i, c := range e.cols {
+		record[i] = cellText(c.value(item))
+	}
+	return e.w.Write(record)
+}
+
+// formulaStarts are the characters that make spreadsheets treat a cell as a
+// formula, plus the quote that cellText puts in front of them
+const formulaStarts = "=+-@\t\r'"
+
+// cellText returns the value as the text of a spreadsheet cell. Text starting
+// with one of formulaStarts gets a quote in front so that a name such as
+// =HYPERLINK(...) is shown instead of being run when the file is opened.
+func cellText(v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+
+	if s != "" && strings.IndexByte(formulaStarts, s[0]) >= 0 {
+		return "'" + s
+	}
+	return s
+}
+
+// uncellText takes off the quote that cellText added, so that exports can be
+// imported again as they were
+func uncellText(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.IndexByte(formulaStarts, s[1]) >= 0 {
+		return s[1:]
+	}
+	return s
+}
+
+func (e *csvExport) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonExport writes the items as a JSON array without holding them all
+type jsonExport struct {
+	w       io.Writer
+	started bool
+}
+
+func (e *jsonExport) write(item items.ExportedItem) error {
+	sep := ","
+	if !e.started {
+		sep = "["
+		e.started = true
+	}
+
+	b, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonExport) close() error {
+	end := "]"
+	if !e.started {
+		end = "[]"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// xlsxExport writes a workbook with a single sheet. The sheet is streamed into
+// the zip and the parts of the workbook that point to it are added at the end.
+type xlsxExport struct {
+	z     *zip.Writer
+	sheet *bufio.Writer
+	cols  []exportColumn
+}
+
+func newXLSXExport(w io.Writer, cols []exportColumn) *xlsxExport {
+	e := &xlsxExport{z: zip.NewWriter(w), cols: cols}
+
+	sw, _ := e.z.Create("xl/worksheets/sheet1.xml")
+	e.sheet = bufio.NewWriter(sw)
+	e.sheet.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	header := make([]interface{}, len(cols))
+	for i, c := range cols {
+		header[i] = c.name
+	}
+	e.row(header)
+
+	return e
+}
+
+func (e *xlsxExport) write(item items.ExportedItem) error {
+	values := make([]interface{}, len(e.cols))
+	for i, c := range e.cols {
+		values[i] = c.value(item)
+	}
+	return e.row(values)
+}
+
+// row writes a row of cells. Numbers are written as numbers and everything
+// else as text.
+func (e *xlsxExport) row(values []interface{}) error {
+	e.sheet.WriteString("<row>")
+	for _, v := range values {
+		if n, ok := v.(int); ok {
+			e.sheet.WriteString(`<c><v>` + strconv.Itoa(n) + `</v></c>`)
+			continue
+		}
+
+		e.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		xml.EscapeText(e.sheet, []byte(cellText(v)))
+		e.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := e.sheet.WriteString("</row>")
+	return err
+}
+
+// xlsxParts are the parts of the workbook other than the sheet
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Items" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+func (e *xlsxExport) close() error {
+	e.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+
+	for _, p := range xlsxParts {
+		pw, err := e.z.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(pw, xml.Header+p.body); err != nil {
+			return err
+		}
+	}
+
+	return e.z.Close()
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportItems(t *testing.T) {
+	type testCase struct {
+		testName       string
+		url            string
+		setMock        func(*items.MockPersister)
+		expectCode     int
+		expectedType   string
+		expectedOutput string
+	}
+
+	categories := items.Categories{
+		{ID: 3, Name: "Tools", Fields: []items.Field{{Name: "Serial", Type: items.FieldText}}},
+	}
+	deletedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	dueAt := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+	hammer := items.ExportedItem{ItemDetail: items.ItemDetail{
+		ID: "1", Name: "Hammer", CategoryID: 3, Category: "Tools", Location: "Shed", Details: "Claw, 16oz",
+		Fields: map[string]string{"Serial": "H-1"}, LastPerformedBy: "bob", Quantity: 3, Available: 2, CheckedOut: 1, Status: items.StatusPartiallyCheckedOut,
+	}}
+	saw := items.ExportedItem{ItemDetail: items.ItemDetail{
+		ID: "2", Name: "Saw", LastPerformedBy: "bob", Quantity: 1, Available: 1, Status: items.StatusCheckedIn,
+	}, DeletedAt: &deletedAt}
+
+	export := func(list ...items.ExportedItem) func(int, items.ItemFilter, func(items.ExportedItem) error) error {
+		return func(orgID int, filter items.ItemFilter, fn func(items.ExportedItem) error) error {
+			for _, item := range list {
+				if err := fn(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	testCases := []testCase{
+		{
+			testName: "csv",
+			url:      "/api/items/export?format=csv&category=3&sort=-quantity",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().ExportItems(1, items.ItemFilter{CategoryID: 3, SortBy: "quantity", Descending: true}, gomock.Any()).DoAndReturn(export(hammer))
+			},
+			expectCode:   200,
+			expectedType: "text/csv",
+			expectedOutput: "id,name,details,category,location,pictureURL,quantity,available,checkedOut,status,lastPerformedBy,fields.Serial\n" +
+				"1,Hammer,\"Claw, 16oz\",Tools,Shed,,3,2,1,partially checked out,bob,H-1\n",
+		},
+		{
+			testName: "csv with deleted items and loans",
+			url:      "/api/items/export?format=csv&deleted=1&loans=1",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLoans(1, items.LoanFilter{OpenOnly: true}).Return(items.Loans{
+					{ItemID: "1", Borrower: "alice", Quantity: 2, Returned: 1, DueAt: &dueAt},
+				}, nil)
+				ip.EXPECT().ExportItems(1, items.ItemFilter{SortBy: "name", Deleted: true}, gomock.Any()).DoAndReturn(export(hammer, saw))
+			},
+			expectCode:   200,
+			expectedType: "text/csv",
+			expectedOutput: "id,name,details,category,location,pictureURL,quantity,available,checkedOut,status,lastPerformedBy,fields.Serial,deletedAt,loans\n" +
+				"1,Hammer,\"Claw, 16oz\",Tools,Shed,,3,2,1,partially checked out,bob,H-1,,alice x1 due 2020-02-01 (overdue)\n" +
+				"2,Saw,,,,,1,1,0,checked in,bob,,2020-01-02T03:04:05Z,\n",
+		},
+		{
+			testName: "csv with formulas",
+			url:      "/api/items/export?format=csv",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().ExportItems(1, items.ItemFilter{SortBy: "name"}, gomock.Any()).DoAndReturn(export(items.ExportedItem{ItemDetail: items.ItemDetail{
+					ID: "3", Name: "=1+2", Details: "+cmd|' /C calc'!A0", CategoryID: 3, Category: "@SUM(A1)", Location: "-2",
+					Fields: map[string]string{"Serial": "'S-1"}, LastPerformedBy: "bob", Quantity: 1, Available: 1, Status: items.StatusCheckedIn,
+				}}))
+			},
+			expectCode:   200,
+			expectedType: "text/csv",
+			expectedOutput: "id,name,details,category,location,pictureURL,quantity,available,checkedOut,status,lastPerformedBy,fields.Serial\n" +
+				"3,'=1+2,'+cmd|' /C calc'!A0,'@SUM(A1),'-2,,1,1,0,checked in,bob,''S-1\n",
+		},
+		{
+			testName: "json",
+			url:      "/api/items/export?format=json&q=saw",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().ExportItems(1, items.ItemFilter{Search: "saw", SortBy: "name"}, gomock.Any()).DoAndReturn(export(saw))
+			},
+			expectCode:   200,
+			expectedType: "application/json",
+			expectedOutput: `[{"ID":"2","Name":"Saw","CategoryID":0,"Category":"","PictureURL":"","Details":"","LocationID":0,"Location":"","Fields":null,` +
+				`"LastPerformedBy":"bob","Quantity":1,"Available":1,"CheckedOut":0,"Status":"checked in","DeletedAt":"2020-01-02T03:04:05Z"}]`,
+		},
+		{
+			testName: "nothing to export",
+			url:      "/api/items/export?format=json",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().ExportItems(1, items.ItemFilter{SortBy: "name"}, gomock.Any()).DoAndReturn(export())
+			},
+			expectCode:     200,
+			expectedType:   "application/json",
+			expectedOutput: `[]`,
+		},
+		{
+			testName:   "missing format",
+			url:        "/api/items/export",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "invalid format",
+			url:        "/api/items/export?format=pdf",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "invalid status",
+			url:        "/api/items/export?format=csv&status=lost",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName: "error",
+			url:      "/api/items/export?format=csv",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().ExportItems(1, gomock.Any(), gomock.Any()).Return(errors.New("some error"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+			ip.EXPECT().GetCategories(1).Return(categories, nil).AnyTimes()
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendGet(server.URL + tc.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				assert.Equal(t, tc.expectedType, resp.Header.Get("Content-Type"))
+				assert.Equal(t, tc.expectedOutput, getBody(t, resp))
+			}
+		})
+	}
+}
+
+func TestExportItemsXLSX(t *testing.T) {
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+
+	ip := items.NewMockPersister(mc)
+	ip.EXPECT().GetCategories(1).Return(items.Categories{}, nil)
+	ip.EXPECT().ExportItems(1, items.ItemFilter{SortBy: "name"}, gomock.Any()).DoAndReturn(
+		func(orgID int, filter items.ItemFilter, fn func(items.ExportedItem) error) error {
+			if err := fn(items.ExportedItem{ItemDetail: items.ItemDetail{ID: "1", Name: "Nuts & Bolts", Quantity: 40}}); err != nil {
+				return err
+			}
+			return fn(items.ExportedItem{ItemDetail: items.ItemDetail{ID: "2", Name: "=HYPERLINK(1)", Quantity: 1}})
+		})
+
+	server := setupServerAuthenticated(ip, t)
+	defer server.Close()
+
+	resp, err := sendGet(server.URL + "/api/items/export?format=xlsx")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	b := []byte(getBody(t, resp))
+	z, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	assert.NoError(t, err)
+
+	parts := map[string]string{}
+	for _, f := range z.File {
+		r, err := f.Open()
+		assert.NoError(t, err)
+		body, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		parts[f.Name] = string(body)
+	}
+
+	assert.Contains(t, parts, "[Content_Types].xml")
+	assert.Contains(t, parts, "xl/workbook.xml")
+	assert.Contains(t, parts["xl/worksheets/sheet1.xml"], `<c t="inlineStr"><is><t xml:space="preserve">Nuts &amp; Bolts</t></is></c>`)
+	assert.Contains(t, parts["xl/worksheets/sheet1.xml"], `<c><v>40</v></c>`)
+	assert.Contains(t, parts["xl/worksheets/sheet1.xml"], `<c t="inlineStr"><is><t xml:space="preserve">&#39;=HYPERLINK(1)</t></is></c>`)
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+// Content types that can be imported
+const (
+	contentTypeCSV    = "text/csv"
+	contentTypeNDJSON = "application/x-ndjson"
+	contentTypeJSONL  = "application/jsonl"
+)
+
+// fieldColumnPrefix starts the name of CSV columns that hold a custom field
+const fieldColumnPrefix = "fields."
+
+// exportOnlyColumns are in CSV exports but are ignored when importing them
+var exportOnlyColumns = map[string]bool{
+	"available":       true,
+	"checkedout":      true,
+	"status":          true,
+	"lastperformedby": true,
+	"deletedat":       true,
+	"loans":           true,
+}
+
+// ImportBody is one line of an import sent as JSON lines
+type ImportBody struct {
+	AddBody
+	// Mode is create, overwrite or skip and says what to do when the ID is
+	// already taken. It defaults to create, which reports it as an error.
+	Mode string `json:"mode"`
+}
+
+// ImportItems adds many items at once from CSV or JSON lines. Every row is
+// checked first and nothing is imported if any of them has a problem, or if
+// dryRun=1 is given.
+func (a *API) ImportItems(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dryRun := getOptionalParam(r, "dryRun") == "1"
+
+		var (
+			rows []items.ImportRow
+			errs items.ImportErrors
+			err  error
+		)
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mt {
+		case contentTypeCSV, "":
+			rows, errs, err = readImportCSV(r.Body, u)
+		case contentTypeNDJSON, contentTypeJSONL:
+			rows, errs, err = readImportJSONLines(r.Body, u)
+		default:
+			err = fmt.Errorf("must be %s or %s", contentTypeCSV, contentTypeNDJSON)
+			responses.SendError(w, responses.InvalidParamError("Content-Type", err))
+			return
+		}
+		if err != nil {
+			responses.SendError(w, responses.InvalidParamError("body", err))
+			return
+		}
+
+		for _, row := range rows {
+			if row.Mode == items.ImportOverwrite && !u.Can(users.PermItemEdit) {
+				responses.SendError(w, responses.Forbidden(fmt.Errorf("you need the %s permission to overwrite items", users.PermItemEdit)))
+				return
+			}
+		}
+
+		// Categories and locations that do not exist yet are created along with
+		// the items, which takes the same permission as creating them directly
+		if !u.Can(users.PermItemEdit) {
+			creates, err := a.itemsService.ImportCreates(u.OrgID, rows)
+			if err != nil {
+				responses.SendError(w, responses.InternalError(err))
+				return
+			} else if creates {
+				responses.SendError(w, responses.Forbidden(createForbiddenErr))
+				return
+			}
+		}
+
+		// Rows that could not be read are reported along with the rest
+		report, err := a.itemsService.ImportItems(u.OrgID, rows, dryRun || len(errs) > 0, u.ID)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+		report.DryRun = dryRun
+		report.Errors = append(errs, report.Errors...)
+		report.Errors.Sort()
+
+		sendJSONorErr(report, w)
+	})
+}
+
+// row returns the row of an import for the body on the line
+func (b ImportBody) row(line int, u users.User) items.ImportRow {
+	return items.ImportRow{
+		Line:     line,
+		Mode:     strings.ToLower(strings.TrimSpace(b.Mode)),
+		Item:     b.item(u),
+		Category: b.Category,
+		Location: b.Location,
+	}
+}
+
+// readImportCSV reads rows with a header naming the fields of AddBody they
+// hold, plus mode and fields.<name> for custom fields. Rows that cannot be
+// read are returned as errors.
+func readImportCSV(r io.Reader, u users.User) ([]items.ImportRow, items.ImportErrors, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("must start with a header row")
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if strings.HasPrefix(strings.ToLower(h), fieldColumnPrefix) {
+			header[i] = fieldColumnPrefix + h[len(fieldColumnPrefix):]
+			continue
+		}
+
+		header[i] = strings.ToLower(h)
+		switch header[i] {
+		case "id", "name", "details", "category", "categoryid", "location", "locationid", "pictureurl", "quantity", "mode":
+		default:
+			if !exportOnlyColumns[header[i]] {
+				return nil, nil, fmt.Errorf("unknown column %q", h)
+			}
+		}
+	}
+
+	rows := []items.ImportRow{}
+	errs := items.ImportErrors{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		if pe, ok := err.(*csv.ParseError); ok && pe.Err == csv.ErrFieldCount {
+			errs = append(errs, items.ImportError{Line: line, Reason: fmt.Sprintf("has %d columns but the header has %d", len(record), len(header))})
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		b := ImportBody{AddBody: AddBody{Fields: map[string]string{}}}
+		failed := len(errs)
+		number := func(field, v string) int {
+			if v = strings.TrimSpace(v); v == "" {
+				return 0
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, items.ImportError{Line: line, Field: field, Reason: "must be a whole number"})
+			}
+			return n
+		}
+
+		for i, v := range record {
+			v = uncellText(v)
+			switch header[i] {
+			case "id":
+				b.ID = strings.TrimSpace(v)
+			case "name":
+				b.Name = strings.TrimSpace(v)
+			case "details":
+				b.Details = v
+			case "category":
+				b.Category = v
+			case "categoryid":
+				b.CategoryID = number("categoryId", v)
+			case "location":
+				b.Location = v
+			case "locationid":
+				b.LocationID = number("locationId", v)
+			case "pictureurl":
+				b.PictureURL = strings.TrimSpace(v)
+			case "quantity":
+				b.Quantity = number("quantity", v)
+			case "mode":
+				b.Mode = v
+			default:
+				if strings.HasPrefix(header[i], fieldColumnPrefix) {
+					b.Fields[strings.TrimPrefix(header[i], fieldColumnPrefix)] = v
+				}
+			}
+		}
+		if len(errs) > failed {
+			// The ID may come after the column with the problem
+			for i := failed; i < len(errs); i++ {
+				errs[i].ID = b.ID
+			}
+			continue
+		}
+
+		rows = append(rows, b.row(line, u))
+	}
+
+	return rows, errs, nil
+}
+
+// readImportJSONLines reads an ImportBody from each line that is not blank.
+// Lines that cannot be read are returned as errors.
+func readImportJSONLines(r io.Reader, u users.User) ([]items.ImportRow, items.ImportErrors, error) {
+	rows := []items.ImportRow{}
+	errs := items.ImportErrors{}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+
+		b := ImportBody{}
+		if err := json.Unmarshal(sc.Bytes(), &b); err != nil {
+			errs = append(errs, items.ImportError{Line: line, Reason: "is not valid JSON: " + err.Error()})
+			continue
+		}
+
+		rows = append(rows, b.row(line, u))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return rows, errs, nil
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/barcode"
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/users"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportItems(t *testing.T) {
+	type testCase struct {
+		testName         string
+		url              string
+		contentType      string
+		body             string
+		setMock          func(*items.MockPersister)
+		expectCode       int
+		expectedResponse items.ImportReport
+	}
+
+	categories := items.Categories{
+		{ID: 3, Name: "Tools", Fields: []items.Field{{Name: "Serial", Type: items.FieldText}}},
+		{ID: 5, Name: "Food", Fields: []items.Field{{Name: "Expiry", Type: items.FieldDate, Required: true}}},
+	}
+	existing := items.ItemDetailList{
+		{ID: "2", Name: "Saw", CategoryID: 3, Quantity: 4, CheckedOut: 2},
+		{ID: "9", Name: "Drill", CategoryID: 3, Quantity: 1},
+	}
+
+	hammer := items.ItemDetail{ID: "1", Name: "Hammer", CategoryID: 3, Fields: map[string]string{"Serial": "H-1"}, LastPerformedBy: "123", Quantity: 2, Status: items.StatusCheckedIn}
+	saw := items.ItemDetail{ID: "2", Name: "Saw", CategoryID: 3, Fields: map[string]string{}, LastPerformedBy: "123", Quantity: 3, Status: items.StatusCheckedIn}
+
+	testCases := []testCase{
+		{
+			testName:    "csv",
+			url:         "/api/items/import",
+			contentType: "text/csv",
+			body:        "ID,Name,Category,Quantity,Mode,Fields.Serial\n1,Hammer,tools,2,,H-1\n2,Saw,Tools,3,overwrite,\n",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"1", "2"}, SortBy: "id"}).Return(existing, 1, nil)
+				ip.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(items.Persister) error) error {
+					return fn(ip)
+				})
+				ip.EXPECT().PurgeItem(1, "1", 123).Return(nil, items.ItemNotFoundErr)
+				ip.EXPECT().AddItem(1, hammer, false).Return(nil)
+				ip.EXPECT().AddItem(1, saw, true).Return(nil)
+			},
+			expectCode:       200,
+			expectedResponse: items.ImportReport{Created: 1, Overwritten: 1, Errors: items.ImportErrors{}},
+		},
+		{
+			testName:    "overwrite row with a new id",
+			url:         "/api/items/import",
+			contentType: "text/csv",
+			body:        "ID,Name,Category,Quantity,Mode,Fields.Serial\n1,Hammer,tools,2,overwrite,H-1\n",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"1"}, SortBy: "id"}).Return(items.ItemDetailList{}, 0, nil)
+				ip.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(items.Persister) error) error {
+					return fn(ip)
+				})
+				ip.EXPECT().PurgeItem(1, "1", 123).Return(nil, items.ItemNotFoundErr)
+				ip.EXPECT().AddItem(1, hammer, false).Return(nil)
+			},
+			expectCode:       200,
+			expectedResponse: items.ImportReport{Created: 1, Errors: items.ImportErrors{}},
+		},
+		{
+			testName:    "quotes added to formulas by exports are taken off",
+			url:         "/api/items/import",
+			contentType: "text/csv",
+			body:        "ID,Name,Category,Quantity,Fields.Serial\n1,'=Hammer,tools,2,''H-1\n",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"1"}, SortBy: "id"}).Return(items.ItemDetailList{}, 0, nil)
+				ip.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(items.Persister) error) error {
+					return fn(ip)
+				})
+				ip.EXPECT().PurgeItem(1, "1", 123).Return(nil, items.ItemNotFoundErr)
+				formula := hammer
+				formula.Name = "=Hammer"
+				formula.Fields = map[string]string{"Serial": "'H-1"}
+				ip.EXPECT().AddItem(1, formula, false).Return(nil)
+			},
+			expectCode:       200,
+			expectedResponse: items.ImportReport{Created: 1, Errors: items.ImportErrors{}},
+		},
+		{
+			testName:    "json lines dry run",
+			url:         "/api/items/import?dryRun=1",
+			contentType: "application/x-ndjson",
+			body:        `{"id": "1", "name": "Hammer", "categoryId": 3, "quantity": 2}` + "\n\n" + `{"id": "2", "name": "Saw", "category": "Tools", "quantity": 1, "mode": "skip"}`,
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"1", "2"}, SortBy: "id"}).Return(existing, 1, nil)
+			},
+			expectCode:       200,
+			expectedResponse: items.ImportReport{DryRun: true, Created: 1, Skipped: 1, Errors: items.ImportErrors{}},
+		},
+		{
+			testName:    "row errors",
+			url:         "/api/items/import",
+			contentType: "text/csv",
+			body: "id,name,category,quantity,mode,fields.Expiry\n" +
+				"1,Hammer,Tools,lots,,\n" +
+				"2,Saw,Tools,1,overwrite,\n" +
+				"9,Drill,Tools,1,,\n" +
+				"3,,Tools,0,replace,\n" +
+				"4,Bread,Food,1,,tomorrow\n" +
+				"5,Milk,Food,1\n" +
+				"6,Apple,Fruit,1,,2020-01-02\n" +
+				"7,Pear,Fruit,1,,\n" +
+				"7,Pear,Fruit,1,,\n" +
+				"036000291452,Cable,Tools,1,,\n" +
+				"0036000291452,Cable,Tools,1,,\n" +
+				"036000291453,Cable,Tools,1,,\n",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"00036000291452", "0036000291452", "036000291452"}, Deleted: true, SortBy: "id"}).Return(items.ItemDetailList{}, 0, nil)
+				ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"2", "9", "3", "4", "6", "7", "7", "00036000291452", "00036000291452", "036000291453"}, SortBy: "id"}).Return(existing, 1, nil)
+			},
+			expectCode: 200,
+			expectedResponse: items.ImportReport{Created: 2, Errors: items.ImportErrors{
+				{Line: 2, ID: "1", Field: "quantity", Reason: "must be a whole number"},
+				{Line: 3, ID: "2", Field: "quantity", Reason: items.QuantityBelowCheckedOutErr.Error()},
+				{Line: 4, ID: "9", Field: "id", Reason: items.ItemAlreadyExistsErr.Error()},
+				{Line: 5, ID: "3", Field: "mode", Reason: "must be one of create, overwrite or skip"},
+				{Line: 5, ID: "3", Field: "name", Reason: "is required"},
+				{Line: 5, ID: "3", Field: "quantity", Reason: "must be at least 1"},
+				{Line: 6, ID: "4", Field: "fields", Reason: `field "Expiry" must be a date formatted as 2006-01-02`},
+				{Line: 7, Reason: "has 4 columns but the header has 6"},
+				{Line: 8, ID: "6", Field: "fields", Reason: `field "Expiry" is not a field of the category Fruit`},
+				{Line: 10, ID: "7", Field: "id", Reason: "appears more than once in the import"},
+				{Line: 12, ID: "00036000291452", Field: "id", Reason: "appears more than once in the import"},
+				{Line: 13, ID: "036000291453", Field: "id", Reason: barcode.InvalidCheckDigitErr.Error()},
+			}},
+		},
+		{
+			testName:    "invalid json",
+			url:         "/api/items/import",
+			contentType: "application/jsonl",
+			body:        `{"id": "1", "name": "Hammer", "category": "Tools", "quantity": 2}` + "\n" + `{"id": 2}`,
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"1"}, SortBy: "id"}).Return(items.ItemDetailList{}, 0, nil)
+			},
+			expectCode: 200,
+			expectedResponse: items.ImportReport{Created: 1, Errors: items.ImportErrors{
+				{Line: 2, Reason: "is not valid JSON: json: cannot unmarshal number into Go struct field ImportBody.id of type string"},
+			}},
+		},
+		{
+			testName:    "unknown column",
+			url:         "/api/items/import",
+			contentType: "text/csv",
+			body:        "id,name,colour\n1,Hammer,red\n",
+			setMock:     func(ip *items.MockPersister) {},
+			expectCode:  400,
+		},
+		{
+			testName:    "unknown content type",
+			url:         "/api/items/import",
+			contentType: "application/xml",
+			body:        "<items/>",
+			setMock:     func(ip *items.MockPersister) {},
+			expectCode:  400,
+		},
+		{
+			testName:    "error",
+			url:         "/api/items/import",
+			contentType: "text/csv",
+			body:        "id,name,category,quantity\n1,Hammer,Tools,2\n",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, gomock.Any()).Return(items.ItemDetailList{}, 0, nil)
+				ip.EXPECT().Transaction(gomock.Any()).Return(errors.New("some error"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+			ip.EXPECT().GetCategories(1).Return(categories, nil).AnyTimes()
+			ip.EXPECT().GetLocations(1).Return(items.Locations{}, nil).AnyTimes()
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendPostRaw(server.URL+tc.url, tc.contentType, tc.body)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				b, err := json.Marshal(tc.expectedResponse)
+				assert.NoError(t, err)
+				assert.JSONEq(t, string(b), string(getBody(t, resp)))
+			}
+		})
+	}
+}
+
+func TestImportItemsWithoutEditPermission(t *testing.T) {
+	adder := users.User{Valid: true, ID: 123, OrgID: 1, Permissions: []string{users.PermItemAdd}}
+
+	type testCase struct {
+		testName   string
+		body       string
+		setMock    func(*items.MockPersister)
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "existing categories and locations",
+			body:     "id,name,category,location,quantity\n1,Hammer,tools,shed,2\n",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"1"}, SortBy: "id"}).Return(items.ItemDetailList{}, 0, nil)
+				ip.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(items.Persister) error) error {
+					return fn(ip)
+				})
+				ip.EXPECT().PurgeItem(1, "1", 123).Return(nil, items.ItemNotFoundErr)
+				ip.EXPECT().AddItem(1, gomock.Any(), false).Return(nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName:   "new category",
+			body:       "id,name,category,quantity\n1,Hammer,tools,2\n2,Saw,Saws,1\n",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 403,
+		},
+		{
+			testName:   "new location",
+			body:       "id,name,category,location,quantity\n1,Hammer,tools,Shed > Shelf 1,2\n",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 403,
+		},
+		{
+			testName:   "overwrite",
+			body:       "id,name,category,quantity,mode\n1,Hammer,tools,2,overwrite\n",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 403,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			ip.EXPECT().GetCategories(1).Return(items.Categories{{ID: 3, Name: "Tools"}}, nil).AnyTimes()
+			ip.EXPECT().GetLocations(1).Return(items.Locations{{ID: 7, Name: "Shed", Path: "Shed"}}, nil).AnyTimes()
+			tc.setMock(ip)
+
+			up := users.NewMockPersister(mc)
+			up.EXPECT().GetUserByToken(gomock.Any()).Return(adder, nil).AnyTimes()
+
+			server := setupServer(ip, up, t)
+			defer server.Close()
+
+			resp, err := sendPostRaw(server.URL+"/api/items/import", "text/csv", tc.body)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
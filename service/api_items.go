@@ -1,9 +1,14 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Timothylock/inventory-management/barcode"
 	"github.com/Timothylock/inventory-management/items"
 	"github.com/Timothylock/inventory-management/responses"
 	"github.com/Timothylock/inventory-management/users"
@@ -12,28 +17,110 @@ import (
 type MoveBody struct {
 	ID        string `json:"id"`
 	Direction string `json:"direction"`
+	// Quantity to move. Everything that can be moved is moved if it is 0.
+	Quantity int `json:"quantity"`
+	// Borrower is the username of the member borrowing the item. It defaults
+	// to the current user unless ExternalBorrower is set.
+	Borrower         string     `json:"borrower"`
+	ExternalBorrower string     `json:"externalBorrower"`
+	DueAt            *time.Time `json:"dueAt"`
+	Notes            string     `json:"notes"`
+	// LoanID is the loan being returned when checking in. The oldest loans are
+	// returned first if it is 0.
+	LoanID int `json:"loanId"`
 }
 
 type AddBody struct {
-	ID         string `json:"id"`
-	Name       string `json:"name"`
-	Details    string `json:"details"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Details string `json:"details"`
+	// Category is the name of the category, which is created if it does not
+	// exist yet. It is only used when CategoryID is 0.
 	Category   string `json:"category"`
+	CategoryID int    `json:"categoryId"`
+	// Fields are the values of the custom fields of the category
+	Fields map[string]string `json:"fields"`
+	// Location is the path of the location, which is created if it does not
+	// exist yet. It is only used when LocationID is 0.
 	Location   string `json:"location"`
+	LocationID int    `json:"locationId"`
 	PictureURL string `json:"pictureURL"`
 	Quantity   int    `json:"quantity"`
 }
 
+// item returns the item the body adds for the user
+func (ad AddBody) item(u users.User) items.ItemDetail {
+	return items.ItemDetail{
+		ID:              ad.ID,
+		Name:            ad.Name,
+		CategoryID:      ad.CategoryID,
+		PictureURL:      ad.PictureURL,
+		Details:         ad.Details,
+		Fields:          ad.Fields,
+		LocationID:      ad.LocationID,
+		LastPerformedBy: strconv.Itoa(u.ID), // Overloaded because it contains the actual username in SearchItems
+		Quantity:        ad.Quantity,
+		Status:          items.StatusCheckedIn,
+	}
+}
+
+// SearchItems returns a page of the items matching the search and filters
 func (a *API) SearchItems(u users.User) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		search, err := getRequiredParam(r, "q")
-		if err != nil {
+		filter, ok := a.getItemFilter(w, r, u)
+		if !ok {
+			return
+		}
+
+		// Filters on their own are enough to list items without a search
+		filter.Search = getOptionalParam(r, "q")
+		if filter.Search == "" && filter.Status == "" && filter.CategoryID == 0 && filter.LocationID == 0 && filter.QuantityBelow == 0 {
 			responses.SendError(w, responses.MissingParamError("q"))
 			return
 		}
 
-		res, err := a.itemsService.FetchItems(search)
+		a.sendItemPage(w, u, filter)
+	})
+}
+
+// ListItems returns a page of every item, or of the items matching the
+// filters if there are any
+func (a *API) ListItems(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter, ok := a.getItemFilter(w, r, u)
+		if !ok {
+			return
+		}
+		filter.Search = getOptionalParam(r, "q")
+
+		a.sendItemPage(w, u, filter)
+	})
+}
+
+// SummarizeItems counts the items matching the filters by category, location
+// or status
+func (a *API) SummarizeItems(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		by, err := getRequiredParam(r, "by")
 		if err != nil {
+			responses.SendError(w, responses.MissingParamError("by"))
+			return
+		}
+
+		filter, ok := a.getItemFilter(w, r, u)
+		if !ok {
+			return
+		}
+		filter.Search = getOptionalParam(r, "q")
+
+		res, err := a.itemsService.SummarizeItems(u.OrgID, by, filter)
+		if err != nil && err == items.InvalidGroupErr {
+			responses.SendError(w, responses.InvalidParamError("by", err))
+			return
+		} else if err != nil && err == items.InvalidStatusErr {
+			responses.SendError(w, responses.InvalidParamError("status", err))
+			return
+		} else if err != nil {
 			responses.SendError(w, responses.InternalError(err))
 			return
 		}
@@ -42,6 +129,95 @@ func (a *API) SearchItems(u users.User) http.Handler {
 	})
 }
 
+// getItemFilter reads the filters, sort and paging params shared by the
+// endpoints that list items. An error is sent if any of them are invalid.
+func (a *API) getItemFilter(w http.ResponseWriter, r *http.Request, u users.User) (items.ItemFilter, bool) {
+	filter := items.ItemFilter{Status: getOptionalParam(r, "status")}
+
+	// Categories are given by ID or name and locations by ID or path
+	if c := getOptionalParam(r, "category"); c != "" {
+		id, err := strconv.Atoi(c)
+		if err != nil {
+			var cat items.Category
+			cat, err = a.itemsService.FindCategory(u.OrgID, c)
+			id = cat.ID
+		}
+		if err != nil && err == items.CategoryNotFoundErr {
+			responses.SendError(w, responses.CategoryNotFound(err))
+			return filter, false
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return filter, false
+		}
+		filter.CategoryID = id
+	}
+
+	if l := getOptionalParam(r, "location"); l != "" {
+		id, err := strconv.Atoi(l)
+		if err != nil {
+			var loc items.Location
+			loc, err = a.itemsService.FindLocation(u.OrgID, l)
+			id = loc.ID
+		}
+		if err != nil && err == items.LocationNotFoundErr {
+			responses.SendError(w, responses.LocationNotFound(err))
+			return filter, false
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return filter, false
+		}
+		filter.LocationID = id
+	}
+
+	var ok bool
+	if filter.QuantityBelow, ok = getPositiveParam(w, r, "qtyLt"); !ok {
+		return filter, false
+	}
+	if filter.Limit, ok = getPositiveParam(w, r, "limit"); !ok {
+		return filter, false
+	}
+
+	if sort := getOptionalParam(r, "sort"); sort != "" {
+		var err error
+		filter.SortBy, filter.Descending, err = items.ParseSort(sort)
+		if err != nil {
+			responses.SendError(w, responses.InvalidParamError("sort", err))
+			return filter, false
+		}
+	}
+
+	if c := getOptionalParam(r, "cursor"); c != "" {
+		var err error
+		filter.After, err = items.ParseCursor(c)
+		if err != nil {
+			responses.SendError(w, responses.InvalidParamError("cursor", err))
+			return filter, false
+		}
+	}
+
+	return filter, true
+}
+
+// sendItemPage sends the page of items matching the filter
+func (a *API) sendItemPage(w http.ResponseWriter, u users.User, filter items.ItemFilter) {
+	res, err := a.itemsService.FetchItems(u.OrgID, filter)
+	if err != nil && err == items.InvalidStatusErr {
+		responses.SendError(w, responses.InvalidParamError("status", err))
+		return
+	} else if err != nil && err == items.InvalidSortErr {
+		responses.SendError(w, responses.InvalidParamError("sort", err))
+		return
+	} else if err != nil && err == items.InvalidCursorErr {
+		responses.SendError(w, responses.InvalidParamError("cursor", err))
+		return
+	} else if err != nil {
+		responses.SendError(w, responses.InternalError(err))
+		return
+	}
+
+	sendJSONorErr(res, w)
+}
+
 func (a *API) DeleteItem(u users.User) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id, err := getRequiredParam(r, "id")
@@ -50,7 +226,7 @@ func (a *API) DeleteItem(u users.User) http.Handler {
 			return
 		}
 
-		err = a.itemsService.DeleteItem(id, u.ID)
+		err = a.itemsService.DeleteItem(u.OrgID, id, u.ID)
 		if err != nil && err == items.ItemNotFoundErr {
 			responses.SendError(w, responses.ItemNotFound(err))
 			return
@@ -72,30 +248,55 @@ func (a *API) AddItem(u users.User) http.Handler {
 			return
 		}
 
-		if ad.ID == "" || ad.Name == "" || ad.Category == "" || ad.Quantity == 0 {
+		if ad.ID == "" || ad.Name == "" || (strings.TrimSpace(ad.Category) == "" && ad.CategoryID == 0) || ad.Quantity == 0 {
 			responses.SendError(w, responses.MissingParamError("ID, name, category, quantity must not be blank/0"))
 			return
 		}
 
 		o := getOptionalParam(r, "overwrite")
 		overwrite := o == "1"
+		if overwrite && !u.Can(users.PermItemEdit) {
+			responses.SendError(w, responses.Forbidden(fmt.Errorf("you need the %s permission to overwrite items", users.PermItemEdit)))
+			return
+		}
+
+		// Categories and locations that do not exist yet are created along with
+		// the item, which takes the same permission as creating them directly
+		if !u.Can(users.PermItemEdit) && !a.canAddWithoutCreating(w, u, ad) {
+			return
+		}
 
-		id := items.ItemDetail{
-			ID:              ad.ID,
-			Name:            ad.Name,
-			Category:        ad.Category,
-			PictureURL:      ad.PictureURL,
-			Details:         ad.Details,
-			Location:        ad.Location,
-			LastPerformedBy: strconv.Itoa(u.ID), // Overloaded because it contains the actual username in SearchItems
-			Quantity:        ad.Quantity,
-			Status:          "checked in",
+		if a.pictureChanged(u, ad, overwrite) {
+			ad.PictureURL = a.picturesService.CacheURL(u.OrgID, ad.PictureURL)
 		}
 
-		err = a.itemsService.AddItem(id, overwrite)
-		if err != nil && err == items.ItemAlreadyExistsErr {
+		item := ad.item(u)
+		item.Category, item.Location = ad.Category, ad.Location
+		err = a.itemsService.AddItem(u.OrgID, item, overwrite, u.ID)
+		if err != nil && err == barcode.InvalidCheckDigitErr {
+			responses.SendError(w, responses.InvalidParamError("id", err))
+			return
+		} else if err != nil && err == items.ItemAlreadyExistsErr {
 			responses.SendError(w, responses.ItemAlreadyExists(err))
 			return
+		} else if err != nil && err == items.ItemNotFoundErr {
+			responses.SendError(w, responses.ItemNotFound(err))
+			return
+		} else if err != nil && err == items.QuantityBelowCheckedOutErr {
+			responses.SendError(w, responses.NotEnoughQuantity(err))
+			return
+		} else if err != nil && err == items.NegativeQuantityErr {
+			responses.SendError(w, responses.InvalidParamError("quantity", err))
+			return
+		} else if err != nil && err == items.LocationNotFoundErr {
+			responses.SendError(w, responses.LocationNotFound(err))
+			return
+		} else if err != nil && err == items.CategoryNotFoundErr {
+			responses.SendError(w, responses.CategoryNotFound(err))
+			return
+		} else if fe, ok := err.(items.FieldError); ok {
+			responses.SendError(w, responses.InvalidParamError("fields", fe))
+			return
 		} else if err != nil {
 			responses.SendError(w, responses.InternalError(err))
 			return
@@ -105,6 +306,53 @@ func (a *API) AddItem(u users.User) http.Handler {
 	})
 }
 
+// createForbiddenErr is sent when adding items would create categories or
+// locations that the user cannot create directly
+var createForbiddenErr = fmt.Errorf("you need the %s permission to create categories and locations", users.PermItemEdit)
+
+// canAddWithoutCreating returns whether the category and location of the
+// body already exist, sending an error if they do not
+func (a *API) canAddWithoutCreating(w http.ResponseWriter, u users.User, ad AddBody) bool {
+	if ad.CategoryID == 0 {
+		_, err := a.itemsService.FindCategory(u.OrgID, ad.Category)
+		if err != nil && err == items.CategoryNotFoundErr {
+			responses.SendError(w, responses.Forbidden(createForbiddenErr))
+			return false
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return false
+		}
+	}
+
+	if ad.LocationID == 0 && strings.TrimSpace(ad.Location) != "" {
+		_, err := a.itemsService.FindLocation(u.OrgID, ad.Location)
+		if err != nil && err == items.LocationNotFoundErr {
+			responses.SendError(w, responses.Forbidden(createForbiddenErr))
+			return false
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return false
+		}
+	}
+
+	return true
+}
+
+// pictureChanged returns whether the picture of the item needs to be copied.
+// Overwriting an item sends its picture again, which is only fetched when it
+// is not the one the item already has.
+func (a *API) pictureChanged(u users.User, ad AddBody, overwrite bool) bool {
+	if ad.PictureURL == "" || !a.picturesService.CachesRemote() {
+		return false
+	}
+	if !overwrite {
+		return true
+	}
+
+	list, err := a.itemsService.FindItems(u.OrgID, []string{ad.ID})
+	return err != nil || list[0].PictureURL != ad.PictureURL
+}
+
 func (a *API) MoveItem(u users.User) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		mb := MoveBody{}
@@ -119,10 +367,65 @@ func (a *API) MoveItem(u users.User) http.Handler {
 			return
 		}
 
-		err = a.itemsService.MoveItem(mb.ID, mb.Direction, u.ID)
+		if mb.Quantity < 0 {
+			responses.SendError(w, responses.InvalidParamError("quantity", errors.New("must not be negative")))
+			return
+		}
+
+		if mb.Direction != "in" && mb.Direction != "out" {
+			responses.SendError(w, responses.InvalidParamError("direction", errors.New("must be in or out")))
+			return
+		}
+
+		// Scanned barcodes find the item whichever form it was stored under
+		mb.ID, err = a.itemsService.ResolveID(u.OrgID, mb.ID)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		var loan items.Loan
+		if mb.Direction == "out" {
+			loan = items.Loan{
+				ItemID:   mb.ID,
+				Quantity: mb.Quantity,
+				Borrower: mb.ExternalBorrower,
+				DueAt:    mb.DueAt,
+				Notes:    mb.Notes,
+			}
+
+			if mb.Borrower != "" || mb.ExternalBorrower == "" {
+				borrower := u
+				if mb.Borrower != "" {
+					borrower, err = a.userService.CheckUserByUsername(mb.Borrower, u.OrgID)
+					if err != nil {
+						responses.SendError(w, responses.InternalError(err))
+						return
+					}
+					if !borrower.Valid || borrower.Role == "" {
+						responses.SendError(w, responses.UserNotFound(users.UserNotFoundErr))
+						return
+					}
+				}
+
+				loan.BorrowerUserID = borrower.ID
+				loan.Borrower = borrower.Username
+			}
+
+			_, err = a.itemsService.CheckOut(u.OrgID, loan, u.ID)
+		} else {
+			err = a.itemsService.CheckIn(u.OrgID, mb.ID, mb.LoanID, mb.Quantity, u.ID)
+		}
+
 		if err != nil && err == items.ItemNotFoundErr {
 			responses.SendError(w, responses.ItemNotFound(err))
 			return
+		} else if err != nil && err == items.LoanNotFoundErr {
+			responses.SendError(w, responses.LoanNotFound(err))
+			return
+		} else if err != nil && (err == items.NotEnoughAvailableErr || err == items.NotEnoughCheckedOutErr) {
+			responses.SendError(w, responses.NotEnoughQuantity(err))
+			return
 		} else if err != nil {
 			responses.SendError(w, responses.InternalError(err))
 			return
@@ -9,6 +9,7 @@ import (
 
 	"github.com/Timothylock/inventory-management/items"
 	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/users"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
@@ -37,7 +38,8 @@ func TestMoveItem(t *testing.T) {
 		{
 			testName: "success",
 			setMock: func(ip *items.MockPersister) {
-				ip.EXPECT().MoveItem("1234", "in", 123).Return(nil)
+				ip.EXPECT().MoveItem(1, "1234", "in", 0, 123).Return(4, nil)
+				ip.EXPECT().ReturnLoans(1, "1234", 0, 4).Return(nil)
 			},
 			expectCode:       200,
 			expectedResponse: responses.Success{Success: true},
@@ -46,10 +48,25 @@ func TestMoveItem(t *testing.T) {
 				Direction: "in",
 			},
 		},
+		{
+			testName: "scanned barcode finds the item under another form",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"00036000291452", "0036000291452", "036000291452"}, Deleted: true, SortBy: "id"}).
+					Return(items.ItemDetailList{{ID: "036000291452"}}, 1, nil)
+				ip.EXPECT().MoveItem(1, "036000291452", "in", 0, 123).Return(1, nil)
+				ip.EXPECT().ReturnLoans(1, "036000291452", 0, 1).Return(nil)
+			},
+			expectCode:       200,
+			expectedResponse: responses.Success{Success: true},
+			body: MoveBody{
+				ID:        "0036000291452",
+				Direction: "in",
+			},
+		},
 		{
 			testName: "internal error",
 			setMock: func(ip *items.MockPersister) {
-				ip.EXPECT().MoveItem("1234", "in", 123).Return(errors.New("sorry"))
+				ip.EXPECT().MoveItem(1, "1234", "in", 0, 123).Return(0, errors.New("sorry"))
 			},
 			expectCode: 500,
 			body: MoveBody{
@@ -60,7 +77,7 @@ func TestMoveItem(t *testing.T) {
 		{
 			testName: "item not found error",
 			setMock: func(ip *items.MockPersister) {
-				ip.EXPECT().MoveItem("1234", "in", 123).Return(items.ItemNotFoundErr)
+				ip.EXPECT().MoveItem(1, "1234", "in", 0, 123).Return(0, items.ItemNotFoundErr)
 			},
 			expectCode: 404,
 			body: MoveBody{
@@ -68,6 +85,63 @@ func TestMoveItem(t *testing.T) {
 				Direction: "in",
 			},
 		},
+		{
+			testName: "partial quantity",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().MoveItem(1, "1234", "out", 3, 123).Return(3, nil)
+				ip.EXPECT().AddLoan(1, items.Loan{ItemID: "1234", Quantity: 3, BorrowerUserID: 123}, 123).Return(items.Loan{ID: 1}, nil)
+			},
+			expectCode:       200,
+			expectedResponse: responses.Success{Success: true},
+			body: MoveBody{
+				ID:        "1234",
+				Direction: "out",
+				Quantity:  3,
+			},
+		},
+		{
+			testName: "not enough available",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().MoveItem(1, "1234", "out", 11, 123).Return(0, items.NotEnoughAvailableErr)
+			},
+			expectCode: 409,
+			body: MoveBody{
+				ID:        "1234",
+				Direction: "out",
+				Quantity:  11,
+			},
+		},
+		{
+			testName: "not enough checked out",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().MoveItem(1, "1234", "in", 2, 123).Return(0, items.NotEnoughCheckedOutErr)
+			},
+			expectCode: 409,
+			body: MoveBody{
+				ID:        "1234",
+				Direction: "in",
+				Quantity:  2,
+			},
+		},
+		{
+			testName:   "negative quantity",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+			body: MoveBody{
+				ID:        "1234",
+				Direction: "out",
+				Quantity:  -1,
+			},
+		},
+		{
+			testName:   "invalid direction",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+			body: MoveBody{
+				ID:        "1234",
+				Direction: "foo",
+			},
+		},
 		{
 			testName:   "missing id in body",
 			setMock:    func(ip *items.MockPersister) {},
@@ -94,6 +168,9 @@ func TestMoveItem(t *testing.T) {
 			defer mc.Finish()
 
 			ip := items.NewMockPersister(mc)
+			ip.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(items.Persister) error) error {
+				return fn(ip)
+			}).AnyTimes()
 			tc.setMock(ip)
 
 			server := setupServerAuthenticated(ip, t)
@@ -138,7 +215,7 @@ func TestDeleteItem(t *testing.T) {
 		{
 			testName: "success",
 			setMock: func(ip *items.MockPersister) {
-				ip.EXPECT().DeleteItem("1", 123).Return(nil)
+				ip.EXPECT().DeleteItem(1, "1", 123).Return(nil)
 			},
 			expectCode:       200,
 			expectedResponse: responses.Success{Success: true},
@@ -153,7 +230,7 @@ func TestDeleteItem(t *testing.T) {
 		{
 			testName: "internal error",
 			setMock: func(ip *items.MockPersister) {
-				ip.EXPECT().DeleteItem("1", 123).Return(errors.New("oops"))
+				ip.EXPECT().DeleteItem(1, "1", 123).Return(errors.New("oops"))
 			},
 			expectCode: 500,
 			id:         "1",
@@ -161,7 +238,7 @@ func TestDeleteItem(t *testing.T) {
 		{
 			testName: "item not found",
 			setMock: func(ip *items.MockPersister) {
-				ip.EXPECT().DeleteItem("1", 123).Return(items.ItemNotFoundErr)
+				ip.EXPECT().DeleteItem(1, "1", 123).Return(items.ItemNotFoundErr)
 			},
 			expectCode: 404,
 			id:         "1",
@@ -201,77 +278,144 @@ func TestDeleteItem(t *testing.T) {
 func TestSearchItems(t *testing.T) {
 	type testCase struct {
 		testName         string
+		url              string
 		setMock          func(*items.MockPersister)
 		expectCode       int
-		expectedResponse items.ItemDetailList
+		expectedResponse items.ItemPage
 	}
 
+	found := items.ItemDetailList{
+		{
+			ID:              "1",
+			Name:            "foo",
+			Category:        "fi",
+			PictureURL:      "bar",
+			Details:         "fum",
+			Location:        "bah",
+			LastPerformedBy: "humbug",
+			Quantity:        3,
+			Status:          "checked in",
+		},
+		{
+			ID:              "2",
+			Name:            "foo",
+			Category:        "fi",
+			PictureURL:      "bar",
+			Details:         "fum",
+			Location:        "bah",
+			LastPerformedBy: "humbug",
+			Quantity:        1,
+			Status:          "checked in",
+		},
+	}
+
+	byName := items.ItemCursor{Sort: "name", Value: "foo", ID: "1"}.String()
+
 	testCases := []testCase{
 		{
 			testName: "success",
+			url:      "/api/item/info?q=foo",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{Search: "foo", SortBy: "name", Limit: 51}).Return(found, 2, nil)
+			},
+			expectCode:       200,
+			expectedResponse: items.ItemPage{Items: found, Total: 2},
+		},
+		{
+			testName: "barcode matches every form of it along with its text",
+			url:      "/api/item/info?q=0-036000-29145-2",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{Search: "0-036000-29145-2", SearchIDs: []string{"00036000291452", "0036000291452", "036000291452"}, SortBy: "name", Limit: 51}).Return(found[:1], 1, nil)
+			},
+			expectCode:       200,
+			expectedResponse: items.ItemPage{Items: found[:1], Total: 1},
+		},
+		{
+			testName: "filters",
+			url:      "/api/item/info?status=checked%20out&category=FI&location=bah%20%3E%20shelf&qtyLt=5&sort=-quantity&limit=1",
 			setMock: func(ip *items.MockPersister) {
-				ip.EXPECT().SearchItems("foo").Return(items.ItemDetailList{
-					{
-						ID:              "1",
-						Name:            "foo",
-						Category:        "fi",
-						PictureURL:      "bar",
-						Details:         "fum",
-						Location:        "bah",
-						LastPerformedBy: "humbug",
-						Quantity:        1,
-						Status:          "checked in",
-					},
-					{
-						ID:              "2",
-						Name:            "foo",
-						Category:        "fi",
-						PictureURL:      "bar",
-						Details:         "fum",
-						Location:        "bah",
-						LastPerformedBy: "humbug",
-						Quantity:        1,
-						Status:          "checked in",
-					},
-				}, nil)
+				ip.EXPECT().GetCategories(1).Return(items.Categories{{ID: 4, Name: "fi"}}, nil)
+				ip.EXPECT().GetLocations(1).Return(items.Locations{{ID: 7, Name: "bah"}, {ID: 8, ParentID: 7, Name: "Shelf"}}, nil)
+				ip.EXPECT().SearchItems(1, items.ItemFilter{
+					Status:        "checked out",
+					CategoryID:    4,
+					LocationID:    8,
+					QuantityBelow: 5,
+					SortBy:        "quantity",
+					Descending:    true,
+					Limit:         2,
+				}).Return(found, 7, nil)
 			},
 			expectCode: 200,
-			expectedResponse: items.ItemDetailList{
-				{
-					ID:              "1",
-					Name:            "foo",
-					Category:        "fi",
-					PictureURL:      "bar",
-					Details:         "fum",
-					Location:        "bah",
-					LastPerformedBy: "humbug",
-					Quantity:        1,
-					Status:          "checked in",
-				},
-				{
-					ID:              "2",
-					Name:            "foo",
-					Category:        "fi",
-					PictureURL:      "bar",
-					Details:         "fum",
-					Location:        "bah",
-					LastPerformedBy: "humbug",
-					Quantity:        1,
-					Status:          "checked in",
-				},
+			expectedResponse: items.ItemPage{
+				Items:      found[:1],
+				Total:      7,
+				NextCursor: items.ItemCursor{Sort: "-quantity", Value: 3, ID: "1"}.String(),
 			},
 		},
 		{
-			testName: "error",
+			testName: "next page",
+			url:      "/api/item/info?q=foo&limit=1&cursor=" + byName,
 			setMock: func(ip *items.MockPersister) {
-				ip.EXPECT().SearchItems("foo").Return(nil, errors.New("some error"))
+				ip.EXPECT().SearchItems(1, items.ItemFilter{
+					Search: "foo",
+					SortBy: "name",
+					After:  &items.ItemCursor{Sort: "name", Value: "foo", ID: "1"},
+					Limit:  2,
+				}).Return(found[1:], 2, nil)
 			},
-			expectCode: 500,
+			expectCode:       200,
+			expectedResponse: items.ItemPage{Items: found[1:], Total: 2},
+		},
+		{
+			testName:   "cursor of another sort",
+			url:        "/api/item/info?q=foo&sort=id&cursor=" + byName,
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "invalid cursor",
+			url:        "/api/item/info?q=foo&cursor=nope",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "invalid sort",
+			url:        "/api/item/info?q=foo&sort=colour",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "relevance without the index",
+			url:        "/api/item/info?q=foo&sort=relevance",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "invalid status",
+			url:        "/api/item/info?status=lost",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "invalid quantity",
+			url:        "/api/item/info?qtyLt=0",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName: "category not found",
+			url:      "/api/item/info?category=food",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetCategories(1).Return(items.Categories{}, nil)
+			},
+			expectCode: 404,
 		},
 		{
 			testName: "error",
+			url:      "/api/item/info?q=foo",
 			setMock: func(ip *items.MockPersister) {
-				ip.EXPECT().SearchItems("foo").Return(nil, errors.New("some error"))
+				ip.EXPECT().SearchItems(1, gomock.Any()).Return(nil, 0, errors.New("some error"))
 			},
 			expectCode: 500,
 		},
@@ -288,7 +432,7 @@ func TestSearchItems(t *testing.T) {
 			server := setupServerAuthenticated(ip, t)
 			defer server.Close()
 
-			resp, err := sendGet(server.URL + "/api/item/info?q=foo")
+			resp, err := sendGet(server.URL + tc.url)
 			assert.NoError(t, err)
 			assert.Equal(t, tc.expectCode, resp.StatusCode)
 
@@ -314,6 +458,135 @@ func TestSearchItemsMissingQuery(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 }
 
+func TestListItems(t *testing.T) {
+	type testCase struct {
+		testName   string
+		url        string
+		setMock    func(*items.MockPersister)
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "everything",
+			url:      "/api/items",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{SortBy: "name", Limit: 51}).Return(items.ItemDetailList{{ID: "1"}}, 1, nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "sorted and filtered",
+			url:      "/api/items?category=4&sort=-available&limit=10",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{CategoryID: 4, SortBy: "available", Descending: true, Limit: 11}).Return(items.ItemDetailList{}, 0, nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName:   "invalid limit",
+			url:        "/api/items?limit=lots",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName: "error",
+			url:      "/api/items",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, gomock.Any()).Return(nil, 0, errors.New("some error"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendGet(server.URL + tc.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestSummarizeItems(t *testing.T) {
+	type testCase struct {
+		testName         string
+		url              string
+		setMock          func(*items.MockPersister)
+		expectCode       int
+		expectedResponse items.ItemGroups
+	}
+
+	groups := items.ItemGroups{
+		{Name: "checked in", Items: 3, Quantity: 12},
+		{Name: "checked out", Items: 1, Quantity: 1},
+	}
+
+	testCases := []testCase{
+		{
+			testName: "by status",
+			url:      "/api/items/summary?by=status&location=7",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GroupItems(1, "status", items.ItemFilter{LocationID: 7}).Return(groups, nil)
+			},
+			expectCode:       200,
+			expectedResponse: groups,
+		},
+		{
+			testName:   "missing by",
+			url:        "/api/items/summary",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "invalid by",
+			url:        "/api/items/summary?by=colour",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName: "error",
+			url:      "/api/items/summary?by=category",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GroupItems(1, "category", items.ItemFilter{}).Return(nil, errors.New("some error"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendGet(server.URL + tc.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				b, err := json.Marshal(tc.expectedResponse)
+				assert.NoError(t, err)
+				assert.JSONEq(t, string(b), string(getBody(t, resp)))
+			}
+		})
+	}
+}
+
 func TestAddItem(t *testing.T) {
 	type testCase struct {
 		testName   string
@@ -326,15 +599,19 @@ func TestAddItem(t *testing.T) {
 		{
 			testName: "success",
 			setMock: func(ip *items.MockPersister) {
-				ip.EXPECT().AddItem(items.ItemDetail{
+				ip.EXPECT().GetLocations(1).Return(items.Locations{{ID: 7, Name: "bah", Path: "bah"}}, nil)
+				ip.EXPECT().AddItem(1, items.ItemDetail{
 					ID:              "1",
 					Name:            "foo",
-					Category:        "fi",
+					CategoryID:      3,
 					PictureURL:      "bar",
 					Details:         "fum",
-					Location:        "bah",
+					LocationID:      7,
 					Quantity:        1,
+					Category:        "fi",
+					Location:        "bah",
 					LastPerformedBy: "123",
+					Fields:          map[string]string{"Serial": "A1"},
 					Status:          "checked in",
 				}, false).Return(nil)
 			},
@@ -344,6 +621,7 @@ func TestAddItem(t *testing.T) {
 				Category:   "fi",
 				PictureURL: "bar",
 				Details:    "fum",
+				Fields:     map[string]string{"serial": "A1"},
 				Location:   "bah",
 				Quantity:   1,
 			},
@@ -364,17 +642,199 @@ func TestAddItem(t *testing.T) {
 			expectCode: 400,
 		},
 		{
-			testName: "item already exists",
+			testName: "new location",
 			setMock: func(ip *items.MockPersister) {
-				ip.EXPECT().AddItem(items.ItemDetail{
+				ip.EXPECT().GetLocations(1).Return(items.Locations{{ID: 7, Name: "Shed", Path: "Shed"}}, nil)
+				ip.EXPECT().AddLocation(1, items.Location{ParentID: 7, Name: "Shelf 1"}, 123).Return(items.Location{ID: 8, ParentID: 7, Name: "Shelf 1"}, nil)
+				ip.EXPECT().AddItem(1, items.ItemDetail{
 					ID:              "1",
 					Name:            "foo",
+					CategoryID:      3,
+					LocationID:      8,
+					Quantity:        1,
 					Category:        "fi",
+					Location:        "shed > Shelf 1",
+					LastPerformedBy: "123",
+					Fields:          map[string]string{},
+					Status:          "checked in",
+				}, false).Return(nil)
+			},
+			sendBody: AddBody{
+				ID:       "1",
+				Name:     "foo",
+				Category: "fi",
+				Location: "shed > Shelf 1",
+				Quantity: 1,
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "location not found",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().AddItem(1, gomock.Any(), false).Return(items.LocationNotFoundErr)
+			},
+			sendBody: AddBody{
+				ID:         "1",
+				Name:       "foo",
+				Category:   "fi",
+				LocationID: 99,
+				Quantity:   1,
+			},
+			expectCode: 404,
+		},
+		{
+			testName: "new category",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetCategories(1).Return(items.Categories{}, nil)
+				ip.EXPECT().AddCategory(1, items.Category{Name: "Tools", Fields: []items.Field{}}, 123).Return(items.Category{ID: 4, Name: "Tools"}, nil)
+				ip.EXPECT().GetCategories(1).Return(items.Categories{{ID: 4, Name: "Tools"}}, nil)
+				ip.EXPECT().AddItem(1, items.ItemDetail{
+					ID:              "1",
+					Name:            "foo",
+					CategoryID:      4,
+					Quantity:        1,
+					Category:        " Tools ",
+					LastPerformedBy: "123",
+					Fields:          map[string]string{},
+					Status:          "checked in",
+				}, false).Return(nil)
+			},
+			sendBody: AddBody{
+				ID:       "1",
+				Name:     "foo",
+				Category: " Tools ",
+				Quantity: 1,
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "barcodes are stored as their GTIN",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"00036000291452", "0036000291452", "036000291452"}, Deleted: true, SortBy: "id"}).
+					Return(items.ItemDetailList{}, 0, nil)
+				ip.EXPECT().AddItem(1, items.ItemDetail{
+					ID:              "00036000291452",
+					Name:            "foo",
+					CategoryID:      3,
+					Quantity:        1,
+					LastPerformedBy: "123",
+					Fields:          map[string]string{},
+					Status:          "checked in",
+				}, false).Return(nil)
+			},
+			sendBody: AddBody{
+				ID:         "036000291452",
+				Name:       "foo",
+				CategoryID: 3,
+				Quantity:   1,
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "barcodes are matched with the item stored under another form",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"00036000291452", "0036000291452", "036000291452"}, Deleted: true, SortBy: "id"}).
+					Return(items.ItemDetailList{{ID: "036000291452"}}, 1, nil)
+				ip.EXPECT().AddItem(1, items.ItemDetail{
+					ID:              "036000291452",
+					Name:            "foo",
+					CategoryID:      3,
+					Quantity:        1,
+					LastPerformedBy: "123",
+					Fields:          map[string]string{},
+					Status:          "checked in",
+				}, false).Return(items.ItemAlreadyExistsErr)
+			},
+			sendBody: AddBody{
+				ID:         "0036000291452",
+				Name:       "foo",
+				CategoryID: 3,
+				Quantity:   1,
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "barcode with the wrong check digit",
+			setMock:  func(ip *items.MockPersister) {},
+			sendBody: AddBody{
+				ID:         "036000291453",
+				Name:       "foo",
+				CategoryID: 3,
+				Quantity:   1,
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "negative quantity",
+			setMock:  func(ip *items.MockPersister) {},
+			sendBody: AddBody{
+				ID:         "1",
+				Name:       "foo",
+				CategoryID: 3,
+				Quantity:   -2,
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "category not found",
+			setMock:  func(ip *items.MockPersister) {},
+			sendBody: AddBody{
+				ID:         "1",
+				Name:       "foo",
+				CategoryID: 99,
+				Quantity:   1,
+			},
+			expectCode: 404,
+		},
+		{
+			testName: "invalid field",
+			setMock:  func(ip *items.MockPersister) {},
+			sendBody: AddBody{
+				ID:         "1",
+				Name:       "foo",
+				CategoryID: 3,
+				Fields:     map[string]string{"Voltage": "230"},
+				Quantity:   1,
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "invalid date",
+			setMock:  func(ip *items.MockPersister) {},
+			sendBody: AddBody{
+				ID:         "1",
+				Name:       "foo",
+				CategoryID: 5,
+				Fields:     map[string]string{"Expiry": "tomorrow"},
+				Quantity:   1,
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "missing required field",
+			setMock:  func(ip *items.MockPersister) {},
+			sendBody: AddBody{
+				ID:         "1",
+				Name:       "foo",
+				CategoryID: 5,
+				Quantity:   1,
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "item already exists",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().AddItem(1, items.ItemDetail{
+					ID:              "1",
+					Name:            "foo",
+					CategoryID:      3,
 					PictureURL:      "bar",
 					Details:         "fum",
-					Location:        "bah",
+					LocationID:      7,
 					Quantity:        1,
+					Category:        "fi",
 					LastPerformedBy: "123",
+					Fields:          map[string]string{},
 					Status:          "checked in",
 				}, false).Return(items.ItemAlreadyExistsErr)
 			},
@@ -384,7 +844,7 @@ func TestAddItem(t *testing.T) {
 				Category:   "fi",
 				PictureURL: "bar",
 				Details:    "fum",
-				Location:   "bah",
+				LocationID: 7,
 				Quantity:   1,
 			},
 			expectCode: 400,
@@ -392,15 +852,17 @@ func TestAddItem(t *testing.T) {
 		{
 			testName: "internal error",
 			setMock: func(ip *items.MockPersister) {
-				ip.EXPECT().AddItem(items.ItemDetail{
+				ip.EXPECT().AddItem(1, items.ItemDetail{
 					ID:              "1",
 					Name:            "foo",
-					Category:        "fi",
+					CategoryID:      3,
 					PictureURL:      "bar",
 					Details:         "fum",
-					Location:        "bah",
+					LocationID:      7,
 					Quantity:        1,
+					Category:        "fi",
 					LastPerformedBy: "123",
+					Fields:          map[string]string{},
 					Status:          "checked in",
 				}, false).Return(errors.New("sorry"))
 			},
@@ -410,7 +872,7 @@ func TestAddItem(t *testing.T) {
 				Category:   "fi",
 				PictureURL: "bar",
 				Details:    "fum",
-				Location:   "bah",
+				LocationID: 7,
 				Quantity:   1,
 			},
 			expectCode: 500,
@@ -423,7 +885,15 @@ func TestAddItem(t *testing.T) {
 			defer mc.Finish()
 
 			ip := items.NewMockPersister(mc)
+			ip.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(items.Persister) error) error {
+				return fn(ip)
+			}).AnyTimes()
 			tc.setMock(ip)
+			ip.EXPECT().PurgeItem(1, gomock.Any(), 123).Return(nil, items.ItemNotFoundErr).AnyTimes()
+			ip.EXPECT().GetCategories(1).Return(items.Categories{
+				{ID: 3, Name: "fi", Fields: []items.Field{{Name: "Serial", Type: items.FieldText}}},
+				{ID: 5, Name: "Food", Fields: []items.Field{{Name: "Expiry", Type: items.FieldDate, Required: true}}},
+			}, nil).AnyTimes()
 
 			server := setupServerAuthenticated(ip, t)
 			defer server.Close()
@@ -441,6 +911,66 @@ func TestAddItem(t *testing.T) {
 	}
 }
 
+func TestAddItemWithoutEditPermission(t *testing.T) {
+	adder := users.User{Valid: true, ID: 123, OrgID: 1, Permissions: []string{users.PermItemAdd}}
+
+	type testCase struct {
+		testName   string
+		setMock    func(*items.MockPersister)
+		sendBody   AddBody
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "existing category and location",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(items.Persister) error) error {
+					return fn(ip)
+				})
+				ip.EXPECT().PurgeItem(1, "1", 123).Return(nil, items.ItemNotFoundErr)
+				ip.EXPECT().AddItem(1, gomock.Any(), false).Return(nil)
+			},
+			sendBody:   AddBody{ID: "1", Name: "foo", Category: "Fi", Location: "shed", Quantity: 1},
+			expectCode: 200,
+		},
+		{
+			testName:   "new category",
+			setMock:    func(ip *items.MockPersister) {},
+			sendBody:   AddBody{ID: "1", Name: "foo", Category: "Tools", Quantity: 1},
+			expectCode: 403,
+		},
+		{
+			testName:   "new location",
+			setMock:    func(ip *items.MockPersister) {},
+			sendBody:   AddBody{ID: "1", Name: "foo", Category: "fi", Location: "Shed > Shelf 1", Quantity: 1},
+			expectCode: 403,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			ip.EXPECT().GetCategories(1).Return(items.Categories{{ID: 3, Name: "fi"}}, nil).AnyTimes()
+			ip.EXPECT().GetLocations(1).Return(items.Locations{{ID: 7, Name: "Shed", Path: "Shed"}}, nil).AnyTimes()
+			tc.setMock(ip)
+
+			up := users.NewMockPersister(mc)
+			up.EXPECT().GetUserByToken(gomock.Any()).Return(adder, nil).AnyTimes()
+
+			server := setupServer(ip, up, t)
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+"/api/item", tc.sendBody)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
+
 func TestAddItemBadBody(t *testing.T) {
 	mc := gomock.NewController(t)
 	defer mc.Finish()
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/labels"
+	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+// Resolution of PNG labels in dots per inch
+const (
+	defaultLabelDPI = 300
+	maxLabelDPI     = 600
+)
+
+// maxSheetLabels is the most labels that can be printed at once, counting
+// every copy
+const maxSheetLabels = 1000
+
+type LabelSheetBody struct {
+	IDs []string `json:"ids"`
+	// Template is the Avery product number of the sheet
+	Template string `json:"template"`
+	// Type is code128 or qr and defaults to code128
+	Type string `json:"type"`
+	// Skip leaves the first places of the first sheet blank so that partly used
+	// sheets can be printed on
+	Skip int `json:"skip"`
+	// Copies of each label, which are printed next to each other. Defaults to 1.
+	Copies int `json:"copies"`
+}
+
+type ReservedID struct {
+	ID string `json:"id"`
+}
+
+// ReserveItemID returns a new ID for an item that has no barcode of its own
+func (a *API) ReserveItemID(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := a.itemsService.ReserveItemID(u.OrgID)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(ReservedID{ID: id}, w)
+	})
+}
+
+// FetchLabelTemplates returns the sheets labels can be printed on
+func (a *API) FetchLabelTemplates(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sendJSONorErr(labels.AveryTemplates, w)
+	})
+}
+
+// FetchItemLabel renders the label of one item the size of a label of the
+// template, as a PNG unless another format is asked for
+func (a *API) FetchItemLabel(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getRequiredParam(r, "id")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("id"))
+			return
+		}
+
+		format := strings.ToLower(getOptionalParam(r, "format"))
+		if format == "" {
+			format = labels.PNGFormat
+		}
+		contentType, ok := labels.ContentTypes[format]
+		if !ok {
+			responses.SendError(w, responses.InvalidParamError("format", errors.New("must be one of png, svg or pdf")))
+			return
+		}
+
+		dpi := defaultLabelDPI
+		if d := getOptionalParam(r, "dpi"); d != "" {
+			dpi, err = strconv.Atoi(d)
+			if err != nil || dpi < 72 || dpi > maxLabelDPI {
+				responses.SendError(w, responses.InvalidParamError("dpi", fmt.Errorf("must be a number from 72 to %d", maxLabelDPI)))
+				return
+			}
+		}
+
+		tmpl, ok := getLabelTemplate(w, getOptionalParam(r, "template"))
+		if !ok {
+			return
+		}
+
+		list, err := a.itemsService.FindItems(u.OrgID, []string{id})
+		if err != nil && err == items.ItemNotFoundErr {
+			responses.SendError(w, responses.ItemNotFound(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		d, err := labels.Render(itemLabel(list[0], getOptionalParam(r, "type")), tmpl.Width, tmpl.Height)
+		if err != nil {
+			sendLabelError(w, err, "id")
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		switch format {
+		case labels.SVGFormat:
+			d.WriteSVG(w)
+		case labels.PDFFormat:
+			labels.WritePDF(w, []*labels.Drawing{d})
+		default:
+			d.WritePNG(w, dpi)
+		}
+	})
+}
+
+// PrintLabels lays out the labels of the items on sheets of the template and
+// sends them as a PDF
+func (a *API) PrintLabels(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := LabelSheetBody{}
+		if err := parseBody(r, &body); err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		if len(body.IDs) == 0 {
+			responses.SendError(w, responses.MissingParamError("ids"))
+			return
+		}
+		if body.Template == "" {
+			responses.SendError(w, responses.MissingParamError("template"))
+			return
+		}
+		tmpl, ok := getLabelTemplate(w, body.Template)
+		if !ok {
+			return
+		}
+
+		if body.Skip < 0 || body.Skip >= tmpl.PerSheet() {
+			responses.SendError(w, responses.InvalidParamError("skip", fmt.Errorf("must be from 0 to %d", tmpl.PerSheet()-1)))
+			return
+		}
+		if body.Copies == 0 {
+			body.Copies = 1
+		}
+		if body.Copies < 0 || len(body.IDs)*body.Copies > maxSheetLabels {
+			responses.SendError(w, responses.InvalidParamError("copies", fmt.Errorf("at most %d labels can be printed at once", maxSheetLabels)))
+			return
+		}
+
+		list, err := a.itemsService.FindItems(u.OrgID, body.IDs)
+		if err != nil && err == items.ItemNotFoundErr {
+			responses.SendError(w, responses.ItemNotFound(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		ls := []labels.Label{}
+		for _, item := range list {
+			for i := 0; i < body.Copies; i++ {
+				ls = append(ls, itemLabel(item, body.Type))
+			}
+		}
+
+		pages, err := labels.Sheets(tmpl, ls, body.Skip)
+		if err != nil {
+			sendLabelError(w, err, "ids")
+			return
+		}
+
+		w.Header().Set("Content-Type", labels.ContentTypes[labels.PDFFormat])
+		w.Header().Set("Content-Disposition", `attachment; filename="labels.pdf"`)
+		labels.WritePDF(w, pages)
+	})
+}
+
+// itemLabel returns the label of the item with the kind of code, which
+// defaults to code128
+func itemLabel(item items.ItemDetail, typ string) labels.Label {
+	typ = strings.ToLower(typ)
+	if typ == "" {
+		typ = labels.Code128Type
+	}
+	return labels.Label{Code: item.ID, Text: item.Name, Type: typ}
+}
+
+// getLabelTemplate returns the template with the name, or the default one if
+// it is blank. An error is sent if there is no such template.
+func getLabelTemplate(w http.ResponseWriter, name string) (labels.Template, bool) {
+	if name == "" {
+		name = labels.DefaultTemplate
+	}
+
+	tmpl, err := labels.AveryTemplates.Find(name)
+	if err != nil {
+		err = fmt.Errorf("must be one of %s", strings.Join(labels.AveryTemplates.Names(), ", "))
+		responses.SendError(w, responses.InvalidParamError("template", err))
+		return tmpl, false
+	}
+
+	return tmpl, true
+}
+
+// sendLabelError sends the error of a label that could not be drawn. IDs that
+// the code cannot hold are blamed on the param.
+func sendLabelError(w http.ResponseWriter, err error, param string) {
+	if err == labels.InvalidTypeErr {
+		responses.SendError(w, responses.InvalidParamError("type", err))
+	} else if err == labels.InvalidCode128Err || err == labels.QRTooLongErr {
+		responses.SendError(w, responses.InvalidParamError(param, err))
+	} else {
+		responses.SendError(w, responses.InternalError(err))
+	}
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserveItemID(t *testing.T) {
+	type testCase struct {
+		testName       string
+		setMock        func(*items.MockPersister)
+		expectCode     int
+		expectedOutput string
+	}
+
+	taken := items.ItemFilter{IDs: []string{"TL0007"}, Deleted: true, SortBy: items.SortID}
+	free := items.ItemFilter{IDs: []string{"TL0008"}, Deleted: true, SortBy: items.SortID}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().NextItemNumber(1).Return(8, nil)
+				ip.EXPECT().SearchItems(1, free).Return(items.ItemDetailList{}, 0, nil)
+			},
+			expectCode:     200,
+			expectedOutput: `{"id":"TL0008"}` + "\n",
+		},
+		{
+			testName: "taken IDs are skipped",
+			setMock: func(ip *items.MockPersister) {
+				gomock.InOrder(
+					ip.EXPECT().NextItemNumber(1).Return(7, nil),
+					ip.EXPECT().SearchItems(1, taken).Return(items.ItemDetailList{{ID: "TL0007"}}, 1, nil),
+					ip.EXPECT().NextItemNumber(1).Return(8, nil),
+					ip.EXPECT().SearchItems(1, free).Return(items.ItemDetailList{}, 0, nil),
+				)
+			},
+			expectCode:     200,
+			expectedOutput: `{"id":"TL0008"}` + "\n",
+		},
+		{
+			testName: "error",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().NextItemNumber(1).Return(0, errors.New("some error"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticatedWith(t, testDeps{items: ip, config: config.Config{ItemIdPrefix: "TL", ItemIdDigits: 4}})
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+"/api/item/id", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				assert.Equal(t, tc.expectedOutput, getBody(t, resp))
+			}
+		})
+	}
+}
+
+func TestFetchItemLabel(t *testing.T) {
+	type testCase struct {
+		testName     string
+		url          string
+		setMock      func(*items.MockPersister)
+		expectCode   int
+		expectedType string
+		expectedBody string
+	}
+
+	find := func(ip *items.MockPersister, list items.ItemDetailList) {
+		ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"INV000042"}, SortBy: items.SortID}).Return(list, len(list), nil)
+	}
+	cord := items.ItemDetailList{{ID: "INV000042", Name: "Extension cord"}}
+
+	testCases := []testCase{
+		{
+			testName:     "png by default",
+			url:          "/api/item/label?id=INV000042",
+			setMock:      func(ip *items.MockPersister) { find(ip, cord) },
+			expectCode:   200,
+			expectedType: "image/png",
+			expectedBody: "\x89PNG",
+		},
+		{
+			testName:     "svg qr code",
+			url:          "/api/item/label?id=INV000042&format=svg&type=qr&template=5163",
+			setMock:      func(ip *items.MockPersister) { find(ip, cord) },
+			expectCode:   200,
+			expectedType: "image/svg+xml",
+			expectedBody: `<svg xmlns="http://www.w3.org/2000/svg" width="288pt" height="144pt"`,
+		},
+		{
+			testName:     "pdf",
+			url:          "/api/item/label?id=INV000042&format=pdf",
+			setMock:      func(ip *items.MockPersister) { find(ip, cord) },
+			expectCode:   200,
+			expectedType: "application/pdf",
+			expectedBody: "%PDF-1.",
+		},
+		{
+			testName:   "missing id",
+			url:        "/api/item/label",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "invalid format",
+			url:        "/api/item/label?id=INV000042&format=gif",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "invalid dpi",
+			url:        "/api/item/label?id=INV000042&dpi=1200",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "unknown template",
+			url:        "/api/item/label?id=INV000042&template=1234",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "invalid type",
+			url:        "/api/item/label?id=INV000042&type=ean",
+			setMock:    func(ip *items.MockPersister) { find(ip, cord) },
+			expectCode: 400,
+		},
+		{
+			testName:   "item not found",
+			url:        "/api/item/label?id=INV000042",
+			setMock:    func(ip *items.MockPersister) { find(ip, items.ItemDetailList{}) },
+			expectCode: 404,
+		},
+		{
+			testName: "error",
+			url:      "/api/item/label?id=INV000042",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().SearchItems(1, gomock.Any()).Return(nil, 0, errors.New("some error"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendGet(server.URL + tc.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				assert.Equal(t, tc.expectedType, resp.Header.Get("Content-Type"))
+				assert.True(t, strings.HasPrefix(getBody(t, resp), tc.expectedBody))
+			}
+		})
+	}
+}
+
+func TestPrintLabels(t *testing.T) {
+	type testCase struct {
+		testName      string
+		body          LabelSheetBody
+		setMock       func(*items.MockPersister)
+		expectCode    int
+		expectedPages int
+	}
+
+	find := func(ip *items.MockPersister, ids []string, list items.ItemDetailList) {
+		ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: ids, SortBy: items.SortID}).Return(list, len(list), nil)
+	}
+	list := items.ItemDetailList{{ID: "1", Name: "Hammer"}, {ID: "2", Name: "Saw"}}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			body:     LabelSheetBody{IDs: []string{"2", "1"}, Template: "5160"},
+			setMock: func(ip *items.MockPersister) {
+				find(ip, []string{"2", "1"}, list)
+			},
+			expectCode:    200,
+			expectedPages: 1,
+		},
+		{
+			testName: "copies and skipped places run onto another sheet",
+			body:     LabelSheetBody{IDs: []string{"1", "2"}, Template: "L7651", Type: "qr", Skip: 60, Copies: 3},
+			setMock: func(ip *items.MockPersister) {
+				find(ip, []string{"1", "2"}, list)
+			},
+			expectCode:    200,
+			expectedPages: 2,
+		},
+		{
+			testName:   "missing ids",
+			body:       LabelSheetBody{Template: "5160"},
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "missing template",
+			body:       LabelSheetBody{IDs: []string{"1"}},
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "unknown template",
+			body:       LabelSheetBody{IDs: []string{"1"}, Template: "1234"},
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "skipping a whole sheet",
+			body:       LabelSheetBody{IDs: []string{"1"}, Template: "5160", Skip: 30},
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "too many copies",
+			body:       LabelSheetBody{IDs: []string{"1", "2"}, Template: "5160", Copies: 501},
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName: "item not found",
+			body:     LabelSheetBody{IDs: []string{"1", "3"}, Template: "5160"},
+			setMock: func(ip *items.MockPersister) {
+				find(ip, []string{"1", "3"}, list[:1])
+			},
+			expectCode: 404,
+		},
+		{
+			testName: "invalid type",
+			body:     LabelSheetBody{IDs: []string{"1"}, Template: "5160", Type: "ean"},
+			setMock: func(ip *items.MockPersister) {
+				find(ip, []string{"1"}, list[:1])
+			},
+			expectCode: 400,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+"/api/items/labels", tc.body)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+				body := getBody(t, resp)
+				assert.True(t, strings.HasPrefix(body, "%PDF-1."))
+				assert.Equal(t, tc.expectedPages, strings.Count(body, "/Type /Page\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+// FetchLoans returns the loans that have not been returned yet
+func (a *API) FetchLoans(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loans, err := a.itemsService.GetLoans(u.OrgID)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(loans, w)
+	})
+}
+
+// FetchOverdueLoans returns the open loans that are past their due date
+func (a *API) FetchOverdueLoans(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loans, err := a.itemsService.GetOverdueLoans(u.OrgID)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(loans, w)
+	})
+}
+
+// FetchBorrowerLoans returns every loan of a borrower, including returned ones
+func (a *API) FetchBorrowerLoans(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, err := getRequiredParam(r, "name")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("name"))
+			return
+		}
+
+		loans, err := a.itemsService.GetBorrowerLoans(u.OrgID, name)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(loans, w)
+	})
+}
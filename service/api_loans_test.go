@@ -0,0 +1,225 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/users"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchLoans(t *testing.T) {
+	past := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	future := time.Now().UTC().Add(24 * time.Hour)
+
+	open := items.Loans{
+		{ID: 1, ItemID: "1234", Quantity: 2, Borrower: "someUser", DueAt: &past},
+		{ID: 2, ItemID: "5678", Quantity: 1, Borrower: "Bob", DueAt: &future},
+		{ID: 3, ItemID: "5678", Quantity: 1, Borrower: "Bob"},
+	}
+
+	type testCase struct {
+		testName         string
+		url              string
+		setMock          func(*items.MockPersister)
+		expectCode       int
+		expectedResponse items.Loans
+	}
+
+	testCases := []testCase{
+		{
+			testName: "open loans",
+			url:      "/api/loans",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLoans(1, items.LoanFilter{OpenOnly: true}).Return(open, nil)
+			},
+			expectCode: 200,
+			expectedResponse: items.Loans{
+				{ID: 1, ItemID: "1234", Quantity: 2, Borrower: "someUser", DueAt: &past, Overdue: true},
+				{ID: 2, ItemID: "5678", Quantity: 1, Borrower: "Bob", DueAt: &future},
+				{ID: 3, ItemID: "5678", Quantity: 1, Borrower: "Bob"},
+			},
+		},
+		{
+			testName: "overdue loans",
+			url:      "/api/loans/overdue",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLoans(1, items.LoanFilter{OpenOnly: true}).Return(open, nil)
+			},
+			expectCode: 200,
+			expectedResponse: items.Loans{
+				{ID: 1, ItemID: "1234", Quantity: 2, Borrower: "someUser", DueAt: &past, Overdue: true},
+			},
+		},
+		{
+			testName: "borrower loans",
+			url:      "/api/loans/borrower?name=Bob",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLoans(1, items.LoanFilter{Borrower: "Bob"}).Return(items.Loans{
+					{ID: 4, ItemID: "5678", Quantity: 1, Borrower: "Bob", DueAt: &past, ReturnedAt: &past},
+				}, nil)
+			},
+			expectCode: 200,
+			expectedResponse: items.Loans{
+				{ID: 4, ItemID: "5678", Quantity: 1, Borrower: "Bob", DueAt: &past, ReturnedAt: &past},
+			},
+		},
+		{
+			testName:   "borrower missing",
+			url:        "/api/loans/borrower",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName: "internal error",
+			url:      "/api/loans",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLoans(1, items.LoanFilter{OpenOnly: true}).Return(nil, errors.New("sorry"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendGet(server.URL + tc.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				b, err := json.Marshal(tc.expectedResponse)
+				assert.NoError(t, err)
+				assert.JSONEq(t, string(b), string(getBody(t, resp)))
+			}
+		})
+	}
+}
+
+func TestMoveItemLoans(t *testing.T) {
+	due := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	mover := users.User{Valid: true, ID: 123, Username: "mover", OrgID: 1, Permissions: users.AllPermissions}
+
+	type testCase struct {
+		testName   string
+		setMock    func(*items.MockPersister, *users.MockPersister)
+		body       MoveBody
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "defaults to the current user",
+			setMock: func(ip *items.MockPersister, up *users.MockPersister) {
+				ip.EXPECT().MoveItem(1, "1234", "out", 0, 123).Return(5, nil)
+				ip.EXPECT().AddLoan(1, items.Loan{ItemID: "1234", Quantity: 5, BorrowerUserID: 123, Borrower: "mover"}, 123).Return(items.Loan{ID: 1}, nil)
+			},
+			body:       MoveBody{ID: "1234", Direction: "out"},
+			expectCode: 200,
+		},
+		{
+			testName: "member borrower",
+			setMock: func(ip *items.MockPersister, up *users.MockPersister) {
+				up.EXPECT().GetUserByUsername("someUser", 1).Return(users.User{Valid: true, ID: 456, Username: "someUser", Role: "viewer"}, nil)
+				ip.EXPECT().MoveItem(1, "1234", "out", 2, 123).Return(2, nil)
+				ip.EXPECT().AddLoan(1, items.Loan{ItemID: "1234", Quantity: 2, BorrowerUserID: 456, Borrower: "someUser", DueAt: &due, Notes: "for the demo"}, 123).Return(items.Loan{ID: 1}, nil)
+			},
+			body:       MoveBody{ID: "1234", Direction: "out", Quantity: 2, Borrower: "someUser", DueAt: &due, Notes: "for the demo"},
+			expectCode: 200,
+		},
+		{
+			testName: "borrower not a member",
+			setMock: func(ip *items.MockPersister, up *users.MockPersister) {
+				up.EXPECT().GetUserByUsername("someUser", 1).Return(users.User{Valid: true, ID: 456, Username: "someUser"}, nil)
+			},
+			body:       MoveBody{ID: "1234", Direction: "out", Borrower: "someUser"},
+			expectCode: 404,
+		},
+		{
+			testName: "external borrower",
+			setMock: func(ip *items.MockPersister, up *users.MockPersister) {
+				ip.EXPECT().MoveItem(1, "1234", "out", 1, 123).Return(1, nil)
+				ip.EXPECT().AddLoan(1, items.Loan{ItemID: "1234", Quantity: 1, Borrower: "Bob from next door"}, 123).Return(items.Loan{ID: 1}, nil)
+			},
+			body:       MoveBody{ID: "1234", Direction: "out", Quantity: 1, ExternalBorrower: "Bob from next door"},
+			expectCode: 200,
+		},
+		{
+			testName: "return loan",
+			setMock: func(ip *items.MockPersister, up *users.MockPersister) {
+				ip.EXPECT().GetLoan(1, 7).Return(items.Loan{ID: 7, ItemID: "1234", Quantity: 3, Returned: 1}, nil)
+				ip.EXPECT().MoveItem(1, "1234", "in", 2, 123).Return(2, nil)
+				ip.EXPECT().ReturnLoans(1, "1234", 7, 2).Return(nil)
+			},
+			body:       MoveBody{ID: "1234", Direction: "in", LoanID: 7},
+			expectCode: 200,
+		},
+		{
+			testName: "return more than was lent",
+			setMock: func(ip *items.MockPersister, up *users.MockPersister) {
+				ip.EXPECT().GetLoan(1, 7).Return(items.Loan{ID: 7, ItemID: "1234", Quantity: 3, Returned: 1}, nil)
+			},
+			body:       MoveBody{ID: "1234", Direction: "in", Quantity: 3, LoanID: 7},
+			expectCode: 409,
+		},
+		{
+			testName: "loan is not recorded",
+			setMock: func(ip *items.MockPersister, up *users.MockPersister) {
+				ip.EXPECT().MoveItem(1, "1234", "out", 1, 123).Return(1, nil)
+				ip.EXPECT().AddLoan(1, gomock.Any(), 123).Return(items.Loan{}, errors.New("sorry"))
+			},
+			body:       MoveBody{ID: "1234", Direction: "out", Quantity: 1},
+			expectCode: 500,
+		},
+		{
+			testName: "loan of another item",
+			setMock: func(ip *items.MockPersister, up *users.MockPersister) {
+				ip.EXPECT().GetLoan(1, 7).Return(items.Loan{ID: 7, ItemID: "5678", Quantity: 3}, nil)
+			},
+			body:       MoveBody{ID: "1234", Direction: "in", LoanID: 7},
+			expectCode: 404,
+		},
+		{
+			testName: "loan not found",
+			setMock: func(ip *items.MockPersister, up *users.MockPersister) {
+				ip.EXPECT().GetLoan(1, 7).Return(items.Loan{}, items.LoanNotFoundErr)
+			},
+			body:       MoveBody{ID: "1234", Direction: "in", LoanID: 7},
+			expectCode: 404,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			ip.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(items.Persister) error) error {
+				return fn(ip)
+			}).AnyTimes()
+			up := users.NewMockPersister(mc)
+			up.EXPECT().GetUserByToken(gomock.Any()).Return(mover, nil).AnyTimes()
+			tc.setMock(ip, up)
+
+			server := setupServer(ip, up, t)
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+"/api/item/move", tc.body)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+type LocationBody struct {
+	// ID is 0 to add a new location
+	ID       int    `json:"id"`
+	ParentID int    `json:"parentId"`
+	Name     string `json:"name"`
+}
+
+// FetchLocations returns every location of the org sorted by their path
+func (a *API) FetchLocations(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := a.itemsService.GetLocations(u.OrgID)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(res, w)
+	})
+}
+
+// SetLocation adds a location, or renames or moves an existing one
+func (a *API) SetLocation(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lb := LocationBody{}
+		err := parseBody(r, &lb)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		loc := items.Location{
+			ID:       lb.ID,
+			ParentID: lb.ParentID,
+			Name:     lb.Name,
+		}
+
+		if loc.ID == 0 {
+			loc, err = a.itemsService.AddLocation(u.OrgID, loc, u.ID)
+		} else {
+			err = a.itemsService.UpdateLocation(u.OrgID, loc, u.ID)
+		}
+
+		if err != nil && err == items.LocationNotFoundErr {
+			responses.SendError(w, responses.LocationNotFound(err))
+			return
+		} else if err != nil && err == items.LocationAlreadyExistsErr {
+			responses.SendError(w, responses.LocationAlreadyExists(err))
+			return
+		} else if err != nil && (err == items.InvalidLocationErr || err == items.LocationCycleErr) {
+			responses.SendError(w, responses.InvalidParamError("location", err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(loc, w)
+	})
+}
+
+// DeleteLocation removes a location that is empty
+func (a *API) DeleteLocation(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idStr, err := getRequiredParam(r, "id")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("id"))
+			return
+		}
+
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			responses.SendError(w, responses.InvalidParamError("id", err))
+			return
+		}
+
+		err = a.itemsService.DeleteLocation(u.OrgID, id, u.ID)
+		if err != nil && err == items.LocationNotFoundErr {
+			responses.SendError(w, responses.LocationNotFound(err))
+			return
+		} else if err != nil && err == items.LocationInUseErr {
+			responses.SendError(w, responses.LocationInUse(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(responses.Success{Success: true}, w)
+	})
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchLocations(t *testing.T) {
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+
+	ls := items.Locations{
+		{ID: 1, Name: "Shed", Path: "Shed"},
+		{ID: 2, ParentID: 1, Name: "Shelf 1", Path: "Shed > Shelf 1"},
+	}
+
+	ip := items.NewMockPersister(mc)
+	ip.EXPECT().GetLocations(1).Return(ls, nil)
+
+	server := setupServerAuthenticated(ip, t)
+	defer server.Close()
+
+	resp, err := sendGet(server.URL + "/api/locations")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	b, err := json.Marshal(ls)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(b), string(getBody(t, resp)))
+}
+
+func TestSetLocation(t *testing.T) {
+	type testCase struct {
+		testName   string
+		sendBody   LocationBody
+		setMock    func(*items.MockPersister)
+		expectCode int
+	}
+
+	existing := items.Locations{
+		{ID: 1, Name: "Shed", Path: "Shed"},
+		{ID: 2, ParentID: 1, Name: "Shelf 1", Path: "Shed > Shelf 1"},
+	}
+
+	testCases := []testCase{
+		{
+			testName: "add",
+			sendBody: LocationBody{ParentID: 1, Name: " Shelf 2 "},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLocations(1).Return(existing, nil)
+				ip.EXPECT().AddLocation(1, items.Location{ParentID: 1, Name: "Shelf 2"}, 123).Return(items.Location{ID: 3, ParentID: 1, Name: "Shelf 2", Path: "Shed > Shelf 2"}, nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "already exists",
+			sendBody: LocationBody{ParentID: 1, Name: "shelf 1"},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLocations(1).Return(existing, nil)
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "parent not found",
+			sendBody: LocationBody{ParentID: 9, Name: "Shelf 2"},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLocations(1).Return(existing, nil)
+			},
+			expectCode: 404,
+		},
+		{
+			testName: "invalid name",
+			sendBody: LocationBody{Name: "Shed > Shelf"},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLocations(1).Return(existing, nil)
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "move",
+			sendBody: LocationBody{ID: 2, Name: "Shelf 1"},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLocations(1).Return(existing, nil)
+				ip.EXPECT().UpdateLocation(1, items.Location{ID: 2, Name: "Shelf 1"}, 123).Return(nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "move under itself",
+			sendBody: LocationBody{ID: 1, ParentID: 2, Name: "Shed"},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLocations(1).Return(existing, nil)
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "internal error",
+			sendBody: LocationBody{Name: "Garage"},
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLocations(1).Return(nil, errors.New("sorry"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+"/api/location", tc.sendBody)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestDeleteLocation(t *testing.T) {
+	type testCase struct {
+		testName   string
+		url        string
+		setMock    func(*items.MockPersister)
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			url:      "/api/location?id=2",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().DeleteLocation(1, 2, 123).Return(nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "in use",
+			url:      "/api/location?id=2",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().DeleteLocation(1, 2, 123).Return(items.LocationInUseErr)
+			},
+			expectCode: 409,
+		},
+		{
+			testName: "not found",
+			url:      "/api/location?id=2",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().DeleteLocation(1, 2, 123).Return(items.LocationNotFoundErr)
+			},
+			expectCode: 404,
+		},
+		{
+			testName:   "invalid id",
+			url:        "/api/location?id=shed",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "missing id",
+			url:        "/api/location",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendDelete(server.URL + tc.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
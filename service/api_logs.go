@@ -0,0 +1,126 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+const dateParamFormat = "2006-01-02"
+
+// FetchItemHistory returns a page of everything that happened to an item
+func (a *API) FetchItemHistory(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getRequiredParam(r, "id")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("id"))
+			return
+		}
+
+		page, limit, ok := getPageParams(w, r)
+		if !ok {
+			return
+		}
+
+		res, err := a.itemsService.GetHistory(u.OrgID, id, page, limit)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(res, w)
+	})
+}
+
+// FetchLogs returns a page of the org's logs. They can be filtered by the user
+// who performed the action, the action, the object it was performed on and a
+// date range where "to" is exclusive.
+func (a *API) FetchLogs(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, limit, ok := getPageParams(w, r)
+		if !ok {
+			return
+		}
+
+		filter := items.LogFilter{
+			ObjectType: getOptionalParam(r, "type"),
+			ObjectID:   getOptionalParam(r, "id"),
+			Username:   getOptionalParam(r, "user"),
+			Action:     getOptionalParam(r, "action"),
+			Page:       page,
+			Limit:      limit,
+		}
+
+		if filter.From, ok = getDateParam(w, r, "from"); !ok {
+			return
+		}
+		if filter.To, ok = getDateParam(w, r, "to"); !ok {
+			return
+		}
+
+		res, err := a.itemsService.GetLogs(u.OrgID, filter)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(res, w)
+	})
+}
+
+// getPageParams reads the optional page and limit params. An error is sent if
+// they are not positive numbers.
+func getPageParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	page, ok := getPositiveParam(w, r, "page")
+	if !ok {
+		return 0, 0, false
+	}
+
+	limit, ok := getPositiveParam(w, r, "limit")
+	return page, limit, ok
+}
+
+// getPositiveParam reads an optional param that must be a whole number of at
+// least 1. It is 0 when missing and an error is sent if it is invalid.
+func getPositiveParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	v := getOptionalParam(r, name)
+	if v == "" {
+		return 0, true
+	}
+
+	n, err := strconv.Atoi(v)
+	if err == nil && n < 1 {
+		err = errors.New("must be at least 1")
+	}
+	if err != nil {
+		responses.SendError(w, responses.InvalidParamError(name, err))
+		return 0, false
+	}
+
+	return n, true
+}
+
+// getDateParam reads an optional param that is either an RFC 3339 timestamp
+// or a date, which is midnight UTC. An error is sent if it is neither.
+func getDateParam(w http.ResponseWriter, r *http.Request, name string) (time.Time, bool) {
+	v := getOptionalParam(r, name)
+	if v == "" {
+		return time.Time{}, true
+	}
+
+	t, err := time.Parse(dateParamFormat, v)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, v)
+	}
+	if err != nil {
+		responses.SendError(w, responses.InvalidParamError(name, err))
+		return time.Time{}, false
+	}
+
+	return t, true
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchItemHistory(t *testing.T) {
+	entries := items.LogEntries{
+		{ID: 2, UserID: 123, Username: "someUser", ObjectType: "item", ObjectID: "1234", Action: "checked out", Details: map[string]interface{}{"quantity": 1.0}},
+		{ID: 1, UserID: 123, Username: "someUser", ObjectType: "item", ObjectID: "1234", Action: "add", Details: map[string]interface{}{}},
+	}
+
+	type testCase struct {
+		testName         string
+		url              string
+		setMock          func(*items.MockPersister)
+		expectCode       int
+		expectedResponse items.LogPage
+	}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			url:      "/api/item/history?id=1234",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLogs(1, items.LogFilter{ObjectType: "item", ObjectID: "1234", Page: 1, Limit: items.DefaultLogLimit}).Return(entries, 2, nil)
+			},
+			expectCode:       200,
+			expectedResponse: items.LogPage{Entries: entries, Page: 1, Limit: items.DefaultLogLimit, Total: 2},
+		},
+		{
+			testName: "paged",
+			url:      "/api/item/history?id=1234&page=3&limit=1000",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLogs(1, items.LogFilter{ObjectType: "item", ObjectID: "1234", Page: 3, Limit: items.MaxLogLimit}).Return(items.LogEntries{}, 2, nil)
+			},
+			expectCode:       200,
+			expectedResponse: items.LogPage{Entries: items.LogEntries{}, Page: 3, Limit: items.MaxLogLimit, Total: 2},
+		},
+		{
+			testName:   "missing id",
+			url:        "/api/item/history",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "invalid page",
+			url:        "/api/item/history?id=1234&page=0",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "invalid limit",
+			url:        "/api/item/history?id=1234&limit=lots",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName: "internal error",
+			url:      "/api/item/history?id=1234",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLogs(1, gomock.Any()).Return(nil, 0, errors.New("sorry"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendGet(server.URL + tc.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				b, err := json.Marshal(tc.expectedResponse)
+				assert.NoError(t, err)
+				assert.JSONEq(t, string(b), string(getBody(t, resp)))
+			}
+		})
+	}
+}
+
+func TestFetchLogs(t *testing.T) {
+	type testCase struct {
+		testName   string
+		url        string
+		setMock    func(*items.MockPersister)
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "no filters",
+			url:      "/api/logs",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLogs(1, items.LogFilter{Page: 1, Limit: items.DefaultLogLimit}).Return(items.LogEntries{}, 0, nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "every filter",
+			url:      "/api/logs?user=someUser&action=delete&type=item&id=1234&from=2020-01-02&to=2020-02-01T10:00:00%2B02:00&page=2&limit=10",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLogs(1, gomock.Any()).DoAndReturn(func(orgID int, f items.LogFilter) (items.LogEntries, int, error) {
+					assert.Equal(t, "someUser", f.Username)
+					assert.Equal(t, "delete", f.Action)
+					assert.Equal(t, "item", f.ObjectType)
+					assert.Equal(t, "1234", f.ObjectID)
+					assert.True(t, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Equal(f.From))
+					assert.True(t, time.Date(2020, 2, 1, 8, 0, 0, 0, time.UTC).Equal(f.To))
+					assert.Equal(t, 2, f.Page)
+					assert.Equal(t, 10, f.Limit)
+					return items.LogEntries{}, 0, nil
+				})
+			},
+			expectCode: 200,
+		},
+		{
+			testName:   "invalid date",
+			url:        "/api/logs?from=yesterday",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName: "internal error",
+			url:      "/api/logs",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().GetLogs(1, gomock.Any()).Return(nil, 0, errors.New("sorry"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendGet(server.URL + tc.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
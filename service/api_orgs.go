@@ -0,0 +1,114 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+type OrgBody struct {
+	Name string `json:"name"`
+}
+
+type MemberBody struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+func (a *API) FetchOrgs(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		orgs, err := a.userService.GetOrgs(u)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(orgs, w)
+	})
+}
+
+// AddOrg creates a new org which the user becomes the admin of
+func (a *API) AddOrg(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ob := OrgBody{}
+		err := parseBody(r, &ob)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		if ob.Name == "" {
+			responses.SendError(w, responses.MissingParamError("name must not be blank"))
+			return
+		}
+
+		org, err := a.userService.AddOrg(ob.Name, u)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(org, w)
+	})
+}
+
+// SwitchOrg changes the org that the current session works in
+func (a *API) SwitchOrg(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idStr, err := getRequiredParam(r, "id")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("id"))
+			return
+		}
+
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			responses.SendError(w, responses.InvalidParamError("id", err))
+			return
+		}
+
+		err = a.userService.SwitchOrg(u, id)
+		if err != nil && err == users.OrgNotFoundErr {
+			responses.SendError(w, responses.OrgNotFound(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(responses.Success{Success: true}, w)
+	})
+}
+
+// AddMember adds an existing user to the current org or changes their role in it
+func (a *API) AddMember(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mb := MemberBody{}
+		err := parseBody(r, &mb)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		if mb.Username == "" || mb.Role == "" {
+			responses.SendError(w, responses.MissingParamError("username and role must not be blank"))
+			return
+		}
+
+		err = a.userService.AddMember(u.OrgID, mb.Username, mb.Role, u.ID)
+		if err != nil && err == users.RoleNotFoundErr {
+			responses.SendError(w, responses.RoleNotFound(err))
+			return
+		} else if err != nil && err == users.UserNotFoundErr {
+			responses.SendError(w, responses.UserNotFound(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(responses.Success{Success: true}, w)
+	})
+}
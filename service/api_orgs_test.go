@@ -0,0 +1,279 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/users"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchOrgs(t *testing.T) {
+	type testCase struct {
+		testName         string
+		setMock          func(up *users.MockPersister)
+		expectCode       int
+		expectedResponse users.Orgs
+	}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 2}, nil).AnyTimes()
+				up.EXPECT().GetOrgs(123).Return(users.Orgs{
+					{ID: 1, Name: "Default", Role: "viewer"},
+					{ID: 2, Name: "Chess Club", Role: "admin"},
+				}, nil)
+			},
+			expectCode: 200,
+			expectedResponse: users.Orgs{
+				{ID: 1, Name: "Default", Role: "viewer"},
+				{ID: 2, Name: "Chess Club", Role: "admin", Current: true},
+			},
+		},
+		{
+			testName: "internal error",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 2}, nil).AnyTimes()
+				up.EXPECT().GetOrgs(123).Return(nil, errors.New("sorry"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			up := users.NewMockPersister(mc)
+			tc.setMock(up)
+
+			server := setupServer(nil, up, t)
+			defer server.Close()
+
+			resp, err := sendGet(server.URL + "/api/orgs")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				b, err := json.Marshal(tc.expectedResponse)
+				assert.NoError(t, err)
+				assert.JSONEq(t, string(b), string(getBody(t, resp)))
+			}
+		})
+	}
+}
+
+func TestAddOrg(t *testing.T) {
+	type testCase struct {
+		testName   string
+		setMock    func(up *users.MockPersister)
+		sendBody   OrgBody
+		expectCode int
+	}
+
+	creator := users.User{Valid: true, ID: 123, OrgID: 1, Permissions: []string{users.PermOrgCreate}}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(creator, nil).AnyTimes()
+				up.EXPECT().AddOrg("Chess Club", 123).Return(users.Org{ID: 2, Name: "Chess Club", Role: "admin"}, nil)
+			},
+			sendBody:   OrgBody{Name: "Chess Club"},
+			expectCode: 200,
+		},
+		{
+			testName: "blank name",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(creator, nil).AnyTimes()
+			},
+			sendBody:   OrgBody{},
+			expectCode: 400,
+		},
+		{
+			testName: "missing permission",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 1, Permissions: []string{users.PermUserManage}}, nil).AnyTimes()
+			},
+			sendBody:   OrgBody{Name: "Chess Club"},
+			expectCode: 403,
+		},
+		{
+			testName: "internal error",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(creator, nil).AnyTimes()
+				up.EXPECT().AddOrg("Chess Club", 123).Return(users.Org{}, errors.New("sorry"))
+			},
+			sendBody:   OrgBody{Name: "Chess Club"},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			up := users.NewMockPersister(mc)
+			tc.setMock(up)
+
+			server := setupServer(nil, up, t)
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+"/api/org", tc.sendBody)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestSwitchOrg(t *testing.T) {
+	type testCase struct {
+		testName   string
+		setMock    func(up *users.MockPersister)
+		idHeader   string
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 1, SessionID: 5}, nil).AnyTimes()
+				up.EXPECT().SwitchOrg(5, 123, 2).Return(nil)
+			},
+			idHeader:   "id=2",
+			expectCode: 200,
+		},
+		{
+			testName: "missing id",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 1, SessionID: 5}, nil).AnyTimes()
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "invalid id",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 1, SessionID: 5}, nil).AnyTimes()
+			},
+			idHeader:   "id=chess",
+			expectCode: 400,
+		},
+		{
+			testName: "not a member",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 1, SessionID: 5}, nil).AnyTimes()
+				up.EXPECT().SwitchOrg(5, 123, 2).Return(users.OrgNotFoundErr)
+			},
+			idHeader:   "id=2",
+			expectCode: 404,
+		},
+		{
+			testName: "internal error",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 1, SessionID: 5}, nil).AnyTimes()
+				up.EXPECT().SwitchOrg(5, 123, 2).Return(errors.New("sorry"))
+			},
+			idHeader:   "id=2",
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			up := users.NewMockPersister(mc)
+			tc.setMock(up)
+
+			server := setupServer(nil, up, t)
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+"/api/org/switch?"+tc.idHeader, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestAddMember(t *testing.T) {
+	type testCase struct {
+		testName   string
+		setMock    func(up *users.MockPersister)
+		sendBody   MemberBody
+		expectCode int
+	}
+
+	manager := users.User{Valid: true, ID: 123, OrgID: 2, Permissions: []string{users.PermUserManage}}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(manager, nil).AnyTimes()
+				up.EXPECT().AddMember(2, "someuser", "borrower", 123).Return(nil)
+			},
+			sendBody:   MemberBody{Username: "someuser", Role: "borrower"},
+			expectCode: 200,
+		},
+		{
+			testName: "missing role",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(manager, nil).AnyTimes()
+			},
+			sendBody:   MemberBody{Username: "someuser"},
+			expectCode: 400,
+		},
+		{
+			testName: "role not found",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(manager, nil).AnyTimes()
+				up.EXPECT().AddMember(2, "someuser", "janitor", 123).Return(users.RoleNotFoundErr)
+			},
+			sendBody:   MemberBody{Username: "someuser", Role: "janitor"},
+			expectCode: 400,
+		},
+		{
+			testName: "user not found",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(manager, nil).AnyTimes()
+				up.EXPECT().AddMember(2, "someuser", "borrower", 123).Return(users.UserNotFoundErr)
+			},
+			sendBody:   MemberBody{Username: "someuser", Role: "borrower"},
+			expectCode: 404,
+		},
+		{
+			testName: "missing permission",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 2, Permissions: []string{users.PermUserView}}, nil).AnyTimes()
+			},
+			sendBody:   MemberBody{Username: "someuser", Role: "borrower"},
+			expectCode: 403,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			up := users.NewMockPersister(mc)
+			tc.setMock(up)
+
+			server := setupServer(nil, up, t)
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+"/api/org/member", tc.sendBody)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
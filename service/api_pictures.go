@@ -0,0 +1,60 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Timothylock/inventory-management/pictures"
+	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+// UploadPicture stores the picture sent as the body and returns the URL to
+// use as the PictureURL of items
+func (a *API) UploadPicture(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.picturesService.Save(u.OrgID, r.Body)
+		if err != nil && (err == pictures.InvalidPictureErr || err == pictures.PictureTooLargeErr) {
+			responses.SendError(w, responses.InvalidParamError("body", err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(p, w)
+	})
+}
+
+// FetchPicture sends an uploaded picture, or its thumbnail if size=thumb
+func (a *API) FetchPicture(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, err := getRequiredParam(r, "name")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("name"))
+			return
+		}
+
+		size := getOptionalParam(r, "size")
+		if size != "" && size != "thumb" {
+			responses.SendError(w, responses.InvalidParamError("size", errors.New("must be thumb or blank")))
+			return
+		}
+
+		f, contentType, err := a.picturesService.Open(u.OrgID, name, size == "thumb")
+		if err != nil && err == pictures.PictureNotFoundErr {
+			responses.SendError(w, responses.PictureNotFound(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+		defer f.Close()
+
+		// Pictures are named after their contents so they never change
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+		io.Copy(w, f)
+	})
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/pictures"
+	"github.com/Timothylock/inventory-management/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStorage(t *testing.T) (storage.Storage, func()) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := storage.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return st, func() { os.RemoveAll(dir) }
+}
+
+func testPicture(t *testing.T) []byte {
+	b := &bytes.Buffer{}
+	if err := png.Encode(b, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestUploadAndFetchPicture(t *testing.T) {
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+
+	st, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	ip := items.NewMockPersister(mc)
+	server := setupServerAuthenticatedWith(t, testDeps{items: ip, storage: st})
+	defer server.Close()
+
+	data := testPicture(t)
+	resp, err := sendPostRaw(server.URL+"/api/pictures", "image/png", string(data))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	p := pictures.Picture{}
+	assert.NoError(t, json.Unmarshal([]byte(getBody(t, resp)), &p))
+	assert.Regexp(t, `^[0-9a-f]{32}\.png$`, p.Name)
+	assert.Equal(t, "api/picture?name="+p.Name+"&size=thumb", p.ThumbnailURL)
+	name := p.Name
+
+	resp, err = sendGet(server.URL + "/api/picture?name=" + name)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+	assert.Equal(t, string(data), getBody(t, resp))
+
+	resp, err = sendGet(server.URL + "/api/picture?name=" + name + "&size=thumb")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/jpeg", resp.Header.Get("Content-Type"))
+
+	resp, err = sendGet(server.URL + "/api/picture?name=" + name + "&size=huge")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = sendGet(server.URL + "/api/picture?name=" + strings.Repeat("0", 32) + ".png")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = sendGet(server.URL + "/api/picture")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Only pictures can be uploaded
+	resp, err = sendPostRaw(server.URL+"/api/pictures", "image/png", "<svg></svg>")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAddItemCachesPicture(t *testing.T) {
+	data := testPicture(t)
+	fetched := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		w.Write(data)
+	}))
+	defer ts.Close()
+	remote := ts.URL + "/a.png"
+
+	type testCase struct {
+		testName        string
+		url             string
+		existingPicture string
+		// private is whether the client refuses the test server's address as
+		// it does outside of tests
+		private         bool
+		expectedPicture string
+		expectedFetches int
+	}
+
+	testCases := []testCase{
+		{
+			testName:        "new item",
+			url:             "/api/item",
+			expectedPicture: `^api/picture\?name=[0-9a-f]{32}\.png$`,
+			expectedFetches: 1,
+		},
+		{
+			testName:        "overwrite with another picture",
+			url:             "/api/item?overwrite=1",
+			existingPicture: "http://example.com/b.png",
+			expectedPicture: `^api/picture\?name=[0-9a-f]{32}\.png$`,
+			expectedFetches: 1,
+		},
+		{
+			testName:        "overwrite with the same picture",
+			url:             "/api/item?overwrite=1",
+			existingPicture: remote,
+			expectedPicture: "^" + regexp.QuoteMeta(remote) + "$",
+		},
+		{
+			testName:        "private address",
+			url:             "/api/item",
+			private:         true,
+			expectedPicture: "^" + regexp.QuoteMeta(remote) + "$",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			st, cleanup := newTestStorage(t)
+			defer cleanup()
+
+			fetched = 0
+			overwrite := tc.existingPicture != ""
+			ip := items.NewMockPersister(mc)
+			ip.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(items.Persister) error) error {
+				return fn(ip)
+			})
+			ip.EXPECT().GetCategories(1).Return(items.Categories{{ID: 3, Name: "fi"}}, nil).AnyTimes()
+			if overwrite {
+				ip.EXPECT().SearchItems(1, items.ItemFilter{IDs: []string{"1"}, SortBy: "id"}).
+					Return(items.ItemDetailList{{ID: "1", PictureURL: tc.existingPicture}}, 1, nil)
+			} else {
+				ip.EXPECT().PurgeItem(1, "1", 123).Return(nil, items.ItemNotFoundErr)
+			}
+			ip.EXPECT().AddItem(1, gomock.Any(), overwrite).DoAndReturn(func(orgID int, item items.ItemDetail, overwrite bool) error {
+				assert.Regexp(t, tc.expectedPicture, item.PictureURL)
+				return nil
+			})
+
+			cfg := config.Config{CachePictures: true}
+			ps := pictures.NewServiceWithClient(st, cfg, ts.Client())
+			if tc.private {
+				ps = pictures.NewService(st, cfg)
+			}
+			server := setupServerAuthenticatedWith(t, testDeps{items: ip, storage: st, pictures: &ps, config: cfg})
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+tc.url, AddBody{ID: "1", Name: "foo", CategoryID: 3, PictureURL: remote, Quantity: 1})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, tc.expectedFetches, fetched)
+		})
+	}
+}
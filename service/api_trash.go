@@ -0,0 +1,76 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/users"
+)
+
+type PurgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+// FetchTrash returns the deleted items that can still be restored
+func (a *API) FetchTrash(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := a.itemsService.GetTrash(u.OrgID)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(res, w)
+	})
+}
+
+func (a *API) RestoreItem(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getRequiredParam(r, "id")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("id"))
+			return
+		}
+
+		err = a.itemsService.RestoreItem(u.OrgID, id, u.ID)
+		if err != nil && err == items.ItemNotFoundErr {
+			responses.SendError(w, responses.ItemNotFound(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(responses.Success{Success: true}, w)
+	})
+}
+
+// PurgeTrash permanently removes the item given by id from the trash, or every
+// item that has been in the trash for longer than the retention if no id is given
+func (a *API) PurgeTrash(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := getOptionalParam(r, "id")
+		if id == "" {
+			n, err := a.itemsService.PurgeTrash(u.OrgID, u.ID)
+			if err != nil {
+				responses.SendError(w, responses.InternalError(err))
+				return
+			}
+
+			sendJSONorErr(PurgeResponse{Purged: n}, w)
+			return
+		}
+
+		err := a.itemsService.PurgeItem(u.OrgID, id, u.ID)
+		if err != nil && err == items.ItemNotFoundErr {
+			responses.SendError(w, responses.ItemNotFound(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(PurgeResponse{Purged: 1}, w)
+	})
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/Timothylock/inventory-management/items"
+	"github.com/Timothylock/inventory-management/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchTrash(t *testing.T) {
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+
+	deleted := items.DeletedItems{
+		{ItemDetail: items.ItemDetail{ID: "1234", Name: "Extension cord", LastPerformedBy: "someUser"}, DeletedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+
+	ip := items.NewMockPersister(mc)
+	ip.EXPECT().GetDeletedItems(1).Return(deleted, nil)
+
+	server := setupServerAuthenticated(ip, t)
+	defer server.Close()
+
+	resp, err := sendGet(server.URL + "/api/items/trash")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	b, err := json.Marshal(deleted)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(b), string(getBody(t, resp)))
+}
+
+func TestRestoreItem(t *testing.T) {
+	type testCase struct {
+		testName   string
+		url        string
+		setMock    func(*items.MockPersister)
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			url:      "/api/item/restore?id=1234",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().RestoreItem(1, "1234", 123).Return(nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "not in the trash",
+			url:      "/api/item/restore?id=1234",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().RestoreItem(1, "1234", 123).Return(items.ItemNotFoundErr)
+			},
+			expectCode: 404,
+		},
+		{
+			testName: "internal error",
+			url:      "/api/item/restore?id=1234",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().RestoreItem(1, "1234", 123).Return(errors.New("sorry"))
+			},
+			expectCode: 500,
+		},
+		{
+			testName:   "missing id",
+			url:        "/api/item/restore",
+			setMock:    func(ip *items.MockPersister) {},
+			expectCode: 400,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			server := setupServerAuthenticated(ip, t)
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+tc.url, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	type testCase struct {
+		testName         string
+		url              string
+		setMock          func(*items.MockPersister)
+		expectCode       int
+		expectedResponse PurgeResponse
+		// expectDeleted is whether the attachment file is deleted
+		expectDeleted bool
+	}
+
+	testCases := []testCase{
+		{
+			testName: "past the retention",
+			url:      "/api/items/trash",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().PurgeItems(1, gomock.Any(), 123).DoAndReturn(func(orgID int, deletedBefore time.Time, userID int) (int, []string, error) {
+					assert.WithinDuration(t, time.Now().Add(-48*time.Hour), deletedBefore, time.Minute)
+					return 3, []string{"attachments/1/a"}, nil
+				})
+			},
+			expectCode:       200,
+			expectedResponse: PurgeResponse{Purged: 3},
+			expectDeleted:    true,
+		},
+		{
+			testName: "one item",
+			url:      "/api/items/trash?id=1234",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().PurgeItem(1, "1234", 123).Return([]string{"attachments/1/a"}, nil)
+			},
+			expectCode:       200,
+			expectedResponse: PurgeResponse{Purged: 1},
+			expectDeleted:    true,
+		},
+		{
+			testName: "one item without attachments",
+			url:      "/api/items/trash?id=1234",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().PurgeItem(1, "1234", 123).Return([]string{}, nil)
+			},
+			expectCode:       200,
+			expectedResponse: PurgeResponse{Purged: 1},
+		},
+		{
+			testName: "not in the trash",
+			url:      "/api/items/trash?id=1234",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().PurgeItem(1, "1234", 123).Return(nil, items.ItemNotFoundErr)
+			},
+			expectCode: 404,
+		},
+		{
+			testName: "internal error",
+			url:      "/api/items/trash",
+			setMock: func(ip *items.MockPersister) {
+				ip.EXPECT().PurgeItems(1, gomock.Any(), 123).Return(0, nil, errors.New("sorry"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			tc.setMock(ip)
+
+			st, cleanup := newTestStorage(t)
+			defer cleanup()
+			assert.NoError(t, st.Put("attachments/1/a", strings.NewReader("some file")))
+
+			server := setupServerAuthenticatedWith(t, testDeps{items: ip, storage: st, config: config.Config{TrashRetention: 48 * time.Hour}})
+			defer server.Close()
+
+			resp, err := sendDelete(server.URL + tc.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				b, err := json.Marshal(tc.expectedResponse)
+				assert.NoError(t, err)
+				assert.JSONEq(t, string(b), string(getBody(t, resp)))
+			}
+
+			_, err = st.Get("attachments/1/a")
+			if tc.expectDeleted {
+				assert.Equal(t, storage.NotFoundErr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
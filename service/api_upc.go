@@ -1,9 +1,13 @@
 package service
 
 import (
+	"fmt"
+	"mime"
 	"net/http"
 
+	"github.com/Timothylock/inventory-management/barcode"
 	"github.com/Timothylock/inventory-management/responses"
+	"github.com/Timothylock/inventory-management/upc"
 	"github.com/Timothylock/inventory-management/users"
 )
 
@@ -15,12 +19,116 @@ func (a *API) LookupBarcode(u users.User) http.Handler {
 			return
 		}
 
+		// The ID to add the item under is the normalized form of the barcode
+		id, err := barcode.Normalize(search)
+		if err != nil {
+			responses.SendError(w, responses.InvalidParamError("barcode", err))
+			return
+		}
+
 		res, err := a.upcService.LookupBarcode(search)
 		if err != nil {
-			responses.SendError(w, responses.InternalError(err))
+			sendLookupError(w, err)
+			return
+		}
+		res.ID = id
+
+		sendJSONorErr(res, w)
+	})
+}
+
+// RefreshBarcode looks up the barcode again even if it is cached
+func (a *API) RefreshBarcode(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		search, err := getRequiredParam(r, "barcode")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("barcode"))
+			return
+		}
+
+		id, err := barcode.Normalize(search)
+		if err != nil {
+			responses.SendError(w, responses.InvalidParamError("barcode", err))
+			return
+		}
+
+		res, err := a.upcService.RefreshBarcode(search)
+		if err != nil {
+			sendLookupError(w, err)
 			return
 		}
+		res.ID = id
 
 		sendJSONorErr(res, w)
 	})
 }
+
+// InvalidateBarcode forgets the cached result of the barcode
+func (a *API) InvalidateBarcode(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		search, err := getRequiredParam(r, "barcode")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("barcode"))
+			return
+		}
+
+		if _, err = barcode.Normalize(search); err != nil {
+			responses.SendError(w, responses.InvalidParamError("barcode", err))
+			return
+		}
+
+		if err = a.upcService.InvalidateBarcode(search); err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(responses.Success{Success: true}, w)
+	})
+}
+
+// sendLookupError sends the error of a barcode lookup with its own code so
+// that the scan pages can tell an unknown barcode from a provider that is down
+func sendLookupError(w http.ResponseWriter, err error) {
+	switch err {
+	case upc.NotFoundErr:
+		responses.SendError(w, responses.BarcodeNotFound(err))
+	case upc.RateLimitedErr:
+		responses.SendError(w, responses.LookupRateLimited(err))
+	case upc.UnavailableErr:
+		responses.SendError(w, responses.LookupUnavailable(err))
+	case upc.UnauthorizedErr:
+		responses.SendError(w, responses.LookupUnauthorized(err))
+	default:
+		responses.SendError(w, responses.InternalError(err))
+	}
+}
+
+// ImportDataset adds the products of a barcode dataset sent as CSV or JSON
+// lines to the ones barcodes are looked up in without the internet
+func (a *API) ImportDataset(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := upc.DatasetCSV
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mt {
+		case contentTypeCSV, "":
+		case contentTypeNDJSON, contentTypeJSONL:
+			format = upc.DatasetJSONL
+		default:
+			err := fmt.Errorf("must be %s or %s", contentTypeCSV, contentTypeNDJSON)
+			responses.SendError(w, responses.InvalidParamError("Content-Type", err))
+			return
+		}
+
+		// Products saved before the dataset turned out to be unreadable are kept
+		report, err := a.upcService.ImportDataset(r.Body, format)
+		if _, ok := err.(upc.DatasetReadError); ok {
+			responses.SendError(w, responses.InvalidParamError("body", err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(report, w)
+	})
+}
@@ -2,10 +2,12 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/Timothylock/inventory-management/config"
 	"github.com/Timothylock/inventory-management/items"
@@ -37,7 +39,70 @@ func TestLookupBarcodeServerError(t *testing.T) {
 
 	resp, err := sendGet(server.URL + "/api/lookup?barcode=123")
 	assert.NoError(t, err)
-	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestLookupBarcodeErrors(t *testing.T) {
+	type testCase struct {
+		testName       string
+		handler        http.HandlerFunc
+		expectCode     int
+		expectedOutput string
+	}
+
+	testCases := []testCase{
+		{
+			testName: "not found",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"product":{}}`)
+			},
+			expectCode:     404,
+			expectedOutput: `{"code":1300,"details":"no provider knows the barcode"}`,
+		},
+		{
+			testName: "rate limited",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			expectCode:     429,
+			expectedOutput: `{"code":1301,"details":"too many barcodes were looked up, try again later"}`,
+		},
+		{
+			testName: "unavailable",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			expectCode:     503,
+			expectedOutput: `{"code":1302,"details":"the barcode lookup provider could not be reached"}`,
+		},
+		{
+			testName: "unauthorized",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			},
+			expectCode:     502,
+			expectedOutput: `{"code":1303,"details":"the barcode lookup provider did not accept its key"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ts := httptest.NewServer(tc.handler)
+			defer ts.Close()
+
+			ip := items.NewMockPersister(mc)
+			server := setupServerAuthenticatedWith(t, testDeps{items: ip, config: config.Config{UpcUrl: ts.URL}})
+			defer server.Close()
+
+			resp, err := sendGet(server.URL + "/api/lookup?barcode=036000291452")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+			assert.JSONEq(t, tc.expectedOutput, getBody(t, resp))
+		})
+	}
 }
 
 func TestLookupBarcodeSuccess(t *testing.T) {
@@ -53,7 +118,7 @@ func TestLookupBarcodeSuccess(t *testing.T) {
 		UpcUrl: ts.URL,
 	}
 	ip := items.NewMockPersister(mc)
-	server := setupServerWithConfigAuthenticated(ip, cfg, t)
+	server := setupServerAuthenticatedWith(t, testDeps{items: ip, config: cfg})
 	defer server.Close()
 
 	expected := upc.ItemDetail{
@@ -70,3 +135,316 @@ func TestLookupBarcodeSuccess(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 	assert.JSONEq(t, string(expectedStr), string(getBody(t, resp)))
 }
+
+func TestLookupBarcodeNormalizes(t *testing.T) {
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"product":{"name":"someName"}}`)
+	}))
+	defer ts.Close()
+
+	ip := items.NewMockPersister(mc)
+	server := setupServerAuthenticatedWith(t, testDeps{items: ip, config: config.Config{UpcUrl: ts.URL}})
+	defer server.Close()
+
+	resp, err := sendGet(server.URL + "/api/lookup?barcode=036000291452")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"id":"00036000291452","name":"someName","category":"","pictureURL":""}`, getBody(t, resp))
+
+	resp, err = sendGet(server.URL + "/api/lookup?barcode=036000291453")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestLookupBarcodeCache(t *testing.T) {
+	type testCase struct {
+		testName       string
+		setMock        func(*upc.MockPersister)
+		expectCode     int
+		expectRequests int
+		expectedOutput string
+	}
+
+	const gtin = "00036000291452"
+	cached := func(found bool, age time.Duration) upc.CachedLookup {
+		c := upc.CachedLookup{Barcode: gtin, Found: found, FetchedAt: time.Now().Add(-age)}
+		if found {
+			c.Item = upc.ItemDetail{ID: gtin, Name: "cachedName"}
+		}
+		return c
+	}
+	stored := gomock.Any()
+
+	testCases := []testCase{
+		{
+			testName: "miss is looked up and cached",
+			setMock: func(upcp *upc.MockPersister) {
+				upcp.EXPECT().GetCachedLookup(gtin).Return(upc.CachedLookup{}, upc.CacheMissErr)
+				upcp.EXPECT().GetProduct(gtin).Return(upc.Product{}, upc.ProductNotFoundErr)
+				upcp.EXPECT().CacheLookup(stored).DoAndReturn(func(c upc.CachedLookup) error {
+					assert.Equal(t, gtin, c.Barcode)
+					assert.True(t, c.Found)
+					assert.Equal(t, "someName", c.Item.Name)
+					return nil
+				})
+			},
+			expectCode:     200,
+			expectRequests: 1,
+			expectedOutput: `{"id":"00036000291452","name":"someName","category":"","pictureURL":""}`,
+		},
+		{
+			testName: "found in the local dataset",
+			setMock: func(upcp *upc.MockPersister) {
+				upcp.EXPECT().GetCachedLookup(gtin).Return(upc.CachedLookup{}, upc.CacheMissErr)
+				upcp.EXPECT().GetProduct(gtin).Return(upc.Product{GTIN: gtin, Name: "tissues", Brand: "Kleenex", Category: "Paper", PictureURL: "someURL"}, nil)
+				upcp.EXPECT().CacheLookup(stored).Return(nil)
+			},
+			expectCode:     200,
+			expectedOutput: `{"id":"00036000291452","name":"Kleenex tissues","category":"Paper","pictureURL":"someURL"}`,
+		},
+		{
+			testName: "hit",
+			setMock: func(upcp *upc.MockPersister) {
+				upcp.EXPECT().GetCachedLookup(gtin).Return(cached(true, time.Hour), nil)
+			},
+			expectCode:     200,
+			expectedOutput: `{"id":"00036000291452","name":"cachedName","category":"","pictureURL":""}`,
+		},
+		{
+			testName: "barcode that was not found",
+			setMock: func(upcp *upc.MockPersister) {
+				upcp.EXPECT().GetCachedLookup(gtin).Return(cached(false, time.Hour), nil)
+			},
+			expectCode: 404,
+		},
+		{
+			testName: "expired",
+			setMock: func(upcp *upc.MockPersister) {
+				upcp.EXPECT().GetCachedLookup(gtin).Return(cached(true, 31*24*time.Hour), nil)
+				upcp.EXPECT().GetProduct(gtin).Return(upc.Product{}, upc.ProductNotFoundErr)
+				upcp.EXPECT().CacheLookup(stored).Return(nil)
+			},
+			expectCode:     200,
+			expectRequests: 1,
+			expectedOutput: `{"id":"00036000291452","name":"someName","category":"","pictureURL":""}`,
+		},
+		{
+			testName: "barcodes that were not found expire sooner",
+			setMock: func(upcp *upc.MockPersister) {
+				upcp.EXPECT().GetCachedLookup(gtin).Return(cached(false, 2*24*time.Hour), nil)
+				upcp.EXPECT().GetProduct(gtin).Return(upc.Product{}, upc.ProductNotFoundErr)
+				upcp.EXPECT().CacheLookup(stored).Return(nil)
+			},
+			expectCode:     200,
+			expectRequests: 1,
+			expectedOutput: `{"id":"00036000291452","name":"someName","category":"","pictureURL":""}`,
+		},
+		{
+			testName: "error",
+			setMock: func(upcp *upc.MockPersister) {
+				upcp.EXPECT().GetCachedLookup(gtin).Return(upc.CachedLookup{}, errors.New("some error"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			requests := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				fmt.Fprintln(w, `{"product":{"name":"someName"}}`)
+			}))
+			defer ts.Close()
+
+			ip := items.NewMockPersister(mc)
+			upcp := upc.NewMockPersister(mc)
+			tc.setMock(upcp)
+
+			server := setupServerAuthenticatedWith(t, testDeps{items: ip, upc: upcp, config: config.Config{UpcUrl: ts.URL}})
+			defer server.Close()
+
+			resp, err := sendGet(server.URL + "/api/lookup?barcode=036000291452")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+			assert.Equal(t, tc.expectRequests, requests)
+
+			if tc.expectCode == 200 {
+				assert.JSONEq(t, tc.expectedOutput, getBody(t, resp))
+			}
+		})
+	}
+}
+
+func TestRefreshBarcode(t *testing.T) {
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"product":{}}`)
+	}))
+	defer ts.Close()
+
+	ip := items.NewMockPersister(mc)
+	upcp := upc.NewMockPersister(mc)
+	upcp.EXPECT().GetProduct("00036000291452").Return(upc.Product{}, upc.ProductNotFoundErr)
+	upcp.EXPECT().CacheLookup(gomock.Any()).DoAndReturn(func(c upc.CachedLookup) error {
+		assert.Equal(t, "00036000291452", c.Barcode)
+		assert.False(t, c.Found)
+		return nil
+	})
+
+	server := setupServerAuthenticatedWith(t, testDeps{items: ip, upc: upcp, config: config.Config{UpcUrl: ts.URL}})
+	defer server.Close()
+
+	// Barcodes no provider knows are cached too
+	resp, err := sendPost(server.URL+"/api/lookup/refresh?barcode=036000291452", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = sendPost(server.URL+"/api/lookup/refresh", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestInvalidateBarcode(t *testing.T) {
+	type testCase struct {
+		testName   string
+		url        string
+		setMock    func(*upc.MockPersister)
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			url:      "/api/lookup/cache?barcode=0-306-40615-2",
+			setMock: func(upcp *upc.MockPersister) {
+				upcp.EXPECT().DeleteCachedLookup("09780306406157").Return(nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName:   "missing barcode",
+			url:        "/api/lookup/cache",
+			setMock:    func(upcp *upc.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName:   "wrong check digit",
+			url:        "/api/lookup/cache?barcode=036000291453",
+			setMock:    func(upcp *upc.MockPersister) {},
+			expectCode: 400,
+		},
+		{
+			testName: "error",
+			url:      "/api/lookup/cache?barcode=036000291452",
+			setMock: func(upcp *upc.MockPersister) {
+				upcp.EXPECT().DeleteCachedLookup("00036000291452").Return(errors.New("some error"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			upcp := upc.NewMockPersister(mc)
+			tc.setMock(upcp)
+
+			server := setupServerAuthenticatedWith(t, testDeps{items: ip, upc: upcp})
+			defer server.Close()
+
+			resp, err := sendDelete(server.URL + tc.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestImportDataset(t *testing.T) {
+	type testCase struct {
+		testName       string
+		contentType    string
+		body           string
+		setMock        func(*upc.MockPersister)
+		expectCode     int
+		expectedOutput string
+	}
+
+	testCases := []testCase{
+		{
+			testName:    "csv",
+			contentType: "text/csv",
+			body:        "gtin,name,brand\n036000291452,Tissues,Kleenex\n1234,Too short\n",
+			setMock: func(upcp *upc.MockPersister) {
+				upcp.EXPECT().SaveProducts([]upc.Product{{GTIN: "00036000291452", Name: "Tissues", Brand: "Kleenex"}}).Return(nil)
+			},
+			expectCode:     200,
+			expectedOutput: `{"imported":1,"skipped":1,"errors":[{"line":3,"gtin":"1234","reason":"not a UPC, EAN, ISBN or GTIN barcode"}]}`,
+		},
+		{
+			testName:    "json lines",
+			contentType: "application/x-ndjson",
+			body:        `{"gtin":"036000291452","name":"Tissues"}`,
+			setMock: func(upcp *upc.MockPersister) {
+				upcp.EXPECT().SaveProducts([]upc.Product{{GTIN: "00036000291452", Name: "Tissues"}}).Return(nil)
+			},
+			expectCode:     200,
+			expectedOutput: `{"imported":1,"skipped":0,"errors":[]}`,
+		},
+		{
+			testName:    "unknown content type",
+			contentType: "application/xml",
+			setMock:     func(upcp *upc.MockPersister) {},
+			expectCode:  400,
+		},
+		{
+			testName:    "missing column",
+			contentType: "text/csv",
+			body:        "barcode,name\n036000291452,Tissues\n",
+			setMock:     func(upcp *upc.MockPersister) {},
+			expectCode:  400,
+		},
+		{
+			testName:    "error",
+			contentType: "text/csv",
+			body:        "gtin,name\n036000291452,Tissues\n",
+			setMock: func(upcp *upc.MockPersister) {
+				upcp.EXPECT().SaveProducts(gomock.Any()).Return(errors.New("some error"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			ip := items.NewMockPersister(mc)
+			upcp := upc.NewMockPersister(mc)
+			tc.setMock(upcp)
+
+			server := setupServerAuthenticatedWith(t, testDeps{items: ip, upc: upcp})
+			defer server.Close()
+
+			resp, err := sendPostRaw(server.URL+"/api/lookup/dataset", tc.contentType, tc.body)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				assert.JSONEq(t, tc.expectedOutput, getBody(t, resp))
+			}
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/Timothylock/inventory-management/responses"
@@ -35,28 +37,107 @@ func (a *API) Login() http.Handler {
 			return
 		}
 
-		expiration := time.Now().Add(31 * 24 * time.Hour)
-		cookie := http.Cookie{Name: "token", Value: u.Token, Expires: expiration, Path: "/"}
+		session, err := a.userService.StartSession(u, r.UserAgent(), clientIP(r))
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		cookie := http.Cookie{Name: "token", Value: session.Token, Expires: session.ExpiresAt, Path: "/", HttpOnly: true}
 		http.SetCookie(w, &cookie)
 
 		fmt.Fprint(w, "OK")
 	})
 }
 
-type UserBody struct {
-	Username   string `json:"username"`
-	Password   string `json:"password"`
-	Email      string `json:"email"`
-	IsSysAdmin string `json:"is_sys_admin"`
+// Logout revokes the session the request was made with
+func (a *API) Logout(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := a.userService.RevokeSession(u, u.SessionID)
+		if err != nil && err != users.SessionNotFoundErr {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		cookie := http.Cookie{Name: "token", Value: "", Expires: time.Unix(0, 0), MaxAge: -1, Path: "/", HttpOnly: true}
+		http.SetCookie(w, &cookie)
+
+		sendJSONorErr(responses.Success{Success: true}, w)
+	})
 }
 
-func (a *API) AddUser(u users.User) http.Handler {
+func (a *API) FetchSessions(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ss, err := a.userService.GetSessions(u)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(ss, w)
+	})
+}
+
+func (a *API) RevokeSession(u users.User) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !u.IsSysAdmin {
-			responses.SendError(w, responses.Unauthorized(errors.New("you are not authorized to perform this action")))
+		idStr, err := getRequiredParam(r, "id")
+		if err != nil {
+			responses.SendError(w, responses.MissingParamError("id"))
+			return
+		}
+
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			responses.SendError(w, responses.InvalidParamError("id", err))
+			return
+		}
+
+		err = a.userService.RevokeSession(u, id)
+		if err != nil && err == users.SessionNotFoundErr {
+			responses.SendError(w, responses.SessionNotFound(err))
+			return
+		} else if err != nil {
+			responses.SendError(w, responses.InternalError(err))
 			return
 		}
 
+		sendJSONorErr(responses.Success{Success: true}, w)
+	})
+}
+
+// RevokeSessions logs the user out everywhere, including the current session
+func (a *API) RevokeSessions(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := a.userService.RevokeSessions(u); err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(responses.Success{Success: true}, w)
+	})
+}
+
+// clientIP returns the address the request came from without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+type UserBody struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	// IsSysAdmin is only used when Role is blank, for older clients
+	IsSysAdmin string `json:"is_sys_admin"`
+}
+
+func (a *API) AddUser(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ad := UserBody{}
 		err := parseBody(r, &ad)
 		if err != nil {
@@ -64,7 +145,18 @@ func (a *API) AddUser(u users.User) http.Handler {
 			return
 		}
 
-		if err = a.userService.AddUser(ad.Username, ad.Email, ad.Password, ad.IsSysAdmin == "true"); err != nil {
+		role := ad.Role
+		if role == "" && ad.IsSysAdmin == "true" {
+			role = users.RoleAdmin
+		} else if role == "" {
+			role = users.RoleStockKeeper
+		}
+
+		err = a.userService.AddUser(u.OrgID, ad.Username, ad.Email, ad.Password, role)
+		if err != nil && err == users.RoleNotFoundErr {
+			responses.SendError(w, responses.RoleNotFound(err))
+			return
+		} else if err != nil {
 			responses.SendError(w, responses.InternalError(err))
 			return
 		}
@@ -81,12 +173,7 @@ func (a *API) LoginCheck(u users.User) http.Handler {
 
 func (a *API) FetchUsers(u users.User) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !u.IsSysAdmin {
-			responses.SendError(w, responses.Unauthorized(errors.New("only admins can do a lookup of users")))
-			return
-		}
-
-		us, err := a.userService.GetUsers()
+		us, err := a.userService.GetUsers(u.OrgID)
 		if err != nil {
 			responses.SendError(w, responses.InternalError(err))
 			return
@@ -98,24 +185,19 @@ func (a *API) FetchUsers(u users.User) http.Handler {
 
 func (a *API) DeleteUser(u users.User) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !u.IsSysAdmin {
-			responses.SendError(w, responses.Unauthorized(errors.New("you are not authorized to perform this action")))
-			return
-		}
-
 		uname, err := getRequiredParam(r, "u")
 		if err != nil {
 			responses.SendError(w, responses.MissingParamError("u"))
 			return
 		}
 
-		targetU, err := a.userService.CheckUserByUsername(uname, u.ID)
+		targetU, err := a.userService.CheckUserByUsername(uname, u.OrgID)
 		if err != nil {
 			responses.SendError(w, responses.InternalError(err))
 			return
 		}
 
-		if !targetU.Valid {
+		if !targetU.Valid || targetU.Role == "" {
 			responses.SendError(w, responses.InternalError(errors.New("username not found or already deleted")))
 			return
 		}
@@ -125,7 +207,7 @@ func (a *API) DeleteUser(u users.User) http.Handler {
 			return
 		}
 
-		if err = a.userService.DeleteUser(targetU.ID, u.ID); err != nil {
+		if err = a.userService.DeleteUser(u.OrgID, targetU.ID, u.ID); err != nil {
 			responses.SendError(w, responses.InternalError(err))
 			return
 		}
@@ -170,7 +252,7 @@ func (a *API) ForgotPassword() http.Handler {
 			return
 		}
 
-		if err = a.userService.EditUser(targetU.Username, targetU.Email, newPass, targetU.IsSysAdmin); err != nil {
+		if err = a.userService.ResetPassword(targetU, newPass); err != nil {
 			responses.SendError(w, responses.InternalError(err))
 			return
 		}
@@ -186,3 +268,38 @@ func randomString(len int) string {
 	}
 	return string(bytes)
 }
+
+func (a *API) FetchRoles(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs, err := a.userService.GetRoles(u.OrgID)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(rs, w)
+	})
+}
+
+func (a *API) SetRole(u users.User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role := users.Role{}
+		err := parseBody(r, &role)
+		if err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		if err = role.Validate(); err != nil {
+			responses.SendError(w, responses.InvalidRole(err))
+			return
+		}
+
+		if err = a.userService.SetRole(u.OrgID, role, u.ID); err != nil {
+			responses.SendError(w, responses.InternalError(err))
+			return
+		}
+
+		sendJSONorErr(responses.Success{Success: true}, w)
+	})
+}
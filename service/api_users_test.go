@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/Timothylock/inventory-management/email"
 	"github.com/Timothylock/inventory-management/items"
@@ -32,12 +33,29 @@ func TestLogin(t *testing.T) {
 			setMock: func(up *users.MockPersister) {
 				u := users.User{
 					Valid: true,
+					ID:    123,
+					OrgID: 1,
 				}
 				up.EXPECT().GetUser("someuser", "somepassword").Return(u, nil)
+				up.EXPECT().AddSession(123, 1, "Go-http-client/1.1", "127.0.0.1", gomock.Any()).Return(users.Session{Token: "sometoken", ExpiresAt: time.Now().Add(time.Hour)}, nil)
 			},
 			sendBody:   sb,
 			expectCode: 200,
 		},
+		{
+			testName: "session error",
+			setMock: func(up *users.MockPersister) {
+				u := users.User{
+					Valid: true,
+					ID:    123,
+					OrgID: 1,
+				}
+				up.EXPECT().GetUser("someuser", "somepassword").Return(u, nil)
+				up.EXPECT().AddSession(123, 1, "Go-http-client/1.1", "127.0.0.1", gomock.Any()).Return(users.Session{}, errors.New("error"))
+			},
+			sendBody:   sb,
+			expectCode: 500,
+		},
 		{
 			testName: "bad login",
 			setMock: func(up *users.MockPersister) {
@@ -76,6 +94,14 @@ func TestLogin(t *testing.T) {
 			resp, err := sendPost(server.URL+"/api/user/login", tc.sendBody)
 			assert.NoError(t, err)
 			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				cookies := resp.Cookies()
+				assert.Len(t, cookies, 1)
+				assert.Equal(t, "token", cookies[0].Name)
+				assert.Equal(t, "sometoken", cookies[0].Value)
+				assert.True(t, cookies[0].HttpOnly)
+			}
 		})
 	}
 }
@@ -165,7 +191,7 @@ func TestGetUsers(t *testing.T) {
 			setMock: func(up *users.MockPersister) {
 				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: false}, nil).AnyTimes()
 			},
-			expectCode: 401,
+			expectCode: 403,
 		},
 		{
 			testName: "Success",
@@ -179,8 +205,8 @@ func TestGetUsers(t *testing.T) {
 					},
 				}
 
-				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true}, nil).AnyTimes()
-				up.EXPECT().GetUsers().Return(u, nil)
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, Permissions: users.AllPermissions, OrgID: 1}, nil).AnyTimes()
+				up.EXPECT().GetUsers(1).Return(u, nil)
 			},
 			expectCode: 200,
 			expectedResponse: users.MultipleUsers{
@@ -204,8 +230,8 @@ func TestGetUsers(t *testing.T) {
 					},
 				}
 
-				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true}, nil).AnyTimes()
-				up.EXPECT().GetUsers().Return(u, errors.New("shoot"))
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, Permissions: users.AllPermissions, OrgID: 1}, nil).AnyTimes()
+				up.EXPECT().GetUsers(1).Return(u, errors.New("shoot"))
 			},
 			expectCode: 500,
 			expectedResponse: users.MultipleUsers{
@@ -262,8 +288,8 @@ func TestAddUser(t *testing.T) {
 		{
 			testName: "success",
 			setMock: func(up *users.MockPersister) {
-				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true}, nil).AnyTimes()
-				up.EXPECT().AddUser("someuser", "someEmail", "somepassword", true, false).Return(nil)
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, Permissions: users.AllPermissions, OrgID: 1}, nil).AnyTimes()
+				up.EXPECT().AddUser(1, "someuser", "someEmail", "somepassword", "admin", false).Return(nil)
 			},
 			sendBody:   sb,
 			expectCode: 200,
@@ -274,7 +300,7 @@ func TestAddUser(t *testing.T) {
 				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: false}, nil).AnyTimes()
 			},
 			sendBody:   sb,
-			expectCode: 401,
+			expectCode: 403,
 		},
 		{
 			testName: "not logged in",
@@ -287,8 +313,8 @@ func TestAddUser(t *testing.T) {
 		{
 			testName: "internal error",
 			setMock: func(up *users.MockPersister) {
-				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true}, nil).AnyTimes()
-				up.EXPECT().AddUser("someuser", "someEmail", "somepassword", true, false).Return(errors.New("sorry"))
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, Permissions: users.AllPermissions, OrgID: 1}, nil).AnyTimes()
+				up.EXPECT().AddUser(1, "someuser", "someEmail", "somepassword", "admin", false).Return(errors.New("sorry"))
 			},
 			sendBody:   sb,
 			expectCode: 500,
@@ -338,9 +364,9 @@ func TestDeleteUser(t *testing.T) {
 		{
 			testName: "success",
 			setMock: func(up *users.MockPersister) {
-				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, ID: 12345}, nil).AnyTimes()
-				up.EXPECT().GetUserByUsername("someuser", 12345).Return(users.User{ID: 123, Valid: true}, nil)
-				up.EXPECT().DeleteUser(123, 12345).Return(nil)
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, Permissions: users.AllPermissions, ID: 12345, OrgID: 1}, nil).AnyTimes()
+				up.EXPECT().GetUserByUsername("someuser", 1).Return(users.User{ID: 123, Valid: true, Role: "viewer"}, nil)
+				up.EXPECT().DeleteUser(1, 123, 12345).Return(nil)
 			},
 			uHeader:    "u=someuser",
 			expectCode: 200,
@@ -351,12 +377,12 @@ func TestDeleteUser(t *testing.T) {
 				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: false, ID: 12345}, nil).AnyTimes()
 			},
 			uHeader:    "u=someuser",
-			expectCode: 401,
+			expectCode: 403,
 		},
 		{
 			testName: "missing user",
 			setMock: func(up *users.MockPersister) {
-				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, ID: 12345}, nil).AnyTimes()
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, Permissions: users.AllPermissions, ID: 12345, OrgID: 1}, nil).AnyTimes()
 			},
 			uHeader:    "",
 			expectCode: 400,
@@ -364,8 +390,17 @@ func TestDeleteUser(t *testing.T) {
 		{
 			testName: "user does not exist",
 			setMock: func(up *users.MockPersister) {
-				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, ID: 12345}, nil).AnyTimes()
-				up.EXPECT().GetUserByUsername("someuser", 12345).Return(users.User{}, nil)
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, Permissions: users.AllPermissions, ID: 12345, OrgID: 1}, nil).AnyTimes()
+				up.EXPECT().GetUserByUsername("someuser", 1).Return(users.User{}, nil)
+			},
+			uHeader:    "u=someuser",
+			expectCode: 500,
+		},
+		{
+			testName: "user is not in the org",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, Permissions: users.AllPermissions, ID: 12345, OrgID: 1}, nil).AnyTimes()
+				up.EXPECT().GetUserByUsername("someuser", 1).Return(users.User{ID: 123, Valid: true}, nil)
 			},
 			uHeader:    "u=someuser",
 			expectCode: 500,
@@ -373,8 +408,8 @@ func TestDeleteUser(t *testing.T) {
 		{
 			testName: "cannot get user from username",
 			setMock: func(up *users.MockPersister) {
-				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, ID: 12345}, nil).AnyTimes()
-				up.EXPECT().GetUserByUsername("someuser", 12345).Return(users.User{}, errors.New("some error"))
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, Permissions: users.AllPermissions, ID: 12345, OrgID: 1}, nil).AnyTimes()
+				up.EXPECT().GetUserByUsername("someuser", 1).Return(users.User{}, errors.New("some error"))
 			},
 			uHeader:    "u=someuser",
 			expectCode: 500,
@@ -382,8 +417,8 @@ func TestDeleteUser(t *testing.T) {
 		{
 			testName: "user is system",
 			setMock: func(up *users.MockPersister) {
-				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, ID: 12345}, nil).AnyTimes()
-				up.EXPECT().GetUserByUsername("someuser", 12345).Return(users.User{ID: 0, Valid: true}, nil)
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, Permissions: users.AllPermissions, ID: 12345, OrgID: 1}, nil).AnyTimes()
+				up.EXPECT().GetUserByUsername("someuser", 1).Return(users.User{ID: 0, Valid: true, Role: "viewer"}, nil)
 			},
 			uHeader:    "u=someuser",
 			expectCode: 500,
@@ -391,9 +426,9 @@ func TestDeleteUser(t *testing.T) {
 		{
 			testName: "error",
 			setMock: func(up *users.MockPersister) {
-				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, ID: 12345}, nil).AnyTimes()
-				up.EXPECT().GetUserByUsername("someuser", 12345).Return(users.User{ID: 123, Valid: true}, nil)
-				up.EXPECT().DeleteUser(123, 12345).Return(errors.New("some error"))
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, IsSysAdmin: true, Permissions: users.AllPermissions, ID: 12345, OrgID: 1}, nil).AnyTimes()
+				up.EXPECT().GetUserByUsername("someuser", 1).Return(users.User{ID: 123, Valid: true, Role: "viewer"}, nil)
+				up.EXPECT().DeleteUser(1, 123, 12345).Return(errors.New("some error"))
 			},
 			uHeader:    "u=someuser",
 			expectCode: 500,
@@ -430,9 +465,9 @@ func TestForgotPassword(t *testing.T) {
 		{
 			testName: "success",
 			setMock: func(up *users.MockPersister, es *email.MockSender) {
-				up.EXPECT().GetUserByUsername("someuser", 0).Return(users.User{ID: 123, Username: "foo", Valid: true, IsSysAdmin: true, Email: "foo@foo.ca"}, nil)
+				up.EXPECT().GetUserByUsername("someuser", 0).Return(users.User{ID: 123, Username: "foo", Valid: true, IsSysAdmin: true, Role: "admin", Email: "foo@foo.ca"}, nil)
 				es.EXPECT().DialAndSend(gomock.Any()).Return(nil)
-				up.EXPECT().AddUser("foo", "foo@foo.ca", gomock.Any(), true, true).Return(nil)
+				up.EXPECT().SetPassword(123, gomock.Any()).Return(nil)
 			},
 			uHeader:    "username=someuser&email=foo@foo.ca",
 			expectCode: 200,
@@ -440,7 +475,7 @@ func TestForgotPassword(t *testing.T) {
 		{
 			testName: "incorrect email",
 			setMock: func(up *users.MockPersister, es *email.MockSender) {
-				up.EXPECT().GetUserByUsername("someuser", 0).Return(users.User{ID: 123, Username: "foo", Valid: true, IsSysAdmin: true, Email: "foo@foo.ca"}, nil)
+				up.EXPECT().GetUserByUsername("someuser", 0).Return(users.User{ID: 123, Username: "foo", Valid: true, IsSysAdmin: true, Role: "admin", Email: "foo@foo.ca"}, nil)
 			},
 			uHeader:    "username=someuser&email=foobar@foo.ca",
 			expectCode: 500,
@@ -456,7 +491,7 @@ func TestForgotPassword(t *testing.T) {
 		{
 			testName: "email failed",
 			setMock: func(up *users.MockPersister, es *email.MockSender) {
-				up.EXPECT().GetUserByUsername("someuser", 0).Return(users.User{ID: 123, Username: "foo", Valid: true, IsSysAdmin: true, Email: "foo@foo.ca"}, nil)
+				up.EXPECT().GetUserByUsername("someuser", 0).Return(users.User{ID: 123, Username: "foo", Valid: true, IsSysAdmin: true, Role: "admin", Email: "foo@foo.ca"}, nil)
 				es.EXPECT().DialAndSend(gomock.Any()).Return(errors.New("Someerror"))
 			},
 			uHeader:    "username=someuser&email=foo@foo.ca",
@@ -465,9 +500,9 @@ func TestForgotPassword(t *testing.T) {
 		{
 			testName: "changing pass failed",
 			setMock: func(up *users.MockPersister, es *email.MockSender) {
-				up.EXPECT().GetUserByUsername("someuser", 0).Return(users.User{ID: 123, Username: "foo", Valid: true, IsSysAdmin: true, Email: "foo@foo.ca"}, nil)
+				up.EXPECT().GetUserByUsername("someuser", 0).Return(users.User{ID: 123, Username: "foo", Valid: true, IsSysAdmin: true, Role: "admin", Email: "foo@foo.ca"}, nil)
 				es.EXPECT().DialAndSend(gomock.Any()).Return(nil)
-				up.EXPECT().AddUser("foo", "foo@foo.ca", gomock.Any(), true, true).Return(errors.New("oops"))
+				up.EXPECT().SetPassword(123, gomock.Any()).Return(errors.New("oops"))
 			},
 			uHeader:    "username=someuser&email=foo@foo.ca",
 			expectCode: 500,
@@ -514,3 +549,388 @@ func TestForgotPassword(t *testing.T) {
 		})
 	}
 }
+
+func TestLogout(t *testing.T) {
+	type testCase struct {
+		testName   string
+		setMock    func(up *users.MockPersister)
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, SessionID: 5}, nil).AnyTimes()
+				up.EXPECT().RevokeSession(5, 123).Return(nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "already revoked",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, SessionID: 5}, nil).AnyTimes()
+				up.EXPECT().RevokeSession(5, 123).Return(users.SessionNotFoundErr)
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "not logged in",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: false}, nil).AnyTimes()
+			},
+			expectCode: 401,
+		},
+		{
+			testName: "internal error",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, SessionID: 5}, nil).AnyTimes()
+				up.EXPECT().RevokeSession(5, 123).Return(errors.New("sorry"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			up := users.NewMockPersister(mc)
+			tc.setMock(up)
+
+			server := setupServer(nil, up, t)
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+"/api/user/logout", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				cookies := resp.Cookies()
+				assert.Len(t, cookies, 1)
+				assert.Equal(t, "", cookies[0].Value)
+				assert.True(t, cookies[0].MaxAge < 0)
+			}
+		})
+	}
+}
+
+func TestFetchSessions(t *testing.T) {
+	type testCase struct {
+		testName         string
+		setMock          func(up *users.MockPersister)
+		expectCode       int
+		expectedResponse users.Sessions
+	}
+
+	created := time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, SessionID: 5}, nil).AnyTimes()
+				up.EXPECT().GetSessions(123).Return(users.Sessions{
+					{ID: 5, Token: "secret", CreatedAt: created, UserAgent: "phone", IP: "1.2.3.4"},
+					{ID: 6, Token: "secret2", CreatedAt: created, UserAgent: "laptop", IP: "1.2.3.5"},
+				}, nil)
+			},
+			expectCode: 200,
+			expectedResponse: users.Sessions{
+				{ID: 5, CreatedAt: created, UserAgent: "phone", IP: "1.2.3.4", Current: true},
+				{ID: 6, CreatedAt: created, UserAgent: "laptop", IP: "1.2.3.5"},
+			},
+		},
+		{
+			testName: "not logged in",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: false}, nil).AnyTimes()
+			},
+			expectCode: 401,
+		},
+		{
+			testName: "internal error",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, SessionID: 5}, nil).AnyTimes()
+				up.EXPECT().GetSessions(123).Return(nil, errors.New("sorry"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			up := users.NewMockPersister(mc)
+			tc.setMock(up)
+
+			server := setupServer(nil, up, t)
+			defer server.Close()
+
+			resp, err := sendGet(server.URL + "/api/user/sessions")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				b, err := json.Marshal(tc.expectedResponse)
+				assert.NoError(t, err)
+				assert.JSONEq(t, string(b), string(getBody(t, resp)))
+			}
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	type testCase struct {
+		testName   string
+		setMock    func(up *users.MockPersister)
+		query      string
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123}, nil).AnyTimes()
+				up.EXPECT().RevokeSession(6, 123).Return(nil)
+			},
+			query:      "?id=6",
+			expectCode: 200,
+		},
+		{
+			testName: "missing id",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123}, nil).AnyTimes()
+			},
+			expectCode: 400,
+		},
+		{
+			testName: "bad id",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123}, nil).AnyTimes()
+			},
+			query:      "?id=six",
+			expectCode: 400,
+		},
+		{
+			testName: "not found",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123}, nil).AnyTimes()
+				up.EXPECT().RevokeSession(6, 123).Return(users.SessionNotFoundErr)
+			},
+			query:      "?id=6",
+			expectCode: 404,
+		},
+		{
+			testName: "internal error",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123}, nil).AnyTimes()
+				up.EXPECT().RevokeSession(6, 123).Return(errors.New("sorry"))
+			},
+			query:      "?id=6",
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			up := users.NewMockPersister(mc)
+			tc.setMock(up)
+
+			server := setupServer(nil, up, t)
+			defer server.Close()
+
+			resp, err := sendDelete(server.URL + "/api/user/session" + tc.query)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	type testCase struct {
+		testName   string
+		setMock    func(up *users.MockPersister)
+		expectCode int
+	}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123}, nil).AnyTimes()
+				up.EXPECT().RevokeSessions(123).Return(nil)
+			},
+			expectCode: 200,
+		},
+		{
+			testName: "internal error",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123}, nil).AnyTimes()
+				up.EXPECT().RevokeSessions(123).Return(errors.New("sorry"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			up := users.NewMockPersister(mc)
+			tc.setMock(up)
+
+			server := setupServer(nil, up, t)
+			defer server.Close()
+
+			resp, err := sendDelete(server.URL + "/api/user/sessions")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestFetchRoles(t *testing.T) {
+	type testCase struct {
+		testName         string
+		setMock          func(up *users.MockPersister)
+		expectCode       int
+		expectedResponse users.Roles
+	}
+
+	roles := users.Roles{
+		{Name: users.RoleAdmin, Permissions: users.AllPermissions},
+		{Name: users.RoleViewer, Permissions: []string{users.PermItemView}},
+	}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 1, Permissions: []string{users.PermUserView}}, nil).AnyTimes()
+				up.EXPECT().GetRoles(1).Return(roles, nil)
+			},
+			expectCode:       200,
+			expectedResponse: roles,
+		},
+		{
+			testName: "missing permission",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 1, Permissions: []string{users.PermItemView}}, nil).AnyTimes()
+			},
+			expectCode: 403,
+		},
+		{
+			testName: "internal error",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 1, Permissions: []string{users.PermUserView}}, nil).AnyTimes()
+				up.EXPECT().GetRoles(1).Return(nil, errors.New("sorry"))
+			},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			up := users.NewMockPersister(mc)
+			tc.setMock(up)
+
+			server := setupServer(nil, up, t)
+			defer server.Close()
+
+			resp, err := sendGet(server.URL + "/api/roles")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+
+			if tc.expectCode == 200 {
+				b, err := json.Marshal(tc.expectedResponse)
+				assert.NoError(t, err)
+				assert.JSONEq(t, string(b), string(getBody(t, resp)))
+			}
+		})
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	type testCase struct {
+		testName   string
+		setMock    func(up *users.MockPersister)
+		sendBody   users.Role
+		expectCode int
+	}
+
+	manager := users.User{Valid: true, ID: 123, OrgID: 1, Permissions: []string{users.PermRoleManage}}
+
+	testCases := []testCase{
+		{
+			testName: "success",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(manager, nil).AnyTimes()
+				up.EXPECT().SetRole(1, users.Role{Name: "auditor", Permissions: []string{users.PermItemView}}, 123).Return(nil)
+			},
+			sendBody:   users.Role{Name: "auditor", Permissions: []string{users.PermItemView}},
+			expectCode: 200,
+		},
+		{
+			testName: "unknown permission",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(manager, nil).AnyTimes()
+			},
+			sendBody:   users.Role{Name: "auditor", Permissions: []string{"item.fly"}},
+			expectCode: 400,
+		},
+		{
+			testName: "admin loses permission",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(manager, nil).AnyTimes()
+			},
+			sendBody:   users.Role{Name: users.RoleAdmin, Permissions: []string{users.PermItemView}},
+			expectCode: 400,
+		},
+		{
+			testName: "missing permission",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(users.User{Valid: true, ID: 123, OrgID: 1, Permissions: []string{users.PermUserManage}}, nil).AnyTimes()
+			},
+			sendBody:   users.Role{Name: "auditor", Permissions: []string{users.PermItemView}},
+			expectCode: 403,
+		},
+		{
+			testName: "internal error",
+			setMock: func(up *users.MockPersister) {
+				up.EXPECT().GetUserByToken(gomock.Any()).Return(manager, nil).AnyTimes()
+				up.EXPECT().SetRole(1, gomock.Any(), 123).Return(errors.New("sorry"))
+			},
+			sendBody:   users.Role{Name: "auditor", Permissions: []string{users.PermItemView}},
+			expectCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			up := users.NewMockPersister(mc)
+			tc.setMock(up)
+
+			server := setupServer(nil, up, t)
+			defer server.Close()
+
+			resp, err := sendPost(server.URL+"/api/role", tc.sendBody)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Timothylock/inventory-management/config"
+)
+
+var (
+	NotFoundErr   = errors.New("file not found")
+	InvalidKeyErr = errors.New("file keys must be relative paths without . or .. parts")
+)
+
+// Storage keeps files under keys, which are slash separated paths such as
+// pictures/1/photo.jpg
+type Storage interface {
+	// Put stores the file, replacing any with the same key
+	Put(key string, r io.Reader) error
+	// Get returns NotFoundErr if there is no file with the key
+	Get(key string) (io.ReadCloser, error)
+	// Delete does nothing if there is no file with the key
+	Delete(key string) error
+}
+
+// New returns the storage that the config asks for
+func New(c config.Config) (Storage, error) {
+	switch c.StorageDriver {
+	case config.StorageLocal, "":
+		return NewLocal(c.StoragePath)
+	}
+
+	return nil, fmt.Errorf("unknown storage driver %q", c.StorageDriver)
+}
+
+// Local keeps files in a directory of the local filesystem
+type Local struct {
+	dir string
+}
+
+// NewLocal returns a storage that keeps files in dir, which is created if it
+// does not exist yet
+func NewLocal(dir string) (Local, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Local{}, err
+	}
+
+	return Local{dir: dir}, nil
+}
+
+// Put writes the file next to where it goes first so that nobody reads half
+// of it
+func (l Local) Put(key string, r io.Reader) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(p), ".upload-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), p)
+}
+
+func (l Local) Get(key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, NotFoundErr
+	}
+
+	return f, err
+}
+
+func (l Local) Delete(key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// path returns where the file with the key is kept. Keys that could point
+// outside of the directory are refused.
+func (l Local) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key {
+		return "", InvalidKeyErr
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "." || part == ".." {
+			return "", InvalidKeyErr
+		}
+	}
+
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := NewLocal(filepath.Join(dir, "files"))
+	assert.Nil(t, err)
+
+	_, err = l.Get("pictures/1/a.jpg")
+	assert.Equal(t, NotFoundErr, err)
+
+	assert.Nil(t, l.Put("pictures/1/a.jpg", strings.NewReader("first")))
+	assert.Nil(t, l.Put("pictures/1/a.jpg", strings.NewReader("second")))
+
+	f, err := l.Get("pictures/1/a.jpg")
+	assert.Nil(t, err)
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, "second", string(b))
+
+	// Nothing is left behind by replacing a file
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "files", "pictures", "1"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	assert.Nil(t, l.Delete("pictures/1/a.jpg"))
+	assert.Nil(t, l.Delete("pictures/1/a.jpg"))
+	_, err = l.Get("pictures/1/a.jpg")
+	assert.Equal(t, NotFoundErr, err)
+
+	for _, key := range []string{"", "/etc/passwd", "../secret", "pictures/../../secret", "pictures//a.jpg", "./a.jpg", `pictures\a.jpg`} {
+		assert.Equal(t, InvalidKeyErr, l.Put(key, strings.NewReader("x")), key)
+		_, err = l.Get(key)
+		assert.Equal(t, InvalidKeyErr, err, key)
+	}
+}
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	st, err := New(config.Config{StorageDriver: config.StorageLocal, StoragePath: dir})
+	assert.Nil(t, err)
+	assert.Equal(t, Local{dir: dir}, st)
+
+	_, err = New(config.Config{StorageDriver: "s3", StoragePath: dir})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,51 @@
+package upc
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/Timothylock/inventory-management/config"
+)
+
+const BarcodeLookupURL = "https://api.barcodelookup.com"
+
+// BarcodeLookup looks barcodes up with Barcode Lookup, which needs a key
+type BarcodeLookup struct {
+	URL    string
+	Key    string
+	Client *http.Client
+}
+
+type barcodeLookupResponse struct {
+	Products []struct {
+		Title    string   `json:"title"`
+		Category string   `json:"category"`
+		Images   []string `json:"images"`
+	} `json:"products"`
+}
+
+func (b BarcodeLookup) Name() string {
+	return config.ProviderBarcodeLookup
+}
+
+func (b BarcodeLookup) Lookup(code string) (ItemDetail, error) {
+	q := url.Values{"barcode": {code}, "key": {b.Key}}
+	req, err := http.NewRequest("GET", b.URL+"/v3/products?"+q.Encode(), nil)
+	if err != nil {
+		return ItemDetail{}, err
+	}
+
+	var res barcodeLookupResponse
+	found, err := getJSON(b.Client, req, &res)
+	if err != nil || !found || len(res.Products) == 0 {
+		return ItemDetail{}, err
+	}
+
+	p := res.Products[0]
+	d := ItemDetail{ID: code, Name: p.Title, Category: lastCategory(p.Category, ">")}
+	if len(p.Images) > 0 {
+		d.PictureURL = p.Images[0]
+	}
+
+	return d, nil
+}
@@ -0,0 +1,260 @@
+package upc
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Timothylock/inventory-management/barcode"
+	"github.com/Timothylock/inventory-management/config"
+)
+
+// Formats that datasets can be imported from
+const (
+	DatasetCSV   = "csv"
+	DatasetJSONL = "jsonl"
+)
+
+// datasetBatch is how many products are saved at a time
+const datasetBatch = 500
+
+// maxDatasetErrors is how many problems an import reports. Datasets are big
+// and rarely clean, so the rest are only counted.
+const maxDatasetErrors = 100
+
+var (
+	ProductNotFoundErr      = errors.New("product not found")
+	InvalidDatasetFormatErr = errors.New("dataset format must be csv or jsonl")
+)
+
+// DatasetReadError is returned when the dataset cannot be read any further, as
+// opposed to when saving its products fails
+type DatasetReadError struct {
+	Err error
+}
+
+func (e DatasetReadError) Error() string {
+	return e.Err.Error()
+}
+
+// Product is a product of an imported dataset
+type Product struct {
+	GTIN       string `json:"gtin"`
+	Name       string `json:"name"`
+	Brand      string `json:"brand"`
+	Category   string `json:"category"`
+	PictureURL string `json:"image"`
+}
+
+// detail returns the product as the result of a lookup. The brand is put in
+// front of the name unless the name starts with it already.
+func (p Product) detail(code string) ItemDetail {
+	name := p.Name
+	if p.Brand != "" && !strings.HasPrefix(strings.ToLower(name), strings.ToLower(p.Brand)) {
+		name = p.Brand + " " + name
+	}
+
+	return ItemDetail{ID: code, Name: name, Category: p.Category, PictureURL: p.PictureURL}
+}
+
+// DatasetReport says how an import of a dataset went
+type DatasetReport struct {
+	Imported int `json:"imported"`
+	// Skipped counts the rows with a problem, only the first of which are in
+	// Errors
+	Skipped int           `json:"skipped"`
+	Errors  DatasetErrors `json:"errors"`
+}
+
+type DatasetErrors []DatasetError
+
+// DatasetError is a problem with one row of a dataset
+type DatasetError struct {
+	Line   int    `json:"line"`
+	GTIN   string `json:"gtin"`
+	Reason string `json:"reason"`
+}
+
+// LocalDataset looks barcodes up in the products imported from datasets
+type LocalDataset struct {
+	Persister Persister
+}
+
+func (l LocalDataset) Name() string {
+	return config.ProviderLocal
+}
+
+func (l LocalDataset) Lookup(code string) (ItemDetail, error) {
+	p, err := l.Persister.GetProduct(cacheKey(code))
+	if err == ProductNotFoundErr {
+		return ItemDetail{}, nil
+	} else if err != nil {
+		return ItemDetail{}, err
+	}
+
+	return p.detail(code), nil
+}
+
+// ImportDataset saves the products read from r in the format, replacing any
+// with the same GTIN. Unlike importing items, rows with a problem are skipped
+// and the rest are still imported.
+func (s *Service) ImportDataset(r io.Reader, format string) (DatasetReport, error) {
+	report := DatasetReport{Errors: DatasetErrors{}}
+	if s.persister == nil {
+		return report, errors.New("there is no database to import the dataset into")
+	}
+
+	batch := []Product{}
+	add := func(line int, p Product, err error) error {
+		if err == nil {
+			p, err = checkProduct(p)
+		}
+		if err != nil {
+			report.Skipped++
+			if len(report.Errors) < maxDatasetErrors {
+				report.Errors = append(report.Errors, DatasetError{Line: line, GTIN: p.GTIN, Reason: err.Error()})
+			}
+			return nil
+		}
+
+		batch = append(batch, p)
+		if len(batch) < datasetBatch {
+			return nil
+		}
+		return s.saveProducts(&batch, &report)
+	}
+
+	var err error
+	switch format {
+	case DatasetCSV:
+		err = readDatasetCSV(r, add)
+	case DatasetJSONL:
+		err = readDatasetJSONLines(r, add)
+	default:
+		return report, InvalidDatasetFormatErr
+	}
+	if err != nil {
+		return report, err
+	}
+
+	return report, s.saveProducts(&batch, &report)
+}
+
+func (s *Service) saveProducts(batch *[]Product, report *DatasetReport) error {
+	if len(*batch) == 0 {
+		return nil
+	}
+	if err := s.persister.SaveProducts(*batch); err != nil {
+		return err
+	}
+
+	report.Imported += len(*batch)
+	*batch = (*batch)[:0]
+	return nil
+}
+
+// checkProduct returns the product with its GTIN normalized, or why it cannot
+// be imported
+func checkProduct(p Product) (Product, error) {
+	p.GTIN = strings.TrimSpace(p.GTIN)
+	p.Name = strings.TrimSpace(p.Name)
+	p.Brand = strings.TrimSpace(p.Brand)
+	p.Category = strings.TrimSpace(p.Category)
+	p.PictureURL = strings.TrimSpace(p.PictureURL)
+
+	if p.GTIN == "" {
+		return p, errors.New("gtin is required")
+	}
+	b, err := barcode.Parse(p.GTIN)
+	if err != nil {
+		return p, err
+	}
+	p.GTIN = b.GTIN
+
+	if p.Name == "" {
+		return p, errors.New("name is required")
+	}
+
+	return p, nil
+}
+
+// readDatasetCSV reads rows with a header naming the fields of Product they
+// hold. Other columns are ignored so that datasets can be imported as they are
+// published.
+func readDatasetCSV(r io.Reader, add func(line int, p Product, err error) error) error {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return DatasetReadError{errors.New("must start with a header row")}
+	} else if err != nil {
+		return DatasetReadError{err}
+	}
+
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, c := range []string{"gtin", "name"} {
+		if _, ok := columns[c]; !ok {
+			return DatasetReadError{fmt.Errorf("the header must have a %s column", c)}
+		}
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return DatasetReadError{err}
+		}
+		line, _ := cr.FieldPos(0)
+
+		get := func(c string) string {
+			if i, ok := columns[c]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		p := Product{GTIN: get("gtin"), Name: get("name"), Brand: get("brand"), Category: get("category"), PictureURL: get("image")}
+		if err = add(line, p, nil); err != nil {
+			return err
+		}
+	}
+}
+
+// readDatasetJSONLines reads a Product from each line that is not blank
+func readDatasetJSONLines(r io.Reader, add func(line int, p Product, err error) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+
+		p := Product{}
+		if err := json.Unmarshal(sc.Bytes(), &p); err != nil {
+			err = errors.New("is not valid JSON: " + err.Error())
+			if err = add(line, Product{}, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := add(line, p, nil); err != nil {
+			return err
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return DatasetReadError{err}
+	}
+	return nil
+}
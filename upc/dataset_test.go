@@ -0,0 +1,194 @@
+package upc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportDataset(t *testing.T) {
+	type testCase struct {
+		testName       string
+		format         string
+		data           string
+		setMock        func(*MockPersister)
+		expectedReport DatasetReport
+		expectedErr    string
+	}
+
+	tissues := Product{GTIN: "00036000291452", Name: "Tissues", Brand: "Kleenex", Category: "Paper", PictureURL: "https://example.com/t.jpg"}
+	book := Product{GTIN: "09780306406157", Name: "Modern physics"}
+
+	testCases := []testCase{
+		{
+			testName: "csv",
+			format:   DatasetCSV,
+			data: "Name,GTIN,Brand,Category,Image,Countries\n" +
+				"Tissues, 036000291452,Kleenex,Paper,https://example.com/t.jpg,US\n" +
+				"Modern physics,0-306-40615-2\n" +
+				"No barcode,,,\n" +
+				"Bad check digit,036000291453\n" +
+				",4006381333931\n",
+			setMock: func(p *MockPersister) {
+				p.EXPECT().SaveProducts([]Product{tissues, book}).Return(nil)
+			},
+			expectedReport: DatasetReport{Imported: 2, Skipped: 3, Errors: DatasetErrors{
+				{Line: 4, Reason: "gtin is required"},
+				{Line: 5, GTIN: "036000291453", Reason: "the check digit of the barcode is wrong"},
+				{Line: 6, GTIN: "04006381333931", Reason: "name is required"},
+			}},
+		},
+		{
+			testName: "json lines",
+			format:   DatasetJSONL,
+			data: `{"gtin":"036000291452","name":"Tissues","brand":"Kleenex","category":"Paper","image":"https://example.com/t.jpg"}` + "\n\n" +
+				`{"gtin":"9780306406157","name":"Modern physics"` + "\n" +
+				`{"gtin":"9780306406157","name":"Modern physics"}` + "\n",
+			setMock: func(p *MockPersister) {
+				p.EXPECT().SaveProducts([]Product{tissues, book}).Return(nil)
+			},
+			expectedReport: DatasetReport{Imported: 2, Skipped: 1, Errors: DatasetErrors{
+				{Line: 3, Reason: "is not valid JSON: unexpected end of JSON input"},
+			}},
+		},
+		{
+			testName:       "csv without a gtin column",
+			format:         DatasetCSV,
+			data:           "name,barcode\nTissues,036000291452\n",
+			setMock:        func(p *MockPersister) {},
+			expectedReport: DatasetReport{Errors: DatasetErrors{}},
+			expectedErr:    "the header must have a gtin column",
+		},
+		{
+			testName:       "unknown format",
+			format:         "xml",
+			setMock:        func(p *MockPersister) {},
+			expectedReport: DatasetReport{Errors: DatasetErrors{}},
+			expectedErr:    InvalidDatasetFormatErr.Error(),
+		},
+		{
+			testName: "error",
+			format:   DatasetCSV,
+			data:     "gtin,name\n036000291452,Tissues\n",
+			setMock: func(p *MockPersister) {
+				p.EXPECT().SaveProducts(gomock.Any()).Return(errors.New("some error"))
+			},
+			expectedReport: DatasetReport{Errors: DatasetErrors{}},
+			expectedErr:    "some error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			mc := gomock.NewController(t)
+			defer mc.Finish()
+
+			p := NewMockPersister(mc)
+			tc.setMock(p)
+
+			s := NewService(p, config.Config{})
+			report, err := s.ImportDataset(strings.NewReader(tc.data), tc.format)
+			if tc.expectedErr == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+			assert.Equal(t, tc.expectedReport, report)
+		})
+	}
+}
+
+func TestImportDatasetBatches(t *testing.T) {
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+
+	// EAN-8s are 7 digits and a check digit that weights them 3 and 1 in turn
+	data := "gtin,name\n"
+	for i := 0; i < datasetBatch+10; i++ {
+		code := fmt.Sprintf("%07d", 2000000+i)
+		sum := 0
+		for j, c := range code {
+			sum += int(c-'0') * (3 - 2*(j%2))
+		}
+		data += fmt.Sprintf("%s%d,Product\n", code, (10-sum%10)%10)
+	}
+	data += "1234,Too short\n"
+
+	// Only the first errors are reported
+	for i := 0; i < maxDatasetErrors+5; i++ {
+		data += "1234,Too short\n"
+	}
+
+	p := NewMockPersister(mc)
+	gomock.InOrder(
+		p.EXPECT().SaveProducts(gomock.Any()).DoAndReturn(func(ps []Product) error {
+			assert.Equal(t, datasetBatch, len(ps))
+			return nil
+		}),
+		p.EXPECT().SaveProducts(gomock.Any()).DoAndReturn(func(ps []Product) error {
+			assert.Equal(t, 10, len(ps))
+			return nil
+		}),
+	)
+
+	s := NewService(p, config.Config{})
+	report, err := s.ImportDataset(strings.NewReader(data), DatasetCSV)
+	assert.Nil(t, err)
+	assert.Equal(t, datasetBatch+10, report.Imported)
+	assert.Equal(t, maxDatasetErrors+6, report.Skipped)
+	assert.Equal(t, maxDatasetErrors, len(report.Errors))
+}
+
+func TestLocalDataset(t *testing.T) {
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+
+	p := NewMockPersister(mc)
+	p.EXPECT().GetProduct("00036000291452").Return(Product{GTIN: "00036000291452", Name: "Tissues", Brand: "Kleenex", Category: "Paper"}, nil)
+	p.EXPECT().GetProduct("09780306406157").Return(Product{GTIN: "09780306406157", Name: "Kleenex tissues", Brand: "kleenex"}, nil)
+	p.EXPECT().GetProduct("04006381333931").Return(Product{}, ProductNotFoundErr)
+
+	l := LocalDataset{Persister: p}
+
+	d, err := l.Lookup("036000291452")
+	assert.Nil(t, err)
+	assert.Equal(t, ItemDetail{ID: "036000291452", Name: "Kleenex Tissues", Category: "Paper"}, d)
+
+	// The brand is not repeated
+	d, err = l.Lookup("0306406152")
+	assert.Nil(t, err)
+	assert.Equal(t, "Kleenex tissues", d.Name)
+
+	d, err = l.Lookup("4006381333931")
+	assert.Nil(t, err)
+	assert.Equal(t, ItemDetail{}, d)
+
+	// Products in the dataset are not looked up online even when details are
+	// missing, while the rest still are
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintln(w, `{"product":{"name":"Pencil"}}`)
+	}))
+	defer ts.Close()
+
+	p.EXPECT().GetProduct("00036000291452").Return(Product{GTIN: "00036000291452", Name: "Tissues"}, nil)
+	p.EXPECT().GetProduct("04006381333931").Return(Product{}, ProductNotFoundErr)
+	chain := Chain{l, UpcAPI{URL: ts.URL + "/upc", Client: ts.Client()}}
+
+	d, err = chain.Lookup("036000291452")
+	assert.Nil(t, err)
+	assert.Equal(t, ItemDetail{ID: "036000291452", Name: "Tissues"}, d)
+
+	d, err = chain.Lookup("4006381333931")
+	assert.Nil(t, err)
+	assert.Equal(t, "Pencil", d.Name)
+	assert.Equal(t, 1, requests)
+}
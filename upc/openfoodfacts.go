@@ -0,0 +1,51 @@
+package upc
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/Timothylock/inventory-management/config"
+)
+
+const OpenFoodFactsURL = "https://world.openfoodfacts.org"
+
+// OpenFoodFacts looks barcodes up in Open Food Facts, which knows food and
+// drink but little else
+type OpenFoodFacts struct {
+	URL    string
+	Client *http.Client
+}
+
+type openFoodFactsResponse struct {
+	Status  int `json:"status"`
+	Product struct {
+		ProductName string `json:"product_name"`
+		Categories  string `json:"categories"`
+		ImageURL    string `json:"image_url"`
+	} `json:"product"`
+}
+
+func (o OpenFoodFacts) Name() string {
+	return config.ProviderOpenFoodFacts
+}
+
+func (o OpenFoodFacts) Lookup(code string) (ItemDetail, error) {
+	req, err := http.NewRequest("GET", o.URL+"/api/v0/product/"+url.PathEscape(code)+".json", nil)
+	if err != nil {
+		return ItemDetail{}, err
+	}
+
+	var res openFoodFactsResponse
+	found, err := getJSON(o.Client, req, &res)
+	// Status is 0 when there is no such product
+	if err != nil || !found || res.Status != 1 {
+		return ItemDetail{}, err
+	}
+
+	return ItemDetail{
+		ID:         code,
+		Name:       res.Product.ProductName,
+		Category:   lastCategory(res.Product.Categories, ","),
+		PictureURL: res.Product.ImageURL,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package upc
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Timothylock/inventory-management/barcode"
+	"github.com/Timothylock/inventory-management/config"
+)
+
+const OpenLibraryURL = "https://openlibrary.org"
+
+// bookCategory is the category of everything found in Open Library
+const bookCategory = "Books"
+
+// OpenLibrary looks ISBNs up in Open Library. Other barcodes are not looked up.
+type OpenLibrary struct {
+	URL    string
+	Client *http.Client
+}
+
+type openLibraryBook struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle"`
+	Cover    struct {
+		Medium string `json:"medium"`
+	} `json:"cover"`
+}
+
+func (o OpenLibrary) Name() string {
+	return config.ProviderOpenLibrary
+}
+
+func (o OpenLibrary) Lookup(code string) (ItemDetail, error) {
+	b, err := barcode.Parse(code)
+	if err != nil || !(strings.HasPrefix(b.GTIN, "0978") || strings.HasPrefix(b.GTIN, "0979")) {
+		return ItemDetail{}, nil
+	}
+	key := "ISBN:" + b.GTIN[1:]
+
+	req, err := http.NewRequest("GET", o.URL+"/api/books?format=json&jscmd=data&bibkeys="+url.QueryEscape(key), nil)
+	if err != nil {
+		return ItemDetail{}, err
+	}
+
+	// Books that are not found are left out of the answer
+	res := map[string]openLibraryBook{}
+	found, err := getJSON(o.Client, req, &res)
+	book, ok := res[key]
+	if err != nil || !found || !ok {
+		return ItemDetail{}, err
+	}
+
+	name := book.Title
+	if book.Subtitle != "" {
+		name += ": " + book.Subtitle
+	}
+
+	return ItemDetail{
+		ID:         code,
+		Name:       name,
+		Category:   bookCategory,
+		PictureURL: book.Cover.Medium,
+	}, nil
+}
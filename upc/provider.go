@@ -0,0 +1,163 @@
+package upc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Timothylock/inventory-management/config"
+)
+
+// Errors of lookups. They are the same whichever provider failed so that the
+// scan pages can tell the user what went wrong.
+var (
+	NotFoundErr     = errors.New("no provider knows the barcode")
+	RateLimitedErr  = errors.New("too many barcodes were looked up, try again later")
+	UnavailableErr  = errors.New("the barcode lookup provider could not be reached")
+	UnauthorizedErr = errors.New("the barcode lookup provider did not accept its key")
+)
+
+// DefaultProviders are used when the config does not list any
+var DefaultProviders = []string{config.ProviderLocal, config.ProviderUpc}
+
+// userAgent is sent to the providers that ask callers to identify themselves
+const userAgent = "inventory-management (+https://github.com/Timothylock/inventory-management)"
+
+// Provider looks barcodes up in one product database. Details the provider
+// does not know, or all of them if it does not know the barcode, are blank.
+// Failures are one of the errors above.
+type Provider interface {
+	Name() string
+	Lookup(code string) (ItemDetail, error)
+}
+
+// Chain asks each provider in turn until the details of the product are
+// complete. Each detail is taken from the first provider that knows it. A
+// product found in the local dataset is returned as it is, without waiting on
+// the providers after it.
+type Chain []Provider
+
+// NewProviders returns the chain of providers listed in the config. The local
+// dataset is left out when there is no persister.
+func NewProviders(c config.Config, p Persister, client *http.Client) Chain {
+	names := c.UpcProviders
+	if len(names) == 0 {
+		names = DefaultProviders
+	}
+
+	chain := Chain{}
+	for _, name := range names {
+		switch name {
+		case config.ProviderLocal:
+			if p != nil {
+				chain = append(chain, LocalDataset{Persister: p})
+			}
+		case config.ProviderUpc:
+			chain = append(chain, UpcAPI{URL: c.UpcUrl, Token: c.UpcToken, Client: client})
+		case config.ProviderUpcItemDB:
+			chain = append(chain, UpcItemDB{URL: UpcItemDBURL, Key: c.UpcItemdbKey, Client: client})
+		case config.ProviderOpenFoodFacts:
+			chain = append(chain, OpenFoodFacts{URL: OpenFoodFactsURL, Client: client})
+		case config.ProviderOpenLibrary:
+			chain = append(chain, OpenLibrary{URL: OpenLibraryURL, Client: client})
+		case config.ProviderBarcodeLookup:
+			chain = append(chain, BarcodeLookup{URL: BarcodeLookupURL, Key: c.UpcBarcodelookupKey, Client: client})
+		}
+	}
+
+	return chain
+}
+
+// Lookup merges what the providers know about the barcode. A provider that
+// fails is skipped, and its error is only returned if no provider knew the
+// name of the product. NotFoundErr is returned when none of them failed.
+func (c Chain) Lookup(code string) (ItemDetail, error) {
+	res := ItemDetail{ID: code}
+	var firstErr error
+	for _, p := range c {
+		d, err := p.Lookup(code)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		res.merge(d)
+		if _, local := p.(LocalDataset); local && res.Name != "" {
+			break
+		}
+		if res.Name != "" && res.Category != "" && res.PictureURL != "" {
+			break
+		}
+	}
+
+	if res.Name == "" && firstErr != nil {
+		return ItemDetail{}, firstErr
+	} else if res.Name == "" {
+		return ItemDetail{}, NotFoundErr
+	}
+	return res, nil
+}
+
+// merge fills in the details that are still blank from d
+func (i *ItemDetail) merge(d ItemDetail) {
+	if i.Name == "" {
+		i.Name = strings.TrimSpace(d.Name)
+	}
+	if i.Category == "" {
+		i.Category = strings.TrimSpace(d.Category)
+	}
+	if i.PictureURL == "" {
+		i.PictureURL = strings.TrimSpace(d.PictureURL)
+	}
+}
+
+// getJSON sends the request and decodes the JSON answer into v. It returns
+// false without an error when the provider answers that there is no such
+// product.
+func getJSON(client *http.Client, req *http.Request, v interface{}) (bool, error) {
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return false, UnavailableErr
+	}
+	defer res.Body.Close()
+
+	if found, err := checkStatus(res); !found || err != nil {
+		return false, err
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(v); err != nil {
+		return false, UnavailableErr
+	}
+	return true, nil
+}
+
+// checkStatus returns the error that the status of the answer stands for. It
+// returns false without an error when the provider answers that there is no
+// such product, which some do for barcodes they think are invalid.
+func checkStatus(res *http.Response) (bool, error) {
+	switch {
+	case res.StatusCode == http.StatusOK:
+		return true, nil
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusBadRequest:
+		return false, nil
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		return false, UnauthorizedErr
+	case res.StatusCode == http.StatusTooManyRequests:
+		return false, RateLimitedErr
+	default:
+		return false, UnavailableErr
+	}
+}
+
+// lastCategory returns the most specific of the categories, which providers
+// list from the broadest to the narrowest
+func lastCategory(categories, sep string) string {
+	parts := strings.Split(categories, sep)
+	return strings.TrimSpace(parts[len(parts)-1])
+}
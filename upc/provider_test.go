@@ -0,0 +1,278 @@
+package upc
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Timothylock/inventory-management/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// newProviderServer stands in for every provider. Each of them knows different
+// details of 036000291452, and Open Library knows 9780306406157.
+func newProviderServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/upc", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, userAgent, r.Header.Get("User-Agent"))
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		fmt.Fprintln(w, `{"product":{"name":""}}`)
+	})
+	mux.HandleFunc("/upcitemdb/trial/lookup", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("upc") != "036000291452" {
+			fmt.Fprintln(w, `{"code":"OK","total":0,"items":[]}`)
+			return
+		}
+		fmt.Fprintln(w, `{"code":"OK","total":1,"items":[{"title":"Tissues","category":"Health & Beauty > Personal Care > Tissues","images":[]}]}`)
+	})
+	mux.HandleFunc("/upcitemdb/v1/lookup", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "someKey", r.Header.Get("user_key"))
+		fmt.Fprintln(w, `{"code":"OK","total":1,"items":[{"title":"Paid tissues"}]}`)
+	})
+	mux.HandleFunc("/off/api/v0/product/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/off/api/v0/product/036000291452.json" {
+			fmt.Fprintln(w, `{"status":0,"status_verbose":"product not found"}`)
+			return
+		}
+		fmt.Fprintln(w, `{"status":1,"product":{"product_name":"Facial tissues","categories":"Paper, Tissues","image_url":"https://example.com/off.jpg"}}`)
+	})
+	mux.HandleFunc("/openlibrary/api/books", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("bibkeys") != "ISBN:9780306406157" {
+			fmt.Fprintln(w, `{}`)
+			return
+		}
+		fmt.Fprintln(w, `{"ISBN:9780306406157":{"title":"Modern physics","subtitle":"an introduction","cover":{"medium":"https://example.com/cover.jpg"}}}`)
+	})
+	mux.HandleFunc("/barcodelookup/v3/products", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "someKey", r.URL.Query().Get("key"))
+		if r.URL.Query().Get("barcode") != "036000291452" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintln(w, `{"products":[{"title":"Kleenex tissues","category":"Home > Paper","images":["https://example.com/bl.jpg"]}]}`)
+	})
+	mux.HandleFunc("/down/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestProviders(t *testing.T) {
+	ts := newProviderServer(t)
+	defer ts.Close()
+
+	type testCase struct {
+		testName string
+		provider Provider
+		code     string
+		expected ItemDetail
+	}
+
+	testCases := []testCase{
+		{
+			testName: "upcitemdb",
+			provider: UpcItemDB{URL: ts.URL + "/upcitemdb", Client: ts.Client()},
+			code:     "036000291452",
+			expected: ItemDetail{ID: "036000291452", Name: "Tissues", Category: "Tissues"},
+		},
+		{
+			testName: "upcitemdb with a key",
+			provider: UpcItemDB{URL: ts.URL + "/upcitemdb", Key: "someKey", Client: ts.Client()},
+			code:     "036000291452",
+			expected: ItemDetail{ID: "036000291452", Name: "Paid tissues"},
+		},
+		{
+			testName: "upcitemdb not found",
+			provider: UpcItemDB{URL: ts.URL + "/upcitemdb", Client: ts.Client()},
+			code:     "4006381333931",
+		},
+		{
+			testName: "openfoodfacts",
+			provider: OpenFoodFacts{URL: ts.URL + "/off", Client: ts.Client()},
+			code:     "036000291452",
+			expected: ItemDetail{ID: "036000291452", Name: "Facial tissues", Category: "Tissues", PictureURL: "https://example.com/off.jpg"},
+		},
+		{
+			testName: "openfoodfacts not found",
+			provider: OpenFoodFacts{URL: ts.URL + "/off", Client: ts.Client()},
+			code:     "4006381333931",
+		},
+		{
+			testName: "openlibrary with an ISBN-10",
+			provider: OpenLibrary{URL: ts.URL + "/openlibrary", Client: ts.Client()},
+			code:     "0306406152",
+			expected: ItemDetail{ID: "0306406152", Name: "Modern physics: an introduction", Category: "Books", PictureURL: "https://example.com/cover.jpg"},
+		},
+		{
+			testName: "openlibrary not found",
+			provider: OpenLibrary{URL: ts.URL + "/openlibrary", Client: ts.Client()},
+			code:     "9780804429573",
+		},
+		{
+			testName: "openlibrary skips barcodes that are not ISBNs",
+			provider: OpenLibrary{URL: ts.URL + "/down", Client: ts.Client()},
+			code:     "036000291452",
+		},
+		{
+			testName: "barcodelookup",
+			provider: BarcodeLookup{URL: ts.URL + "/barcodelookup", Key: "someKey", Client: ts.Client()},
+			code:     "036000291452",
+			expected: ItemDetail{ID: "036000291452", Name: "Kleenex tissues", Category: "Paper", PictureURL: "https://example.com/bl.jpg"},
+		},
+		{
+			testName: "barcodelookup not found",
+			provider: BarcodeLookup{URL: ts.URL + "/barcodelookup", Key: "someKey", Client: ts.Client()},
+			code:     "4006381333931",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			d, err := tc.provider.Lookup(tc.code)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, d)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	ts := newProviderServer(t)
+	defer ts.Close()
+
+	down := UpcAPI{URL: ts.URL + "/nowhere", Client: ts.Client()}
+	upcAPI := UpcAPI{URL: ts.URL + "/upc", Client: ts.Client()}
+	upcitemdb := UpcItemDB{URL: ts.URL + "/upcitemdb", Client: ts.Client()}
+	off := OpenFoodFacts{URL: ts.URL + "/off", Client: ts.Client()}
+	ol := OpenLibrary{URL: ts.URL + "/openlibrary", Client: ts.Client()}
+	bl := BarcodeLookup{URL: ts.URL + "/down", Client: ts.Client()}
+
+	// Details come from the first provider that knows them
+	d, err := Chain{down, upcAPI, upcitemdb, off, ol}.Lookup("036000291452")
+	assert.Nil(t, err)
+	assert.Equal(t, ItemDetail{ID: "036000291452", Name: "Tissues", Category: "Tissues", PictureURL: "https://example.com/off.jpg"}, d)
+
+	d, err = Chain{upcitemdb, off, ol}.Lookup("0306406152")
+	assert.Nil(t, err)
+	assert.Equal(t, ItemDetail{ID: "0306406152", Name: "Modern physics: an introduction", Category: "Books", PictureURL: "https://example.com/cover.jpg"}, d)
+
+	_, err = Chain{upcAPI, off}.Lookup("4006381333931")
+	assert.Equal(t, NotFoundErr, err)
+
+	// A provider failing is reported instead of the barcode not being found,
+	// since that provider might have known it
+	_, err = Chain{off, bl}.Lookup("4006381333931")
+	assert.Equal(t, UnavailableErr, err)
+}
+
+func TestUpcAPIGzip(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		fmt.Fprintln(gz, `{"product":{"name":"Tissues"}}`)
+		gz.Close()
+	}))
+	defer ts.Close()
+
+	d, err := UpcAPI{URL: ts.URL, Client: ts.Client()}.Lookup("036000291452")
+	assert.Nil(t, err)
+	assert.Equal(t, ItemDetail{ID: "036000291452", Name: "Tissues"}, d)
+}
+
+func TestProviderErrors(t *testing.T) {
+	type testCase struct {
+		testName    string
+		handler     http.HandlerFunc
+		expectedErr error
+	}
+
+	status := func(code int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}
+	}
+
+	testCases := []testCase{
+		{
+			testName:    "unauthorized",
+			handler:     status(http.StatusUnauthorized),
+			expectedErr: UnauthorizedErr,
+		},
+		{
+			testName:    "forbidden",
+			handler:     status(http.StatusForbidden),
+			expectedErr: UnauthorizedErr,
+		},
+		{
+			testName:    "rate limited",
+			handler:     status(http.StatusTooManyRequests),
+			expectedErr: RateLimitedErr,
+		},
+		{
+			testName:    "server error",
+			handler:     status(http.StatusInternalServerError),
+			expectedErr: UnavailableErr,
+		},
+		{
+			testName: "not json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, "<html>Maintenance</html>")
+			},
+			expectedErr: UnavailableErr,
+		},
+		{
+			testName: "not gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				fmt.Fprintln(w, `{"product":{"name":"someName"}}`)
+			},
+			expectedErr: UnavailableErr,
+		},
+		{
+			testName:    "not found",
+			handler:     status(http.StatusNotFound),
+			expectedErr: NotFoundErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			ts := httptest.NewServer(tc.handler)
+			defer ts.Close()
+
+			for _, p := range []Provider{
+				UpcAPI{URL: ts.URL, Client: ts.Client()},
+				UpcItemDB{URL: ts.URL, Client: ts.Client()},
+				OpenFoodFacts{URL: ts.URL, Client: ts.Client()},
+				OpenLibrary{URL: ts.URL, Client: ts.Client()},
+				BarcodeLookup{URL: ts.URL, Client: ts.Client()},
+			} {
+				_, err := Chain{p}.Lookup("9780306406157")
+				assert.Equal(t, tc.expectedErr, err, p.Name())
+			}
+		})
+	}
+
+	// Providers that cannot be reached are unavailable
+	ts := httptest.NewServer(status(http.StatusOK))
+	ts.Close()
+	_, err := UpcAPI{URL: ts.URL, Client: ts.Client()}.Lookup("9780306406157")
+	assert.Equal(t, UnavailableErr, err)
+}
+
+func TestNewProviders(t *testing.T) {
+	chain := NewProviders(config.Config{}, nil, http.DefaultClient)
+	assert.Equal(t, 1, len(chain))
+	assert.Equal(t, config.ProviderUpc, chain[0].Name())
+
+	names := []string{config.ProviderOpenLibrary, config.ProviderUpcItemDB, config.ProviderBarcodeLookup, config.ProviderOpenFoodFacts, config.ProviderUpc}
+	chain = NewProviders(config.Config{UpcProviders: names}, nil, http.DefaultClient)
+	for i, p := range chain {
+		assert.Equal(t, names[i], p.Name())
+	}
+	assert.Equal(t, len(names), len(chain))
+}
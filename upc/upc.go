@@ -1,41 +1,73 @@
 package upc
 
 import (
-	"compress/gzip"
-	"encoding/json"
-	"io"
-	"io/ioutil"
+	"errors"
 	"net/http"
-	"strings"
 	"time"
 
+	"github.com/Timothylock/inventory-management/barcode"
 	"github.com/Timothylock/inventory-management/config"
 )
 
+// How long lookup results are cached for when the config does not say
+const (
+	DefaultCacheTTL     = 720 * time.Hour
+	DefaultCacheMissTTL = 24 * time.Hour
+)
+
+var CacheMissErr = errors.New("barcode is not in the cache")
+
+type Persister interface {
+	GetCachedLookup(barcode string) (CachedLookup, error)
+	// CacheLookup stores the lookup, replacing any earlier one of the barcode
+	CacheLookup(c CachedLookup) error
+	DeleteCachedLookup(barcode string) error
+
+	GetProduct(gtin string) (Product, error)
+	// SaveProducts stores products of a dataset, replacing any with the same GTIN
+	SaveProducts(ps []Product) error
+}
+
+// CachedLookup is the result of looking up a barcode. Found is false when none
+// of the providers knew the barcode.
+type CachedLookup struct {
+	Barcode   string
+	Found     bool
+	Item      ItemDetail
+	FetchedAt time.Time
+}
+
 type Service struct {
-	config config.Config
-	client *http.Client
+	persister Persister
+	providers Chain
+	ttl       time.Duration
+	missTTL   time.Duration
 }
 
-func NewService(c config.Config) Service {
+// NewService returns a service that looks barcodes up with the providers of
+// the config and caches the results with p, or that always asks the providers
+// when p is nil
+func NewService(p Persister, c config.Config) Service {
+	ttl := c.UpcCacheTtl
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	missTTL := c.UpcCacheMissTtl
+	if missTTL <= 0 {
+		missTTL = DefaultCacheMissTTL
+	}
+
 	return Service{
-		config: c,
-		client: &http.Client{
+		persister: p,
+		providers: NewProviders(c, p, &http.Client{
 			Timeout: 10000 * time.Millisecond,
-		},
+		}),
+		ttl:     ttl,
+		missTTL: missTTL,
 	}
 }
 
-type LookupAPIResponse struct {
-	Product struct {
-		Name     string `json:"name"`
-		ImageURL string `json:"image_url"`
-		Category struct {
-			Name string `json:"name"`
-		} `json:"category"`
-	} `json:"product"`
-}
-
 type ItemDetail struct {
 	ID         string `json:"id"`
 	Name       string `json:"name"`
@@ -43,64 +75,72 @@ type ItemDetail struct {
 	PictureURL string `json:"pictureURL"`
 }
 
-type LookupBody struct {
-	BarcodeNumber string `json:"barcode_number"`
-	Token         string `json:"token"`
-}
-
-func (s *Service) LookupBarcode(barcode string) (ItemDetail, error) {
-	payload := LookupBody{
-		BarcodeNumber: barcode,
-		Token:         s.config.UpcToken,
-	}
-
-	payloadStr, err := json.Marshal(payload)
-	if err != nil {
-		return ItemDetail{}, err
+// LookupBarcode returns the cached result of the barcode if it has not expired
+// and asks the providers otherwise. Barcodes none of them know are
+// NotFoundErr.
+func (s *Service) LookupBarcode(code string) (ItemDetail, error) {
+	if s.persister == nil {
+		return s.providers.Lookup(code)
 	}
 
-	req, err := http.NewRequest("POST", s.config.UpcUrl, strings.NewReader(string(payloadStr)))
-	if err != nil {
+	c, err := s.persister.GetCachedLookup(cacheKey(code))
+	if err != nil && err != CacheMissErr {
 		return ItemDetail{}, err
+	} else if err == nil && time.Since(c.FetchedAt) < s.expiry(c) {
+		if !c.Found {
+			return ItemDetail{}, NotFoundErr
+		}
+		c.Item.ID = code
+		return c.Item, nil
 	}
 
-	req.Header.Add("user-agent", "Dalvik/2.1.0")
-	req.Header.Add("accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8")
-	req.Header.Add("accept-encoding", "gzip")
-	req.Header.Add("accept-language", "en-US,en;q=0.9")
-	req.Header.Add("content-type", "application/json")
+	return s.RefreshBarcode(code)
+}
 
-	res, err := s.client.Do(req)
-	if err != nil {
-		return ItemDetail{}, err
+// RefreshBarcode asks the providers for the barcode even if it is cached and
+// caches the result. Failed lookups are not cached, but barcodes that no
+// provider knows are.
+func (s *Service) RefreshBarcode(code string) (ItemDetail, error) {
+	item, err := s.providers.Lookup(code)
+	if (err != nil && err != NotFoundErr) || s.persister == nil {
+		return item, err
 	}
-	defer res.Body.Close()
-
-	// Check that the server actually sent compressed data
-	var reader io.ReadCloser
-	switch res.Header.Get("Content-Encoding") {
-	case "gzip":
-		reader, err = gzip.NewReader(res.Body)
-		defer reader.Close()
-	default:
-		reader = res.Body
+
+	cerr := s.persister.CacheLookup(CachedLookup{
+		Barcode:   cacheKey(code),
+		Found:     err == nil,
+		Item:      item,
+		FetchedAt: time.Now().UTC(),
+	})
+	if cerr != nil {
+		return ItemDetail{}, cerr
 	}
-	defer reader.Close()
 
-	body, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return ItemDetail{}, err
+	return item, err
+}
+
+// InvalidateBarcode removes the cached result of the barcode so that the next
+// lookup asks the providers
+func (s *Service) InvalidateBarcode(code string) error {
+	if s.persister == nil {
+		return nil
 	}
 
-	var apiReponse = new(LookupAPIResponse)
-	if err = json.Unmarshal(body, &apiReponse); err != nil {
-		return ItemDetail{}, err
+	return s.persister.DeleteCachedLookup(cacheKey(code))
+}
+
+func (s *Service) expiry(c CachedLookup) time.Duration {
+	if c.Found {
+		return s.ttl
 	}
+	return s.missTTL
+}
 
-	return ItemDetail{
-		ID:         barcode,
-		Name:       apiReponse.Product.Name,
-		Category:   apiReponse.Product.Category.Name,
-		PictureURL: apiReponse.Product.ImageURL,
-	}, nil
+// cacheKey returns the GTIN of the barcode so that every form of it shares one
+// cached result
+func cacheKey(code string) string {
+	if b, err := barcode.Parse(code); err == nil {
+		return b.GTIN
+	}
+	return code
 }
@@ -0,0 +1,67 @@
+package upc
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Timothylock/inventory-management/config"
+)
+
+type LookupAPIResponse struct {
+	Product struct {
+		Name     string `json:"name"`
+		ImageURL string `json:"image_url"`
+		Category struct {
+			Name string `json:"name"`
+		} `json:"category"`
+	} `json:"product"`
+}
+
+type LookupBody struct {
+	BarcodeNumber string `json:"barcode_number"`
+	Token         string `json:"token"`
+}
+
+// UpcAPI is the lookup API at UPC_URL, which is sent the barcode and a token
+type UpcAPI struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (u UpcAPI) Name() string {
+	return config.ProviderUpc
+}
+
+func (u UpcAPI) Lookup(code string) (ItemDetail, error) {
+	payload := LookupBody{
+		BarcodeNumber: code,
+		Token:         u.Token,
+	}
+
+	payloadStr, err := json.Marshal(payload)
+	if err != nil {
+		return ItemDetail{}, err
+	}
+
+	req, err := http.NewRequest("POST", u.URL, strings.NewReader(string(payloadStr)))
+	if err != nil {
+		return ItemDetail{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// The client asks for gzip and decompresses the answer on its own
+	apiReponse := LookupAPIResponse{}
+	if found, err := getJSON(u.Client, req, &apiReponse); !found || err != nil {
+		return ItemDetail{}, err
+	}
+
+	return ItemDetail{
+		ID:         code,
+		Name:       apiReponse.Product.Name,
+		Category:   apiReponse.Product.Category.Name,
+		PictureURL: apiReponse.Product.ImageURL,
+	}, nil
+}
@@ -0,0 +1,60 @@
+package upc
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/Timothylock/inventory-management/config"
+)
+
+const UpcItemDBURL = "https://api.upcitemdb.com/prod"
+
+// UpcItemDB looks barcodes up with UPCitemdb. Without a key its free trial API
+// is used, which allows 100 lookups a day.
+type UpcItemDB struct {
+	URL    string
+	Key    string
+	Client *http.Client
+}
+
+type upcItemDBResponse struct {
+	Items []struct {
+		Title    string   `json:"title"`
+		Category string   `json:"category"`
+		Images   []string `json:"images"`
+	} `json:"items"`
+}
+
+func (u UpcItemDB) Name() string {
+	return config.ProviderUpcItemDB
+}
+
+func (u UpcItemDB) Lookup(code string) (ItemDetail, error) {
+	path := "/trial/lookup"
+	if u.Key != "" {
+		path = "/v1/lookup"
+	}
+
+	req, err := http.NewRequest("GET", u.URL+path+"?upc="+url.QueryEscape(code), nil)
+	if err != nil {
+		return ItemDetail{}, err
+	}
+	if u.Key != "" {
+		req.Header.Set("user_key", u.Key)
+		req.Header.Set("key_type", "3scale")
+	}
+
+	var res upcItemDBResponse
+	found, err := getJSON(u.Client, req, &res)
+	if err != nil || !found || len(res.Items) == 0 {
+		return ItemDetail{}, err
+	}
+
+	item := res.Items[0]
+	d := ItemDetail{ID: code, Name: item.Title, Category: lastCategory(item.Category, ">")}
+	if len(item.Images) > 0 {
+		d.PictureURL = item.Images[0]
+	}
+
+	return d, nil
+}
@@ -6,6 +6,7 @@ package users
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -60,51 +61,188 @@ func (mr *MockPersisterMockRecorder) GetUserByToken(token interface{}) *gomock.C
 }
 
 // GetUserByUsername mocks base method
-func (m *MockPersister) GetUserByUsername(username string, curUserID int) (User, error) {
-	ret := m.ctrl.Call(m, "GetUserByUsername", username, curUserID)
+func (m *MockPersister) GetUserByUsername(username string, orgID int) (User, error) {
+	ret := m.ctrl.Call(m, "GetUserByUsername", username, orgID)
 	ret0, _ := ret[0].(User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUserByUsername indicates an expected call of GetUserByUsername
-func (mr *MockPersisterMockRecorder) GetUserByUsername(username, curUserID interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByUsername", reflect.TypeOf((*MockPersister)(nil).GetUserByUsername), username, curUserID)
+func (mr *MockPersisterMockRecorder) GetUserByUsername(username, orgID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByUsername", reflect.TypeOf((*MockPersister)(nil).GetUserByUsername), username, orgID)
 }
 
 // AddUser mocks base method
-func (m *MockPersister) AddUser(username, email, password string, isSysAdmin, overwrite bool) error {
-	ret := m.ctrl.Call(m, "AddUser", username, email, password, isSysAdmin, overwrite)
+func (m *MockPersister) AddUser(orgID int, username, email, password, role string, overwrite bool) error {
+	ret := m.ctrl.Call(m, "AddUser", orgID, username, email, password, role, overwrite)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddUser indicates an expected call of AddUser
-func (mr *MockPersisterMockRecorder) AddUser(username, email, password, isSysAdmin, overwrite interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockPersister)(nil).AddUser), username, email, password, isSysAdmin, overwrite)
+func (mr *MockPersisterMockRecorder) AddUser(orgID, username, email, password, role, overwrite interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockPersister)(nil).AddUser), orgID, username, email, password, role, overwrite)
+}
+
+// SetPassword mocks base method
+func (m *MockPersister) SetPassword(userID int, password string) error {
+	ret := m.ctrl.Call(m, "SetPassword", userID, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPassword indicates an expected call of SetPassword
+func (mr *MockPersisterMockRecorder) SetPassword(userID, password interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPassword", reflect.TypeOf((*MockPersister)(nil).SetPassword), userID, password)
 }
 
 // GetUsers mocks base method
-func (m *MockPersister) GetUsers() (MultipleUsers, error) {
-	ret := m.ctrl.Call(m, "GetUsers")
+func (m *MockPersister) GetUsers(orgID int) (MultipleUsers, error) {
+	ret := m.ctrl.Call(m, "GetUsers", orgID)
 	ret0, _ := ret[0].(MultipleUsers)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUsers indicates an expected call of GetUsers
-func (mr *MockPersisterMockRecorder) GetUsers() *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockPersister)(nil).GetUsers))
+func (mr *MockPersisterMockRecorder) GetUsers(orgID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockPersister)(nil).GetUsers), orgID)
 }
 
 // DeleteUser mocks base method
-func (m *MockPersister) DeleteUser(targetID, userID int) error {
-	ret := m.ctrl.Call(m, "DeleteUser", targetID, userID)
+func (m *MockPersister) DeleteUser(orgID, targetID, userID int) error {
+	ret := m.ctrl.Call(m, "DeleteUser", orgID, targetID, userID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteUser indicates an expected call of DeleteUser
-func (mr *MockPersisterMockRecorder) DeleteUser(targetID, userID interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockPersister)(nil).DeleteUser), targetID, userID)
+func (mr *MockPersisterMockRecorder) DeleteUser(orgID, targetID, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockPersister)(nil).DeleteUser), orgID, targetID, userID)
+}
+
+// AddSession mocks base method
+func (m *MockPersister) AddSession(userID, orgID int, userAgent, ip string, expiresAt time.Time) (Session, error) {
+	ret := m.ctrl.Call(m, "AddSession", userID, orgID, userAgent, ip, expiresAt)
+	ret0, _ := ret[0].(Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddSession indicates an expected call of AddSession
+func (mr *MockPersisterMockRecorder) AddSession(userID, orgID, userAgent, ip, expiresAt interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSession", reflect.TypeOf((*MockPersister)(nil).AddSession), userID, orgID, userAgent, ip, expiresAt)
+}
+
+// GetSessions mocks base method
+func (m *MockPersister) GetSessions(userID int) (Sessions, error) {
+	ret := m.ctrl.Call(m, "GetSessions", userID)
+	ret0, _ := ret[0].(Sessions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessions indicates an expected call of GetSessions
+func (mr *MockPersisterMockRecorder) GetSessions(userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessions", reflect.TypeOf((*MockPersister)(nil).GetSessions), userID)
+}
+
+// RevokeSession mocks base method
+func (m *MockPersister) RevokeSession(sessionID, userID int) error {
+	ret := m.ctrl.Call(m, "RevokeSession", sessionID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession
+func (mr *MockPersisterMockRecorder) RevokeSession(sessionID, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockPersister)(nil).RevokeSession), sessionID, userID)
+}
+
+// RevokeSessions mocks base method
+func (m *MockPersister) RevokeSessions(userID int) error {
+	ret := m.ctrl.Call(m, "RevokeSessions", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSessions indicates an expected call of RevokeSessions
+func (mr *MockPersisterMockRecorder) RevokeSessions(userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSessions", reflect.TypeOf((*MockPersister)(nil).RevokeSessions), userID)
+}
+
+// GetRoles mocks base method
+func (m *MockPersister) GetRoles(orgID int) (Roles, error) {
+	ret := m.ctrl.Call(m, "GetRoles", orgID)
+	ret0, _ := ret[0].(Roles)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoles indicates an expected call of GetRoles
+func (mr *MockPersisterMockRecorder) GetRoles(orgID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoles", reflect.TypeOf((*MockPersister)(nil).GetRoles), orgID)
+}
+
+// SetRole mocks base method
+func (m *MockPersister) SetRole(orgID int, role Role, userID int) error {
+	ret := m.ctrl.Call(m, "SetRole", orgID, role, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRole indicates an expected call of SetRole
+func (mr *MockPersisterMockRecorder) SetRole(orgID, role, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRole", reflect.TypeOf((*MockPersister)(nil).SetRole), orgID, role, userID)
+}
+
+// GetOrgs mocks base method
+func (m *MockPersister) GetOrgs(userID int) (Orgs, error) {
+	ret := m.ctrl.Call(m, "GetOrgs", userID)
+	ret0, _ := ret[0].(Orgs)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrgs indicates an expected call of GetOrgs
+func (mr *MockPersisterMockRecorder) GetOrgs(userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrgs", reflect.TypeOf((*MockPersister)(nil).GetOrgs), userID)
+}
+
+// AddOrg mocks base method
+func (m *MockPersister) AddOrg(name string, userID int) (Org, error) {
+	ret := m.ctrl.Call(m, "AddOrg", name, userID)
+	ret0, _ := ret[0].(Org)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddOrg indicates an expected call of AddOrg
+func (mr *MockPersisterMockRecorder) AddOrg(name, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrg", reflect.TypeOf((*MockPersister)(nil).AddOrg), name, userID)
+}
+
+// AddMember mocks base method
+func (m *MockPersister) AddMember(orgID int, username, role string, userID int) error {
+	ret := m.ctrl.Call(m, "AddMember", orgID, username, role, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddMember indicates an expected call of AddMember
+func (mr *MockPersisterMockRecorder) AddMember(orgID, username, role, userID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMember", reflect.TypeOf((*MockPersister)(nil).AddMember), orgID, username, role, userID)
+}
+
+// SwitchOrg mocks base method
+func (m *MockPersister) SwitchOrg(sessionID, userID, orgID int) error {
+	ret := m.ctrl.Call(m, "SwitchOrg", sessionID, userID, orgID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SwitchOrg indicates an expected call of SwitchOrg
+func (mr *MockPersisterMockRecorder) SwitchOrg(sessionID, userID, orgID interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SwitchOrg", reflect.TypeOf((*MockPersister)(nil).SwitchOrg), sessionID, userID, orgID)
 }
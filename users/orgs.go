@@ -0,0 +1,29 @@
+package users
+
+import (
+	"errors"
+)
+
+// DefaultOrgID is the organization that everything created before
+// organizations existed belongs to
+const DefaultOrgID = 1
+
+var OrgNotFoundErr = errors.New("organization not found")
+var UserNotFoundErr = errors.New("user not found")
+
+type Orgs []Org
+type Org struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Role is the role of the user the org was fetched for
+	Role    string `json:"role"`
+	Current bool   `json:"current"`
+}
+
+// DefaultRoles are given to every new organization
+var DefaultRoles = Roles{
+	{Name: RoleViewer, Permissions: []string{PermItemView}},
+	{Name: RoleBorrower, Permissions: []string{PermItemView, PermItemMove, PermUpcLookup}},
+	{Name: RoleStockKeeper, Permissions: []string{PermItemView, PermItemMove, PermItemAdd, PermItemEdit, PermItemDelete, PermUpcLookup}},
+	{Name: RoleAdmin, Permissions: AllPermissions},
+}
@@ -0,0 +1,94 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Permissions that routes can require
+const (
+	PermItemView   = "item.view"
+	PermItemMove   = "item.move"
+	PermItemAdd    = "item.add"
+	PermItemEdit   = "item.edit"
+	PermItemDelete = "item.delete"
+	PermItemPurge  = "item.purge"
+	PermUpcLookup  = "upc.lookup"
+	PermUpcManage  = "upc.manage"
+	PermUserView   = "user.view"
+	PermUserManage = "user.manage"
+	PermRoleManage = "role.manage"
+	PermOrgCreate  = "org.create"
+	PermLogView    = "log.view"
+)
+
+// AllPermissions is every permission that exists
+var AllPermissions = []string{
+	PermItemView,
+	PermItemMove,
+	PermItemAdd,
+	PermItemEdit,
+	PermItemDelete,
+	PermUpcLookup,
+	PermUserView,
+	PermUserManage,
+	PermRoleManage,
+	PermOrgCreate,
+	PermLogView,
+	PermItemPurge,
+	PermUpcManage,
+}
+
+// Built in roles. Their permissions can be changed but RoleAdmin always keeps
+// every permission so that nobody can lock themselves out.
+const (
+	RoleViewer      = "viewer"
+	RoleBorrower    = "borrower"
+	RoleStockKeeper = "stock-keeper"
+	RoleAdmin       = "admin"
+)
+
+var RoleNotFoundErr = errors.New("role not found")
+
+type Roles []Role
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// Validate checks that the role has a name and only known permissions
+func (r Role) Validate() error {
+	if r.Name == "" {
+		return errors.New("role name must not be blank")
+	}
+
+	for _, p := range r.Permissions {
+		if !contains(AllPermissions, p) {
+			return fmt.Errorf("unknown permission %s", p)
+		}
+	}
+
+	if r.Name == RoleAdmin {
+		for _, p := range AllPermissions {
+			if !contains(r.Permissions, p) {
+				return errors.New("the admin role must keep every permission")
+			}
+		}
+	}
+
+	return nil
+}
+
+// Can returns whether the user's role grants the permission
+func (u User) Can(permission string) bool {
+	return contains(u.Permissions, permission)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,14 +1,37 @@
 package users
 
+import (
+	"errors"
+	"time"
+
+	"github.com/Timothylock/inventory-management/config"
+)
+
 type Persister interface {
 	GetUser(username, password string) (User, error)
 	GetUserByToken(token string) (User, error)
-	GetUserByUsername(username string, curUserID int) (User, error)
-	AddUser(username, email, password string, isSysAdmin, overwrite bool) error
-	GetUsers() (MultipleUsers, error)
-	DeleteUser(targetID, userID int) error
+	GetUserByUsername(username string, orgID int) (User, error)
+	AddUser(orgID int, username, email, password, role string, overwrite bool) error
+	SetPassword(userID int, password string) error
+	GetUsers(orgID int) (MultipleUsers, error)
+	DeleteUser(orgID, targetID, userID int) error
+	AddSession(userID, orgID int, userAgent, ip string, expiresAt time.Time) (Session, error)
+	GetSessions(userID int) (Sessions, error)
+	RevokeSession(sessionID, userID int) error
+	RevokeSessions(userID int) error
+	GetRoles(orgID int) (Roles, error)
+	SetRole(orgID int, role Role, userID int) error
+	GetOrgs(userID int) (Orgs, error)
+	AddOrg(name string, userID int) (Org, error)
+	AddMember(orgID int, username, role string, userID int) error
+	SwitchOrg(sessionID, userID, orgID int) error
 }
 
+var SessionNotFoundErr = errors.New("session not found")
+
+// DefaultSessionLength is used when SESSION_LENGTH is not configured
+const DefaultSessionLength = 31 * 24 * time.Hour
+
 type MultipleUsers []User
 type User struct {
 	Valid      bool   `json:"-"`
@@ -16,16 +39,45 @@ type User struct {
 	Username   string `json:"username"`
 	Email      string `json:"email"`
 	IsSysAdmin bool   `json:"isSysAdmin"`
-	Token      string `json:"-"`
+	// OrgID is the organization the user is working in. Role and Permissions
+	// apply to this organization only.
+	OrgID int    `json:"orgId"`
+	Role  string `json:"role"`
+	// Permissions granted by the role
+	Permissions []string `json:"permissions"`
+	Token       string   `json:"-"`
+	// SessionID is the session the user authenticated with, if any
+	SessionID int `json:"-"`
+}
+
+type Sessions []Session
+type Session struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"-"`
+	OrgID      int       `json:"orgId"`
+	Token      string    `json:"-"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"`
 }
 
 type Service struct {
-	persister Persister
+	persister     Persister
+	sessionLength time.Duration
 }
 
-func NewService(p Persister) Service {
+func NewService(p Persister, c config.Config) Service {
+	sl := c.SessionLength
+	if sl <= 0 {
+		sl = DefaultSessionLength
+	}
+
 	return Service{
-		persister: p,
+		persister:     p,
+		sessionLength: sl,
 	}
 }
 
@@ -37,22 +89,108 @@ func (s *Service) CheckUserByToken(token string) (User, error) {
 	return s.persister.GetUserByToken(token)
 }
 
-func (s *Service) CheckUserByUsername(username string, curUserID int) (User, error) {
-	return s.persister.GetUserByUsername(username, curUserID)
+// CheckUserByUsername returns the user along with their role in the org. The
+// role is blank if the user is not a member.
+func (s *Service) CheckUserByUsername(username string, orgID int) (User, error) {
+	return s.persister.GetUserByUsername(username, orgID)
+}
+
+func (s *Service) AddUser(orgID int, username, email, password, role string) error {
+	return s.persister.AddUser(orgID, username, email, password, role, false)
+}
+
+// GetUsers returns the members of the org
+func (s *Service) GetUsers(orgID int) (MultipleUsers, error) {
+	return s.persister.GetUsers(orgID)
+}
+
+// DeleteUser removes the user from the org. Users that are no longer in any org
+// are deactivated.
+func (s *Service) DeleteUser(orgID, targetID int, curUserID int) error {
+	return s.persister.DeleteUser(orgID, targetID, curUserID)
+}
+
+func (s *Service) EditUser(orgID int, username, email, password, role string) error {
+	return s.persister.AddUser(orgID, username, email, password, role, true)
+}
+
+// ResetPassword replaces the user's password and logs them out everywhere
+func (s *Service) ResetPassword(u User, password string) error {
+	return s.persister.SetPassword(u.ID, password)
+}
+
+func (s *Service) GetRoles(orgID int) (Roles, error) {
+	return s.persister.GetRoles(orgID)
+}
+
+// SetRole creates the role or replaces the permissions of an existing one
+func (s *Service) SetRole(orgID int, role Role, userID int) error {
+	if err := role.Validate(); err != nil {
+		return err
+	}
+
+	return s.persister.SetRole(orgID, role, userID)
 }
 
-func (s *Service) AddUser(username, email, password string, isSysAdmin bool) error {
-	return s.persister.AddUser(username, email, password, isSysAdmin, false)
+// GetOrgs returns the orgs the user is a member of, flagging the one they are
+// currently working in
+func (s *Service) GetOrgs(u User) (Orgs, error) {
+	orgs, err := s.persister.GetOrgs(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range orgs {
+		orgs[i].Current = orgs[i].ID == u.OrgID
+	}
+
+	return orgs, nil
+}
+
+// AddOrg creates an org with the default roles and makes the user its admin
+func (s *Service) AddOrg(name string, u User) (Org, error) {
+	if name == "" {
+		return Org{}, errors.New("organization name must not be blank")
+	}
+
+	return s.persister.AddOrg(name, u.ID)
 }
 
-func (s *Service) GetUsers() (MultipleUsers, error) {
-	return s.persister.GetUsers()
+// AddMember adds an existing user to the org or changes their role in it
+func (s *Service) AddMember(orgID int, username, role string, curUserID int) error {
+	return s.persister.AddMember(orgID, username, role, curUserID)
+}
+
+// SwitchOrg changes the org that the user's current session works in
+func (s *Service) SwitchOrg(u User, orgID int) error {
+	return s.persister.SwitchOrg(u.SessionID, u.ID, orgID)
+}
+
+// StartSession creates a new session for the user which expires after the
+// configured session length. The session starts out in the user's org.
+func (s *Service) StartSession(u User, userAgent, ip string) (Session, error) {
+	return s.persister.AddSession(u.ID, u.OrgID, userAgent, ip, time.Now().UTC().Add(s.sessionLength))
+}
+
+// GetSessions returns the active sessions of the user, flagging the one they are
+// currently using
+func (s *Service) GetSessions(u User) (Sessions, error) {
+	ss, err := s.persister.GetSessions(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ss {
+		ss[i].Current = ss[i].ID == u.SessionID
+	}
+
+	return ss, nil
 }
 
-func (s *Service) DeleteUser(targetID int, curUserID int) error {
-	return s.persister.DeleteUser(targetID, curUserID)
+func (s *Service) RevokeSession(u User, sessionID int) error {
+	return s.persister.RevokeSession(sessionID, u.ID)
 }
 
-func (s *Service) EditUser(username, email, password string, isSysAdmin bool) error {
-	return s.persister.AddUser(username, email, password, isSysAdmin, true)
+func (s *Service) RevokeSessions(u User) error {
+	return s.persister.RevokeSessions(u.ID)
 }